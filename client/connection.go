@@ -297,6 +297,38 @@ func ConnectSimpleStreams(url string, args *ConnectionArgs) (ImageServer, error)
 	return &server, nil
 }
 
+// ConnectOCI lets you connect to a remote OCI registry.
+//
+// The registry is only used as an image source, images get pulled and converted by the LXD server.
+func ConnectOCI(url string, args *ConnectionArgs) (ImageServer, error) {
+	logger.Debug("Connecting to a remote OCI registry", logger.Ctx{"URL": url})
+
+	// Cleanup URL
+	url = strings.TrimSuffix(url, "/")
+
+	// Use empty args if not specified
+	if args == nil {
+		args = &ConnectionArgs{}
+	}
+
+	// Initialize the client struct
+	server := ProtocolOCI{
+		httpHost:        url,
+		httpUserAgent:   args.UserAgent,
+		httpCertificate: args.TLSServerCert,
+	}
+
+	// Setup the HTTP client
+	httpClient, err := tlsHTTPClient(args.HTTPClient, args.TLSClientCert, args.TLSClientKey, args.TLSCA, args.TLSServerCert, args.InsecureSkipVerify, args.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	server.http = httpClient
+
+	return &server, nil
+}
+
 // Internal function called by ConnectLXD and ConnectPublicLXD.
 func httpsLXD(ctx context.Context, requestURL string, args *ConnectionArgs) (InstanceServer, error) {
 	// Use empty args if not specified
@@ -170,6 +170,9 @@ type InstanceServer interface {
 	RenameInstance(name string, instance api.InstancePost) (op Operation, err error)
 	MigrateInstance(name string, instance api.InstancePost) (op Operation, err error)
 	DeleteInstance(name string) (op Operation, err error)
+	RebuildInstance(name string, req api.InstanceRebuildPost) (op Operation, err error)
+	RebuildInstanceFromImage(source ImageServer, image api.Image, name string, req api.InstanceRebuildPost) (op RemoteOperation, err error)
+	GetInstanceDiff(name string) (entries []api.InstanceDiffEntry, err error)
 	UpdateInstances(state api.InstancesPut, ETag string) (op Operation, err error)
 
 	ExecInstance(instanceName string, exec api.InstanceExecPost, args *InstanceExecArgs) (op Operation, err error)
@@ -178,10 +181,14 @@ type InstanceServer interface {
 
 	GetInstanceConsoleLog(instanceName string, args *InstanceConsoleLogArgs) (content io.ReadCloser, err error)
 	DeleteInstanceConsoleLog(instanceName string, args *InstanceConsoleLogArgs) (err error)
+	GetInstanceConsoleScreenshot(instanceName string, format string) (content io.ReadCloser, err error)
 
 	GetInstanceFile(instanceName string, path string) (content io.ReadCloser, resp *InstanceFileResponse, err error)
 	CreateInstanceFile(instanceName string, path string, args InstanceFileArgs) (err error)
 	DeleteInstanceFile(instanceName string, path string) (err error)
+	GetInstanceFileWatch(instanceName string, path string, recursive bool) (conn *websocket.Conn, err error)
+	GetInstanceFileArchive(instanceName string, path string) (content io.ReadCloser, err error)
+	CreateInstanceFileArchive(instanceName string, path string, content io.Reader) (err error)
 
 	GetInstanceFileSFTPConn(instanceName string) (net.Conn, error)
 	GetInstanceFileSFTP(instanceName string) (*sftp.Client, error)
@@ -205,9 +212,17 @@ type InstanceServer interface {
 	GetInstanceBackupFile(instanceName string, name string, req *BackupFileRequest) (resp *BackupFileResponse, err error)
 	CreateInstanceFromBackup(args InstanceBackupArgs) (op Operation, err error)
 
+	GetInstanceRecordingNames(instanceName string) (names []string, err error)
+	GetInstanceRecordings(instanceName string) (recordings []api.InstanceRecording, err error)
+	GetInstanceRecording(instanceName string, name string) (recording *api.InstanceRecording, ETag string, err error)
+	GetInstanceRecordingFile(instanceName string, name string) (content io.ReadCloser, err error)
+
 	GetInstanceState(name string) (state *api.InstanceState, ETag string, err error)
+	GetInstanceStateHistory(name string) (stateHistory *api.InstanceStateHistory, err error)
 	UpdateInstanceState(name string, state api.InstanceStatePut, ETag string) (op Operation, err error)
 
+	GetInstanceFirewall(name string) (rulesets map[string]api.NetworkFirewallRuleset, err error)
+
 	GetInstanceLogfiles(name string) (logfiles []string, err error)
 	GetInstanceLogfile(name string, filename string) (content io.ReadCloser, err error)
 	DeleteInstanceLogfile(name string, filename string) (err error)
@@ -215,6 +230,9 @@ type InstanceServer interface {
 	GetInstanceMetadata(name string) (metadata *api.ImageMetadata, ETag string, err error)
 	UpdateInstanceMetadata(name string, metadata api.ImageMetadata, ETag string) (err error)
 
+	GetInstanceUEFIVars(name string) (vars *api.InstanceUEFIVars, ETag string, err error)
+	UpdateInstanceUEFIVars(name string, vars api.InstanceUEFIVars, ETag string) (err error)
+
 	GetInstanceTemplateFiles(instanceName string) (templates []string, err error)
 	GetInstanceTemplateFile(instanceName string, templateName string) (content io.ReadCloser, err error)
 	CreateInstanceTemplateFile(instanceName string, templateName string, content io.ReadSeeker) (err error)
@@ -243,6 +261,7 @@ type InstanceServer interface {
 	GetNetwork(name string) (network *api.Network, ETag string, err error)
 	GetNetworkLeases(name string) (leases []api.NetworkLease, err error)
 	GetNetworkState(name string) (state *api.NetworkState, err error)
+	GetNetworkFirewall(name string) (ruleset *api.NetworkFirewallRuleset, err error)
 	CreateNetwork(network api.NetworksPost) (err error)
 	UpdateNetwork(name string, network api.NetworkPut, ETag string) (err error)
 	RenameNetwork(name string, network api.NetworkPost) (err error)
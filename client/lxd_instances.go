@@ -622,6 +622,166 @@ func (r *ProtocolLXD) CreateInstanceFromImage(source ImageServer, image api.Imag
 	return r.tryCreateInstance(req, info.Addresses, nil)
 }
 
+// RebuildInstance rebuilds a stopped instance from the image specified in the request.
+func (r *ProtocolLXD) RebuildInstance(name string, req api.InstanceRebuildPost) (Operation, error) {
+	if !r.HasExtension("instance_rebuild") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_rebuild\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("%s/%s/rebuild", path, url.PathEscape(name)), req, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+func (r *ProtocolLXD) tryRebuildInstance(name string, req api.InstanceRebuildPost, urls []string) (RemoteOperation, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("The source server isn't listening on the network")
+	}
+
+	rop := remoteOperation{
+		chDone: make(chan bool),
+	}
+
+	// Forward targetOp to remote op
+	go func() {
+		success := false
+		var errors []remoteOperationResult
+		for _, serverURL := range urls {
+			req.Source.Server = serverURL
+
+			op, err := r.RebuildInstance(name, req)
+			if err != nil {
+				errors = append(errors, remoteOperationResult{URL: serverURL, Error: err})
+				continue
+			}
+
+			rop.handlerLock.Lock()
+			rop.targetOp = op
+			rop.handlerLock.Unlock()
+
+			for _, handler := range rop.handlers {
+				_, _ = rop.targetOp.AddHandler(handler)
+			}
+
+			err = rop.targetOp.Wait()
+			if err != nil {
+				errors = append(errors, remoteOperationResult{URL: serverURL, Error: err})
+
+				if shared.IsConnectionError(err) {
+					continue
+				}
+
+				break
+			}
+
+			success = true
+			break
+		}
+
+		if !success {
+			rop.err = remoteOperationError("Failed instance rebuild", errors)
+		}
+
+		close(rop.chDone)
+	}()
+
+	return &rop, nil
+}
+
+// RebuildInstanceFromImage is a convenience function to make it easier to rebuild an instance from an existing image.
+func (r *ProtocolLXD) RebuildInstanceFromImage(source ImageServer, image api.Image, name string, req api.InstanceRebuildPost) (RemoteOperation, error) {
+	// Set the minimal source fields
+	req.Source.Type = "image"
+
+	// Optimization for the local image case
+	if r.isSameServer(source) {
+		// Always use fingerprints for local case
+		req.Source.Fingerprint = image.Fingerprint
+		req.Source.Alias = ""
+
+		op, err := r.RebuildInstance(name, req)
+		if err != nil {
+			return nil, err
+		}
+
+		rop := remoteOperation{
+			targetOp: op,
+			chDone:   make(chan bool),
+		}
+
+		// Forward targetOp to remote op
+		go func() {
+			rop.err = rop.targetOp.Wait()
+			close(rop.chDone)
+		}()
+
+		return &rop, nil
+	}
+
+	// Minimal source fields for remote image
+	req.Source.Mode = "pull"
+
+	// If we have an alias and the image is public, use that
+	if req.Source.Alias != "" && image.Public {
+		req.Source.Fingerprint = ""
+	} else {
+		req.Source.Fingerprint = image.Fingerprint
+		req.Source.Alias = ""
+	}
+
+	// Get source server connection information
+	info, err := source.GetConnectionInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Source.Protocol = info.Protocol
+	req.Source.Certificate = info.Certificate
+
+	// Generate secret token if needed
+	if !image.Public {
+		secret, err := source.GetImageSecret(image.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Source.Secret = secret
+	}
+
+	return r.tryRebuildInstance(name, req, info.Addresses)
+}
+
+// GetInstanceDiff returns the paths of the instance's root filesystem which differ from the image it was created from.
+func (r *ProtocolLXD) GetInstanceDiff(name string) ([]api.InstanceDiffEntry, error) {
+	if !r.HasExtension("instance_diff") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_diff\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []api.InstanceDiffEntry{}
+
+	// Fetch the raw value.
+	_, err = r.queryStruct("GET", fmt.Sprintf("%s/%s/diff", path, url.PathEscape(name)), nil, "", &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
 // CopyInstance copies a instance from a remote server. Additional options can be passed using InstanceCopyArgs.
 func (r *ProtocolLXD) CopyInstance(source InstanceServer, instance api.Instance, args *InstanceCopyArgs) (RemoteOperation, error) {
 	// Base request
@@ -1353,6 +1513,121 @@ func (r *ProtocolLXD) DeleteInstanceFile(instanceName string, filePath string) e
 	return nil
 }
 
+// GetInstanceFileWatch returns a websocket streaming the changes to the provided path in the instance.
+// Changes are sent as api.InstanceFileWatchEvent JSON messages.
+func (r *ProtocolLXD) GetInstanceFileWatch(instanceName string, filePath string, recursive bool) (*websocket.Conn, error) {
+	if !r.IsAgent() && !r.HasExtension("instance_file_watch") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_file_watch\" API extension")
+	}
+
+	values := url.Values{}
+	values.Set("path", filePath)
+	if recursive {
+		values.Set("recursive", "true")
+	}
+
+	var uri string
+	if r.IsAgent() {
+		uri = fmt.Sprintf("/files/watch?%s", values.Encode())
+	} else {
+		path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+		if err != nil {
+			return nil, err
+		}
+
+		uri = fmt.Sprintf("%s/%s/files/watch?%s", path, url.PathEscape(instanceName), values.Encode())
+	}
+
+	uri, err := r.setQueryAttributes(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.websocket(uri)
+}
+
+// GetInstanceFileArchive retrieves the provided directory from the instance as a tar archive.
+func (r *ProtocolLXD) GetInstanceFileArchive(instanceName string, filePath string) (io.ReadCloser, error) {
+	if !r.HasExtension("instance_file_archive") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_file_archive\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the HTTP request
+	requestURL := fmt.Sprintf("%s/1.0%s/%s/files/archive?path=%s", r.httpBaseURL.String(), path, url.PathEscape(instanceName), url.QueryEscape(filePath))
+
+	requestURL, err = r.setQueryAttributes(requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	resp, err := r.DoHTTP(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp.Body, nil
+}
+
+// CreateInstanceFileArchive extracts the provided tar archive into a directory of the instance.
+func (r *ProtocolLXD) CreateInstanceFileArchive(instanceName string, filePath string, content io.Reader) error {
+	if !r.HasExtension("instance_file_archive") {
+		return fmt.Errorf("The server is missing the required \"instance_file_archive\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	// Prepare the HTTP request
+	requestURL := fmt.Sprintf("%s/1.0%s/%s/files/archive?path=%s", r.httpBaseURL.String(), path, url.PathEscape(instanceName), url.QueryEscape(filePath))
+
+	requestURL, err = r.setQueryAttributes(requestURL)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", requestURL, content)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	// Send the request
+	resp, err := r.DoHTTP(req)
+	if err != nil {
+		return err
+	}
+
+	// Check the return value for a cleaner error
+	_, _, err = lxdParseResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // rawSFTPConn connects to the apiURL, upgrades to an SFTP raw connection and returns it.
 func (r *ProtocolLXD) rawSFTPConn(apiURL *url.URL) (net.Conn, error) {
 	// Get the HTTP transport.
@@ -1913,6 +2188,28 @@ func (r *ProtocolLXD) GetInstanceState(name string) (*api.InstanceState, string,
 	return &state, etag, nil
 }
 
+// GetInstanceStateHistory returns the resource usage history of the instance.
+func (r *ProtocolLXD) GetInstanceStateHistory(name string) (*api.InstanceStateHistory, error) {
+	if !r.HasExtension("instance_state_history") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_state_history\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	stateHistory := api.InstanceStateHistory{}
+
+	// Fetch the raw value
+	_, err = r.queryStruct("GET", fmt.Sprintf("%s/%s/state/history", path, url.PathEscape(name)), nil, "", &stateHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stateHistory, nil
+}
+
 // UpdateInstanceState updates the instance to match the requested state.
 func (r *ProtocolLXD) UpdateInstanceState(name string, state api.InstanceStatePut, ETag string) (Operation, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -1929,6 +2226,23 @@ func (r *ProtocolLXD) UpdateInstanceState(name string, state api.InstanceStatePu
 	return op, nil
 }
 
+// GetInstanceFirewall returns the firewall rules generated for the instance's NIC devices, keyed on device name.
+func (r *ProtocolLXD) GetInstanceFirewall(name string) (map[string]api.NetworkFirewallRuleset, error) {
+	if !r.HasExtension("network_firewall_ruleset") {
+		return nil, fmt.Errorf("The server is missing the required \"network_firewall_ruleset\" API extension")
+	}
+
+	rulesets := map[string]api.NetworkFirewallRuleset{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/instances/%s/firewall", url.PathEscape(name)), nil, "", &rulesets)
+	if err != nil {
+		return nil, err
+	}
+
+	return rulesets, nil
+}
+
 // GetInstanceLogfiles returns a list of logfiles for the instance.
 func (r *ProtocolLXD) GetInstanceLogfiles(name string) ([]string, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -2045,6 +2359,46 @@ func (r *ProtocolLXD) UpdateInstanceMetadata(name string, metadata api.ImageMeta
 	return nil
 }
 
+// GetInstanceUEFIVars returns the UEFI variables stored in the NVRAM of a virtual machine.
+func (r *ProtocolLXD) GetInstanceUEFIVars(name string) (*api.InstanceUEFIVars, string, error) {
+	if !r.HasExtension("instance_uefi_vars") {
+		return nil, "", fmt.Errorf("The server is missing the required \"instance_uefi_vars\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, "", err
+	}
+
+	vars := api.InstanceUEFIVars{}
+
+	etag, err := r.queryStruct("GET", fmt.Sprintf("%s/%s/uefi-vars", path, url.PathEscape(name)), nil, "", &vars)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &vars, etag, nil
+}
+
+// UpdateInstanceUEFIVars replaces the UEFI variables stored in the NVRAM of a stopped virtual machine.
+func (r *ProtocolLXD) UpdateInstanceUEFIVars(name string, vars api.InstanceUEFIVars, ETag string) error {
+	if !r.HasExtension("instance_uefi_vars") {
+		return fmt.Errorf("The server is missing the required \"instance_uefi_vars\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = r.query("PUT", fmt.Sprintf("%s/%s/uefi-vars", path, url.PathEscape(name)), vars, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // GetInstanceTemplateFiles returns the list of names of template files for a instance.
 func (r *ProtocolLXD) GetInstanceTemplateFiles(instanceName string) ([]string, error) {
 	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
@@ -2395,6 +2749,47 @@ func (r *ProtocolLXD) DeleteInstanceConsoleLog(instanceName string, args *Instan
 	return nil
 }
 
+// GetInstanceConsoleScreenshot returns a screenshot of the VGA console of a virtual machine in the given format (png or ppm).
+func (r *ProtocolLXD) GetInstanceConsoleScreenshot(instanceName string, format string) (io.ReadCloser, error) {
+	if !r.HasExtension("instance_console_screenshot") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_console_screenshot\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the HTTP request
+	url := fmt.Sprintf("%s/1.0%s/%s/console?type=vga&format=%s", r.httpBaseURL.String(), path, url.PathEscape(instanceName), url.QueryEscape(format))
+
+	url, err = r.setQueryAttributes(url)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	resp, err := r.DoHTTP(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp.Body, nil
+}
+
 // GetInstanceBackupNames returns a list of backup names for the instance.
 func (r *ProtocolLXD) GetInstanceBackupNames(instanceName string) ([]string, error) {
 	if !r.HasExtension("container_backup") {
@@ -2673,3 +3068,112 @@ func (r *ProtocolLXD) proxyMigration(targetOp *operation, targetSecrets map[stri
 
 	return nil
 }
+
+// GetInstanceRecordingNames returns a list of recorded session names for the instance.
+func (r *ProtocolLXD) GetInstanceRecordingNames(instanceName string) ([]string, error) {
+	if !r.HasExtension("instance_session_recording") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_session_recording\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the raw URL values.
+	urls := []string{}
+	baseURL := fmt.Sprintf("%s/%s/recordings", path, url.PathEscape(instanceName))
+	_, err = r.queryStruct("GET", baseURL, nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it.
+	return urlsToResourceNames(baseURL, urls...)
+}
+
+// GetInstanceRecordings returns a list of recorded sessions for the instance.
+func (r *ProtocolLXD) GetInstanceRecordings(instanceName string) ([]api.InstanceRecording, error) {
+	if !r.HasExtension("instance_session_recording") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_session_recording\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the raw value
+	recordings := []api.InstanceRecording{}
+
+	_, err = r.queryStruct("GET", fmt.Sprintf("%s/%s/recordings?recursion=1", path, url.PathEscape(instanceName)), nil, "", &recordings)
+	if err != nil {
+		return nil, err
+	}
+
+	return recordings, nil
+}
+
+// GetInstanceRecording returns a recorded session for the instance.
+func (r *ProtocolLXD) GetInstanceRecording(instanceName string, name string) (*api.InstanceRecording, string, error) {
+	if !r.HasExtension("instance_session_recording") {
+		return nil, "", fmt.Errorf("The server is missing the required \"instance_session_recording\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Fetch the raw value
+	recording := api.InstanceRecording{}
+	etag, err := r.queryStruct("GET", fmt.Sprintf("%s/%s/recordings/%s", path, url.PathEscape(instanceName), url.PathEscape(name)), nil, "", &recording)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &recording, etag, nil
+}
+
+// GetInstanceRecordingFile returns the content of a recorded session in the asciicast v2 format.
+//
+// Note that it's the caller's responsibility to close the returned ReadCloser.
+func (r *ProtocolLXD) GetInstanceRecordingFile(instanceName string, name string) (io.ReadCloser, error) {
+	if !r.HasExtension("instance_session_recording") {
+		return nil, fmt.Errorf("The server is missing the required \"instance_session_recording\" API extension")
+	}
+
+	path, _, err := r.instanceTypeToPath(api.InstanceTypeAny)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the HTTP request
+	url := fmt.Sprintf("%s/1.0%s/%s/recordings/%s/export", r.httpBaseURL.String(), path, url.PathEscape(instanceName), url.PathEscape(name))
+
+	url, err = r.setQueryAttributes(url)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request
+	resp, err := r.DoHTTP(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the return value for a cleaner error
+	if resp.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp.Body, nil
+}
@@ -93,6 +93,23 @@ func (r *ProtocolLXD) GetNetworkState(name string) (*api.NetworkState, error) {
 	return &state, nil
 }
 
+// GetNetworkFirewall returns the firewall rules generated for the network and the rules currently loaded.
+func (r *ProtocolLXD) GetNetworkFirewall(name string) (*api.NetworkFirewallRuleset, error) {
+	if !r.HasExtension("network_firewall_ruleset") {
+		return nil, fmt.Errorf("The server is missing the required \"network_firewall_ruleset\" API extension")
+	}
+
+	ruleset := api.NetworkFirewallRuleset{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/networks/%s/firewall", url.PathEscape(name)), nil, "", &ruleset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ruleset, nil
+}
+
 // CreateNetwork defines a new network using the provided Network struct.
 func (r *ProtocolLXD) CreateNetwork(network api.NetworksPost) error {
 	if !r.HasExtension("network") {
@@ -0,0 +1,101 @@
+package lxd
+
+import (
+	"fmt"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Image handling functions
+
+// GetImages isn't supported for OCI registries.
+func (r *ProtocolOCI) GetImages() ([]api.Image, error) {
+	return nil, fmt.Errorf("GetImages is not supported by the OCI protocol")
+}
+
+// GetImageFingerprints isn't supported for OCI registries.
+func (r *ProtocolOCI) GetImageFingerprints() ([]string, error) {
+	return nil, fmt.Errorf("GetImageFingerprints is not supported by the OCI protocol")
+}
+
+// GetImagesWithFilter isn't supported for OCI registries.
+func (r *ProtocolOCI) GetImagesWithFilter(filters []string) ([]api.Image, error) {
+	return nil, fmt.Errorf("GetImagesWithFilter is not supported by the OCI protocol")
+}
+
+// GetImage returns an Image struct for the provided image reference (e.g. "alpine:latest").
+// The image is resolved by the LXD server when pulled, so only a minimal public container image is returned.
+func (r *ProtocolOCI) GetImage(fingerprint string) (*api.Image, string, error) {
+	if fingerprint == "" {
+		return nil, "", fmt.Errorf("Missing OCI image reference")
+	}
+
+	image := api.Image{
+		Fingerprint: fingerprint,
+		Type:        "container",
+	}
+
+	image.Public = true
+
+	return &image, "", nil
+}
+
+// GetImageFile isn't supported for OCI registries, images are pulled by the LXD server.
+func (r *ProtocolOCI) GetImageFile(fingerprint string, req ImageFileRequest) (*ImageFileResponse, error) {
+	return nil, fmt.Errorf("GetImageFile is not supported by the OCI protocol")
+}
+
+// GetImageSecret isn't relevant for OCI registries.
+func (r *ProtocolOCI) GetImageSecret(fingerprint string) (string, error) {
+	return "", fmt.Errorf("Private images aren't supported by the OCI protocol")
+}
+
+// GetPrivateImage isn't relevant for OCI registries.
+func (r *ProtocolOCI) GetPrivateImage(fingerprint string, secret string) (*api.Image, string, error) {
+	return nil, "", fmt.Errorf("Private images aren't supported by the OCI protocol")
+}
+
+// GetPrivateImageFile isn't relevant for OCI registries.
+func (r *ProtocolOCI) GetPrivateImageFile(fingerprint string, secret string, req ImageFileRequest) (*ImageFileResponse, error) {
+	return nil, fmt.Errorf("Private images aren't supported by the OCI protocol")
+}
+
+// GetImageAliases isn't supported for OCI registries.
+func (r *ProtocolOCI) GetImageAliases() ([]api.ImageAliasesEntry, error) {
+	return nil, fmt.Errorf("GetImageAliases is not supported by the OCI protocol")
+}
+
+// GetImageAliasNames isn't supported for OCI registries.
+func (r *ProtocolOCI) GetImageAliasNames() ([]string, error) {
+	return nil, fmt.Errorf("GetImageAliasNames is not supported by the OCI protocol")
+}
+
+// GetImageAlias returns an existing alias as an ImageAliasesEntry struct.
+// Image references act as their own alias.
+func (r *ProtocolOCI) GetImageAlias(name string) (*api.ImageAliasesEntry, string, error) {
+	return r.GetImageAliasType("container", name)
+}
+
+// GetImageAliasType returns an existing alias as an ImageAliasesEntry struct.
+func (r *ProtocolOCI) GetImageAliasType(imageType string, name string) (*api.ImageAliasesEntry, string, error) {
+	if imageType != "" && imageType != "container" {
+		return nil, "", fmt.Errorf("OCI images can only be used for containers")
+	}
+
+	alias := api.ImageAliasesEntry{}
+	alias.Name = name
+	alias.Target = name
+	alias.Type = "container"
+
+	return &alias, "", nil
+}
+
+// GetImageAliasArchitectures isn't supported for OCI registries, images are resolved for the server's architecture.
+func (r *ProtocolOCI) GetImageAliasArchitectures(imageType string, name string) (map[string]*api.ImageAliasesEntry, error) {
+	return nil, fmt.Errorf("GetImageAliasArchitectures is not supported by the OCI protocol")
+}
+
+// ExportImage isn't supported for OCI registries.
+func (r *ProtocolOCI) ExportImage(fingerprint string, image api.ImageExportPost) (Operation, error) {
+	return nil, fmt.Errorf("ExportImage is not supported by the OCI protocol")
+}
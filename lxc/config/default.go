@@ -30,6 +30,13 @@ var UbuntuDailyRemote = Remote{
 	Protocol: "simplestreams",
 }
 
+// OCIRemote is the Docker Hub registry (over the OCI distribution protocol).
+var OCIRemote = Remote{
+	Addr:     "https://docker.io",
+	Public:   true,
+	Protocol: "oci",
+}
+
 // StaticRemotes is the list of remotes which can't be removed.
 var StaticRemotes = map[string]Remote{
 	"local":        LocalRemote,
@@ -41,6 +48,7 @@ var StaticRemotes = map[string]Remote{
 var DefaultRemotes = map[string]Remote{
 	"images":       ImagesRemote,
 	"local":        LocalRemote,
+	"oci":          OCIRemote,
 	"ubuntu":       UbuntuRemote,
 	"ubuntu-daily": UbuntuDailyRemote,
 }
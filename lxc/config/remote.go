@@ -161,6 +161,16 @@ func (c *Config) GetImageServer(name string) (lxd.ImageServer, error) {
 		return d, nil
 	}
 
+	// OCI registry
+	if remote.Protocol == "oci" {
+		d, err := lxd.ConnectOCI(remote.Addr, args)
+		if err != nil {
+			return nil, err
+		}
+
+		return d, nil
+	}
+
 	// HTTPs (public LXD)
 	if remote.Public {
 		d, err := lxd.ConnectPublicLXD(remote.Addr, args)
@@ -262,7 +272,7 @@ func (c *Config) getConnectionArgs(name string) (*lxd.ConnectionArgs, error) {
 	}
 
 	// Stop here if no client certificate involved
-	if remote.Protocol == "simplestreams" || remote.AuthType == "candid" {
+	if shared.StringInSlice(remote.Protocol, []string{"simplestreams", "oci"}) || remote.AuthType == "candid" {
 		return &args, nil
 	}
 
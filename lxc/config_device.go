@@ -54,6 +54,12 @@ func (c *cmdConfigDevice) Command() *cobra.Command {
 	configDeviceShowCmd := cmdConfigDeviceShow{global: c.global, config: c.config, profile: c.profile, configDevice: c}
 	cmd.AddCommand(configDeviceShowCmd.Command())
 
+	// Show firewall
+	if c.config != nil {
+		configDeviceShowFirewallCmd := cmdConfigDeviceShowFirewall{global: c.global, config: c.config, configDevice: c}
+		cmd.AddCommand(configDeviceShowFirewallCmd.Command())
+	}
+
 	// Unset
 	configDeviceUnsetCmd := cmdConfigDeviceUnset{global: c.global, config: c.config, profile: c.profile, configDevice: c, configDeviceSet: &configDeviceSetCmd}
 	cmd.AddCommand(configDeviceUnsetCmd.Command())
@@ -716,6 +722,68 @@ func (c *cmdConfigDeviceShow) Run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// Show firewall.
+type cmdConfigDeviceShowFirewall struct {
+	global       *cmdGlobal
+	config       *cmdConfig
+	configDevice *cmdConfigDevice
+}
+
+func (c *cmdConfigDeviceShowFirewall) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show-firewall", i18n.G("[<remote>:]<instance> <device>"))
+	cmd.Short = i18n.G("Show instance device firewall rules")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Show instance device firewall rules
+
+The output includes the rules LXD generates for the NIC device from its current config,
+the rules currently loaded in the kernel and the differences between them.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigDeviceShowFirewall) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing name"))
+	}
+
+	// Get the firewall rules
+	rulesets, err := resource.server.GetInstanceFirewall(resource.name)
+	if err != nil {
+		return err
+	}
+
+	ruleset, ok := rulesets[args[1]]
+	if !ok {
+		return fmt.Errorf(i18n.G("Device %s has no firewall rules (only NIC devices do)"), args[1])
+	}
+
+	data, err := yaml.Marshal(&ruleset)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(data))
+
+	return nil
+}
+
 // Unset.
 type cmdConfigDeviceUnset struct {
 	global          *cmdGlobal
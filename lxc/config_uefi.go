@@ -0,0 +1,804 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pborman/uuid"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxc/utils"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+	"github.com/lxc/lxd/shared/termios"
+	"github.com/lxc/lxd/shared/uefi"
+)
+
+type cmdConfigUEFI struct {
+	global *cmdGlobal
+	config *cmdConfig
+}
+
+func (c *cmdConfigUEFI) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("uefi")
+	cmd.Short = i18n.G("Manage virtual machine UEFI variables")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Manage virtual machine UEFI variables
+
+Variables are identified by their name and vendor GUID, as in "BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c".
+The GUID may be omitted for the EFI global variables (such as BootOrder, Boot0001, PK and KEK).
+
+UEFI variables can only be modified while the virtual machine is stopped.`))
+
+	// Boot order
+	configUEFIBootOrderCmd := cmdConfigUEFIBootOrder{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIBootOrderCmd.Command())
+
+	// Edit
+	configUEFIEditCmd := cmdConfigUEFIEdit{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIEditCmd.Command())
+
+	// Enroll
+	configUEFIEnrollCmd := cmdConfigUEFIEnroll{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIEnrollCmd.Command())
+
+	// Get
+	configUEFIGetCmd := cmdConfigUEFIGet{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIGetCmd.Command())
+
+	// List
+	configUEFIListCmd := cmdConfigUEFIList{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIListCmd.Command())
+
+	// Set
+	configUEFISetCmd := cmdConfigUEFISet{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFISetCmd.Command())
+
+	// Show
+	configUEFIShowCmd := cmdConfigUEFIShow{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIShowCmd.Command())
+
+	// Unset
+	configUEFIUnsetCmd := cmdConfigUEFIUnset{global: c.global, config: c.config, configUEFI: c}
+	cmd.AddCommand(configUEFIUnsetCmd.Command())
+
+	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
+	cmd.Args = cobra.NoArgs
+	cmd.Run = func(cmd *cobra.Command, args []string) { _ = cmd.Usage() }
+	return cmd
+}
+
+// parseInstance parses the instance argument and returns the matching server resource.
+func (c *cmdConfigUEFI) parseInstance(arg string) (*remoteResource, error) {
+	resources, err := c.global.ParseServers(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := resources[0]
+
+	if resource.name == "" {
+		return nil, fmt.Errorf(i18n.G("Missing instance name"))
+	}
+
+	return &resource, nil
+}
+
+// variableKey returns the <name>-<GUID> key of the variable, defaulting to the EFI global variable GUID.
+func (c *cmdConfigUEFI) variableKey(key string) (string, error) {
+	_, _, err := uefi.ParseVariableKey(key)
+	if err == nil {
+		return key, nil
+	}
+
+	if strings.Contains(key, "-") {
+		return "", err
+	}
+
+	return uefi.VariableKey(key, uefi.GlobalVariableGUID), nil
+}
+
+// describe returns a human readable description of the variable's content.
+func (c *cmdConfigUEFI) describe(name string, guid uefi.GUID, data []byte) string {
+	switch {
+	case guid == uefi.GlobalVariableGUID && name == "BootOrder":
+		order, err := uefi.ParseBootOrder(data)
+		if err != nil {
+			return ""
+		}
+
+		entries := make([]string, 0, len(order))
+		for _, number := range order {
+			entries = append(entries, uefi.BootEntryName(number))
+		}
+
+		return strings.Join(entries, ", ")
+	case guid == uefi.GlobalVariableGUID && len(name) == 8 && strings.HasPrefix(name, "Boot"):
+		_, err := strconv.ParseUint(name[4:], 16, 16)
+		if err != nil {
+			return ""
+		}
+
+		option, err := uefi.ParseLoadOption(data)
+		if err != nil {
+			return ""
+		}
+
+		if option.Attributes&uefi.LoadOptionActive == 0 {
+			return fmt.Sprintf(i18n.G("%s (inactive)"), option.Description)
+		}
+
+		return option.Description
+	case (guid == uefi.GlobalVariableGUID && shared.StringInSlice(name, []string{"PK", "KEK"})) || (guid == uefi.ImageSecurityDatabaseGUID && shared.StringInSlice(name, []string{"db", "dbx"})):
+		certs, err := uefi.SignatureListCertificates(data)
+		if err != nil {
+			return ""
+		}
+
+		subjects := make([]string, 0, len(certs))
+		for _, der := range certs {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				continue
+			}
+
+			subjects = append(subjects, cert.Subject.CommonName)
+		}
+
+		return strings.Join(subjects, ", ")
+	}
+
+	return ""
+}
+
+// Boot order.
+type cmdConfigUEFIBootOrder struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIBootOrder) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("boot-order", i18n.G("[<remote>:]<instance> [<entry>...]"))
+	cmd.Short = i18n.G("Show or set the UEFI boot order")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Show or set the UEFI boot order
+
+Entries are given as their Boot#### variable name or number, as listed by "lxc config uefi list".`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc config uefi boot-order v1 Boot0002 Boot0001
+    Boot v1 from the Boot0002 entry first, falling back to Boot0001.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIBootOrder) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, -1)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	key := uefi.VariableKey("BootOrder", uefi.GlobalVariableGUID)
+
+	// Show the current boot order.
+	if len(args) == 1 {
+		v, ok := vars.Variables[key]
+		if !ok {
+			return nil
+		}
+
+		data, err := hex.DecodeString(v.Data)
+		if err != nil {
+			return err
+		}
+
+		order, err := uefi.ParseBootOrder(data)
+		if err != nil {
+			return err
+		}
+
+		for _, number := range order {
+			entry := uefi.BootEntryName(number)
+			description := ""
+
+			bootVar, ok := vars.Variables[uefi.VariableKey(entry, uefi.GlobalVariableGUID)]
+			if ok {
+				bootData, err := hex.DecodeString(bootVar.Data)
+				if err == nil {
+					description = c.configUEFI.describe(entry, uefi.GlobalVariableGUID, bootData)
+				}
+			}
+
+			fmt.Printf("%s: %s\n", entry, description)
+		}
+
+		return nil
+	}
+
+	// Set the new boot order.
+	order := make([]uint16, 0, len(args)-1)
+	for _, entry := range args[1:] {
+		number, err := strconv.ParseUint(strings.TrimPrefix(entry, "Boot"), 16, 16)
+		if err != nil {
+			return fmt.Errorf(i18n.G("Invalid boot entry %q"), entry)
+		}
+
+		_, ok := vars.Variables[uefi.VariableKey(uefi.BootEntryName(uint16(number)), uefi.GlobalVariableGUID)]
+		if !ok {
+			return fmt.Errorf(i18n.G("Boot entry %q doesn't exist"), entry)
+		}
+
+		order = append(order, uint16(number))
+	}
+
+	v, ok := vars.Variables[key]
+	if !ok {
+		v.Attributes = uefi.AttributesDefault
+	}
+
+	v.Data = hex.EncodeToString(uefi.BootOrder(order))
+	vars.Variables[key] = v
+
+	return resource.server.UpdateInstanceUEFIVars(resource.name, *vars, etag)
+}
+
+// Edit.
+type cmdConfigUEFIEdit struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIEdit) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("edit", i18n.G("[<remote>:]<instance>"))
+	cmd.Short = i18n.G("Edit virtual machine UEFI variables")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Edit virtual machine UEFI variables`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIEdit) helpTemplate() string {
+	return i18n.G(
+		`### This is a YAML representation of the virtual machine UEFI variables.
+### Any line starting with a '# will be ignored.
+###
+### Variables are indexed by <name>-<vendor GUID> and hold their hex encoded
+### data and attributes. Variables which are removed get deleted.
+###
+### A sample configuration looks like:
+###
+### variables:
+###   BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c:
+###     data: "01000000"
+###     attributes: 7`)
+}
+
+func (c *cmdConfigUEFIEdit) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	// If stdin isn't a terminal, read text from it
+	if !termios.IsTerminal(getStdinFd()) {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		newdata := api.InstanceUEFIVars{}
+		err = yaml.Unmarshal(contents, &newdata)
+		if err != nil {
+			return err
+		}
+
+		return resource.server.UpdateInstanceUEFIVars(resource.name, newdata, "")
+	}
+
+	// Extract the current value
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(vars)
+	if err != nil {
+		return err
+	}
+
+	// Spawn the editor
+	content, err := shared.TextEditor("", []byte(c.helpTemplate()+"\n\n"+string(data)))
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Parse the text received from the editor
+		newdata := api.InstanceUEFIVars{}
+		err = yaml.Unmarshal(content, &newdata)
+		if err == nil {
+			err = resource.server.UpdateInstanceUEFIVars(resource.name, newdata, etag)
+		}
+
+		// Respawn the editor
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.G("Config parsing error: %s")+"\n", err)
+			fmt.Println(i18n.G("Press enter to open the editor again or ctrl+c to abort change"))
+
+			_, err := os.Stdin.Read(make([]byte, 1))
+			if err != nil {
+				return err
+			}
+
+			content, err = shared.TextEditor("", content)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		break
+	}
+
+	return nil
+}
+
+// Enroll.
+type cmdConfigUEFIEnroll struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+
+	flagAppend bool
+}
+
+func (c *cmdConfigUEFIEnroll) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("enroll", i18n.G("[<remote>:]<instance> <PK|KEK|db|dbx> <certificate>..."))
+	cmd.Short = i18n.G("Enroll Secure Boot keys")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Enroll Secure Boot keys
+
+The certificates are read from PEM or DER encoded files and replace the current content
+of the key database, unless --append is passed.
+
+Enrolled keys are lost whenever the NVRAM gets regenerated, such as when changing security.secureboot.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc config uefi enroll v1 db signing.crt
+    Only trust binaries signed by signing.crt in v1.
+
+lxc config uefi enroll v1 db signing.crt --append
+    Also trust binaries signed by signing.crt in v1.`))
+	cmd.Flags().BoolVar(&c.flagAppend, "append", false, i18n.G("Append the certificates to the existing ones"))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIEnroll) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, -1)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	var guid uefi.GUID
+	switch args[1] {
+	case "PK", "KEK":
+		guid = uefi.GlobalVariableGUID
+	case "db", "dbx":
+		guid = uefi.ImageSecurityDatabaseGUID
+	default:
+		return fmt.Errorf(i18n.G("Invalid key database %q, must be one of PK, KEK, db or dbx"), args[1])
+	}
+
+	// Load the certificates.
+	certs := [][]byte{}
+	for _, path := range args[2:] {
+		content, err := ioutil.ReadFile(shared.HostPathFollow(path))
+		if err != nil {
+			return err
+		}
+
+		ders := [][]byte{}
+		for {
+			var block *pem.Block
+			block, content = pem.Decode(content)
+			if block == nil {
+				break
+			}
+
+			if block.Type == "CERTIFICATE" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+
+		// Fallback to DER encoding.
+		if len(ders) == 0 {
+			ders = append(ders, content)
+		}
+
+		for _, der := range ders {
+			_, err = x509.ParseCertificate(der)
+			if err != nil {
+				return fmt.Errorf(i18n.G("Invalid certificate %q: %w"), path, err)
+			}
+		}
+
+		certs = append(certs, ders...)
+	}
+
+	if args[1] == "PK" && (len(certs) > 1 || c.flagAppend) {
+		return fmt.Errorf(i18n.G("Only one certificate can be enrolled as PK"))
+	}
+
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	key := uefi.VariableKey(args[1], guid)
+
+	data := []byte{}
+	if c.flagAppend {
+		v, ok := vars.Variables[key]
+		if ok {
+			data, err = hex.DecodeString(v.Data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	owner, err := uefi.ParseGUID(uuid.New())
+	if err != nil {
+		return err
+	}
+
+	data = append(data, uefi.SignatureList(owner, certs...)...)
+
+	vars.Variables[key] = api.InstanceUEFIVariable{
+		Data:       hex.EncodeToString(data),
+		Attributes: uefi.AttributesAuthenticated,
+	}
+
+	return resource.server.UpdateInstanceUEFIVars(resource.name, *vars, etag)
+}
+
+// Get.
+type cmdConfigUEFIGet struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIGet) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("get", i18n.G("[<remote>:]<instance> <variable>"))
+	cmd.Short = i18n.G("Get the hex encoded value of a UEFI variable")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Get the hex encoded value of a UEFI variable`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIGet) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	key, err := c.configUEFI.variableKey(args[1])
+	if err != nil {
+		return err
+	}
+
+	vars, _, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	v, ok := vars.Variables[key]
+	if !ok {
+		return fmt.Errorf(i18n.G("UEFI variable %q doesn't exist"), key)
+	}
+
+	fmt.Println(v.Data)
+
+	return nil
+}
+
+// List.
+type cmdConfigUEFIList struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+
+	flagFormat string
+}
+
+func (c *cmdConfigUEFIList) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("list", i18n.G("[<remote>:]<instance>"))
+	cmd.Aliases = []string{"ls"}
+	cmd.Short = i18n.G("List virtual machine UEFI variables")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`List virtual machine UEFI variables
+
+Boot entries, the boot order and Secure Boot key databases are described in a human readable form.`))
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "table", i18n.G("Format (csv|json|table|yaml|compact)")+"``")
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIList) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	vars, _, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	data := make([][]string, 0, len(vars.Variables))
+	for key, v := range vars.Variables {
+		name, guid, err := uefi.ParseVariableKey(key)
+		if err != nil {
+			return err
+		}
+
+		value, err := hex.DecodeString(v.Data)
+		if err != nil {
+			return err
+		}
+
+		data = append(data, []string{
+			name,
+			guid.String(),
+			fmt.Sprintf("0x%02x", v.Attributes),
+			fmt.Sprintf("%d", len(value)),
+			c.configUEFI.describe(name, guid, value),
+		})
+	}
+
+	sort.Sort(utils.ByName(data))
+
+	header := []string{
+		i18n.G("NAME"),
+		i18n.G("GUID"),
+		i18n.G("ATTRIBUTES"),
+		i18n.G("SIZE"),
+		i18n.G("DESCRIPTION"),
+	}
+
+	return utils.RenderTable(c.flagFormat, header, data, vars)
+}
+
+// Set.
+type cmdConfigUEFISet struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+
+	flagAttributes string
+}
+
+func (c *cmdConfigUEFISet) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("set", i18n.G("[<remote>:]<instance> <variable> <hex value>"))
+	cmd.Short = i18n.G("Set the value of a UEFI variable")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Set the value of a UEFI variable
+
+New variables get non-volatile, boot service and runtime access attributes (0x07), unless --attributes is passed.`))
+	cmd.Flags().StringVar(&c.flagAttributes, "attributes", "", i18n.G("Variable attributes (EFI_VARIABLE_* bit mask)")+"``")
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFISet) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 3, 3)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	key, err := c.configUEFI.variableKey(args[1])
+	if err != nil {
+		return err
+	}
+
+	value := strings.TrimPrefix(args[2], "0x")
+	_, err = hex.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf(i18n.G("Invalid hex value %q: %w"), args[2], err)
+	}
+
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	v, ok := vars.Variables[key]
+	if !ok {
+		v.Attributes = uefi.AttributesDefault
+	}
+
+	if c.flagAttributes != "" {
+		attributes, err := strconv.ParseUint(c.flagAttributes, 0, 32)
+		if err != nil {
+			return fmt.Errorf(i18n.G("Invalid attributes %q: %w"), c.flagAttributes, err)
+		}
+
+		v.Attributes = uint32(attributes)
+	}
+
+	v.Data = strings.ToLower(value)
+	vars.Variables[key] = v
+
+	return resource.server.UpdateInstanceUEFIVars(resource.name, *vars, etag)
+}
+
+// Show.
+type cmdConfigUEFIShow struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIShow) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show", i18n.G("[<remote>:]<instance>"))
+	cmd.Short = i18n.G("Show virtual machine UEFI variables")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Show virtual machine UEFI variables`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIShow) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	vars, _, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(vars)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", content)
+
+	return nil
+}
+
+// Unset.
+type cmdConfigUEFIUnset struct {
+	global     *cmdGlobal
+	config     *cmdConfig
+	configUEFI *cmdConfigUEFI
+}
+
+func (c *cmdConfigUEFIUnset) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("unset", i18n.G("[<remote>:]<instance> <variable>"))
+	cmd.Short = i18n.G("Delete a UEFI variable")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Delete a UEFI variable`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdConfigUEFIUnset) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	resource, err := c.configUEFI.parseInstance(args[0])
+	if err != nil {
+		return err
+	}
+
+	key, err := c.configUEFI.variableKey(args[1])
+	if err != nil {
+		return err
+	}
+
+	vars, etag, err := resource.server.GetInstanceUEFIVars(resource.name)
+	if err != nil {
+		return err
+	}
+
+	_, ok := vars.Variables[key]
+	if !ok {
+		return fmt.Errorf(i18n.G("UEFI variable %q doesn't exist"), key)
+	}
+
+	delete(vars.Variables, key)
+
+	return resource.server.UpdateInstanceUEFIVars(resource.name, *vars, etag)
+}
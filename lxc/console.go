@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/websocket"
@@ -26,8 +27,9 @@ import (
 type cmdConsole struct {
 	global *cmdGlobal
 
-	flagShowLog bool
-	flagType    string
+	flagShowLog    bool
+	flagType       string
+	flagScreenshot string
 }
 
 func (c *cmdConsole) Command() *cobra.Command {
@@ -38,11 +40,15 @@ func (c *cmdConsole) Command() *cobra.Command {
 		`Attach to instance consoles
 
 This command allows you to interact with the boot console of an instance
-as well as retrieve past log entries from it.`))
+as well as retrieve past log entries from it.
+
+For virtual machines, --screenshot saves a screenshot of the VGA console
+to a PNG (or PPM, based on the file extension) file.`))
 
 	cmd.RunE = c.Run
 	cmd.Flags().BoolVar(&c.flagShowLog, "show-log", false, i18n.G("Retrieve the instance's console log"))
 	cmd.Flags().StringVarP(&c.flagType, "type", "t", "console", i18n.G("Type of connection to establish: 'console' for serial console, 'vga' for SPICE graphical output")+"``")
+	cmd.Flags().StringVar(&c.flagScreenshot, "screenshot", "", i18n.G("Save a screenshot of the VGA console to a file")+"``")
 
 	return cmd
 }
@@ -141,6 +147,34 @@ func (c *cmdConsole) Run(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	// Save a screenshot if requested
+	if c.flagScreenshot != "" {
+		format := "png"
+		if strings.HasSuffix(strings.ToLower(c.flagScreenshot), ".ppm") {
+			format = "ppm"
+		}
+
+		screenshot, err := d.GetInstanceConsoleScreenshot(name, format)
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = screenshot.Close() }()
+
+		target, err := os.Create(shared.HostPathFollow(c.flagScreenshot))
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(target, screenshot)
+		if err != nil {
+			_ = target.Close()
+			return err
+		}
+
+		return target.Close()
+	}
+
 	return c.Console(d, name)
 }
 
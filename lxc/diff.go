@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/lxc/utils"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type cmdDiff struct {
+	global *cmdGlobal
+
+	flagFormat string
+}
+
+func (c *cmdDiff) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("diff", i18n.G("[<remote>:]<instance>"))
+	cmd.Short = i18n.G("Show the changes made to instances")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Show the changes made to instances
+
+Lists the paths of the instance's root filesystem which were added, changed or removed
+compared to the image it was created from. Only containers are supported.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc diff c1
+    Show the paths changed in c1 since it was created.`))
+
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "table", i18n.G("Format (csv|json|table|yaml|compact)")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdDiff) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing instance name"))
+	}
+
+	// Get the changes
+	entries, err := resource.server.GetInstanceDiff(resource.name)
+	if err != nil {
+		return err
+	}
+
+	// Render the table
+	data := [][]string{}
+	for _, entry := range entries {
+		data = append(data, []string{strings.ToUpper(entry.Change), entry.Path})
+	}
+
+	header := []string{
+		i18n.G("CHANGE"),
+		i18n.G("PATH"),
+	}
+
+	return utils.RenderTable(c.flagFormat, header, data, entries)
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -16,12 +19,14 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/lxc/lxd/client"
 	"github.com/lxc/lxd/lxc/utils"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	cli "github.com/lxc/lxd/shared/cmd"
 	"github.com/lxc/lxd/shared/i18n"
 	"github.com/lxc/lxd/shared/ioprogress"
@@ -100,6 +105,10 @@ func (c *cmdFile) Command() *cobra.Command {
 	fileMountCmd := cmdFileMount{global: c.global, file: c}
 	cmd.AddCommand(fileMountCmd.Command())
 
+	// Watch
+	fileWatchCmd := cmdFileWatch{global: c.global, file: c}
+	cmd.AddCommand(fileWatchCmd.Command())
+
 	// Workaround for subcommand usage errors. See: https://github.com/spf13/cobra/issues/706
 	cmd.Args = cobra.NoArgs
 	cmd.Run = func(cmd *cobra.Command, args []string) { _ = cmd.Usage() }
@@ -324,7 +333,15 @@ func (c *cmdFilePull) Run(cmd *cobra.Command, args []string) error {
 					targetIsDir = true
 				}
 
-				err := c.file.recursivePullFile(resource.server, pathSpec[0], pathSpec[1], target)
+				if resource.server.HasExtension("instance_file_archive") {
+					err = c.file.archivePullFile(resource.server, pathSpec[0], pathSpec[1], target)
+				}
+
+				// Fallback to pulling the files one by one if the instance can't send archives.
+				if !resource.server.HasExtension("instance_file_archive") || api.StatusErrorCheck(err, http.StatusNotImplemented) {
+					err = c.file.recursivePullFile(resource.server, pathSpec[0], pathSpec[1], target)
+				}
+
 				if err != nil {
 					return err
 				}
@@ -551,7 +568,15 @@ func (c *cmdFilePush) Run(cmd *cobra.Command, args []string) error {
 
 		// Transfer the files
 		for _, fname := range sourcefilenames {
-			err := c.file.recursivePushFile(resource.server, resource.name, fname, targetPath)
+			if resource.server.HasExtension("instance_file_archive") {
+				err = c.file.archivePushFile(resource.server, resource.name, fname, targetPath)
+			}
+
+			// Fallback to pushing the files one by one if the instance can't receive archives.
+			if !resource.server.HasExtension("instance_file_archive") || api.StatusErrorCheck(err, http.StatusNotImplemented) {
+				err = c.file.recursivePushFile(resource.server, resource.name, fname, targetPath)
+			}
+
 			if err != nil {
 				return err
 			}
@@ -883,6 +908,287 @@ func (c *cmdFile) recursivePushFile(d lxd.InstanceServer, inst string, source st
 	return filepath.Walk(source, sendFile)
 }
 
+// archivePullFile pulls the directory p from the instance as a single archive and extracts it into targetDir.
+func (c *cmdFile) archivePullFile(d lxd.InstanceServer, inst string, p string, targetDir string) error {
+	target := filepath.Join(targetDir, filepath.Base(p))
+	logger.Infof("Pulling %s from %s (archive)", target, p)
+
+	buf, err := d.GetInstanceFileArchive(inst, p)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = buf.Close() }()
+
+	progress := utils.ProgressRenderer{
+		Format: fmt.Sprintf(i18n.G("Pulling %s from %s: %%s"), p, target),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	reader := &ioprogress.ProgressReader{
+		ReadCloser: buf,
+		Tracker: &ioprogress.ProgressTracker{
+			Handler: func(bytesReceived int64, speed int64) {
+				progress.UpdateProgress(ioprogress.ProgressData{
+					Text: fmt.Sprintf("%s (%s/s)",
+						units.GetByteSizeString(bytesReceived, 2),
+						units.GetByteSizeString(speed, 2))})
+			},
+		},
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			progress.Done("")
+			return err
+		}
+
+		err = archiveExtractEntry(tarReader, hdr, target)
+		if err != nil {
+			progress.Done("")
+			return err
+		}
+	}
+
+	progress.Done("")
+
+	return nil
+}
+
+// archiveEntryPath returns the path of the archive entry name inside root.
+// The entry is rejected if it's outside of root or if any of its parent directories isn't a directory, so that
+// symlinks created by previous entries are never followed.
+func archiveEntryPath(root string, name string) (string, error) {
+	name = path.Clean(name)
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf(i18n.G("Invalid archive entry %q"), name)
+	}
+
+	if name == "." {
+		return root, nil
+	}
+
+	parts := strings.Split(name, "/")
+	entryPath := root
+	for _, part := range parts[:len(parts)-1] {
+		entryPath = filepath.Join(entryPath, part)
+
+		fInfo, err := os.Lstat(entryPath)
+		if err != nil {
+			return "", err
+		}
+
+		if !fInfo.IsDir() {
+			return "", fmt.Errorf(i18n.G("Invalid archive entry %q: %s isn't a directory"), name, entryPath)
+		}
+	}
+
+	return filepath.Join(entryPath, parts[len(parts)-1]), nil
+}
+
+// archiveExtractEntry extracts the archive entry described by hdr into root.
+// Entries and hard link targets resolving outside of root are rejected.
+func archiveExtractEntry(tarReader *tar.Reader, hdr *tar.Header, root string) error {
+	entryPath, err := archiveEntryPath(root, hdr.Name)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(hdr.Mode).Perm()
+
+	// Replace any existing entry rather than writing through it.
+	if hdr.Typeflag != tar.TypeDir && entryPath != root {
+		err = os.Remove(entryPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		err = os.Mkdir(entryPath, mode)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+
+		fInfo, err := os.Lstat(entryPath)
+		if err != nil {
+			return err
+		}
+
+		if !fInfo.IsDir() {
+			return fmt.Errorf(i18n.G("Invalid archive entry %q: %s isn't a directory"), hdr.Name, entryPath)
+		}
+	case tar.TypeReg:
+		f, err := os.OpenFile(entryPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY|fileOpenNoFollow, mode)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, tarReader)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+
+		err = os.Chtimes(entryPath, hdr.ModTime, hdr.ModTime)
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		// Symlinks are recreated as-is, entries are never written through them.
+		err = os.Symlink(hdr.Linkname, entryPath)
+		if err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		linkPath, err := archiveEntryPath(root, hdr.Linkname)
+		if err != nil {
+			return err
+		}
+
+		fInfo, err := os.Lstat(linkPath)
+		if err != nil {
+			return err
+		}
+
+		if !fInfo.Mode().IsRegular() {
+			return fmt.Errorf(i18n.G("Invalid archive entry %q: hard link target %q isn't a regular file"), hdr.Name, hdr.Linkname)
+		}
+
+		err = os.Link(linkPath, entryPath)
+		if err != nil {
+			return err
+		}
+	default:
+		// Device nodes and fifos can't be created as a regular user, skip them.
+		logger.Infof("Skipping %s (unsupported file type)", entryPath)
+	}
+
+	return nil
+}
+
+// archivePushFile pushes the local source as a single archive, extracted into target on the instance.
+func (c *cmdFile) archivePushFile(d lxd.InstanceServer, inst string, source string, target string) error {
+	source = filepath.Clean(source)
+	targetPath := path.Join(target, filepath.Base(source))
+	logger.Infof("Pushing %s to %s (archive)", source, targetPath)
+
+	// Generate the archive while it's being sent.
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		tarWriter := tar.NewWriter(pipeWriter)
+
+		err := filepath.Walk(source, func(p string, fInfo os.FileInfo, err error) error {
+			if err != nil {
+				return fmt.Errorf(i18n.G("Failed to walk path for %s: %s"), p, err)
+			}
+
+			// Detect unsupported files
+			if !fInfo.Mode().IsRegular() && !fInfo.Mode().IsDir() && fInfo.Mode()&os.ModeSymlink != os.ModeSymlink {
+				return fmt.Errorf(i18n.G("'%s' isn't a supported file type"), p)
+			}
+
+			name, err := filepath.Rel(source, p)
+			if err != nil {
+				return err
+			}
+
+			link := ""
+			if fInfo.Mode()&os.ModeSymlink == os.ModeSymlink {
+				link, err = os.Readlink(p)
+				if err != nil {
+					return err
+				}
+			}
+
+			hdr, err := tar.FileInfoHeader(fInfo, link)
+			if err != nil {
+				return err
+			}
+
+			hdr.Name = filepath.ToSlash(name)
+			if fInfo.IsDir() {
+				hdr.Name += "/"
+			}
+
+			_, uid, gid := shared.GetOwnerMode(fInfo)
+			hdr.Uid = 0
+			if uid > 0 {
+				hdr.Uid = uid
+			}
+
+			hdr.Gid = 0
+			if gid > 0 {
+				hdr.Gid = gid
+			}
+
+			hdr.Uname = ""
+			hdr.Gname = ""
+
+			err = tarWriter.WriteHeader(hdr)
+			if err != nil {
+				return err
+			}
+
+			if hdr.Typeflag == tar.TypeReg {
+				f, err := os.Open(p)
+				if err != nil {
+					return err
+				}
+
+				defer func() { _ = f.Close() }()
+
+				_, err = io.Copy(tarWriter, io.LimitReader(f, hdr.Size))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err == nil {
+			err = tarWriter.Close()
+		}
+
+		_ = pipeWriter.CloseWithError(err)
+	}()
+
+	progress := utils.ProgressRenderer{
+		Format: fmt.Sprintf(i18n.G("Pushing %s to %s: %%s"), source, targetPath),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	reader := &ioprogress.ProgressReader{
+		ReadCloser: pipeReader,
+		Tracker: &ioprogress.ProgressTracker{
+			Handler: func(bytesSent int64, speed int64) {
+				progress.UpdateProgress(ioprogress.ProgressData{
+					Text: fmt.Sprintf("%s (%s/s)",
+						units.GetByteSizeString(bytesSent, 2),
+						units.GetByteSizeString(speed, 2))})
+			},
+		},
+	}
+
+	err := d.CreateInstanceFileArchive(inst, targetPath, reader)
+	_ = pipeReader.Close()
+	progress.Done("")
+
+	return err
+}
+
 func (c *cmdFile) recursiveMkdir(d lxd.InstanceServer, inst string, p string, mode *os.FileMode, uid int64, gid int64) error {
 	/* special case, every instance has a /, we don't need to do anything */
 	if p == "/" {
@@ -940,6 +1246,103 @@ func (c *cmdFile) recursiveMkdir(d lxd.InstanceServer, inst string, p string, mo
 	return nil
 }
 
+// Watch.
+type cmdFileWatch struct {
+	global *cmdGlobal
+	file   *cmdFile
+
+	flagFormat string
+}
+
+func (c *cmdFileWatch) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("watch", i18n.G("[<remote>:]<instance>/<path>"))
+	cmd.Short = i18n.G("Watch files in instances for changes")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Watch files in instances for changes
+
+The create, modify (written and closed) and delete events for the path are printed as they happen.
+If the path is a directory, the events for its entries are printed instead (and for those of its
+subdirectories with --recursive). The command exits when the path itself is deleted.
+
+The instance must be running.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc file watch c1/etc/nginx -r
+    Print the changes to the files in /etc/nginx and its subdirectories.
+
+lxc file watch c1/etc/nginx/nginx.conf --format=json
+    Print the changes to /etc/nginx/nginx.conf as JSON, one event per line.`))
+
+	cmd.Flags().BoolVarP(&c.file.flagRecursive, "recursive", "r", false, i18n.G("Watch subdirectories too"))
+	cmd.Flags().StringVarP(&c.flagFormat, "format", "f", "pretty", i18n.G("Format (json|pretty)")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdFileWatch) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	if !shared.StringInSlice(c.flagFormat, []string{"json", "pretty"}) {
+		return fmt.Errorf(i18n.G("Invalid format: %s"), c.flagFormat)
+	}
+
+	// Parse remote
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+	pathSpec := strings.SplitN(resource.name, "/", 2)
+	if len(pathSpec) != 2 {
+		return fmt.Errorf(i18n.G("Invalid path %s"), resource.name)
+	}
+
+	conn, err := resource.server.GetInstanceFileWatch(pathSpec[0], "/"+pathSpec[1], c.file.flagRecursive)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	const layout = "2006/01/02 15:04:05 MST"
+
+	for {
+		event := api.InstanceFileWatchEvent{}
+		err := conn.ReadJSON(&event)
+		if err != nil {
+			// The server closes the websocket once the watched path is deleted.
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+
+			return err
+		}
+
+		if c.flagFormat == "json" {
+			data, err := json.Marshal(&event)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(data))
+			continue
+		}
+
+		path := event.Path
+		if event.Directory {
+			path += "/"
+		}
+
+		fmt.Printf("%s %-6s %s\n", event.Timestamp.Local().Format(layout), event.Action, path)
+	}
+}
+
 // Mount.
 type cmdFileMount struct {
 	global *cmdGlobal
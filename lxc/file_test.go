@@ -0,0 +1,164 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveExtract extracts the archive made of the given headers (with the given content for regular files) into root.
+func archiveExtract(t *testing.T, root string, hdrs []*tar.Header, content string) error {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(content))
+		}
+
+		require.NoError(t, tarWriter.WriteHeader(hdr))
+
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tarWriter.Write([]byte(content))
+			require.NoError(t, err)
+		}
+	}
+
+	require.NoError(t, tarWriter.Close())
+
+	tarReader := tar.NewReader(&buf)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		require.NoError(t, err)
+
+		err = archiveExtractEntry(tarReader, hdr, root)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestArchiveExtractEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		hdrs    []*tar.Header
+		wantErr bool
+	}{
+		{
+			name: "files and links inside the target",
+			hdrs: []*tar.Header{
+				{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "dir/file", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "dir/symlink", Typeflag: tar.TypeSymlink, Linkname: "../dir/file"},
+				{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "dir/file"},
+			},
+		},
+		{
+			name:    "absolute entry",
+			hdrs:    []*tar.Header{{Name: "/file", Typeflag: tar.TypeReg, Mode: 0644}},
+			wantErr: true,
+		},
+		{
+			name:    "entry in the parent directory",
+			hdrs:    []*tar.Header{{Name: "dir/../../file", Typeflag: tar.TypeReg, Mode: 0644}},
+			wantErr: true,
+		},
+		{
+			name: "absolute symlink",
+			hdrs: []*tar.Header{{Name: "symlink", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+		},
+		{
+			name: "symlink to the parent directory",
+			hdrs: []*tar.Header{{Name: "symlink", Typeflag: tar.TypeSymlink, Linkname: "../outside"}},
+		},
+		{
+			name: "entry through a symlink to the parent directory",
+			hdrs: []*tar.Header{
+				{Name: "symlink", Typeflag: tar.TypeSymlink, Linkname: ".."},
+				{Name: "symlink/outside", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			wantErr: true,
+		},
+		{
+			name: "file replacing a symlink to the parent directory",
+			hdrs: []*tar.Header{
+				{Name: "symlink", Typeflag: tar.TypeSymlink, Linkname: "../outside"},
+				{Name: "symlink", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+		},
+		{
+			name: "entry through a symlink",
+			hdrs: []*tar.Header{
+				{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "symlink", Typeflag: tar.TypeSymlink, Linkname: "dir"},
+				{Name: "symlink/file", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "hard link outside of the target",
+			hdrs:    []*tar.Header{{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "../outside"}},
+			wantErr: true,
+		},
+		{
+			name: "hard link to a symlink",
+			hdrs: []*tar.Header{
+				{Name: "symlink", Typeflag: tar.TypeSymlink, Linkname: "../outside"},
+				{Name: "hardlink", Typeflag: tar.TypeLink, Linkname: "symlink"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			root := filepath.Join(dir, "target")
+			require.NoError(t, os.Mkdir(root, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "outside"), []byte("outside"), 0644))
+
+			err := archiveExtract(t, root, tt.hdrs, "content")
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// Nothing outside of the target is ever modified.
+			content, err := os.ReadFile(filepath.Join(dir, "outside"))
+			require.NoError(t, err)
+			assert.Equal(t, "outside", string(content))
+		})
+	}
+}
+
+func TestArchiveExtractEntryReplacesSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "target")
+	require.NoError(t, os.Mkdir(root, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "outside"), []byte("outside"), 0644))
+
+	// A symlink left by a previous pull is replaced rather than written through.
+	require.NoError(t, os.Symlink("../outside", filepath.Join(root, "file")))
+
+	err := archiveExtract(t, root, []*tar.Header{{Name: "file", Typeflag: tar.TypeReg, Mode: 0644}}, "content")
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(root, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	content, err = os.ReadFile(filepath.Join(dir, "outside"))
+	require.NoError(t, err)
+	assert.Equal(t, "outside", string(content))
+}
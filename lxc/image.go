@@ -652,7 +652,10 @@ func (c *cmdImageImport) Command() *cobra.Command {
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
 		`Import image into the image store
 
-Directory import is only available on Linux and must be performed as root.`))
+Directory import is only available on Linux and must be performed as root.
+
+OCI image layout directories as well as OCI and docker-archive tarballs
+can also be imported, they get converted into container images by the server.`))
 
 	cmd.Flags().BoolVar(&c.flagPublic, "public", false, i18n.G("Make image public"))
 	cmd.Flags().StringArrayVar(&c.flagAliases, "alias", nil, i18n.G("New aliases to add to the image")+"``")
@@ -662,6 +665,24 @@ Directory import is only available on Linux and must be performed as root.`))
 }
 
 func (c *cmdImageImport) packImageDir(path string) (string, error) {
+	// OCI image layouts are sent as-is and converted by the server.
+	if shared.PathExists(filepath.Join(path, "oci-layout")) {
+		outFile, err := ioutil.TempFile("", "lxd_image_")
+		if err != nil {
+			return "", err
+		}
+
+		defer func() { _ = outFile.Close() }()
+
+		outFileName := outFile.Name()
+		_, err = shared.RunCommand("tar", "-C", path, "--force-local", "-cf", outFileName, ".")
+		if err != nil {
+			return "", err
+		}
+
+		return outFileName, outFile.Close()
+	}
+
 	// Quick checks.
 	if os.Geteuid() == -1 {
 		return "", fmt.Errorf(i18n.G("Directory import is not available on this platform"))
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
@@ -25,6 +28,7 @@ type cmdInfo struct {
 
 	flagShowLog   bool
 	flagResources bool
+	flagHistory   bool
 	flagTarget    string
 }
 
@@ -35,7 +39,7 @@ func (c *cmdInfo) Command() *cobra.Command {
 	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
 		`Show instance or server information`))
 	cmd.Example = cli.FormatSection("", i18n.G(
-		`lxc info [<remote>:]<instance> [--show-log]
+		`lxc info [<remote>:]<instance> [--show-log] [--history]
     For instance information.
 
 lxc info [<remote>:] [--resources]
@@ -44,6 +48,7 @@ lxc info [<remote>:] [--resources]
 	cmd.RunE = c.Run
 	cmd.Flags().BoolVar(&c.flagShowLog, "show-log", false, i18n.G("Show the instance's last 100 log lines?"))
 	cmd.Flags().BoolVar(&c.flagResources, "resources", false, i18n.G("Show the resources available to the server"))
+	cmd.Flags().BoolVar(&c.flagHistory, "history", false, i18n.G("Show the recent resource usage of the instance"))
 	cmd.Flags().StringVar(&c.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
 
 	return cmd
@@ -493,6 +498,15 @@ func (c *cmdInfo) instanceInfo(d lxd.InstanceServer, remote config.Remote, name
 		fmt.Printf(i18n.G("Last Used: %s")+"\n", inst.LastUsedAt.Local().Format(layout))
 	}
 
+	if inst.State.Health != nil {
+		fmt.Printf(i18n.G("Health: %s")+"\n", inst.State.Health.Status)
+
+		if inst.State.Health.Failures > 0 {
+			fmt.Printf("  "+i18n.G("Failed checks: %d")+"\n", inst.State.Health.Failures)
+			fmt.Printf("  "+i18n.G("Last error: %s")+"\n", inst.State.Health.LastError)
+		}
+	}
+
 	if inst.State.Pid != 0 {
 		fmt.Println("\n" + i18n.G("Resources:"))
 		// Processes
@@ -571,6 +585,22 @@ func (c *cmdInfo) instanceInfo(d lxd.InstanceServer, remote config.Remote, name
 				networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets received"), net.Counters.PacketsReceived)
 				networkInfo += fmt.Sprintf("      %s: %d\n", i18n.G("Packets sent"), net.Counters.PacketsSent)
 
+				if net.Transfer != nil {
+					networkInfo += fmt.Sprintf("      %s:\n", i18n.G("Transfer"))
+					networkInfo += fmt.Sprintf("        %s: %s\n", i18n.G("Bytes received"), units.GetByteSizeString(net.Transfer.BytesReceived, 2))
+					networkInfo += fmt.Sprintf("        %s: %s\n", i18n.G("Bytes sent"), units.GetByteSizeString(net.Transfer.BytesSent, 2))
+					networkInfo += fmt.Sprintf("        %s: %s\n", i18n.G("Period start"), net.Transfer.PeriodStart.Local().Format(layout))
+					networkInfo += fmt.Sprintf("        %s: %s\n", i18n.G("Period usage"), units.GetByteSizeString(net.Transfer.PeriodBytesReceived+net.Transfer.PeriodBytesSent, 2))
+
+					if net.Transfer.Limit > 0 {
+						networkInfo += fmt.Sprintf("        %s: %s\n", i18n.G("Period limit"), units.GetByteSizeString(net.Transfer.Limit, 2))
+					}
+
+					if net.Transfer.Limited != "" {
+						networkInfo += fmt.Sprintf("        %s: %s\n", i18n.G("Limit action"), net.Transfer.Limited)
+					}
+				}
+
 				networkInfo += fmt.Sprintf("      %s:\n", i18n.G("IP addresses"))
 
 				for _, addr := range net.Addresses {
@@ -589,6 +619,60 @@ func (c *cmdInfo) instanceInfo(d lxd.InstanceServer, remote config.Remote, name
 		}
 	}
 
+	// Guest OS details
+	if inst.State.OS != nil {
+		guest := inst.State.OS
+
+		fmt.Println("\n" + i18n.G("Operating system:"))
+		if guest.Name != "" {
+			fmt.Printf("  %s: %s %s\n", i18n.G("OS"), guest.Name, guest.Version)
+		}
+
+		if guest.Kernel != "" {
+			fmt.Printf("  %s: %s\n", i18n.G("Kernel"), guest.Kernel)
+		}
+
+		if guest.Hostname != "" {
+			fmt.Printf("  %s: %s\n", i18n.G("Hostname"), guest.Hostname)
+		}
+
+		if guest.Uptime > 0 {
+			fmt.Printf("  %s: %s\n", i18n.G("Uptime"), time.Duration(guest.Uptime)*time.Second)
+		}
+
+		if len(guest.Packages) > 0 {
+			managers := make([]string, 0, len(guest.Packages))
+			for manager := range guest.Packages {
+				managers = append(managers, manager)
+			}
+
+			sort.Strings(managers)
+
+			fmt.Printf("  %s:\n", i18n.G("Packages"))
+			for _, manager := range managers {
+				fmt.Printf("    %s: %d\n", manager, guest.Packages[manager])
+			}
+		}
+
+		if len(guest.Users) > 0 {
+			fmt.Printf("  %s:\n", i18n.G("Logged-in users"))
+			for _, user := range guest.Users {
+				if user.Host != "" {
+					fmt.Printf("    %s (%s, %s, %s)\n", user.Name, user.Terminal, user.Host, user.LoginTime.Local().Format(layout))
+				} else {
+					fmt.Printf("    %s (%s, %s)\n", user.Name, user.Terminal, user.LoginTime.Local().Format(layout))
+				}
+			}
+		}
+
+		if len(guest.ListeningPorts) > 0 {
+			fmt.Printf("  %s:\n", i18n.G("Listening ports"))
+			for _, port := range guest.ListeningPorts {
+				fmt.Printf("    %s: %s\n", port.Protocol, net.JoinHostPort(port.Address, strconv.FormatInt(port.Port, 10)))
+			}
+		}
+	}
+
 	// List snapshots
 	firstSnapshot := true
 	if len(inst.Snapshots) > 0 {
@@ -689,6 +773,13 @@ func (c *cmdInfo) instanceInfo(d lxd.InstanceServer, remote config.Remote, name
 		_ = utils.RenderTable(utils.TableFormatTable, backupHeader, backupData, inst.Backups)
 	}
 
+	if c.flagHistory {
+		err = c.instanceHistory(d, name)
+		if err != nil {
+			return err
+		}
+	}
+
 	if showLog {
 		var log io.Reader
 		if inst.Type == "container" {
@@ -715,3 +806,74 @@ func (c *cmdInfo) instanceInfo(d lxd.InstanceServer, remote config.Remote, name
 
 	return nil
 }
+
+// instanceHistory prints sparklines of the recent resource usage of the instance.
+func (c *cmdInfo) instanceHistory(d lxd.InstanceServer, name string) error {
+	stateHistory, err := d.GetInstanceStateHistory(name)
+	if err != nil {
+		return err
+	}
+
+	samples := stateHistory.Samples
+	if len(samples) < 2 {
+		fmt.Println("\n" + i18n.G("Resource usage history: not enough samples yet"))
+		return nil
+	}
+
+	// Rates of the counters between consecutive samples, counters going down when the instance restarted.
+	rates := func(value func(sample api.InstanceStateHistorySample) float64) []float64 {
+		out := make([]float64, 0, len(samples)-1)
+		for i := 1; i < len(samples); i++ {
+			elapsed := samples[i].Time.Sub(samples[i-1].Time).Seconds()
+			delta := value(samples[i]) - value(samples[i-1])
+			if elapsed <= 0 || delta < 0 {
+				out = append(out, 0)
+				continue
+			}
+
+			out = append(out, delta/elapsed)
+		}
+
+		return out
+	}
+
+	byteRate := func(value float64) string {
+		return units.GetByteSizeString(int64(value), 2) + "/s"
+	}
+
+	cpu := rates(func(sample api.InstanceStateHistorySample) float64 { return sample.CPUSeconds * 100 })
+	memory := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		memory = append(memory, float64(sample.MemoryUsage))
+	}
+
+	rows := []struct {
+		name   string
+		values []float64
+		format func(float64) string
+	}{
+		{i18n.G("CPU usage"), cpu, func(value float64) string { return fmt.Sprintf("%.1f%%", value) }},
+		{i18n.G("Memory usage"), memory, func(value float64) string { return units.GetByteSizeStringIEC(int64(value), 2) }},
+		{i18n.G("Disk read"), rates(func(sample api.InstanceStateHistorySample) float64 { return float64(sample.DiskReadBytes) }), byteRate},
+		{i18n.G("Disk written"), rates(func(sample api.InstanceStateHistorySample) float64 { return float64(sample.DiskWrittenBytes) }), byteRate},
+		{i18n.G("Network received"), rates(func(sample api.InstanceStateHistorySample) float64 { return float64(sample.NetworkReceivedBytes) }), byteRate},
+		{i18n.G("Network sent"), rates(func(sample api.InstanceStateHistorySample) float64 { return float64(sample.NetworkSentBytes) }), byteRate},
+	}
+
+	const layout = "2006/01/02 15:04 MST"
+	fmt.Println("\n" + fmt.Sprintf(i18n.G("Resource usage history (%s to %s):"), samples[0].Time.Local().Format(layout), samples[len(samples)-1].Time.Local().Format(layout)))
+
+	for _, row := range rows {
+		var peak float64
+		for _, value := range row.values {
+			if value > peak {
+				peak = value
+			}
+		}
+
+		current := row.values[len(row.values)-1]
+		fmt.Printf("  %-18s %s  %s\n", row.name+":", utils.Sparkline(row.values, 60), fmt.Sprintf(i18n.G("(current: %s, peak: %s)"), row.format(current), row.format(peak)))
+	}
+
+	return nil
+}
@@ -128,6 +128,10 @@ For help with any of those, simply call them with --help.`))
 	deleteCmd := cmdDelete{global: &globalCmd}
 	app.AddCommand(deleteCmd.Command())
 
+	// diff sub-command
+	diffCmd := cmdDiff{global: &globalCmd}
+	app.AddCommand(diffCmd.Command())
+
 	// exec sub-command
 	execCmd := cmdExec{global: &globalCmd}
 	app.AddCommand(execCmd.Command())
@@ -204,6 +208,10 @@ For help with any of those, simply call them with --help.`))
 	queryCmd := cmdQuery{global: &globalCmd}
 	app.AddCommand(queryCmd.Command())
 
+	// rebuild sub-command
+	rebuildCmd := cmdRebuild{global: &globalCmd}
+	app.AddCommand(rebuildCmd.Command())
+
 	// rename sub-command
 	renameCmd := cmdRename{global: &globalCmd}
 	app.AddCommand(renameCmd.Command())
@@ -89,6 +89,10 @@ func (c *cmdNetwork) Command() *cobra.Command {
 	networkShowCmd := cmdNetworkShow{global: c.global, network: c}
 	cmd.AddCommand(networkShowCmd.Command())
 
+	// Show firewall
+	networkShowFirewallCmd := cmdNetworkShowFirewall{global: c.global, network: c}
+	cmd.AddCommand(networkShowFirewallCmd.Command())
+
 	// Unset
 	networkUnsetCmd := cmdNetworkUnset{global: c.global, network: c, networkSet: &networkSetCmd}
 	cmd.AddCommand(networkUnsetCmd.Command())
@@ -1223,6 +1227,72 @@ func (c *cmdNetworkShow) Run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// Show firewall.
+type cmdNetworkShowFirewall struct {
+	global  *cmdGlobal
+	network *cmdNetwork
+}
+
+func (c *cmdNetworkShowFirewall) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("show-firewall", i18n.G("[<remote>:]<network>"))
+	cmd.Short = i18n.G("Show network firewall rules")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Show network firewall rules
+
+The output includes the rules LXD generates for the network (including its forwards and ACLs)
+from its current config, the rules currently loaded in the kernel and the differences between them.`))
+
+	cmd.Flags().StringVar(&c.network.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdNetworkShowFirewall) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 1, 1)
+	if exit {
+		return err
+	}
+
+	// Parse remote.
+	resources, err := c.global.ParseServers(args[0])
+	if err != nil {
+		return err
+	}
+
+	resource := resources[0]
+	client := resource.server
+
+	if resource.name == "" {
+		return fmt.Errorf(i18n.G("Missing network name"))
+	}
+
+	// Targeting.
+	if c.network.flagTarget != "" {
+		if !client.IsClustered() {
+			return fmt.Errorf(i18n.G("To use --target, the destination remote must be a cluster"))
+		}
+
+		client = client.UseTarget(c.network.flagTarget)
+	}
+
+	ruleset, err := client.GetNetworkFirewall(resource.name)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(&ruleset)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", data)
+
+	return nil
+}
+
 // Unset.
 type cmdNetworkUnset struct {
 	global     *cmdGlobal
@@ -783,7 +783,7 @@ func (c *cmdProjectInfo) Run(cmd *cobra.Command, args []string) error {
 	}
 
 	// Render the output
-	byteLimits := []string{"disk", "memory"}
+	byteLimits := []string{"disk", "memory", "network-transfer"}
 	data := [][]string{}
 	for k, v := range projectState.Resources {
 		limit := i18n.G("UNLIMITED")
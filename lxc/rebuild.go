@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxc/utils"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	cli "github.com/lxc/lxd/shared/cmd"
+	"github.com/lxc/lxd/shared/i18n"
+)
+
+type cmdRebuild struct {
+	global *cmdGlobal
+}
+
+func (c *cmdRebuild) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Use = usage("rebuild", i18n.G("[<remote>:]<image> [<remote>:]<instance>"))
+	cmd.Short = i18n.G("Rebuild instances from images")
+	cmd.Long = cli.FormatSection(i18n.G("Description"), i18n.G(
+		`Rebuild instances from images
+
+The root disk of the instance is replaced with a fresh copy of the image.
+Its configuration, devices, profiles, snapshots and volatile keys (including MAC addresses) are kept.
+The instance must be stopped.`))
+	cmd.Example = cli.FormatSection("", i18n.G(
+		`lxc rebuild ubuntu:22.04 u1
+    Rebuild the u1 instance from the Ubuntu 22.04 image.`))
+
+	cmd.RunE = c.Run
+
+	return cmd
+}
+
+func (c *cmdRebuild) Run(cmd *cobra.Command, args []string) error {
+	conf := c.global.conf
+
+	// Quick checks.
+	exit, err := c.global.CheckArgs(cmd, args, 2, 2)
+	if exit {
+		return err
+	}
+
+	// Parse remotes
+	iremote, image, err := conf.ParseRemote(args[0])
+	if err != nil {
+		return err
+	}
+
+	remote, name, err := conf.ParseRemote(args[1])
+	if err != nil {
+		return err
+	}
+
+	d, err := conf.GetInstanceServer(remote)
+	if err != nil {
+		return err
+	}
+
+	inst, _, err := d.GetInstance(name)
+	if err != nil {
+		return err
+	}
+
+	// Connect to the image server
+	var imgRemote lxd.ImageServer
+	if iremote == remote {
+		imgRemote = d
+	} else {
+		imgRemote, err = conf.GetImageServer(iremote)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Deal with the default image
+	if image == "" {
+		image = "default"
+	}
+
+	req := api.InstanceRebuildPost{}
+
+	var imgInfo *api.Image
+
+	// Optimisation for simplestreams and OCI registries
+	if shared.StringInSlice(conf.Remotes[iremote].Protocol, []string{"simplestreams", "oci"}) {
+		imgInfo = &api.Image{}
+		imgInfo.Fingerprint = image
+		imgInfo.Public = true
+		req.Source.Alias = image
+	} else {
+		// Attempt to resolve an image alias
+		alias, _, err := imgRemote.GetImageAlias(image)
+		if err == nil {
+			req.Source.Alias = image
+			image = alias.Target
+		}
+
+		// Get the image info
+		imgInfo, _, err = imgRemote.GetImage(image)
+		if err != nil {
+			return err
+		}
+
+		if imgInfo.Type != inst.Type {
+			return fmt.Errorf(i18n.G("The image is of type %s but the instance is of type %s"), imgInfo.Type, inst.Type)
+		}
+	}
+
+	// Rebuild the instance
+	op, err := d.RebuildInstanceFromImage(imgRemote, *imgInfo, name, req)
+	if err != nil {
+		return err
+	}
+
+	// Watch the background operation
+	progress := utils.ProgressRenderer{
+		Format: i18n.G("Retrieving image: %s"),
+		Quiet:  c.global.flagQuiet,
+	}
+
+	_, err = op.AddHandler(progress.UpdateOp)
+	if err != nil {
+		progress.Done("")
+		return err
+	}
+
+	err = utils.CancelableWait(op, &progress)
+	if err != nil {
+		progress.Done("")
+		return err
+	}
+
+	progress.Done("")
+
+	return nil
+}
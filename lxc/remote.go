@@ -98,7 +98,7 @@ Basic authentication can be used when combined with the "simplestreams" protocol
 	cmd.RunE = c.Run
 	cmd.Flags().BoolVar(&c.flagAcceptCert, "accept-certificate", false, i18n.G("Accept certificate"))
 	cmd.Flags().StringVar(&c.flagPassword, "password", "", i18n.G("Remote admin password")+"``")
-	cmd.Flags().StringVar(&c.flagProtocol, "protocol", "", i18n.G("Server protocol (lxd or simplestreams)")+"``")
+	cmd.Flags().StringVar(&c.flagProtocol, "protocol", "", i18n.G("Server protocol (lxd, simplestreams or oci)")+"``")
 	cmd.Flags().StringVar(&c.flagAuthType, "auth-type", "", i18n.G("Server authentication type (tls or candid)")+"``")
 	cmd.Flags().BoolVar(&c.flagPublic, "public", false, i18n.G("Public image server"))
 	cmd.Flags().StringVar(&c.flagDomain, "domain", "", i18n.G("Candid domain to use")+"``")
@@ -326,6 +326,14 @@ func (c *cmdRemoteAdd) Run(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf(i18n.G("Only https URLs are supported for simplestreams"))
 		}
 
+		conf.Remotes[server] = config.Remote{Addr: addr, Public: true, Protocol: c.flagProtocol}
+		return conf.SaveConfig(c.global.confPath)
+	} else if c.flagProtocol == "oci" {
+		// Plain HTTP is allowed for local registries.
+		if remoteURL.Scheme != "https" && remoteURL.Scheme != "http" {
+			return fmt.Errorf(i18n.G("Only http and https URLs are supported for OCI registries"))
+		}
+
 		conf.Remotes[server] = config.Remote{Addr: addr, Public: true, Protocol: c.flagProtocol}
 		return conf.SaveConfig(c.global.confPath)
 	} else if c.flagProtocol != "lxd" {
@@ -701,7 +709,7 @@ func (c *cmdRemoteList) Run(cmd *cobra.Command, args []string) error {
 		if rc.AuthType == "" {
 			if strings.HasPrefix(rc.Addr, "unix:") {
 				rc.AuthType = "file access"
-			} else if rc.Protocol == "simplestreams" {
+			} else if shared.StringInSlice(rc.Protocol, []string{"simplestreams", "oci"}) {
 				rc.AuthType = "none"
 			} else {
 				rc.AuthType = "tls"
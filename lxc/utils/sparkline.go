@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"strings"
+)
+
+// sparklineBlocks are the characters used to render sparklines, from lowest to highest.
+var sparklineBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders the values as a sparkline of at most width characters, averaging consecutive values when
+// there are more values than characters. The values are scaled from zero to the maximum value.
+func Sparkline(values []float64, width int) string {
+	if len(values) == 0 || width <= 0 {
+		return ""
+	}
+
+	// Average the values into buckets.
+	buckets := values
+	if len(values) > width {
+		buckets = make([]float64, width)
+		for i := range buckets {
+			start := i * len(values) / width
+			end := (i + 1) * len(values) / width
+
+			var sum float64
+			for _, value := range values[start:end] {
+				sum += value
+			}
+
+			buckets[i] = sum / float64(end-start)
+		}
+	}
+
+	var max float64
+	for _, value := range buckets {
+		if value > max {
+			max = value
+		}
+	}
+
+	var sb strings.Builder
+	for _, value := range buckets {
+		level := 0
+		if max > 0 && value > 0 {
+			level = int(value / max * float64(len(sparklineBlocks)-1))
+		}
+
+		sb.WriteRune(sparklineBlocks[level])
+	}
+
+	return sb.String()
+}
@@ -9,6 +9,9 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// fileOpenNoFollow is the flag preventing os.OpenFile from following a symlink at the end of the path.
+const fileOpenNoFollow = unix.O_NOFOLLOW
+
 func getStdout() io.WriteCloser {
 	return os.Stdout
 }
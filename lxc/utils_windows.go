@@ -11,6 +11,10 @@ import (
 	"golang.org/x/sys/windows"
 )
 
+// fileOpenNoFollow is the flag preventing os.OpenFile from following a symlink at the end of the path.
+// Windows doesn't have one, os.O_EXCL is relied on instead.
+const fileOpenNoFollow = 0
+
 func getStdout() io.WriteCloser {
 	return &WrappedWriteCloser{os.Stdout, colorable.NewColorableStdout()}
 }
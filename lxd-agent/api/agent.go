@@ -18,3 +18,14 @@ type API10Put struct {
 	// Example: true
 	Devlxd bool `json:"devlxd" yaml:"devlxd"`
 }
+
+// FilesystemFreezePost contains the fields to freeze or thaw the guest filesystems.
+type FilesystemFreezePost struct {
+	// Action (freeze or thaw)
+	// Example: freeze
+	Action string `json:"action" yaml:"action"`
+
+	// Number of seconds after which the filesystems are thawed automatically (freeze only)
+	// Example: 60
+	Timeout int `json:"timeout" yaml:"timeout"`
+}
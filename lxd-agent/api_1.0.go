@@ -24,11 +24,14 @@ var api10Cmd = APIEndpoint{
 var api10 = []APIEndpoint{
 	api10Cmd,
 	execCmd,
+	fileWatchCmd,
+	fsFreezeCmd,
 	eventsCmd,
 	metricsCmd,
 	operationsCmd,
 	operationCmd,
 	operationWebsocket,
+	proxyCmd,
 	sftpCmd,
 	stateCmd,
 }
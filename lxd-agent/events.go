@@ -99,5 +99,17 @@ func eventsPost(d *Daemon, r *http.Request) response.Response {
 		return response.InternalError(err)
 	}
 
+	// Bring the hot-plugged vCPUs and memory online.
+	if event.Type == "config" {
+		var config struct {
+			Key string `json:"key"`
+		}
+
+		err = json.Unmarshal(event.Metadata, &config)
+		if err == nil && shared.StringInSlice(config.Key, []string{"limits.cpu", "limits.memory"}) {
+			go hotplugOnline()
+		}
+	}
+
 	return response.SyncResponse(true, nil)
 }
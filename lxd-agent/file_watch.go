@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/lxd/filewatch"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+)
+
+var fileWatchCmd = APIEndpoint{
+	Name: "fileWatch",
+	Path: "files/watch",
+
+	Get: APIEndpointAction{Handler: fileWatchHandler},
+}
+
+func fileWatchHandler(d *Daemon, r *http.Request) response.Response {
+	path := r.FormValue("path")
+	if path == "" {
+		return response.BadRequest(fmt.Errorf("Missing path argument"))
+	}
+
+	return &fileWatchServe{r: r, path: path, recursive: shared.IsTrue(r.FormValue("recursive"))}
+}
+
+type fileWatchServe struct {
+	r         *http.Request
+	path      string
+	recursive bool
+}
+
+func (r *fileWatchServe) String() string {
+	return "file watch handler"
+}
+
+func (r *fileWatchServe) Render(w http.ResponseWriter) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := filewatch.Watch(ctx, "/", r.path, r.recursive)
+	if err != nil {
+		return response.SmartError(err).Render(w)
+	}
+
+	conn, err := shared.WebsocketUpgrader.Upgrade(w, r.r, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	// Stop watching when the client goes away.
+	go func() {
+		for {
+			_, _, err := conn.NextReader()
+			if err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for event := range events {
+		err = conn.WriteJSON(event)
+		if err != nil {
+			return nil
+		}
+	}
+
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
+	return nil
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	agentAPI "github.com/lxc/lxd/lxd-agent/api"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// fsFreezeHookDir contains the executables run with "freeze" before freezing the filesystems and with "thaw"
+// after thawing them.
+const fsFreezeHookDir = "/etc/lxd-agent/fsfreeze-hook.d"
+
+// FIFREEZE and FITHAW ioctls, _IOWR('X', 119, int) and _IOWR('X', 120, int).
+const (
+	ioctlFIFreeze = 0xC0045877
+	ioctlFIThaw   = 0xC0045878
+)
+
+var fsFreezeCmd = APIEndpoint{
+	Name: "fsfreeze",
+	Path: "fsfreeze",
+
+	Post: APIEndpointAction{Handler: fsFreezePost},
+}
+
+// fsFreezeState holds the filesystems currently frozen and whether the last freeze ended on timeout.
+var fsFreezeState struct {
+	mu       sync.Mutex
+	frozen   []*os.File
+	hooks    []string
+	timer    *time.Timer
+	timedOut bool
+}
+
+func fsFreezePost(d *Daemon, r *http.Request) response.Response {
+	req := agentAPI.FilesystemFreezePost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	switch req.Action {
+	case "freeze":
+		if req.Timeout <= 0 {
+			return response.BadRequest(fmt.Errorf("Timeout must be greater than 0"))
+		}
+
+		err = fsFreeze(time.Duration(req.Timeout) * time.Second)
+	case "thaw":
+		err = fsThaw()
+	default:
+		return response.BadRequest(fmt.Errorf("Invalid action %q", req.Action))
+	}
+
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.EmptySyncResponse
+}
+
+// fsFreeze runs the freeze hooks and freezes the filesystems, thawing them automatically after timeout.
+func fsFreeze(timeout time.Duration) error {
+	fsFreezeState.mu.Lock()
+	defer fsFreezeState.mu.Unlock()
+
+	if fsFreezeState.timer != nil {
+		return fmt.Errorf("Filesystems are already frozen")
+	}
+
+	fsFreezeState.timedOut = false
+
+	hooks, err := fsFreezeHooks()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for i, hook := range hooks {
+		err := fsFreezeRunHook(ctx, hook, "freeze")
+		if err != nil {
+			// Let the hooks which already ran undo their changes.
+			fsFreezeRunHooks(hooks[:i], "thaw", timeout)
+			return err
+		}
+	}
+
+	fsFreezeState.hooks = hooks
+
+	mounts, err := fsFreezeMounts()
+	if err == nil {
+		for _, mount := range mounts {
+			var f *os.File
+			f, err = os.Open(mount)
+			if err != nil {
+				err = fmt.Errorf("Failed opening %q: %w", mount, err)
+				break
+			}
+
+			err = unix.IoctlSetInt(int(f.Fd()), ioctlFIFreeze, 0)
+			if err != nil {
+				_ = f.Close()
+
+				// Filesystems which don't support freezing are skipped.
+				if err == unix.EOPNOTSUPP {
+					err = nil
+					continue
+				}
+
+				err = fmt.Errorf("Failed freezing %q: %w", mount, err)
+				break
+			}
+
+			fsFreezeState.frozen = append(fsFreezeState.frozen, f)
+		}
+	}
+
+	if err != nil {
+		_ = fsThawLocked()
+		return err
+	}
+
+	// Thaw the filesystems on timeout, should the host never ask for it.
+	fsFreezeState.timer = time.AfterFunc(timeout, func() {
+		fsFreezeState.mu.Lock()
+		defer fsFreezeState.mu.Unlock()
+
+		if fsFreezeState.timer != nil {
+			logger.Warn("Thawing filesystems after timeout", logger.Ctx{"timeout": timeout})
+			_ = fsThawLocked()
+			fsFreezeState.timedOut = true
+		}
+	})
+
+	logger.Info("Froze filesystems", logger.Ctx{"count": len(fsFreezeState.frozen)})
+
+	return nil
+}
+
+// fsThaw thaws the filesystems and runs the thaw hooks. Fails if the filesystems were already thawed on timeout,
+// so that the host doesn't keep a snapshot taken while they may not have been frozen anymore.
+func fsThaw() error {
+	fsFreezeState.mu.Lock()
+	defer fsFreezeState.mu.Unlock()
+
+	if fsFreezeState.timer == nil {
+		if fsFreezeState.timedOut {
+			fsFreezeState.timedOut = false
+			return fmt.Errorf("Filesystems were already thawed on timeout")
+		}
+
+		return fmt.Errorf("Filesystems aren't frozen")
+	}
+
+	return fsThawLocked()
+}
+
+// fsThawLocked thaws the frozen filesystems in the reverse order and runs the thaw hooks.
+// Must be called with fsFreezeState.mu held.
+func fsThawLocked() error {
+	var thawErr error
+
+	if fsFreezeState.timer != nil {
+		fsFreezeState.timer.Stop()
+		fsFreezeState.timer = nil
+	}
+
+	for i := len(fsFreezeState.frozen) - 1; i >= 0; i-- {
+		f := fsFreezeState.frozen[i]
+
+		err := unix.IoctlSetInt(int(f.Fd()), ioctlFIThaw, 0)
+		if err != nil && err != unix.EINVAL {
+			logger.Error("Failed thawing filesystem", logger.Ctx{"path": f.Name(), "err": err})
+			thawErr = fmt.Errorf("Failed thawing %q: %w", f.Name(), err)
+		}
+
+		_ = f.Close()
+	}
+
+	fsFreezeState.frozen = nil
+
+	fsFreezeRunHooks(fsFreezeState.hooks, "thaw", time.Minute)
+	fsFreezeState.hooks = nil
+
+	return thawErr
+}
+
+// fsFreezeMounts returns the mount points of the block device backed read-write filesystems, nested ones first
+// and only one per filesystem.
+func fsFreezeMounts() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	return fsFreezeParseMounts(f)
+}
+
+// fsFreezeParseMounts returns the mount points to freeze from the mountinfo content read from r.
+func fsFreezeParseMounts(r io.Reader) ([]string, error) {
+	mounts := []string{}
+	devices := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Format: ID PARENT MAJOR:MINOR ROOT MOUNTPOINT OPTIONS [OPTIONAL...] - FSTYPE SOURCE SUPEROPTIONS
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+
+		if sep < 6 || len(fields) < sep+3 {
+			continue
+		}
+
+		device := fields[2]
+		source := fields[sep+2]
+		if !strings.HasPrefix(source, "/dev/") || devices[device] {
+			continue
+		}
+
+		readOnly := false
+		for _, option := range strings.Split(fields[5], ",") {
+			if option == "ro" {
+				readOnly = true
+			}
+		}
+
+		if readOnly {
+			continue
+		}
+
+		// Mount points have spaces, tabs, newlines and backslashes escaped in octal.
+		mountPoint, err := strconv.Unquote(`"` + strings.ReplaceAll(fields[4], `"`, `\"`) + `"`)
+		if err != nil {
+			mountPoint = fields[4]
+		}
+
+		devices[device] = true
+		mounts = append(mounts, mountPoint)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	// Freeze nested mounts before the filesystems they're mounted on.
+	for i, j := 0, len(mounts)-1; i < j; i, j = i+1, j-1 {
+		mounts[i], mounts[j] = mounts[j], mounts[i]
+	}
+
+	return mounts, nil
+}
+
+// fsFreezeHooks returns the executables in the hook directory, in name order.
+func fsFreezeHooks() ([]string, error) {
+	entries, err := os.ReadDir(fsFreezeHookDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	hooks := []string{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+
+		hooks = append(hooks, filepath.Join(fsFreezeHookDir, entry.Name()))
+	}
+
+	sort.Strings(hooks)
+
+	return hooks, nil
+}
+
+// fsFreezeRunHook runs hook with action as its argument.
+func fsFreezeRunHook(ctx context.Context, hook string, action string) error {
+	out, err := exec.CommandContext(ctx, hook, action).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed running %s hook %q: %w (%s)", action, hook, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// fsFreezeRunHooks runs hooks with action as their argument in the reverse order, logging any failure.
+func fsFreezeRunHooks(hooks []string, action string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		err := fsFreezeRunHook(ctx, hooks[i], action)
+		if err != nil {
+			logger.Error("Failed running filesystem freeze hook", logger.Ctx{"err": err})
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFsFreezeParseMounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		mountinfo string
+		mounts    []string
+	}{
+		{
+			name: "read-write block device filesystems",
+			mountinfo: `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+23 22 8:1 / /boot/efi rw,relatime shared:2 - vfat /dev/sda1 rw
+24 22 8:3 / /srv rw,relatime - xfs /dev/sda3 rw
+`,
+			mounts: []string{"/srv", "/boot/efi", "/"},
+		},
+		{
+			name: "read-only and virtual filesystems",
+			mountinfo: `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+25 22 0:5 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+26 22 0:24 / /run rw,nosuid,nodev shared:13 - tmpfs tmpfs rw,size=400000k
+27 22 8:16 / /mnt/cdrom ro,relatime shared:14 - iso9660 /dev/sr0 ro
+28 22 0:25 / /lxd-agent rw,relatime - virtiofs config rw
+`,
+			mounts: []string{"/"},
+		},
+		{
+			name: "filesystem mounted more than once",
+			mountinfo: `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+29 22 8:2 /var/lib/data /data rw,relatime shared:1 - ext4 /dev/sda2 rw
+`,
+			mounts: []string{"/"},
+		},
+		{
+			name: "escaped mount points",
+			mountinfo: `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+30 22 8:4 / /mnt/with\040space rw,relatime - ext4 /dev/sdb1 rw
+31 22 8:5 / /mnt/tab\011and\134backslash rw,relatime - ext4 /dev/sdb2 rw
+`,
+			mounts: []string{"/mnt/tab\tand\\backslash", "/mnt/with space", "/"},
+		},
+		{
+			name: "malformed lines",
+			mountinfo: `22 1 8:2 / / rw,relatime
+23 1 8:3 / /srv rw - ext4
+`,
+			mounts: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mounts, err := fsFreezeParseMounts(strings.NewReader(tt.mountinfo))
+			require.NoError(t, err)
+			assert.Equal(t, tt.mounts, mounts)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// hotplugFile describes the sysfs files controlling whether hot-pluggable resources are online.
+type hotplugFile struct {
+	pattern string
+	offline string
+	online  string
+}
+
+// hotplugFiles are the files of the vCPUs and memory blocks.
+var hotplugFiles = []hotplugFile{
+	{pattern: "/sys/devices/system/cpu/cpu*/online", offline: "0", online: "1"},
+	{pattern: "/sys/devices/system/memory/memory*/state", offline: "offline", online: "online"},
+}
+
+// hotplugAttempts is how many times the new resources are looked for, a second apart.
+var hotplugAttempts = 10
+
+// hotplugKnown holds the files of the vCPUs and memory blocks that were present when last checked. Only the
+// resources that appear afterwards are brought online so that the ones taken offline in the guest stay offline.
+var hotplugKnown map[string]bool
+
+// hotplugLock protects hotplugKnown.
+var hotplugLock sync.Mutex
+
+// hotplugInit records the vCPUs and memory blocks present when the agent starts.
+func hotplugInit() {
+	hotplugLock.Lock()
+	defer hotplugLock.Unlock()
+
+	hotplugKnown = hotplugPresent()
+}
+
+// hotplugPresent returns the files of the vCPUs and memory blocks currently present.
+func hotplugPresent() map[string]bool {
+	present := map[string]bool{}
+
+	for _, file := range hotplugFiles {
+		paths, err := filepath.Glob(file.pattern)
+		if err != nil {
+			continue
+		}
+
+		for _, path := range paths {
+			present[path] = true
+		}
+	}
+
+	return present
+}
+
+// hotplugOnline brings the vCPUs and memory blocks hot-plugged since the last call online, retrying for a while as
+// the kernel may not have registered them yet.
+func hotplugOnline() {
+	hotplugLock.Lock()
+	defer hotplugLock.Unlock()
+
+	for i := 0; i < hotplugAttempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+
+		for _, file := range hotplugFiles {
+			paths, err := filepath.Glob(file.pattern)
+			if err != nil {
+				continue
+			}
+
+			for _, path := range paths {
+				if hotplugKnown[path] {
+					continue
+				}
+
+				if hotplugOnlineFile(path, file.offline, file.online) {
+					hotplugKnown[path] = true
+				}
+			}
+		}
+	}
+
+	// Forget about the removed resources so they are brought online again if plugged back.
+	hotplugKnown = hotplugPresent()
+}
+
+// hotplugOnlineFile writes the online value to the file if it contains the offline value. Returns whether the
+// resource is online.
+func hotplugOnlineFile(path string, offline string, online string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	switch strings.TrimSpace(string(content)) {
+	case online:
+		return true
+	case offline:
+		err = os.WriteFile(path, []byte(online), 0)
+		if err != nil {
+			logger.Warn("Failed bringing hot-plugged resource online", logger.Ctx{"path": path, "err": err})
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotplugOnline(t *testing.T) {
+	dir := t.TempDir()
+
+	hotplugFiles = []hotplugFile{
+		{pattern: filepath.Join(dir, "cpu*", "online"), offline: "0", online: "1"},
+		{pattern: filepath.Join(dir, "memory*", "state"), offline: "offline", online: "online"},
+	}
+
+	hotplugAttempts = 1
+
+	writeFile := func(name string, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0644))
+	}
+
+	readFile := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	// A vCPU and a memory block taken offline in the guest before any hotplug.
+	writeFile("cpu1/online", "0")
+	writeFile("memory1/state", "offline")
+	hotplugInit()
+
+	// Hot-plugged resources.
+	writeFile("cpu2/online", "0")
+	writeFile("memory2/state", "offline")
+	writeFile("memory3/state", "online")
+	hotplugOnline()
+
+	assert.Equal(t, "0\n", readFile("cpu1/online"))
+	assert.Equal(t, "offline\n", readFile("memory1/state"))
+	assert.Equal(t, "1", readFile("cpu2/online"))
+	assert.Equal(t, "online", readFile("memory2/state"))
+	assert.Equal(t, "online\n", readFile("memory3/state"))
+
+	// A vCPU taken offline after being brought online stays offline.
+	writeFile("cpu2/online", "0")
+	hotplugOnline()
+	assert.Equal(t, "0\n", readFile("cpu2/online"))
+
+	// A vCPU that is unplugged and plugged back is brought online again.
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "cpu2")))
+	hotplugOnline()
+	writeFile("cpu2/online", "0")
+	hotplugOnline()
+	assert.Equal(t, "1", readFile("cpu2/online"))
+}
@@ -111,6 +111,9 @@ func (c *cmdAgent) Run(cmd *cobra.Command, args []string) error {
 
 	reconfigureNetworkInterfaces()
 
+	// Record the vCPUs and memory present at boot, only the ones hot-plugged later are brought online.
+	hotplugInit()
+
 	// Load the kernel driver.
 	logger.Info("Loading vsock module")
 	err = util.LoadModule("vsock")
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var proxyCmd = APIEndpoint{
+	Name: "proxy",
+	Path: "proxy",
+
+	Get: APIEndpointAction{Handler: proxyHandler},
+}
+
+// proxyHandler connects to the given address inside the guest and relays the connection over a websocket.
+// This is used by the proxy devices of VMs which aren't in NAT mode.
+func proxyHandler(d *Daemon, r *http.Request) response.Response {
+	connType, address, found := strings.Cut(r.FormValue("connect"), ":")
+	if !found || (connType != "tcp" && connType != "unix") {
+		return response.BadRequest(fmt.Errorf("Invalid connect address %q", r.FormValue("connect")))
+	}
+
+	// Abstract unix sockets keep their leading @ which net.Dial understands.
+	conn, err := net.Dial(connType, address)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed connecting to %q: %w", r.FormValue("connect"), err))
+	}
+
+	return &proxyServe{r: r, conn: conn}
+}
+
+type proxyServe struct {
+	r    *http.Request
+	conn net.Conn
+}
+
+func (r *proxyServe) String() string {
+	return "proxy handler"
+}
+
+func (r *proxyServe) Render(w http.ResponseWriter) error {
+	defer func() { _ = r.conn.Close() }()
+
+	ws, err := shared.WebsocketUpgrader.Upgrade(w, r.r, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = ws.Close() }()
+
+	logger.Debug("Started proxy connection", logger.Ctx{"connect": r.r.FormValue("connect")})
+	defer logger.Debug("Finished proxy connection", logger.Ctx{"connect": r.r.FormValue("connect")})
+
+	readDone, writeDone := shared.WebsocketMirror(ws, r.conn, r.conn, nil, nil)
+
+	// Stop relaying as soon as either side is done.
+	select {
+	case <-readDone:
+	case <-writeDone:
+	}
+
+	_ = r.conn.Close()
+	_ = ws.Close()
+
+	<-readDone
+	<-writeDone
+
+	return nil
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/pkg/sftp"
 
+	"github.com/lxc/lxd/lxd/filearchive"
 	"github.com/lxc/lxd/lxd/response"
 )
 
@@ -59,11 +61,13 @@ func (r *sftpServe) Render(w http.ResponseWriter) error {
 		return nil
 	}
 
-	// Start sftp server.
-	server, err := sftp.NewServer(conn)
-	if err != nil {
-		return nil
-	}
+	// Start sftp server (or handle an archive transfer).
+	return filearchive.Serve(conn, func(conn net.Conn) error {
+		server, err := sftp.NewServer(conn)
+		if err != nil {
+			return nil
+		}
 
-	return server.Serve()
+		return server.Serve()
+	})
 }
@@ -7,9 +7,11 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/lxc/lxd/lxd/guestinfo"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -39,9 +41,21 @@ func renderState() *api.InstanceState {
 		Network:   networkState(),
 		Pid:       1,
 		Processes: processesState(),
+		OS:        osState(),
 	}
 }
 
+func osState() *api.InstanceStateOS {
+	info := guestinfo.Get("/", "/proc/1")
+
+	hostname, err := os.Hostname()
+	if err == nil {
+		info.Hostname = hostname
+	}
+
+	return info
+}
+
 func cpuState() api.InstanceStateCPU {
 	var value []byte
 	var err error
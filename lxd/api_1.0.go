@@ -54,6 +54,8 @@ var api10 = []APIEndpoint{
 	instanceConsoleCmd,
 	instanceExecCmd,
 	instanceFileCmd,
+	instanceFileArchiveCmd,
+	instanceFileWatchCmd,
 	instanceLogCmd,
 	instanceLogsCmd,
 	instanceMetadataCmd,
@@ -63,6 +65,14 @@ var api10 = []APIEndpoint{
 	instanceSnapshotCmd,
 	instanceSnapshotsCmd,
 	instanceStateCmd,
+	instanceStateHistoryCmd,
+	instanceUEFIVarsCmd,
+	instanceFirewallCmd,
+	instanceRebuildCmd,
+	instanceDiffCmd,
+	instanceRecordingsCmd,
+	instanceRecordingCmd,
+	instanceRecordingExportCmd,
 	eventsCmd,
 	imageAliasCmd,
 	imageAliasesCmd,
@@ -75,6 +85,7 @@ var api10 = []APIEndpoint{
 	networkLeasesCmd,
 	networksCmd,
 	networkStateCmd,
+	networkFirewallCmd,
 	networkACLCmd,
 	networkACLsCmd,
 	networkACLLogCmd,
@@ -589,6 +600,13 @@ func doApi10Update(d *Daemon, r *http.Request, req api.ServerPut, patch bool) re
 			}
 		}
 
+		if nodeValues["storage.recordings_volume"] != nil && nodeValues["storage.recordings_volume"] != newNodeConfig.StorageRecordingsVolume() {
+			err := daemonStorageValidate(s, nodeValues["storage.recordings_volume"].(string))
+			if err != nil {
+				return err
+			}
+		}
+
 		if patch {
 			nodeChanged, err = newNodeConfig.Patch(nodeValues)
 		} else {
@@ -833,6 +851,14 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	value, ok = nodeChanged["storage.recordings_volume"]
+	if ok {
+		err := daemonStorageMove(s, "recordings", value)
+		if err != nil {
+			return err
+		}
+	}
+
 	if maasChanged {
 		url, key := clusterConfig.MAASController()
 		machine := nodeConfig.MAASMachine()
@@ -46,6 +46,7 @@ var apiInternal = []APIEndpoint{
 	internalClusterAcceptCmd,
 	internalClusterAssignCmd,
 	internalClusterHandoverCmd,
+	internalClusterInstanceMoveCmd,
 	internalClusterInstanceMovedCmd,
 	internalClusterRaftNodeCmd,
 	internalClusterRebalanceCmd,
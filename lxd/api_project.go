@@ -1056,6 +1056,7 @@ func projectValidateConfig(s *state.State, config map[string]string) error {
 		"images.compression_algorithm":         validate.IsCompressionAlgorithm,
 		"images.default_architecture":          validate.Optional(validate.IsArchitecture),
 		"images.remote_cache_expiry":           validate.Optional(validate.IsInt64),
+		"instances.session_recording":          validate.Optional(validate.IsBool),
 		"limits.instances":                     validate.Optional(validate.IsUint32),
 		"limits.containers":                    validate.Optional(validate.IsUint32),
 		"limits.virtual-machines":              validate.Optional(validate.IsUint32),
@@ -9,6 +9,7 @@ import (
 	"text/template"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/lxd/util"
@@ -45,6 +46,9 @@ profile "{{ .name }}" flags=(attach_disconnected,mediate_deleted) {
   network inet stream,
   network inet6 stream,
   network unix stream,
+{{- if .agentPath }}
+  network vsock stream,
+{{- end }}
 
   # Forkproxy operation
   {{ .logPath }}/** rw,
@@ -61,6 +65,13 @@ profile "{{ .name }}" flags=(attach_disconnected,mediate_deleted) {
 {{range $index, $element := .sockets}}
   {{$element}} rw,
 {{- end }}
+{{- end }}
+{{- if .agentPath }}
+
+  # Agent certificates (VM proxies through the lxd-agent)
+  {{ .agentPath }}/agent.crt r,
+  {{ .agentPath }}/agent-client.crt r,
+  {{ .agentPath }}/agent-client.key r,
 {{- end }}
 
   # Things that we definitely don't need
@@ -143,6 +154,16 @@ func forkproxyProfile(sysOS *sys.OS, inst instance, dev device) (string, error)
 		}
 	}
 
+	// Proxies of VMs without NAT connect through the lxd-agent and need its certificates.
+	agentPath := ""
+	if inst.Type() == instancetype.VM && shared.IsFalseOrEmpty(dev.Config()["nat"]) {
+		var err error
+		agentPath, err = filepath.EvalSymlinks(inst.Path())
+		if err != nil {
+			return "", err
+		}
+	}
+
 	execPath := util.GetExecPath()
 	execPathFull, err := filepath.EvalSymlinks(execPath)
 	if err == nil {
@@ -160,6 +181,7 @@ func forkproxyProfile(sysOS *sys.OS, inst instance, dev device) (string, error)
 		"logPath":     inst.LogPath(),
 		"libraryPath": strings.Split(os.Getenv("LD_LIBRARY_PATH"), ":"),
 		"sockets":     sockets,
+		"agentPath":   agentPath,
 	})
 	if err != nil {
 		return "", err
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/lxc/lxd/lxd/sys"
+	"github.com/lxc/lxd/shared"
 )
 
 // Internal copy of the network interface.
@@ -14,6 +15,11 @@ type network interface {
 	Name() string
 }
 
+// usesForkdnsProfile returns whether the network runs a forkdns or DNS64 proxy process.
+func usesForkdnsProfile(n network) bool {
+	return n.Config()["bridge.mode"] == "fan" || shared.IsTrue(n.Config()["dns.dns64"])
+}
+
 // NetworkLoad ensures that the network's profiles are loaded into the kernel.
 func NetworkLoad(sysOS *sys.OS, n network) error {
 	/* In order to avoid forcing a profile parse (potentially slow) on
@@ -53,7 +59,7 @@ func NetworkLoad(sysOS *sys.OS, n network) error {
 	}
 
 	// forkdns
-	if n.Config()["bridge.mode"] == "fan" {
+	if usesForkdnsProfile(n) {
 		profile := filepath.Join(aaPath, "profiles", forkdnsProfileFilename(n))
 		content, err := ioutil.ReadFile(profile)
 		if err != nil && !os.IsNotExist(err) {
@@ -91,7 +97,7 @@ func NetworkUnload(sysOS *sys.OS, n network) error {
 	}
 
 	// forkdns
-	if n.Config()["bridge.mode"] == "fan" {
+	if usesForkdnsProfile(n) {
 		err := unloadProfile(sysOS, ForkdnsProfileName(n), forkdnsProfileFilename(n))
 		if err != nil {
 			return err
@@ -108,7 +114,7 @@ func NetworkDelete(sysOS *sys.OS, n network) error {
 		return err
 	}
 
-	if n.Config()["bridge.mode"] == "fan" {
+	if usesForkdnsProfile(n) {
 		err := deleteProfile(sysOS, ForkdnsProfileName(n), forkdnsProfileFilename(n))
 		if err != nil {
 			return err
@@ -22,6 +22,12 @@ profile "{{ .name }}" flags=(attach_disconnected,mediate_deleted) {
   # Network access
   network inet dgram,
   network inet6 dgram,
+  network inet stream,
+  network inet6 stream,
+
+  # Host resolvers (DNS64)
+  /etc/resolv.conf r,
+  /run/systemd/resolve/*.conf r,
 
   # Network-specific paths
   {{ .varPath }}/networks/{{ .networkName }}/dnsmasq.leases r,
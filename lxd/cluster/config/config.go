@@ -197,11 +197,26 @@ func (c *Config) ImagesRemoteCacheExpiryDays() int64 {
 	return c.m.GetInt64("images.remote_cache_expiry")
 }
 
+// InstancesHistoryInterval returns the number of seconds between the resource usage samples of instances.
+func (c *Config) InstancesHistoryInterval() int64 {
+	return c.m.GetInt64("instances.history.interval")
+}
+
+// InstancesHistoryRetentionHours returns the number of hours the resource usage samples of instances are kept for.
+func (c *Config) InstancesHistoryRetentionHours() int64 {
+	return c.m.GetInt64("instances.history.retention")
+}
+
 // InstancesNICHostname returns hostname mode to use for instance NICs.
 func (c *Config) InstancesNICHostname() string {
 	return c.m.GetString("instances.nic.host_name")
 }
 
+// InstancesSessionRecording returns whether exec and console sessions of all instances are recorded.
+func (c *Config) InstancesSessionRecording() bool {
+	return c.m.GetBool("instances.session_recording")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]any {
@@ -270,7 +285,10 @@ var ConfigSchema = config.Schema{
 	"images.compression_algorithm":   {Default: "gzip", Validator: validate.IsCompressionAlgorithm},
 	"images.default_architecture":    {Validator: validate.Optional(validate.IsArchitecture)},
 	"images.remote_cache_expiry":     {Type: config.Int64, Default: "10"},
+	"instances.history.interval":     {Type: config.Int64, Default: "60", Validator: validate.IsUint32},
+	"instances.history.retention":    {Type: config.Int64, Default: "24", Validator: validate.IsUint32},
 	"instances.nic.host_name":        {Validator: validate.Optional(validate.IsOneOf("random", "mac"))},
+	"instances.session_recording":    {Type: config.Bool, Default: "false"},
 	"maas.api.key":                   {},
 	"maas.api.url":                   {},
 	"rbac.agent.url":                 {},
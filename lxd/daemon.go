@@ -1593,6 +1593,24 @@ func (d *Daemon) Ready() error {
 
 		// Remove resolved warnings (daily)
 		d.tasks.Add(pruneResolvedWarningsTask(d))
+
+		// Account for instance NIC traffic and apply transfer limits (every 5 minutes)
+		d.tasks.Add(networkTransferUpdateTask(d))
+
+		// Run the health checks of instances (every 5 seconds)
+		d.tasks.Add(instanceHealthCheckTask(d))
+
+		// Start and stop instances (minutely check of configurable cron expressions)
+		d.tasks.Add(instancesScheduledPowerTask(d))
+
+		// Record the resource usage of instances (configurable interval)
+		d.tasks.Add(instancesHistoryTask(d))
+
+		// Stop idle instances (minutely check)
+		d.tasks.Add(instancesIdleStopTask(d))
+
+		// Trim the console logs of virtual machines (minutely check)
+		d.tasks.Add(instanceConsoleLogTrimTask(d))
 	}
 
 	// Start all background tasks
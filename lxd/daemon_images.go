@@ -120,6 +120,38 @@ func (d *Daemon) ImageDownload(r *http.Request, op *operations.Operation, args *
 		}
 	}
 
+	// Resolve the image reference to the digest of the image on the OCI registry.
+	var ociRef string
+	var ociTLSVerify bool
+	if protocol == "oci" {
+		if args.Type == "virtual-machine" {
+			return nil, fmt.Errorf("OCI images can only be used for containers")
+		}
+
+		ociRef, ociTLSVerify, err = ociImageReference(args.Server, alias)
+		if err != nil {
+			return nil, err
+		}
+
+		ociInfo, err := ociImageInspect(ociRef, ociTLSVerify)
+		if err != nil {
+			return nil, err
+		}
+
+		fp, err = ociImageFingerprint(ociInfo.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		// Images are fingerprinted by their generated tarball, look for one already generated from this digest.
+		cachedFingerprint, err := d.db.Cluster.GetImageFingerprintByProperty("oci.digest", ociInfo.Digest)
+		if err == nil {
+			fp = cachedFingerprint
+		} else if !response.IsNotFoundError(err) {
+			return nil, err
+		}
+	}
+
 	// Ensure we are the only ones operating on this image.
 	unlock := d.imageOperationLock(fp)
 	defer unlock()
@@ -471,6 +503,46 @@ func (d *Daemon) ImageDownload(r *http.Request, op *operations.Operation, args *
 		info.Properties = imageMeta.Properties
 		info.Type = imageType
 
+		err = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if protocol == "oci" {
+		// Create the target file
+		f, err := os.Create(destName)
+		if err != nil {
+			return nil, err
+		}
+
+		defer func() { _ = f.Close() }()
+
+		// Pull and convert the image
+		progress(ioprogress.ProgressData{Text: "Unpacking OCI image"})
+
+		sha256 := sha256.New()
+		writer := shared.NewQuotaWriter(io.MultiWriter(f, sha256), args.Budget)
+		imageMeta, err := ociImageUnpack(ociRef, ociTLSVerify, alias, d.State().GlobalConfig.ImagesCompressionAlgorithm(), writer)
+		if err != nil {
+			return nil, err
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		// The image is fingerprinted by the generated tarball rather than by the OCI digest.
+		fp = fmt.Sprintf("%x", sha256.Sum(nil))
+
+		info = &api.Image{}
+		info.Fingerprint = fp
+		info.Size = fi.Size()
+		info.Architecture = imageMeta.Architecture
+		info.CreatedAt = time.Unix(imageMeta.CreationDate, 0)
+		info.ExpiresAt = time.Unix(imageMeta.ExpiryDate, 0)
+		info.Properties = imageMeta.Properties
+		info.Type = "container"
+
 		err = f.Close()
 		if err != nil {
 			return nil, err
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/osarch"
+)
+
+// ociImageInfo represents the subset of the "skopeo inspect" output used by LXD.
+type ociImageInfo struct {
+	Name         string            `json:"Name"`
+	Digest       string            `json:"Digest"`
+	Created      *time.Time        `json:"Created"`
+	Architecture string            `json:"Architecture"`
+	Os           string            `json:"Os"`
+	Labels       map[string]string `json:"Labels"`
+}
+
+// ociRuntimeConfig represents the subset of the OCI runtime configuration generated by umoci used by LXD.
+type ociRuntimeConfig struct {
+	Process struct {
+		Args []string `json:"args"`
+		Env  []string `json:"env"`
+		Cwd  string   `json:"cwd"`
+		User struct {
+			UID uint32 `json:"uid"`
+			GID uint32 `json:"gid"`
+		} `json:"user"`
+	} `json:"process"`
+}
+
+// ociImageReference returns the skopeo reference of an image on an OCI registry and whether TLS verification
+// should be performed when talking to it.
+// As with Docker, an image name whose first component looks like a host name is pulled from that registry,
+// anything else is pulled from the registry of the remote.
+func ociImageReference(server string, name string) (string, bool, error) {
+	if name == "" {
+		return "", false, fmt.Errorf("Missing OCI image name")
+	}
+
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", false, fmt.Errorf("Invalid OCI registry %q: %w", server, err)
+	}
+
+	if u.Host == "" {
+		return "", false, fmt.Errorf("Invalid OCI registry %q", server)
+	}
+
+	first, _, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return fmt.Sprintf("docker://%s", name), true, nil
+	}
+
+	return fmt.Sprintf("docker://%s/%s", u.Host, name), u.Scheme != "http", nil
+}
+
+// ociImageInspect returns information about the OCI image at the given skopeo reference.
+func ociImageInspect(ref string, tlsVerify bool) (*ociImageInfo, error) {
+	args := []string{"inspect", "--no-tags"}
+	if !tlsVerify {
+		args = append(args, "--tls-verify=false")
+	}
+
+	out, err := shared.RunCommand("skopeo", append(args, ref)...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed inspecting OCI image %q: %w", ref, err)
+	}
+
+	info := ociImageInfo{}
+	err = json.Unmarshal([]byte(out), &info)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing OCI image information for %q: %w", ref, err)
+	}
+
+	return &info, nil
+}
+
+// ociImageFingerprint returns the fingerprint used to refer to an OCI image with the given digest until its
+// unified image tarball has been generated and fingerprinted.
+func ociImageFingerprint(digest string) (string, error) {
+	fp := strings.TrimPrefix(digest, "sha256:")
+	if fp == digest || len(fp) != 64 {
+		return "", fmt.Errorf("Unsupported OCI image digest %q", digest)
+	}
+
+	return fp, nil
+}
+
+// ociImageProperties returns the image properties describing how to run the OCI image with the given runtime
+// configuration.
+func ociImageProperties(config ociRuntimeConfig) map[string]string {
+	properties := map[string]string{}
+
+	if len(config.Process.Args) > 0 {
+		properties["oci.entrypoint"] = shellquote.Join(config.Process.Args...)
+	}
+
+	if config.Process.Cwd != "" {
+		properties["oci.cwd"] = config.Process.Cwd
+	}
+
+	properties["oci.uid"] = strconv.FormatUint(uint64(config.Process.User.UID), 10)
+	properties["oci.gid"] = strconv.FormatUint(uint64(config.Process.User.GID), 10)
+
+	for _, env := range config.Process.Env {
+		key, value, found := strings.Cut(env, "=")
+		if !found || key == "" {
+			continue
+		}
+
+		properties[fmt.Sprintf("oci.env.%s", key)] = value
+	}
+
+	return properties
+}
+
+// ociImageUnpack pulls the OCI image at the given skopeo reference, unpacks its layers and writes a unified
+// LXD image tarball to dest, compressed with the given algorithm.
+// The tarball's metadata records the image's digest, entrypoint, environment, working directory and user as
+// properties.
+func ociImageUnpack(ref string, tlsVerify bool, description string, compress string, dest io.Writer) (*api.ImageMetadata, error) {
+	_, err := exec.LookPath("skopeo")
+	if err != nil {
+		return nil, fmt.Errorf("OCI images require skopeo to be installed: %w", err)
+	}
+
+	_, err = exec.LookPath("umoci")
+	if err != nil {
+		return nil, fmt.Errorf("OCI images require umoci to be installed: %w", err)
+	}
+
+	info, err := ociImageInspect(ref, tlsVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp(shared.VarPath("images"), "lxd_oci_")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	// Copy the image into a local OCI layout.
+	layoutRef := fmt.Sprintf("%s:latest", filepath.Join(tmpDir, "oci"))
+	args := []string{"copy"}
+	if !tlsVerify {
+		args = append(args, "--src-tls-verify=false")
+	}
+
+	_, err = shared.RunCommand("skopeo", append(args, ref, fmt.Sprintf("oci:%s", layoutRef))...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed pulling OCI image %q: %w", ref, err)
+	}
+
+	// Unpack the layers into a runtime bundle.
+	bundlePath := filepath.Join(tmpDir, "bundle")
+	_, err = shared.RunCommand("umoci", "unpack", "--image", layoutRef, bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed unpacking OCI image %q: %w", ref, err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(bundlePath, "config.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	config := ociRuntimeConfig{}
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing OCI runtime configuration: %w", err)
+	}
+
+	// LXD needs the usual mount points to be present in the rootfs.
+	for _, dir := range []string{"dev", "proc", "sys"} {
+		err = os.MkdirAll(filepath.Join(bundlePath, "rootfs", dir), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	architectureID, err := osarch.ArchitectureId(info.Architecture)
+	if err != nil {
+		return nil, err
+	}
+
+	architecture, err := osarch.ArchitectureName(architectureID)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := api.ImageMetadata{
+		Architecture: architecture,
+		CreationDate: time.Now().UTC().Unix(),
+		Properties:   ociImageProperties(config),
+	}
+
+	if info.Created != nil {
+		meta.CreationDate = info.Created.Unix()
+	}
+
+	meta.Properties["description"] = description
+	meta.Properties["architecture"] = architecture
+	meta.Properties["oci.digest"] = info.Digest
+
+	if strings.HasPrefix(ref, "docker://") {
+		meta.Properties["oci.reference"] = strings.TrimPrefix(ref, "docker://")
+	}
+
+	if info.Os != "" {
+		meta.Properties["os"] = info.Os
+	}
+
+	data, err := yaml.Marshal(&meta)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.WriteFile(filepath.Join(bundlePath, "metadata.yaml"), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the unified image tarball.
+	cmd := exec.Command("tar", "-cf", "-", "--numeric-owner", "--xattrs", "-C", bundlePath, "metadata.yaml", "rootfs")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	if compress != "none" {
+		err = compressFile(compress, stdout, dest)
+	} else {
+		_, err = io.Copy(dest, stdout)
+	}
+
+	waitErr := cmd.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	if waitErr != nil {
+		return nil, fmt.Errorf("Failed generating image tarball: %w (%s)", waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return &meta, nil
+}
+
+// ociArchiveType returns the skopeo transport matching the content of the given tarball
+// ("oci-archive" or "docker-archive"), or an empty string if it isn't an OCI image.
+func ociArchiveType(path string) string {
+	out, err := shared.RunCommand("tar", "-tf", path)
+	if err != nil {
+		return ""
+	}
+
+	entries := strings.Split(strings.TrimSpace(out), "\n")
+	for i := range entries {
+		entries[i] = strings.TrimPrefix(entries[i], "./")
+	}
+
+	if shared.StringInSlice("oci-layout", entries) {
+		return "oci-archive"
+	}
+
+	if shared.StringInSlice("manifest.json", entries) {
+		return "docker-archive"
+	}
+
+	return ""
+}
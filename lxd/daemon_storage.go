@@ -20,6 +20,7 @@ import (
 func daemonStorageVolumesUnmount(s *state.State) error {
 	var storageBackups string
 	var storageImages string
+	var storageRecordings string
 
 	err := s.DB.Node.Transaction(func(tx *db.NodeTx) error {
 		nodeConfig, err := node.ConfigLoad(tx)
@@ -29,6 +30,7 @@ func daemonStorageVolumesUnmount(s *state.State) error {
 
 		storageBackups = nodeConfig.StorageBackupsVolume()
 		storageImages = nodeConfig.StorageImagesVolume()
+		storageRecordings = nodeConfig.StorageRecordingsVolume()
 
 		return nil
 	})
@@ -71,12 +73,20 @@ func daemonStorageVolumesUnmount(s *state.State) error {
 		}
 	}
 
+	if storageRecordings != "" {
+		err := unmount("recordings", storageRecordings)
+		if err != nil {
+			return fmt.Errorf("Failed to unmount recordings storage: %w", err)
+		}
+	}
+
 	return nil
 }
 
 func daemonStorageMount(s *state.State) error {
 	var storageBackups string
 	var storageImages string
+	var storageRecordings string
 	err := s.DB.Node.Transaction(func(tx *db.NodeTx) error {
 		nodeConfig, err := node.ConfigLoad(tx)
 		if err != nil {
@@ -85,6 +95,7 @@ func daemonStorageMount(s *state.State) error {
 
 		storageBackups = nodeConfig.StorageBackupsVolume()
 		storageImages = nodeConfig.StorageImagesVolume()
+		storageRecordings = nodeConfig.StorageRecordingsVolume()
 
 		return nil
 	})
@@ -127,6 +138,13 @@ func daemonStorageMount(s *state.State) error {
 		}
 	}
 
+	if storageRecordings != "" {
+		err := mount("recordings", storageRecordings)
+		if err != nil {
+			return fmt.Errorf("Failed to mount recordings storage: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -23,6 +23,7 @@ var ImageSourceProtocol = map[int]string{
 	0: "lxd",
 	1: "direct",
 	2: "simplestreams",
+	3: "oci",
 }
 
 // GetLocalImagesFingerprints returns the fingerprints of all local images.
@@ -351,6 +352,34 @@ func (c *Cluster) GetCachedImageSourceFingerprint(server string, protocol string
 	return fingerprints[0], nil
 }
 
+// GetImageFingerprintByProperty returns the fingerprint of the most recently created image, in any project,
+// which has the given property set to the given value.
+func (c *Cluster) GetImageFingerprintByProperty(key string, value string) (string, error) {
+	q := `SELECT images.fingerprint
+			FROM images
+			INNER JOIN images_properties
+			ON images_properties.image_id=images.id
+			WHERE images_properties.key=? AND images_properties.value=?
+			ORDER BY images.creation_date DESC
+`
+
+	var fingerprints []string
+	err := c.Transaction(context.TODO(), func(ctx context.Context, tx *ClusterTx) error {
+		var err error
+		fingerprints, err = query.SelectStrings(tx.tx, q, key, value)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(fingerprints) == 0 {
+		return "", api.StatusErrorf(http.StatusNotFound, "Image not found")
+	}
+
+	return fingerprints[0], nil
+}
+
 // ImageExists returns whether an image with the given fingerprint exists.
 func (c *Cluster) ImageExists(project string, fingerprint string) (bool, error) {
 	table := "images JOIN projects ON projects.id = images.project_id"
@@ -835,6 +835,31 @@ func (c *ClusterTx) UpdateInstanceNode(ctx context.Context, project, oldName str
 	return nil
 }
 
+// UpdateInstanceNodeToLocal changes the cluster member hosting an instance to the local member.
+// Unlike UpdateInstanceNode it's not restricted to ceph, it's used once the instance volumes have been moved.
+func (c *ClusterTx) UpdateInstanceNodeToLocal(ctx context.Context, project string, name string) error {
+	instanceID, err := cluster.GetInstanceID(ctx, c.tx, project, name)
+	if err != nil {
+		return fmt.Errorf("Failed to get instance's ID: %w", err)
+	}
+
+	result, err := c.tx.Exec("UPDATE instances SET node_id=? WHERE id=?", c.nodeID, instanceID)
+	if err != nil {
+		return fmt.Errorf("Failed to update instance's node ID: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Failed to get rows affected by instance update: %w", err)
+	}
+
+	if n != 1 {
+		return fmt.Errorf("Unexpected number of updated rows in instances table: %d", n)
+	}
+
+	return nil
+}
+
 // GetLocalInstancesInProject retuurns all instances of the given type on the local member in the given project.
 // If projectName is empty then all instances in all projects are returned.
 func (c *ClusterTx) GetLocalInstancesInProject(ctx context.Context, filter cluster.InstanceFilter) ([]cluster.Instance, error) {
@@ -1007,6 +1032,27 @@ SELECT instances.id, projects.name AS project, instances.name, nodes.name AS nod
 	return &inst, nil
 }
 
+// LocalInstancesHaveConfigKey returns whether any of the instances on this node has the config key set, either
+// directly or through one of its profiles.
+func (c *ClusterTx) LocalInstancesHaveConfigKey(ctx context.Context, key string) (bool, error) {
+	q := `
+SELECT count(*) FROM instances
+  WHERE instances.node_id = ? AND (
+    EXISTS (SELECT 1 FROM instances_config WHERE instances_config.instance_id = instances.id AND instances_config.key = ?)
+    OR EXISTS (SELECT 1 FROM instances_profiles JOIN profiles_config ON profiles_config.profile_id = instances_profiles.profile_id
+      WHERE instances_profiles.instance_id = instances.id AND profiles_config.key = ?))
+  LIMIT 1
+`
+
+	var count int
+	err := c.tx.QueryRowContext(ctx, q, c.nodeID, key, key).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
 // GetInstancePool returns the storage pool of a given instance (or snapshot).
 func (c *ClusterTx) GetInstancePool(projectName string, instanceName string) (string, error) {
 	// Strip snapshot name if supplied in instanceName, and lookup the storage pool of the parent instance
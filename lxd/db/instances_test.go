@@ -458,6 +458,27 @@ func TestGetInstanceToNodeMap(t *testing.T) {
 		}, result)
 }
 
+// An instance can be moved to the local member.
+func TestUpdateInstanceNodeToLocal(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	nodeID2, err := tx.CreateNode("node2", "1.2.3.4:666")
+	require.NoError(t, err)
+
+	addContainer(t, tx, nodeID2, "c1")
+
+	err = tx.UpdateInstanceNodeToLocal(context.Background(), project.Default, "c1")
+	require.NoError(t, err)
+
+	result, err := tx.GetProjectInstanceToNodeMap([]string{"default"}, db.InstanceTypeFilter(instancetype.Container))
+	require.NoError(t, err)
+	assert.Equal(t, map[[2]string]string{{project.Default, "c1"}: "none"}, result)
+
+	err = tx.UpdateInstanceNodeToLocal(context.Background(), project.Default, "c2")
+	assert.Error(t, err)
+}
+
 func TestGetInstancePool(t *testing.T) {
 	dbCluster, cleanup := db.NewTestCluster(t)
 	defer cleanup()
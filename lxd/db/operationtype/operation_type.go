@@ -67,6 +67,7 @@ const (
 	ClusterMemberRestore
 	CertificateAddToken
 	RemoveOrphanedOperations
+	InstanceRebuild
 )
 
 // Description return a human-readable description of the operation type.
@@ -182,6 +183,8 @@ func (t Type) Description() string {
 		return "Restoring cluster member"
 	case RemoveOrphanedOperations:
 		return "Remove orphaned operations"
+	case InstanceRebuild:
+		return "Rebuilding instance"
 	default:
 		return "Executing operation"
 	}
@@ -227,6 +230,8 @@ func (t Type) Permission() string {
 		return "manage-containers"
 	case InstanceUpdate:
 		return "manage-containers"
+	case InstanceRebuild:
+		return "manage-containers"
 	case InstanceRename:
 		return "manage-containers"
 	case InstanceMigrate:
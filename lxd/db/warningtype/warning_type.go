@@ -56,6 +56,8 @@ const (
 	InstanceTypeNotOperational
 	// StoragePoolUnvailable represents a storage pool that cannot be initialized on the local server.
 	StoragePoolUnvailable
+	// InstanceRestartFailure represents the failure of instance automatic restarts after the configured retries.
+	InstanceRestartFailure
 )
 
 // TypeNames associates a warning code to its name.
@@ -85,6 +87,7 @@ var TypeNames = map[Type]string{
 	InstanceAutostartFailure:               "Failed to autostart instance",
 	InstanceTypeNotOperational:             "Instance type not operational",
 	StoragePoolUnvailable:                  "Storage pool unavailable",
+	InstanceRestartFailure:                 "Failed to automatically restart instance",
 }
 
 // Severity returns the severity of the warning type.
@@ -140,6 +143,8 @@ func (t Type) Severity() Severity {
 		return SeverityLow
 	case StoragePoolUnvailable:
 		return SeverityHigh
+	case InstanceRestartFailure:
+		return SeverityModerate
 	}
 
 	return SeverityLow
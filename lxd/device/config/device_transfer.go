@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NICTransfer represents the transfer accounting state of a NIC device stored in its instance's volatile config.
+// Received and sent are from the point of view of the instance.
+type NICTransfer struct {
+	BytesReceived       int64     // Total bytes received since accounting started.
+	BytesSent           int64     // Total bytes sent since accounting started.
+	PeriodStart         time.Time // Start of the billing period the period counters relate to.
+	PeriodBytesReceived int64     // Bytes received within the billing period.
+	PeriodBytesSent     int64     // Bytes sent within the billing period.
+	LastIndex           int64     // Index of the host-side interface as of the last update.
+	LastBytesReceived   int64     // Bytes received counter of the host-side interface as of the last update.
+	LastBytesSent       int64     // Bytes sent counter of the host-side interface as of the last update.
+	Limited             string    // Limit action currently applied to the NIC (empty if none).
+}
+
+// NICTransferFromVolatile loads the transfer accounting state of the named NIC device from instance config.
+// Missing or invalid values are treated as zero so that accounting can always continue.
+func NICTransferFromVolatile(config map[string]string, devName string) *NICTransfer {
+	prefix := fmt.Sprintf("volatile.%s.transfer.", devName)
+
+	parseInt := func(key string) int64 {
+		value, _ := strconv.ParseInt(config[prefix+key], 10, 64)
+		return value
+	}
+
+	t := &NICTransfer{
+		BytesReceived:       parseInt("rx"),
+		BytesSent:           parseInt("tx"),
+		PeriodBytesReceived: parseInt("period.rx"),
+		PeriodBytesSent:     parseInt("period.tx"),
+		Limited:             config[prefix+"limited"],
+	}
+
+	periodStart := parseInt("period.start")
+	if periodStart > 0 {
+		t.PeriodStart = time.Unix(periodStart, 0).UTC()
+	}
+
+	fields := strings.Split(config[prefix+"last"], ":")
+	if len(fields) == 3 {
+		t.LastIndex, _ = strconv.ParseInt(fields[0], 10, 64)
+		t.LastBytesReceived, _ = strconv.ParseInt(fields[1], 10, 64)
+		t.LastBytesSent, _ = strconv.ParseInt(fields[2], 10, 64)
+	}
+
+	return t
+}
+
+// IsNICTransferKey returns whether the instance config key holds the transfer accounting state of a NIC device.
+func IsNICTransferKey(key string) bool {
+	return strings.HasPrefix(key, "volatile.") && strings.Contains(key, ".transfer.")
+}
+
+// Volatile returns the volatile config keys (without the device prefix) representing the transfer state.
+func (t *NICTransfer) Volatile() map[string]string {
+	v := map[string]string{
+		"transfer.rx":           strconv.FormatInt(t.BytesReceived, 10),
+		"transfer.tx":           strconv.FormatInt(t.BytesSent, 10),
+		"transfer.period.start": "",
+		"transfer.period.rx":    strconv.FormatInt(t.PeriodBytesReceived, 10),
+		"transfer.period.tx":    strconv.FormatInt(t.PeriodBytesSent, 10),
+		"transfer.last":         "",
+		"transfer.limited":      t.Limited,
+	}
+
+	if !t.PeriodStart.IsZero() {
+		v["transfer.period.start"] = strconv.FormatInt(t.PeriodStart.Unix(), 10)
+	}
+
+	if t.LastIndex > 0 {
+		v["transfer.last"] = fmt.Sprintf("%d:%d:%d", t.LastIndex, t.LastBytesReceived, t.LastBytesSent)
+	}
+
+	return v
+}
+
+// Account adds the traffic seen on the host-side interface since the last update to the counters.
+// If the interface has changed or its counters have gone backwards then it has been recreated since the last
+// update and all of its traffic is accounted for. The period counters are reset when a new period has started.
+func (t *NICTransfer) Account(index int64, bytesReceived int64, bytesSent int64, periodStart time.Time) {
+	if !t.PeriodStart.Equal(periodStart) {
+		t.PeriodStart = periodStart
+		t.PeriodBytesReceived = 0
+		t.PeriodBytesSent = 0
+	}
+
+	deltaReceived := bytesReceived
+	deltaSent := bytesSent
+	if index == t.LastIndex && bytesReceived >= t.LastBytesReceived && bytesSent >= t.LastBytesSent {
+		deltaReceived -= t.LastBytesReceived
+		deltaSent -= t.LastBytesSent
+	}
+
+	t.BytesReceived += deltaReceived
+	t.BytesSent += deltaSent
+	t.PeriodBytesReceived += deltaReceived
+	t.PeriodBytesSent += deltaSent
+
+	t.LastIndex = index
+	t.LastBytesReceived = bytesReceived
+	t.LastBytesSent = bytesSent
+}
+
+// PeriodUsage returns the combined bytes received and sent within the specified billing period.
+func (t *NICTransfer) PeriodUsage(periodStart time.Time) int64 {
+	if !t.PeriodStart.Equal(periodStart) {
+		return 0
+	}
+
+	return t.PeriodBytesReceived + t.PeriodBytesSent
+}
+
+// NICTransferResetDay returns the day of the month the transfer billing period starts on from instance config.
+func NICTransferResetDay(config map[string]string) int {
+	day, err := strconv.Atoi(config["limits.network.transfer.reset_day"])
+	if err != nil || day < 1 || day > 28 {
+		return 1
+	}
+
+	return day
+}
+
+// NICTransferPeriodStart returns the start of the monthly billing period containing the specified time when
+// periods start on the specified day of the month (in UTC).
+func NICTransferPeriodStart(now time.Time, resetDay int) time.Time {
+	now = now.UTC()
+
+	start := time.Date(now.Year(), now.Month(), resetDay, 0, 0, 0, 0, time.UTC)
+	if start.After(now) {
+		start = start.AddDate(0, -1, 0)
+	}
+
+	return start
+}
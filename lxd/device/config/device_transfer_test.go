@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNICTransferPeriodStart(t *testing.T) {
+	tests := []struct {
+		now      time.Time
+		resetDay int
+		expected time.Time
+	}{
+		{time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC), 1, time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), 1, time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC), 20, time.Date(2022, 6, 20, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 1, 5, 12, 0, 0, 0, time.UTC), 10, time.Date(2021, 12, 10, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2022, 3, 31, 23, 0, 0, 0, time.FixedZone("", -2*3600)), 1, time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for i, tt := range tests {
+		result := NICTransferPeriodStart(tt.now, tt.resetDay)
+		if !result.Equal(tt.expected) {
+			t.Errorf("Test %d: expected %v, got %v", i, tt.expected, result)
+		}
+	}
+}
+
+func TestNICTransferAccount(t *testing.T) {
+	period1 := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	period2 := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	transfer := NICTransferFromVolatile(map[string]string{}, "eth0")
+
+	// First update accounts for all traffic seen on the interface.
+	transfer.Account(5, 100, 50, period1)
+	if transfer.BytesReceived != 100 || transfer.BytesSent != 50 || transfer.PeriodUsage(period1) != 150 {
+		t.Errorf("Unexpected counters after first update: %+v", transfer)
+	}
+
+	// Subsequent updates on the same interface only account for the difference.
+	transfer.Account(5, 150, 60, period1)
+	if transfer.BytesReceived != 150 || transfer.BytesSent != 60 || transfer.PeriodUsage(period1) != 210 {
+		t.Errorf("Unexpected counters after second update: %+v", transfer)
+	}
+
+	// A recreated interface accounts for all of its traffic.
+	transfer.Account(7, 10, 20, period1)
+	if transfer.BytesReceived != 160 || transfer.BytesSent != 80 || transfer.PeriodUsage(period1) != 240 {
+		t.Errorf("Unexpected counters after interface change: %+v", transfer)
+	}
+
+	// Counters going backwards on the same interface index also indicate a new interface.
+	transfer.Account(7, 5, 25, period1)
+	if transfer.BytesReceived != 165 || transfer.BytesSent != 105 {
+		t.Errorf("Unexpected counters after counter reset: %+v", transfer)
+	}
+
+	// A new period resets the period counters but not the totals.
+	transfer.Account(7, 15, 30, period2)
+	if transfer.BytesReceived != 175 || transfer.BytesSent != 110 || transfer.PeriodUsage(period2) != 15 || transfer.PeriodUsage(period1) != 0 {
+		t.Errorf("Unexpected counters after period change: %+v", transfer)
+	}
+
+	// The state survives a round trip through volatile config.
+	config := map[string]string{}
+	for k, v := range transfer.Volatile() {
+		if v != "" {
+			config["volatile.eth0."+k] = v
+		}
+	}
+
+	loaded := NICTransferFromVolatile(config, "eth0")
+	if !reflect.DeepEqual(transfer, loaded) {
+		t.Errorf("Expected %+v after loading from volatile config, got %+v", transfer, loaded)
+	}
+}
+
+func TestIsNICTransferKey(t *testing.T) {
+	tests := map[string]bool{
+		"volatile.eth0.transfer.rx":           true,
+		"volatile.eth0.transfer.period.start": true,
+		"volatile.eth0.transfer.limited":      true,
+		"volatile.eth0.host_name":             false,
+		"limits.network.transfer":             false,
+		"user.transfer.rx":                    false,
+	}
+
+	for key, want := range tests {
+		if IsNICTransferKey(key) != want {
+			t.Errorf("Expected IsNICTransferKey(%q) to be %v", key, want)
+		}
+	}
+}
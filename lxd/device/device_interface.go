@@ -2,6 +2,7 @@ package device
 
 import (
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared/api"
@@ -86,3 +87,8 @@ type device interface {
 type NICState interface {
 	State() (*api.InstanceStateNetwork, error)
 }
+
+// NICFirewall provides the ability to generate the host firewall rules of a NIC.
+type NICFirewall interface {
+	FirewallRuleset() (*firewallDrivers.Ruleset, error)
+}
@@ -428,7 +428,8 @@ func networkNICRouteDelete(routeDev string, routes ...string) {
 }
 
 // networkSetupHostVethLimits applies any network rate limits to the veth device specified in the config.
-func networkSetupHostVethLimits(m deviceConfig.Device) error {
+// If mirror is not nil then the device's traffic is also mirrored to the mirror's target interface.
+func networkSetupHostVethLimits(m deviceConfig.Device, mirror *networkMirror) error {
 	var err error
 
 	veth := m["host_name"]
@@ -466,6 +467,20 @@ func networkSetupHostVethLimits(m deviceConfig.Device) error {
 	qdisc = &ip.Qdisc{Dev: veth, Ingress: true}
 	_ = qdisc.Delete()
 
+	// Traffic received by the instance is sent by the host-side interface and vice versa.
+	var ingressActions, egressActions []ip.Action
+	if mirror != nil {
+		mirred := &ip.ActionMirred{Direction: "egress", Action: "mirror", Dev: mirror.dev, Control: "pipe"}
+
+		if mirror.ingress {
+			ingressActions = append(ingressActions, mirred)
+		}
+
+		if mirror.egress {
+			egressActions = append(egressActions, mirred)
+		}
+	}
+
 	// Apply new limits
 	if m["limits.ingress"] != "" {
 		qdiscHTB := &ip.QdiscHTB{Qdisc: ip.Qdisc{Dev: veth, Handle: "1:0", Root: true}, Default: "10"}
@@ -480,22 +495,38 @@ func networkSetupHostVethLimits(m deviceConfig.Device) error {
 			return fmt.Errorf("Failed to create limit tc class: %s", err)
 		}
 
-		filter := &ip.U32Filter{Filter: ip.Filter{Dev: veth, Parent: "1:0", Protocol: "all", Flowid: "1:1"}, Value: "0", Mask: "0"}
+		filter := &ip.U32Filter{Filter: ip.Filter{Dev: veth, Parent: "1:0", Protocol: "all", Flowid: "1:1"}, Value: "0", Mask: "0", Actions: ingressActions}
 		err = filter.Add()
 		if err != nil {
 			return fmt.Errorf("Failed to create tc filter: %s", err)
 		}
+	} else if len(ingressActions) > 0 {
+		qdiscPrio := &ip.QdiscPrio{Qdisc: ip.Qdisc{Dev: veth, Handle: "1:0", Root: true}}
+		err := qdiscPrio.Add()
+		if err != nil {
+			return fmt.Errorf("Failed to create root tc qdisc: %s", err)
+		}
+
+		filter := &ip.U32Filter{Filter: ip.Filter{Dev: veth, Parent: "1:0", Protocol: "all"}, Value: "0", Mask: "0", Actions: ingressActions}
+		err = filter.Add()
+		if err != nil {
+			return fmt.Errorf("Failed to create tc mirror filter: %s", err)
+		}
 	}
 
 	if m["limits.egress"] != "" {
+		police := &ip.ActionPolice{Rate: fmt.Sprintf("%dbit", egressInt), Burst: "1024k", Mtu: "64kb", Drop: true}
+		egressActions = append(egressActions, police)
+	}
+
+	if len(egressActions) > 0 {
 		qdisc = &ip.Qdisc{Dev: veth, Handle: "ffff:0", Ingress: true}
 		err := qdisc.Add()
 		if err != nil {
 			return fmt.Errorf("Failed to create ingress tc qdisc: %s", err)
 		}
 
-		police := &ip.ActionPolice{Rate: fmt.Sprintf("%dbit", egressInt), Burst: "1024k", Mtu: "64kb", Drop: true}
-		filter := &ip.U32Filter{Filter: ip.Filter{Dev: veth, Parent: "ffff:0", Protocol: "all"}, Value: "0", Mask: "0", Actions: []ip.Action{police}}
+		filter := &ip.U32Filter{Filter: ip.Filter{Dev: veth, Parent: "ffff:0", Protocol: "all"}, Value: "0", Mask: "0", Actions: egressActions}
 		err = filter.Add()
 		if err != nil {
 			return fmt.Errorf("Failed to create ingress tc filter: %s", err)
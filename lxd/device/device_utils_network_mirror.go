@@ -0,0 +1,223 @@
+package device
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/device/nictype"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/validate"
+)
+
+// networkMirror represents the port mirroring settings of a NIC's host-side interface.
+// Ingress and egress are from the point of view of the instance.
+type networkMirror struct {
+	dev     string // Host interface the traffic is copied to.
+	ingress bool   // Whether traffic received by the instance is copied.
+	egress  bool   // Whether traffic sent by the instance is copied.
+}
+
+// networkValidMirror validates a NIC mirror target, either a host interface name or "<instance>/<device>".
+func networkValidMirror(value string) error {
+	instName, devName, found := strings.Cut(value, "/")
+	if !found {
+		return validate.IsInterfaceName(value)
+	}
+
+	err := instance.ValidName(instName, false)
+	if err != nil {
+		return fmt.Errorf("Invalid mirror target instance: %w", err)
+	}
+
+	err = validate.IsDeviceName(devName)
+	if err != nil {
+		return fmt.Errorf("Invalid mirror target device: %w", err)
+	}
+
+	return nil
+}
+
+// networkMirrorDirection returns whether traffic received and sent by the instance should be mirrored.
+func networkMirrorDirection(m deviceConfig.Device) (bool, bool) {
+	switch m["mirror.direction"] {
+	case "ingress":
+		return true, false
+	case "egress":
+		return false, true
+	default:
+		return true, true
+	}
+}
+
+// networkMirrorSource identifies a NIC that mirrors its traffic to another instance's NIC.
+type networkMirrorSource struct {
+	project  string
+	instance string
+	device   string
+}
+
+// networkMirrorSources indexes the NICs mirroring their traffic to another instance's NIC by the mirror target
+// ("<project>/<instance>/<device>"), so that only those are refreshed when the target NIC is started.
+var networkMirrorSources = map[string]map[networkMirrorSource]struct{}{}
+var networkMirrorSourcesMu sync.Mutex
+
+// networkMirrorTarget returns the key of the NIC targeted by the mirror config in networkMirrorSources.
+// Returns empty string if the NIC isn't mirrored to another instance's NIC.
+func networkMirrorTarget(projectName string, m deviceConfig.Device) string {
+	instName, devName, found := strings.Cut(m["mirror"], "/")
+	if !found {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s/%s", projectName, instName, devName)
+}
+
+// networkMirrorRegister records that the NIC mirrors its traffic to another instance's NIC, if it does.
+func networkMirrorRegister(inst instance.Instance, devName string, m deviceConfig.Device) {
+	target := networkMirrorTarget(inst.Project(), m)
+	if target == "" {
+		return
+	}
+
+	networkMirrorSourcesMu.Lock()
+	defer networkMirrorSourcesMu.Unlock()
+
+	if networkMirrorSources[target] == nil {
+		networkMirrorSources[target] = map[networkMirrorSource]struct{}{}
+	}
+
+	networkMirrorSources[target][networkMirrorSource{project: inst.Project(), instance: inst.Name(), device: devName}] = struct{}{}
+}
+
+// networkMirrorUnregister removes the NIC from the mirror targets it was recorded against.
+func networkMirrorUnregister(inst instance.Instance, devName string) {
+	source := networkMirrorSource{project: inst.Project(), instance: inst.Name(), device: devName}
+
+	networkMirrorSourcesMu.Lock()
+	defer networkMirrorSourcesMu.Unlock()
+
+	for target, sources := range networkMirrorSources {
+		delete(sources, source)
+		if len(sources) == 0 {
+			delete(networkMirrorSources, target)
+		}
+	}
+}
+
+// networkMirrorGet returns the port mirroring settings of the NIC. Returns nil if the NIC isn't mirrored or if the
+// mirror target isn't currently available on this server (such as when the target instance isn't running).
+// NICs mirrored to another instance's NIC are recorded so their mirroring is reapplied when the target is started.
+func networkMirrorGet(s *state.State, inst instance.Instance, devName string, m deviceConfig.Device) (*networkMirror, error) {
+	if m["mirror"] == "" {
+		return nil, nil
+	}
+
+	projectName := inst.Project()
+	mirror := &networkMirror{}
+	mirror.ingress, mirror.egress = networkMirrorDirection(m)
+
+	instName, targetDevName, found := strings.Cut(m["mirror"], "/")
+	if found {
+		networkMirrorRegister(inst, devName, m)
+
+		targetInst, err := instance.LoadByProjectAndName(s, projectName, instName)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return nil, fmt.Errorf("Failed loading mirror target instance %q: %w", instName, err)
+		}
+
+		if targetInst != nil && targetInst.IsRunning() {
+			mirror.dev = targetInst.LocalConfig()[fmt.Sprintf("volatile.%s.host_name", targetDevName)]
+			if mirror.dev == "" {
+				mirror.dev = targetInst.ExpandedDevices()[targetDevName]["host_name"]
+			}
+		}
+	} else {
+		mirror.dev = m["mirror"]
+	}
+
+	if mirror.dev == "" || mirror.dev == m["host_name"] || !network.InterfaceExists(mirror.dev) {
+		logger.Warn("Network mirror target unavailable, not mirroring traffic", logger.Ctx{"project": projectName, "mirror": m["mirror"], "host_name": m["host_name"]})
+		return nil, nil
+	}
+
+	return mirror, nil
+}
+
+// networkMirrorRefresh re-applies the port mirroring of the NICs of other running instances that mirror their
+// traffic to the specified NIC. This is needed as mirroring is tied to the target's host-side interface which is
+// recreated each time the target NIC is started.
+func networkMirrorRefresh(s *state.State, target instance.Instance, targetDevName string, targetHostName string) error {
+	targetKey := fmt.Sprintf("%s/%s/%s", target.Project(), target.Name(), targetDevName)
+
+	networkMirrorSourcesMu.Lock()
+	sources := make([]networkMirrorSource, 0, len(networkMirrorSources[targetKey]))
+	for source := range networkMirrorSources[targetKey] {
+		sources = append(sources, source)
+	}
+
+	networkMirrorSourcesMu.Unlock()
+
+	for _, source := range sources {
+		inst, err := instance.LoadByProjectAndName(s, source.project, source.instance)
+		if err != nil && !api.StatusErrorCheck(err, http.StatusNotFound) {
+			return err
+		}
+
+		// Forget about NICs which no longer exist or are no longer mirrored to the target.
+		m := deviceConfig.Device(nil)
+		if inst != nil && inst.IsRunning() {
+			m = inst.ExpandedDevices()[source.device]
+		}
+
+		if m == nil || m["type"] != "nic" || networkMirrorTarget(source.project, m) != targetKey {
+			networkMirrorSourcesMu.Lock()
+			delete(networkMirrorSources[targetKey], source)
+			networkMirrorSourcesMu.Unlock()
+			continue
+		}
+
+		nicType, err := nictype.NICType(s, inst.Project(), m)
+		if err != nil {
+			return err
+		}
+
+		if !shared.StringInSlice(nicType, networkTransferNICTypes) {
+			continue
+		}
+
+		m = m.Clone()
+		if m["host_name"] == "" {
+			m["host_name"] = inst.LocalConfig()[fmt.Sprintf("volatile.%s.host_name", source.device)]
+		}
+
+		if m["host_name"] == "" || !network.InterfaceExists(m["host_name"]) {
+			continue
+		}
+
+		mirror := &networkMirror{dev: targetHostName}
+		mirror.ingress, mirror.egress = networkMirrorDirection(m)
+
+		// Preserve any transfer limit currently applied to the NIC.
+		_, _, throttle, err := networkTransferLimit(inst.ExpandedConfig())
+		if err != nil {
+			return err
+		}
+
+		limited := inst.LocalConfig()[fmt.Sprintf("volatile.%s.transfer.limited", source.device)]
+
+		err = networkSetupHostVethTransferLimit(m, mirror, limited, throttle)
+		if err != nil {
+			return fmt.Errorf("Failed applying mirroring to NIC %q of instance %q: %w", source.device, inst.Name(), err)
+		}
+	}
+
+	return nil
+}
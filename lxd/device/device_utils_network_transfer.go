@@ -0,0 +1,266 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/db/cluster"
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/device/nictype"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/ip"
+	"github.com/lxc/lxd/lxd/network"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
+)
+
+// networkTransferLock serialises NIC transfer accounting updates so that periodic updates and NICs being started
+// or stopped don't overwrite each other's counters.
+var networkTransferLock sync.Mutex
+
+// networkTransferNICTypes are the NIC types whose traffic can be accounted for using their host-side interface.
+var networkTransferNICTypes = []string{"bridged", "p2p", "routed"}
+
+// networkTransferMode indicates why a NIC's transfer accounting is being updated.
+type networkTransferMode int
+
+const (
+	// networkTransferPeriodic is used for periodic updates, the limit is only changed if its state has changed.
+	networkTransferPeriodic networkTransferMode = iota
+
+	// networkTransferStarted is used when the host-side interface's limits have just been (re)applied, so any
+	// transfer limit that is reached needs applying again.
+	networkTransferStarted
+
+	// networkTransferStopping is used when the host-side interface is about to be removed.
+	networkTransferStopping
+)
+
+// NetworkTransferUpdate accounts for the traffic of the instance's NIC devices since the last update and applies
+// or removes the instance's transfer limit as needed. Only NICs that have a host-side interface are accounted for.
+func NetworkTransferUpdate(s *state.State, inst instance.Instance) error {
+	for _, dev := range inst.ExpandedDevices().Sorted() {
+		if dev.Config["type"] != "nic" {
+			continue
+		}
+
+		nicType, err := nictype.NICType(s, inst.Project(), dev.Config)
+		if err != nil {
+			return err
+		}
+
+		if !shared.StringInSlice(nicType, networkTransferNICTypes) {
+			continue
+		}
+
+		m := dev.Config.Clone()
+		if m["host_name"] == "" {
+			m["host_name"] = inst.LocalConfig()[fmt.Sprintf("volatile.%s.host_name", dev.Name)]
+		}
+
+		err = networkTransferUpdate(s, inst, dev.Name, m, networkTransferPeriodic)
+		if err != nil {
+			return fmt.Errorf("Failed updating transfer accounting for NIC %q: %w", dev.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// networkTransferUpdate accounts for the traffic seen on the NIC's host-side interface since the last update and
+// applies or removes the instance's transfer limit on it as needed.
+func networkTransferUpdate(s *state.State, inst instance.Instance, devName string, m deviceConfig.Device, mode networkTransferMode) error {
+	hostName := m["host_name"]
+	if hostName == "" || !network.InterfaceExists(hostName) {
+		return nil
+	}
+
+	limit, action, throttle, err := networkTransferLimit(inst.ExpandedConfig())
+	if err != nil {
+		return err
+	}
+
+	networkTransferLock.Lock()
+	defer networkTransferLock.Unlock()
+
+	// Load the counters from the database rather than the instance as they may have been updated since the
+	// instance was loaded.
+	var config map[string]string
+	err = s.DB.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
+		config, err = cluster.GetInstanceConfig(ctx, tx.Tx(), inst.ID())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Failed loading instance config: %w", err)
+	}
+
+	index, bytesReceived, bytesSent, err := networkHostInterfaceCounters(hostName)
+	if err != nil {
+		return err
+	}
+
+	transfer := deviceConfig.NICTransferFromVolatile(config, devName)
+	periodStart := deviceConfig.NICTransferPeriodStart(time.Now(), deviceConfig.NICTransferResetDay(inst.ExpandedConfig()))
+	transfer.Account(index, bytesReceived, bytesSent, periodStart)
+
+	if mode == networkTransferStopping {
+		// The interface and any limit applied to it are about to go away.
+		transfer.LastIndex = 0
+		transfer.Limited = ""
+	} else {
+		limited := ""
+		if limit > 0 && transfer.PeriodUsage(periodStart) >= limit {
+			limited = action
+		}
+
+		if limited != transfer.Limited || (mode == networkTransferStarted && limited != "") {
+			mirror, err := networkMirrorGet(s, inst, devName, m)
+			if err != nil {
+				return err
+			}
+
+			err = networkSetupHostVethTransferLimit(m, mirror, limited, throttle)
+			if err != nil {
+				return fmt.Errorf("Failed applying transfer limit: %w", err)
+			}
+
+			if limited != transfer.Limited {
+				logger.Info("Network transfer limit changed", logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "device": devName, "action": limited, "usage": transfer.PeriodUsage(periodStart), "limit": limit})
+			}
+
+			transfer.Limited = limited
+		}
+	}
+
+	volatile := map[string]string{}
+	for k, v := range transfer.Volatile() {
+		volatile[fmt.Sprintf("volatile.%s.%s", devName, k)] = v
+	}
+
+	return inst.VolatileSet(volatile)
+}
+
+// networkTransferStop accounts for the traffic of the NIC's host-side interface before it is removed.
+func networkTransferStop(d *deviceCommon) {
+	m := d.config.Clone()
+	networkVethFillFromVolatile(m, d.volatileGet())
+
+	err := networkTransferUpdate(d.state, d.inst, d.name, m, networkTransferStopping)
+	if err != nil {
+		d.logger.Warn("Failed updating network transfer accounting", logger.Ctx{"err": err})
+	}
+}
+
+// networkTransferLimit returns the per-NIC transfer limit in bytes (0 if none), the action to take once it has
+// been reached and the rate to throttle to from the instance config.
+func networkTransferLimit(config map[string]string) (int64, string, string, error) {
+	if config["limits.network.transfer"] == "" {
+		return 0, "", "", nil
+	}
+
+	limit, err := units.ParseByteSizeString(config["limits.network.transfer"])
+	if err != nil {
+		return 0, "", "", fmt.Errorf("Invalid limits.network.transfer: %w", err)
+	}
+
+	action := config["limits.network.transfer.action"]
+	if action == "" {
+		action = "throttle"
+	}
+
+	throttle := config["limits.network.transfer.throttle"]
+	if throttle == "" {
+		throttle = "1Mbit"
+	}
+
+	return limit, action, throttle, nil
+}
+
+// networkHostInterfaceCounters returns the index of the host-side interface of a NIC along with its bytes received
+// and sent counters from the point of view of the instance (traffic sent by the host-side interface is received
+// by the instance and vice versa).
+func networkHostInterfaceCounters(hostName string) (int64, int64, int64, error) {
+	readValue := func(name string) (int64, error) {
+		content, err := ioutil.ReadFile(filepath.Join("/sys/class/net", hostName, name))
+		if err != nil {
+			return -1, err
+		}
+
+		return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	}
+
+	index, err := readValue("ifindex")
+	if err != nil {
+		return -1, -1, -1, fmt.Errorf("Failed getting index of %q: %w", hostName, err)
+	}
+
+	hostReceived, err := readValue("statistics/rx_bytes")
+	if err != nil {
+		return -1, -1, -1, fmt.Errorf("Failed getting received bytes of %q: %w", hostName, err)
+	}
+
+	hostSent, err := readValue("statistics/tx_bytes")
+	if err != nil {
+		return -1, -1, -1, fmt.Errorf("Failed getting sent bytes of %q: %w", hostName, err)
+	}
+
+	return index, hostSent, hostReceived, nil
+}
+
+// networkSetupHostVethTransferLimit applies the specified transfer limit action to the host-side interface of a NIC.
+// An empty action restores the NIC's normal limits and state. Any port mirroring is applied alongside the limits.
+func networkSetupHostVethTransferLimit(m deviceConfig.Device, mirror *networkMirror, action string, throttle string) error {
+	link := &ip.Link{Name: m["host_name"]}
+
+	switch action {
+	case "disconnect":
+		return link.SetDown()
+	case "throttle":
+		throttleInt, err := units.ParseBitSizeString(throttle)
+		if err != nil {
+			return err
+		}
+
+		limits := m.Clone()
+		if limits["limits.max"] != "" {
+			limits["limits.ingress"] = limits["limits.max"]
+			limits["limits.egress"] = limits["limits.max"]
+			delete(limits, "limits.max")
+		}
+
+		// Only lower the existing limits.
+		for _, key := range []string{"limits.ingress", "limits.egress"} {
+			if limits[key] != "" {
+				limitInt, err := units.ParseBitSizeString(limits[key])
+				if err == nil && limitInt <= throttleInt {
+					continue
+				}
+			}
+
+			limits[key] = throttle
+		}
+
+		err = networkSetupHostVethLimits(limits, mirror)
+		if err != nil {
+			return err
+		}
+	case "":
+		err := networkSetupHostVethLimits(m.Clone(), mirror)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Invalid transfer limit action %q", action)
+	}
+
+	return link.SetUp()
+}
@@ -25,6 +25,8 @@ func nicValidationRules(requiredFields []string, optionalFields []string, instCo
 		"limits.ingress":                       validate.IsAny,
 		"limits.egress":                        validate.IsAny,
 		"limits.max":                           validate.IsAny,
+		"mirror":                               networkValidMirror,
+		"mirror.direction":                     validate.Optional(validate.IsOneOf("ingress", "egress", "both")),
 		"security.mac_filtering":               validate.IsAny,
 		"security.ipv4_filtering":              validate.IsAny,
 		"security.ipv6_filtering":              validate.IsAny,
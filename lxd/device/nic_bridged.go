@@ -22,6 +22,7 @@ import (
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/dnsmasq"
 	"github.com/lxc/lxd/lxd/dnsmasq/dhcpalloc"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/ip"
@@ -74,6 +75,8 @@ func (d *nicBridged) validateConfig(instConf instance.ConfigReader) error {
 		"limits.ingress",
 		"limits.egress",
 		"limits.max",
+		"mirror",
+		"mirror.direction",
 		"ipv4.address",
 		"ipv6.address",
 		"ipv4.routes",
@@ -470,7 +473,7 @@ func (d *nicBridged) UpdatableFields(oldDevice Type) []string {
 		return []string{}
 	}
 
-	return []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes", "ipv4.routes.external", "ipv6.routes.external", "ipv4.address", "ipv6.address", "security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering"}
+	return []string{"limits.ingress", "limits.egress", "limits.max", "mirror", "mirror.direction", "ipv4.routes", "ipv6.routes", "ipv4.routes.external", "ipv6.routes.external", "ipv4.address", "ipv6.address", "security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering"}
 }
 
 // Add is run when a device is added to a non-snapshot instance whether or not the instance is running.
@@ -570,12 +573,29 @@ func (d *nicBridged) Start() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
-	// Apply host-side limits.
-	err = networkSetupHostVethLimits(d.config)
+	// Apply host-side limits and port mirroring.
+	mirror, err := networkMirrorGet(d.state, d.inst, d.name, d.config)
 	if err != nil {
 		return nil, err
 	}
 
+	err = networkSetupHostVethLimits(d.config, mirror)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply any network transfer limit the instance has reached.
+	err = networkTransferUpdate(d.state, d.inst, d.name, d.config, networkTransferStarted)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reapply the port mirroring of any NICs mirroring their traffic to this NIC.
+	err = networkMirrorRefresh(d.state, d.inst, d.name, d.config["host_name"])
+	if err != nil {
+		d.logger.Warn("Failed refreshing network mirroring to NIC", logger.Ctx{"err": err})
+	}
+
 	// Disable IPv6 on host-side veth interface (prevents host-side interface getting link-local address)
 	// which isn't needed because the host-side interface is connected to a bridge.
 	err = util.SysctlSet(fmt.Sprintf("net/ipv6/conf/%s/disable_ipv6", saveData["host_name"]), "1")
@@ -754,8 +774,19 @@ func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) err
 			return err
 		}
 
-		// Apply host-side limits.
-		err = networkSetupHostVethLimits(d.config)
+		// Apply host-side limits and port mirroring.
+		mirror, err := networkMirrorGet(d.state, d.inst, d.name, d.config)
+		if err != nil {
+			return err
+		}
+
+		err = networkSetupHostVethLimits(d.config, mirror)
+		if err != nil {
+			return err
+		}
+
+		// Reapply any network transfer limit the instance has reached.
+		err = networkTransferUpdate(d.state, d.inst, d.name, d.config, networkTransferStarted)
 		if err != nil {
 			return err
 		}
@@ -808,6 +839,9 @@ func (d *nicBridged) Update(oldDevices deviceConfig.Devices, isRunning bool) err
 
 // Stop is run when the device is removed from the instance.
 func (d *nicBridged) Stop() (*deviceConfig.RunConfig, error) {
+	networkTransferStop(&d.deviceCommon)
+	networkMirrorUnregister(d.inst, d.name)
+
 	// Remove BGP announcements.
 	err := bgpRemovePrefix(&d.deviceCommon, d.config)
 	if err != nil {
@@ -1157,6 +1191,57 @@ func (d *nicBridged) setFilters() (err error) {
 	return nil
 }
 
+// FirewallRuleset returns the host firewall rules generated from the current config of the device alongside the
+// rules loaded in the kernel.
+func (d *nicBridged) FirewallRuleset() (*firewallDrivers.Ruleset, error) {
+	config := d.config.Clone()
+	networkVethFillFromVolatile(config, d.volatileGet())
+
+	opts := firewallDrivers.InstanceOpts{HostName: config["host_name"]}
+
+	if opts.HostName != "" && (shared.IsTrue(config["security.mac_filtering"]) || shared.IsTrue(config["security.ipv4_filtering"]) || shared.IsTrue(config["security.ipv6_filtering"])) {
+		// Filter the IPs allocated by the managed parent when not specified, as done by setFilters.
+		if d.network != nil && (config["ipv4.address"] == "" || config["ipv6.address"] == "") {
+			deviceStaticFileName := dnsmasq.StaticAllocationFileName(d.inst.Project(), d.inst.Name(), d.Name())
+			_, IPv4Alloc, IPv6Alloc, err := dnsmasq.DHCPStaticAllocation(config["parent"], deviceStaticFileName)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("Failed getting static IP allocations: %w", err)
+			}
+
+			if shared.IsTrue(config["security.ipv4_filtering"]) && config["ipv4.address"] == "" {
+				if d.network.DHCPv4Subnet() == nil {
+					config["ipv4.address"] = "none"
+				} else if IPv4Alloc.IP != nil {
+					config["ipv4.address"] = IPv4Alloc.IP.String()
+				}
+			}
+
+			if shared.IsTrue(config["security.ipv6_filtering"]) && config["ipv6.address"] == "" {
+				if d.network.DHCPv6Subnet() == nil {
+					config["ipv6.address"] = "none"
+				} else if IPv6Alloc.IP != nil {
+					config["ipv6.address"] = IPv6Alloc.IP.String()
+				}
+			}
+		}
+
+		IPv4Nets, IPv6Nets, err := allowedIPNets(config)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.BridgeFilter = &firewallDrivers.BridgeFilterOpts{
+			ParentName:    config["parent"],
+			HWAddr:        config["hwaddr"],
+			IPv4Nets:      IPv4Nets,
+			IPv6Nets:      IPv6Nets,
+			ParentManaged: d.network != nil,
+		}
+	}
+
+	return d.state.Firewall.InstanceRuleset(d.inst.Project(), d.inst.Name(), d.name, opts)
+}
+
 // allowedIPNets accepts a device config. For each IP version it returns nil if all addresses should be allowed,
 // an empty slice if all addresses should be blocked, and a populated slice of subnets to allow traffic from specific ranges.
 func allowedIPNets(config deviceConfig.Device) (IPv4Nets []*net.IPNet, IPv6Nets []*net.IPNet, err error) {
@@ -1767,5 +1852,7 @@ func (d *nicBridged) Register() error {
 		return err
 	}
 
+	networkMirrorRegister(d.inst, d.name, d.config)
+
 	return nil
 }
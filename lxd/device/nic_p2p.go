@@ -9,6 +9,7 @@ import (
 	"github.com/lxc/lxd/lxd/network"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
 )
 
 type nicP2P struct {
@@ -34,6 +35,8 @@ func (d *nicP2P) validateConfig(instConf instance.ConfigReader) error {
 		"limits.ingress",
 		"limits.egress",
 		"limits.max",
+		"mirror",
+		"mirror.direction",
 		"ipv4.routes",
 		"ipv6.routes",
 		"boot.priority",
@@ -64,7 +67,14 @@ func (d *nicP2P) UpdatableFields(oldDevice Type) []string {
 		return []string{}
 	}
 
-	return []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes"}
+	return []string{"limits.ingress", "limits.egress", "limits.max", "mirror", "mirror.direction", "ipv4.routes", "ipv6.routes"}
+}
+
+// Register sets up anything needed on LXD startup.
+func (d *nicP2P) Register() error {
+	networkMirrorRegister(d.inst, d.name, d.config)
+
+	return nil
 }
 
 // Start is run when the device is added to a running instance or instance is starting up.
@@ -120,12 +130,29 @@ func (d *nicP2P) Start() (*deviceConfig.RunConfig, error) {
 		return nil, err
 	}
 
-	// Apply host-side limits.
-	err = networkSetupHostVethLimits(d.config)
+	// Apply host-side limits and port mirroring.
+	mirror, err := networkMirrorGet(d.state, d.inst, d.name, d.config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = networkSetupHostVethLimits(d.config, mirror)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply any network transfer limit the instance has reached.
+	err = networkTransferUpdate(d.state, d.inst, d.name, d.config, networkTransferStarted)
 	if err != nil {
 		return nil, err
 	}
 
+	// Reapply the port mirroring of any NICs mirroring their traffic to this NIC.
+	err = networkMirrorRefresh(d.state, d.inst, d.name, d.config["host_name"])
+	if err != nil {
+		d.logger.Warn("Failed refreshing network mirroring to NIC", logger.Ctx{"err": err})
+	}
+
 	err = d.volatileSet(saveData)
 	if err != nil {
 		return nil, err
@@ -179,8 +206,19 @@ func (d *nicP2P) Update(oldDevices deviceConfig.Devices, isRunning bool) error {
 		return err
 	}
 
-	// Apply host-side limits.
-	err = networkSetupHostVethLimits(d.config)
+	// Apply host-side limits and port mirroring.
+	mirror, err := networkMirrorGet(d.state, d.inst, d.name, d.config)
+	if err != nil {
+		return err
+	}
+
+	err = networkSetupHostVethLimits(d.config, mirror)
+	if err != nil {
+		return err
+	}
+
+	// Reapply any network transfer limit the instance has reached.
+	err = networkTransferUpdate(d.state, d.inst, d.name, d.config, networkTransferStarted)
 	if err != nil {
 		return err
 	}
@@ -190,6 +228,9 @@ func (d *nicP2P) Update(oldDevices deviceConfig.Devices, isRunning bool) error {
 
 // Stop is run when the device is removed from the instance.
 func (d *nicP2P) Stop() (*deviceConfig.RunConfig, error) {
+	networkTransferStop(&d.deviceCommon)
+	networkMirrorUnregister(d.inst, d.name)
+
 	runConf := deviceConfig.RunConfig{
 		PostHooks: []func() error{d.postStop},
 	}
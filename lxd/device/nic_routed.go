@@ -9,6 +9,7 @@ import (
 	"time"
 
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/ip"
@@ -43,7 +44,7 @@ func (d *nicRouted) UpdatableFields(oldDevice Type) []string {
 		return []string{}
 	}
 
-	return []string{"limits.ingress", "limits.egress", "limits.max"}
+	return []string{"limits.ingress", "limits.egress", "limits.max", "mirror", "mirror.direction"}
 }
 
 // validateConfig checks the supplied config for correctness.
@@ -68,6 +69,8 @@ func (d *nicRouted) validateConfig(instConf instance.ConfigReader) error {
 		"limits.ingress",
 		"limits.egress",
 		"limits.max",
+		"mirror",
+		"mirror.direction",
 		"ipv4.gateway",
 		"ipv6.gateway",
 		"ipv4.routes",
@@ -262,6 +265,13 @@ func (d *nicRouted) checkIPAvailability(parent string) error {
 	return nil
 }
 
+// Register sets up anything needed on LXD startup.
+func (d *nicRouted) Register() error {
+	networkMirrorRegister(d.inst, d.name, d.config)
+
+	return nil
+}
+
 // Start is run when the instance is starting up (Routed mode doesn't support hot plugging).
 func (d *nicRouted) Start() (*deviceConfig.RunConfig, error) {
 	err := d.validateEnvironment()
@@ -344,12 +354,29 @@ func (d *nicRouted) Start() (*deviceConfig.RunConfig, error) {
 	// Populate device config with volatile fields if needed.
 	networkVethFillFromVolatile(d.config, saveData)
 
-	// Apply host-side limits.
-	err = networkSetupHostVethLimits(d.config)
+	// Apply host-side limits and port mirroring.
+	mirror, err := networkMirrorGet(d.state, d.inst, d.name, d.config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = networkSetupHostVethLimits(d.config, mirror)
 	if err != nil {
 		return nil, err
 	}
 
+	// Apply any network transfer limit the instance has reached.
+	err = networkTransferUpdate(d.state, d.inst, d.name, d.config, networkTransferStarted)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reapply the port mirroring of any NICs mirroring their traffic to this NIC.
+	err = networkMirrorRefresh(d.state, d.inst, d.name, d.config["host_name"])
+	if err != nil {
+		d.logger.Warn("Failed refreshing network mirroring to NIC", logger.Ctx{"err": err})
+	}
+
 	// Attempt to disable IPv6 router advertisement acceptance from instance.
 	err = util.SysctlSet(fmt.Sprintf("net/ipv6/conf/%s/accept_ra", saveData["host_name"]), "0")
 	if err != nil && !os.IsNotExist(err) {
@@ -576,8 +603,19 @@ func (d *nicRouted) Update(oldDevices deviceConfig.Devices, isRunning bool) erro
 		// Populate device config with volatile fields if needed.
 		networkVethFillFromVolatile(d.config, v)
 
-		// Apply host-side limits.
-		err = networkSetupHostVethLimits(d.config)
+		// Apply host-side limits and port mirroring.
+		mirror, err := networkMirrorGet(d.state, d.inst, d.name, d.config)
+		if err != nil {
+			return err
+		}
+
+		err = networkSetupHostVethLimits(d.config, mirror)
+		if err != nil {
+			return err
+		}
+
+		// Reapply any network transfer limit the instance has reached.
+		err = networkTransferUpdate(d.state, d.inst, d.name, d.config, networkTransferStarted)
 		if err != nil {
 			return err
 		}
@@ -586,8 +624,25 @@ func (d *nicRouted) Update(oldDevices deviceConfig.Devices, isRunning bool) erro
 	return nil
 }
 
+// FirewallRuleset returns the host firewall rules generated from the current config of the device alongside the
+// rules loaded in the kernel.
+func (d *nicRouted) FirewallRuleset() (*firewallDrivers.Ruleset, error) {
+	hostName := d.config["host_name"]
+	if hostName == "" {
+		hostName = d.volatileGet()["host_name"]
+	}
+
+	// The reverse path filter is set up on the host side interface when started.
+	opts := firewallDrivers.InstanceOpts{HostName: hostName, RPFilter: hostName != ""}
+
+	return d.state.Firewall.InstanceRuleset(d.inst.Project(), d.inst.Name(), d.name, opts)
+}
+
 // Stop is run when the device is removed from the instance.
 func (d *nicRouted) Stop() (*deviceConfig.RunConfig, error) {
+	networkTransferStop(&d.deviceCommon)
+	networkMirrorUnregister(d.inst, d.name)
+
 	runConf := deviceConfig.RunConfig{
 		PostHooks: []func() error{d.postStop},
 	}
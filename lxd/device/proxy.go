@@ -92,10 +92,6 @@ func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
 		return err
 	}
 
-	if instConf.Type() == instancetype.VM && shared.IsFalseOrEmpty(d.config["nat"]) {
-		return fmt.Errorf("Only NAT mode is supported for proxies on VM instances")
-	}
-
 	listenAddr, err := ProxyParseAddr(d.config["listen"])
 	if err != nil {
 		return err
@@ -106,6 +102,17 @@ func (d *proxy) validateConfig(instConf instance.ConfigReader) error {
 		return err
 	}
 
+	// Proxies of VMs without NAT connect to the instance through the lxd-agent.
+	if instConf.Type() == instancetype.VM && shared.IsFalseOrEmpty(d.config["nat"]) {
+		if d.config["bind"] != "" && d.config["bind"] != "host" {
+			return fmt.Errorf("Only host-bound proxies are supported on VM instances when not using NAT")
+		}
+
+		if listenAddr.ConnType == "udp" || connectAddr.ConnType == "udp" {
+			return fmt.Errorf("Proxying %s <-> %s is not supported on VM instances when not using NAT", listenAddr.ConnType, connectAddr.ConnType)
+		}
+	}
+
 	if (listenAddr.ConnType != "unix" && len(connectAddr.Ports) > len(listenAddr.Ports)) || (listenAddr.ConnType == "unix" && len(connectAddr.Ports) > 1) {
 		// Cannot support single address (or port) -> multiple port.
 		return fmt.Errorf("Mismatch between listen port(s) and connect port(s) count")
@@ -215,9 +222,39 @@ func (d *proxy) Start() (*deviceConfig.RunConfig, error) {
 				return nil // Don't proceed with forkproxy setup.
 			}
 
-			proxyValues, err := d.setupProxyProcInfo()
-			if err != nil {
-				return err
+			// Prepare the proxy process arguments
+			var forkproxyargs []string
+			var inheritFds []*os.File
+
+			if d.inst.Type() == instancetype.VM {
+				// VMs are reached through the lxd-agent.
+				forkproxyargs, err = d.setupVsockProxyArgs()
+				if err != nil {
+					return err
+				}
+			} else {
+				proxyValues, err := d.setupProxyProcInfo()
+				if err != nil {
+					return err
+				}
+
+				forkproxyargs = []string{"forkproxy",
+					"--",
+					proxyValues.listenPid,
+					proxyValues.listenPidFd,
+					proxyValues.listenAddr,
+					proxyValues.connectPid,
+					proxyValues.connectPidFd,
+					proxyValues.connectAddr,
+					proxyValues.listenAddrGID,
+					proxyValues.listenAddrUID,
+					proxyValues.listenAddrMode,
+					proxyValues.securityGID,
+					proxyValues.securityUID,
+					proxyValues.proxyProtocol,
+				}
+
+				inheritFds = proxyValues.inheritFds
 			}
 
 			devFileName := fmt.Sprintf("proxy.%s", d.name)
@@ -233,22 +270,6 @@ func (d *proxy) Start() (*deviceConfig.RunConfig, error) {
 
 			// Spawn the daemon using subprocess
 			command := d.state.OS.ExecPath
-			forkproxyargs := []string{"forkproxy",
-				"--",
-				proxyValues.listenPid,
-				proxyValues.listenPidFd,
-				proxyValues.listenAddr,
-				proxyValues.connectPid,
-				proxyValues.connectPidFd,
-				proxyValues.connectAddr,
-				proxyValues.listenAddrGID,
-				proxyValues.listenAddrUID,
-				proxyValues.listenAddrMode,
-				proxyValues.securityGID,
-				proxyValues.securityUID,
-				proxyValues.proxyProtocol,
-			}
-
 			p, err := subprocess.NewProcess(command, forkproxyargs, logPath, logPath)
 			if err != nil {
 				return fmt.Errorf("Failed to start device %q: Failed to creating subprocess: %w", d.name, err)
@@ -256,12 +277,12 @@ func (d *proxy) Start() (*deviceConfig.RunConfig, error) {
 
 			p.SetApparmor(apparmor.ForkproxyProfileName(d.inst, d))
 
-			err = p.StartWithFiles(proxyValues.inheritFds)
+			err = p.StartWithFiles(inheritFds)
 			if err != nil {
 				return fmt.Errorf("Failed to start device %q: Failed running: %s %s: %w", d.name, command, strings.Join(forkproxyargs, " "), err)
 			}
 
-			for _, file := range proxyValues.inheritFds {
+			for _, file := range inheritFds {
 				_ = file.Close()
 			}
 
@@ -559,6 +580,35 @@ func (d *proxy) setupProxyProcInfo() (*proxyProcInfo, error) {
 	return p, nil
 }
 
+// setupVsockProxyArgs returns the forkvsockproxy arguments used to proxy connections to a VM through the lxd-agent.
+func (d *proxy) setupVsockProxyArgs() ([]string, error) {
+	vsockID := d.inst.LocalConfig()["volatile.vsock_id"]
+	if vsockID == "" {
+		return nil, fmt.Errorf("Instance has no vsock ID")
+	}
+
+	listenAddrMode := "0644"
+	if d.config["mode"] != "" {
+		listenAddrMode = d.config["mode"]
+	}
+
+	args := []string{"forkvsockproxy",
+		"--",
+		d.rewriteHostAddr(d.config["listen"]),
+		d.config["connect"],
+		vsockID,
+		d.inst.Path(),
+		d.config["gid"],
+		d.config["uid"],
+		listenAddrMode,
+		d.config["security.gid"],
+		d.config["security.uid"],
+		d.config["proxy_protocol"],
+	}
+
+	return args, nil
+}
+
 func (d *proxy) killProxyProc(pidPath string) error {
 	// If the pid file doesn't exist, there is no process to kill.
 	if !shared.PathExists(pidPath) {
@@ -0,0 +1,178 @@
+// Package filearchive transfers directory trees in and out of instances as tar archives.
+//
+// The transfers go over the same connections as the instance's SFTP server (forkfile for containers and the
+// lxd-agent for virtual machines), so that the file operations happen inside the instance with its ownership.
+// Clients request an archive transfer by sending Magic followed by a JSON encoded Request on its own line.
+package filearchive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Magic is sent by clients at the start of a connection to request an archive transfer instead of an SFTP
+// session. It can't be mistaken for an SFTP packet, whose first 4 bytes are the packet length.
+const Magic = "LXD-TAR\n"
+
+// CommandPull sends an archive of the requested path to the client.
+const CommandPull = "pull"
+
+// CommandPush extracts the archive sent by the client into the requested path.
+const CommandPush = "push"
+
+// Request is sent by clients after Magic.
+type Request struct {
+	Command string `json:"command"`
+	Path    string `json:"path"`
+}
+
+// Response is sent by the server on its own line, before the archive for pulls and after it for pushes.
+type Response struct {
+	Error string `json:"error"`
+}
+
+// bufferedConn is a net.Conn whose reads go through a buffered reader that may have already consumed data.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// Serve handles an archive transfer if the client requested one, otherwise the connection is passed to serveSFTP.
+func Serve(conn net.Conn, serveSFTP func(conn net.Conn) error) error {
+	reader := bufio.NewReader(conn)
+
+	magic, err := reader.Peek(len(Magic))
+	if err != nil || string(magic) != Magic {
+		return serveSFTP(&bufferedConn{Conn: conn, reader: reader})
+	}
+
+	_, err = reader.Discard(len(Magic))
+	if err != nil {
+		return err
+	}
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+
+	req := Request{}
+	err = json.Unmarshal(line, &req)
+	if err != nil {
+		return sendResponse(conn, fmt.Errorf("Invalid archive request: %w", err))
+	}
+
+	switch req.Command {
+	case CommandPull:
+		err = Check(req.Path)
+		if err != nil {
+			return sendResponse(conn, err)
+		}
+
+		err = sendResponse(conn, nil)
+		if err != nil {
+			return err
+		}
+
+		return Write(conn, req.Path)
+	case CommandPush:
+		return sendResponse(conn, Extract(reader, req.Path))
+	default:
+		return sendResponse(conn, fmt.Errorf("Unknown archive command %q", req.Command))
+	}
+}
+
+// Pull requests an archive of path on conn. The returned reader provides the archive and closes conn.
+func Pull(conn net.Conn, path string) (io.ReadCloser, error) {
+	err := sendRequest(conn, Request{Command: CommandPull, Path: path})
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	err = readResponse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// Push sends the archive read from r on conn to be extracted into path.
+func Push(conn net.Conn, path string, r io.Reader) error {
+	err := sendRequest(conn, Request{Command: CommandPush, Path: path})
+	if err != nil {
+		return err
+	}
+
+	_, copyErr := io.Copy(conn, r)
+
+	// Signal the end of the archive if the connection supports it, in case it was truncated.
+	closeWriter, ok := conn.(interface{ CloseWrite() error })
+	if copyErr == nil && ok {
+		_ = closeWriter.CloseWrite()
+	}
+
+	// The server error takes precedence as it may have stopped reading the archive because of it.
+	err = readResponse(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+
+	return copyErr
+}
+
+func sendRequest(conn net.Conn, req Request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(append([]byte(Magic), append(data, '\n')...))
+	return err
+}
+
+func sendResponse(conn net.Conn, err error) error {
+	resp := Response{}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	data, jsonErr := json.Marshal(resp)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	_, writeErr := conn.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return writeErr
+}
+
+func readResponse(reader *bufio.Reader) error {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("Failed reading archive response: %w", err)
+	}
+
+	resp := Response{}
+	err = json.Unmarshal(line, &resp)
+	if err != nil {
+		return fmt.Errorf("Invalid archive response: %w", err)
+	}
+
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+
+	return nil
+}
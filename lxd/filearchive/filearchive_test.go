@@ -0,0 +1,104 @@
+package filearchive
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteExtract(t *testing.T) {
+	src := t.TempDir()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, os.Mkdir(filepath.Join(src, "dir"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "dir", "file"), []byte("content"), 0640))
+	require.NoError(t, os.Chmod(filepath.Join(src, "dir", "file"), 0640))
+	require.NoError(t, os.Link(filepath.Join(src, "dir", "file"), filepath.Join(src, "hardlink")))
+	require.NoError(t, os.Symlink("dir/file", filepath.Join(src, "symlink")))
+	require.NoError(t, os.Chtimes(filepath.Join(src, "dir", "file"), modTime, modTime))
+	require.NoError(t, os.Chtimes(filepath.Join(src, "dir"), modTime, modTime))
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, src))
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	require.NoError(t, Extract(&buf, dst))
+
+	content, err := os.ReadFile(filepath.Join(dst, "dir", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	fi, err := os.Stat(filepath.Join(dst, "dir", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+	assert.True(t, fi.ModTime().Equal(modTime))
+
+	fi, err = os.Stat(filepath.Join(dst, "dir"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+	assert.True(t, fi.ModTime().Equal(modTime))
+
+	target, err := os.Readlink(filepath.Join(dst, "symlink"))
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file", target)
+
+	fileFi, err := os.Stat(filepath.Join(dst, "dir", "file"))
+	require.NoError(t, err)
+
+	linkFi, err := os.Stat(filepath.Join(dst, "hardlink"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(fileFi, linkFi))
+}
+
+func TestExtractInvalidEntry(t *testing.T) {
+	for _, name := range []string{"../escape", "/escape", "dir/../../escape"} {
+		_, err := entryPath("/tmp/dst", name)
+		assert.Error(t, err, name)
+	}
+
+	path, err := entryPath("/tmp/dst", "./dir/../file")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/dst/file", path)
+}
+
+func TestServe(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "file"), []byte("content"), 0644))
+
+	serve := func() net.Conn {
+		client, server := net.Pipe()
+		go func() {
+			_ = Serve(server, func(conn net.Conn) error {
+				t.Error("Unexpected SFTP session")
+				return nil
+			})
+
+			_ = server.Close()
+		}()
+
+		return client
+	}
+
+	// Pull the source directory, then push it to the destination.
+	conn := serve()
+	reader, err := Pull(conn, src)
+	require.NoError(t, err)
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	require.NoError(t, Push(serve(), dst, reader))
+	_ = reader.Close()
+
+	content, err := os.ReadFile(filepath.Join(dst, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	// Errors are returned to the client.
+	_, err = Pull(serve(), filepath.Join(src, "missing"))
+	assert.Error(t, err)
+}
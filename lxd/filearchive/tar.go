@@ -0,0 +1,290 @@
+package filearchive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// Check returns an error if path can't be archived.
+func Check(path string) error {
+	_, err := os.Lstat(path)
+	return err
+}
+
+// Write writes a tar archive of path to w.
+// Entry names are relative to path, which itself is the "." entry.
+func Write(w io.Writer, path string) error {
+	tarWriter := tar.NewWriter(w)
+	linkMap := map[uint64]string{}
+
+	err := filepath.Walk(path, func(srcPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(path, srcPath)
+		if err != nil {
+			return err
+		}
+
+		return writeEntry(tarWriter, linkMap, name, srcPath, fi)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tarWriter.Close()
+}
+
+func writeEntry(tarWriter *tar.Writer, linkMap map[uint64]string, name string, srcPath string, fi os.FileInfo) error {
+	var err error
+
+	// Sockets cannot be stored in tarballs, just skip them (consistent with tar).
+	if fi.Mode()&os.ModeSocket == os.ModeSocket {
+		return nil
+	}
+
+	link := ""
+	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		link, err = os.Readlink(srcPath)
+		if err != nil {
+			return fmt.Errorf("Failed to resolve symlink for %q: %w", srcPath, err)
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return fmt.Errorf("Failed to create tar info header: %w", err)
+	}
+
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+
+	uid, gid, major, minor, ino, nlink, err := shared.GetFileStat(srcPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get file stat %q: %w", srcPath, err)
+	}
+
+	hdr.Uid = uid
+	hdr.Gid = gid
+	hdr.Uname = ""
+	hdr.Gname = ""
+	hdr.Devmajor = int64(major)
+	hdr.Devminor = int64(minor)
+
+	// If it's a hardlink we've already seen use the old name.
+	if fi.Mode().IsRegular() && nlink > 1 {
+		firstPath, found := linkMap[ino]
+		if found {
+			hdr.Typeflag = tar.TypeLink
+			hdr.Linkname = firstPath
+			hdr.Size = 0
+		} else {
+			linkMap[ino] = hdr.Name
+		}
+	}
+
+	// Handle xattrs (for real files only).
+	if link == "" {
+		xattrs, err := shared.GetAllXattr(srcPath)
+		if err != nil {
+			return fmt.Errorf("Failed to read xattr for %q: %w", srcPath, err)
+		}
+
+		hdr.PAXRecords = make(map[string]string, len(xattrs))
+		for key, val := range xattrs {
+			hdr.PAXRecords["SCHILY.xattr."+key] = val
+		}
+	}
+
+	err = tarWriter.WriteHeader(hdr)
+	if err != nil {
+		return fmt.Errorf("Failed to write tar header: %w", err)
+	}
+
+	if hdr.Typeflag == tar.TypeReg {
+		f, err := os.Open(srcPath)
+		if err != nil {
+			return fmt.Errorf("Failed to open file %q: %w", srcPath, err)
+		}
+
+		defer func() { _ = f.Close() }()
+
+		// Only write the size recorded in the header in case the file grew in the meantime.
+		_, err = io.Copy(tarWriter, io.LimitReader(f, hdr.Size))
+		if err != nil {
+			return fmt.Errorf("Failed to copy file content %q: %w", srcPath, err)
+		}
+	}
+
+	return nil
+}
+
+// entryPath returns the path an archive entry is extracted to, making sure it doesn't escape from path.
+func entryPath(path string, name string) (string, error) {
+	name = filepath.Clean(name)
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("Invalid archive entry %q", name)
+	}
+
+	return filepath.Join(path, name), nil
+}
+
+// Extract extracts the tar archive read from r into path, which is created if missing.
+// Ownership, permissions, modification times and xattrs of the entries are restored.
+func Extract(r io.Reader, path string) error {
+	tarReader := tar.NewReader(r)
+
+	// Directory modification times are restored last, as adding entries to them updates them.
+	dirTimes := map[string]time.Time{}
+	dirs := []string{}
+
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed reading archive: %w", err)
+		}
+
+		target, err := entryPath(path, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		// Replace anything other than directories already in the way.
+		if hdr.Typeflag != tar.TypeDir {
+			fi, err := os.Lstat(target)
+			if err == nil && fi.IsDir() {
+				return fmt.Errorf("Cannot replace directory %q", target)
+			} else if err == nil {
+				err = os.Remove(target)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		mode := uint32(hdr.Mode & 07777)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.Mkdir(target, os.FileMode(mode))
+			if err != nil && !os.IsExist(err) {
+				return err
+			}
+
+			dirTimes[target] = hdr.ModTime
+			dirs = append(dirs, target)
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, os.FileMode(mode))
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(f, tarReader)
+			if err != nil {
+				_ = f.Close()
+				return fmt.Errorf("Failed writing %q: %w", target, err)
+			}
+
+			err = f.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			err = os.Symlink(hdr.Linkname, target)
+			if err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			linkTarget, err := entryPath(path, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+
+			err = os.Link(linkTarget, target)
+			if err != nil {
+				return err
+			}
+
+			// Hard links share the metadata of their target.
+			continue
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			devType := map[byte]uint32{tar.TypeChar: unix.S_IFCHR, tar.TypeBlock: unix.S_IFBLK, tar.TypeFifo: unix.S_IFIFO}[hdr.Typeflag]
+
+			err = unix.Mknod(target, devType|mode, int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor))))
+			if err != nil {
+				return fmt.Errorf("Failed creating %q: %w", target, err)
+			}
+		default:
+			return fmt.Errorf("Unsupported archive entry type %q for %q", hdr.Typeflag, hdr.Name)
+		}
+
+		err = os.Lchown(target, hdr.Uid, hdr.Gid)
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeSymlink {
+			// Set the permissions after the ownership, as changing it clears the setuid and setgid bits.
+			err = unix.Chmod(target, mode)
+			if err != nil {
+				return fmt.Errorf("Failed setting permissions of %q: %w", target, err)
+			}
+		}
+
+		for key, val := range hdr.PAXRecords {
+			if !strings.HasPrefix(key, "SCHILY.xattr.") {
+				continue
+			}
+
+			err = unix.Lsetxattr(target, strings.TrimPrefix(key, "SCHILY.xattr."), []byte(val), 0)
+			if err != nil && !errors.Is(err, unix.EOPNOTSUPP) {
+				return fmt.Errorf("Failed setting xattr %q on %q: %w", key, target, err)
+			}
+		}
+
+		if hdr.Typeflag != tar.TypeDir {
+			err = setModTime(target, hdr.ModTime)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err := setModTime(dirs[i], dirTimes[dirs[i]])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setModTime sets the access and modification times of path without following symlinks.
+func setModTime(path string, modTime time.Time) error {
+	ts := unix.NsecToTimespec(modTime.UnixNano())
+
+	err := unix.UtimesNanoAt(unix.AT_FDCWD, path, []unix.Timespec{ts, ts}, unix.AT_SYMLINK_NOFOLLOW)
+	if err != nil {
+		return fmt.Errorf("Failed setting modification time of %q: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,420 @@
+// Package filewatch watches paths inside an instance for changes using inotify.
+//
+// Paths are resolved relative to a root directory (/proc/PID/root for containers, / in the lxd-agent), without
+// following symlinks out of it. The inotify watches are then added through the resolved file descriptors.
+package filewatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// watchMask is the set of inotify events which are reported.
+const watchMask = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_CLOSE_WRITE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// maxWatches is the maximum number of directories watched by a single recursive watch.
+var maxWatches = 16384
+
+// errWatchLimit is returned when no more directories can be watched.
+var errWatchLimit = errors.New("Too many directories to watch")
+
+type watcher struct {
+	rootFD    int
+	recursive bool
+	inotify   *os.File
+	inotifyFD int
+	dev       uint64
+
+	mu      sync.Mutex
+	paths   map[int]string
+	rootWD  int
+	rootDir bool
+}
+
+// Watch watches path, resolved inside root, for changes. If path is a directory, changes to its entries are
+// reported, along with those of all its subdirectories if recursive is true.
+// The returned channel is closed once ctx is done, or when path itself is deleted.
+func Watch(ctx context.Context, root string, path string, recursive bool) (<-chan api.InstanceFileWatchEvent, error) {
+	path = filepath.Join("/", path)
+
+	rootFD, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed opening root %q: %w", root, err)
+	}
+
+	inotifyFD, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		_ = unix.Close(rootFD)
+		return nil, fmt.Errorf("Failed initializing inotify: %w", err)
+	}
+
+	w := &watcher{
+		rootFD:    rootFD,
+		recursive: recursive,
+		inotify:   os.NewFile(uintptr(inotifyFD), "inotify"),
+		inotifyFD: inotifyFD,
+		paths:     map[int]string{},
+	}
+
+	w.rootWD, w.rootDir, err = w.add(path, true)
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+
+	if w.rootDir && recursive {
+		err = w.addTree(path)
+		if err != nil {
+			w.close()
+			return nil, err
+		}
+	}
+
+	events := make(chan api.InstanceFileWatchEvent, 64)
+
+	// Stop reading once the context is done.
+	go func() {
+		<-ctx.Done()
+		_ = w.inotify.Close()
+	}()
+
+	go func() {
+		defer close(events)
+		defer w.close()
+
+		w.read(ctx, path, events)
+	}()
+
+	return events, nil
+}
+
+func (w *watcher) close() {
+	_ = w.inotify.Close()
+	_ = unix.Close(w.rootFD)
+}
+
+// open resolves path inside the root and opens it with flags.
+func (w *watcher) open(path string, flags int, follow bool) (int, error) {
+	if !follow {
+		flags |= unix.O_NOFOLLOW
+	}
+
+	fd, err := unix.Openat2(w.rootFD, path, &unix.OpenHow{
+		Flags:   uint64(flags | unix.O_CLOEXEC),
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if !errors.Is(err, unix.ENOSYS) {
+		return fd, err
+	}
+
+	// Without openat2, walk the path refusing any symlink along the way.
+	parts := []string{}
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return unix.Openat(w.rootFD, ".", flags|unix.O_CLOEXEC, 0)
+	}
+
+	fd, err = unix.Dup(w.rootFD)
+	if err != nil {
+		return -1, err
+	}
+
+	for i, part := range parts {
+		partFlags := unix.O_PATH | unix.O_NOFOLLOW | unix.O_CLOEXEC
+		if i == len(parts)-1 {
+			partFlags = flags | unix.O_NOFOLLOW | unix.O_CLOEXEC
+		}
+
+		nextFD, err := unix.Openat(fd, part, partFlags, 0)
+		_ = unix.Close(fd)
+		if err != nil {
+			if errors.Is(err, unix.ELOOP) {
+				return -1, fmt.Errorf("Path %q contains symlinks", path)
+			}
+
+			return -1, err
+		}
+
+		fd = nextFD
+	}
+
+	return fd, nil
+}
+
+// add adds an inotify watch for path, returning its watch descriptor and whether it's a directory.
+func (w *watcher) add(path string, follow bool) (int, bool, error) {
+	fd, err := w.open(path, unix.O_PATH, follow)
+	if err != nil {
+		return -1, false, fmt.Errorf("Failed opening %q: %w", path, err)
+	}
+
+	defer func() { _ = unix.Close(fd) }()
+
+	var stat unix.Stat_t
+	err = unix.Fstat(fd, &stat)
+	if err != nil {
+		return -1, false, err
+	}
+
+	// Never add a watch through a symlink, as it would be resolved outside of the root.
+	if stat.Mode&unix.S_IFMT == unix.S_IFLNK {
+		if follow {
+			return -1, false, fmt.Errorf("Path %q contains symlinks", path)
+		}
+
+		return -1, false, nil
+	}
+
+	isDir := stat.Mode&unix.S_IFMT == unix.S_IFDIR
+
+	// Only subdirectories get their own watch.
+	if !follow && !isDir {
+		return -1, false, nil
+	}
+
+	// Stay on the filesystem of the watched path, never descending into the likes of /proc or /sys.
+	if follow {
+		w.dev = uint64(stat.Dev)
+	} else if uint64(stat.Dev) != w.dev {
+		return -1, false, nil
+	}
+
+	w.mu.Lock()
+	full := len(w.paths) >= maxWatches
+	w.mu.Unlock()
+
+	if full {
+		return -1, false, fmt.Errorf("Failed watching %q: %w", path, errWatchLimit)
+	}
+
+	wd, err := unix.InotifyAddWatch(w.inotifyFD, fmt.Sprintf("/proc/self/fd/%d", fd), watchMask)
+	if err != nil {
+		// ENOSPC is returned once the inotify watches of the user are exhausted.
+		if errors.Is(err, unix.ENOSPC) {
+			err = errWatchLimit
+		}
+
+		return -1, false, fmt.Errorf("Failed watching %q: %w", path, err)
+	}
+
+	w.mu.Lock()
+	w.paths[wd] = path
+	w.mu.Unlock()
+
+	return wd, isDir, nil
+}
+
+// addTree adds watches for all the subdirectories of path.
+// Subdirectories going away while walking are skipped, errWatchLimit is returned once no more can be watched.
+func (w *watcher) addTree(path string) error {
+	fd, err := w.open(path, unix.O_RDONLY|unix.O_DIRECTORY, false)
+	if err != nil {
+		return nil
+	}
+
+	dir := os.NewFile(uintptr(fd), path)
+	entries, err := dir.ReadDir(-1)
+	_ = dir.Close()
+	if err != nil {
+		return nil
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		entryPath := filepath.Join(path, entry.Name())
+
+		wd, _, err := w.add(entryPath, false)
+		if err != nil {
+			if errors.Is(err, errWatchLimit) {
+				return err
+			}
+
+			continue
+		}
+
+		// Not watched, like mount points.
+		if wd == -1 {
+			continue
+		}
+
+		err = w.addTree(entryPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeTree removes the watches of path and all its subdirectories.
+func (w *watcher) removeTree(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for wd, wdPath := range w.paths {
+		if wd != w.rootWD && (wdPath == path || strings.HasPrefix(wdPath, path+"/")) {
+			_, _ = unix.InotifyRmWatch(w.inotifyFD, uint32(wd))
+			delete(w.paths, wd)
+		}
+	}
+}
+
+// read reads inotify events until the watch ends and sends them.
+func (w *watcher) read(ctx context.Context, path string, events chan<- api.InstanceFileWatchEvent) {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+
+	send := func(event api.InstanceFileWatchEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		n, err := w.inotify.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(raw.Len)]
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+			offset += unix.SizeofInotifyEvent + int(raw.Len)
+
+			// The kernel dropped events, let the client know that it missed some.
+			if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+				if !send(api.InstanceFileWatchEvent{Path: path, Action: api.InstanceFileWatchEventOverflow, Directory: w.rootDir, Timestamp: time.Now()}) {
+					return
+				}
+
+				continue
+			}
+
+			w.mu.Lock()
+			watchPath, found := w.paths[int(raw.Wd)]
+			if raw.Mask&unix.IN_IGNORED != 0 {
+				delete(w.paths, int(raw.Wd))
+			}
+
+			w.mu.Unlock()
+
+			if !found {
+				continue
+			}
+
+			// The watched path itself went away.
+			if int(raw.Wd) == w.rootWD && raw.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF|unix.IN_IGNORED) != 0 {
+				send(api.InstanceFileWatchEvent{Path: path, Action: api.InstanceFileWatchEventDelete, Directory: w.rootDir, Timestamp: time.Now()})
+				return
+			}
+
+			// Subdirectories are reported through their parent's watch. Their watches are normally removed
+			// when they're moved away from their parent already, any left is stale.
+			if raw.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF|unix.IN_IGNORED) != 0 {
+				if raw.Mask&unix.IN_MOVE_SELF != 0 {
+					w.removeTree(watchPath)
+				}
+
+				continue
+			}
+
+			event := api.InstanceFileWatchEvent{
+				Path:      filepath.Join(watchPath, name),
+				Directory: raw.Mask&unix.IN_ISDIR != 0,
+				Timestamp: time.Now(),
+			}
+
+			overflow := false
+
+			switch {
+			case raw.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+				event.Action = api.InstanceFileWatchEventCreate
+
+				if event.Directory && w.recursive {
+					wd, _, err := w.add(event.Path, false)
+					if err == nil && wd != -1 {
+						err = w.addTree(event.Path)
+					}
+
+					overflow = errors.Is(err, errWatchLimit)
+				}
+			case raw.Mask&unix.IN_CLOSE_WRITE != 0:
+				event.Action = api.InstanceFileWatchEventModify
+			case raw.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+				event.Action = api.InstanceFileWatchEventDelete
+
+				// Directories moved away are watched again under their new path, if it's still watched.
+				if raw.Mask&unix.IN_MOVED_FROM != 0 && event.Directory {
+					w.removeTree(event.Path)
+				}
+			default:
+				continue
+			}
+
+			if !send(event) {
+				return
+			}
+
+			// Changes in the directories which couldn't be watched will be missed.
+			if overflow && !send(api.InstanceFileWatchEvent{Path: event.Path, Action: api.InstanceFileWatchEventOverflow, Directory: true, Timestamp: time.Now()}) {
+				return
+			}
+		}
+	}
+}
+
+// Receive returns a channel with the events received on conn, as sent by a remote watch.
+// The channel is closed once ctx is done or conn is closed.
+func Receive(ctx context.Context, conn *websocket.Conn) <-chan api.InstanceFileWatchEvent {
+	// Stop receiving once the context is done.
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	events := make(chan api.InstanceFileWatchEvent)
+	go func() {
+		defer close(events)
+		defer func() { _ = conn.Close() }()
+
+		for {
+			event := api.InstanceFileWatchEvent{}
+			err := conn.ReadJSON(&event)
+			if err != nil {
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
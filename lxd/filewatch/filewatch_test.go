@@ -0,0 +1,148 @@
+package filewatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func nextEvent(t *testing.T, events <-chan api.InstanceFileWatchEvent) api.InstanceFileWatchEvent {
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "Watch ended early")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+
+	return api.InstanceFileWatchEvent{}
+}
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir"), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx, root, "/dir", true)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "file"), []byte("content"), 0644))
+
+	event := nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/file", Action: "create", Timestamp: event.Timestamp}, event)
+
+	event = nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/file", Action: "modify", Timestamp: event.Timestamp}, event)
+
+	// Subdirectories created after the watch started are watched too.
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir", "sub"), 0755))
+
+	event = nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/sub", Action: "create", Directory: true, Timestamp: event.Timestamp}, event)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "dir", "file")))
+
+	event = nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/file", Action: "delete", Timestamp: event.Timestamp}, event)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "sub", "file"), nil, 0644))
+
+	event = nextEvent(t, events)
+	assert.Equal(t, "/dir/sub/file", event.Path)
+
+	// The watch ends when the watched path is removed.
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "dir")))
+
+	for event := range events {
+		if event.Path == "/dir" {
+			assert.Equal(t, "delete", event.Action)
+		}
+	}
+}
+
+func TestWatchSymlink(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "link")))
+
+	// Absolute symlinks are resolved inside the root.
+	_, err := Watch(context.Background(), root, "/link", false)
+	assert.Error(t, err)
+}
+
+func TestWatchMove(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir", "a", "sub"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "other"), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx, root, "/dir", true)
+	require.NoError(t, err)
+
+	// Directories moved inside the watched path are reported under their new path.
+	require.NoError(t, os.Rename(filepath.Join(root, "dir", "a"), filepath.Join(root, "dir", "b")))
+
+	event := nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/a", Action: "delete", Directory: true, Timestamp: event.Timestamp}, event)
+
+	event = nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/b", Action: "create", Directory: true, Timestamp: event.Timestamp}, event)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "b", "sub", "file"), nil, 0644))
+
+	event = nextEvent(t, events)
+	assert.Equal(t, "/dir/b/sub/file", event.Path)
+
+	event = nextEvent(t, events)
+	assert.Equal(t, "/dir/b/sub/file", event.Path)
+
+	// Directories moved away aren't watched anymore.
+	require.NoError(t, os.Rename(filepath.Join(root, "dir", "b"), filepath.Join(root, "other", "b")))
+
+	event = nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/b", Action: "delete", Directory: true, Timestamp: event.Timestamp}, event)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "other", "b", "sub", "file"), nil, 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir", "c"), 0755))
+
+	event = nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/c", Action: "create", Directory: true, Timestamp: event.Timestamp}, event)
+}
+
+func TestWatchLimit(t *testing.T) {
+	defer func(limit int) { maxWatches = limit }(maxWatches)
+	maxWatches = 2
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir", "a"), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Watch(ctx, root, "/dir", true)
+	require.NoError(t, err)
+
+	// Clients are told about the directories which can't be watched.
+	require.NoError(t, os.Mkdir(filepath.Join(root, "dir", "b"), 0755))
+
+	event := nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/b", Action: "create", Directory: true, Timestamp: event.Timestamp}, event)
+
+	event = nextEvent(t, events)
+	assert.Equal(t, api.InstanceFileWatchEvent{Path: "/dir/b", Action: "overflow", Directory: true, Timestamp: event.Timestamp}, event)
+
+	// Watches fail upfront when there are too many directories.
+	_, err = Watch(ctx, root, "/dir", true)
+	assert.ErrorIs(t, err, errWatchLimit)
+}
@@ -17,13 +17,37 @@ type SNATOpts struct {
 	SNATAddress net.IP     // SNAT IP address to use. If nil then MASQUERADE is used.
 }
 
+// EgressProxyOpts specify how outbound HTTP and HTTPS traffic is redirected to the egress proxy.
+type EgressProxyOpts struct {
+	HTTPPort  int // Local port outbound HTTP traffic is redirected to.
+	HTTPSPort int // Local port outbound HTTPS traffic is redirected to.
+}
+
 // Opts for setting up the firewall.
 type Opts struct {
-	FeaturesV4 *FeatureOpts // Enable IPv4 firewall with specified options. Off if not provided.
-	FeaturesV6 *FeatureOpts // Enable IPv6 firewall with specified options. Off if not provided.
-	SNATV4     *SNATOpts    // Enable IPv4 SNAT with specified options. Off if not provided.
-	SNATV6     *SNATOpts    // Enable IPv6 SNAT with specified options. Off if not provided.
-	ACL        bool         // Enable ACL during setup.
+	FeaturesV4    *FeatureOpts     // Enable IPv4 firewall with specified options. Off if not provided.
+	FeaturesV6    *FeatureOpts     // Enable IPv6 firewall with specified options. Off if not provided.
+	SNATV4        *SNATOpts        // Enable IPv4 SNAT with specified options. Off if not provided.
+	SNATV6        *SNATOpts        // Enable IPv6 SNAT with specified options. Off if not provided.
+	EgressProxyV4 *EgressProxyOpts // Enable IPv4 egress proxy redirection with specified options. Off if not provided.
+	EgressProxyV6 *EgressProxyOpts // Enable IPv6 egress proxy redirection with specified options. Off if not provided.
+	ACL           bool             // Enable ACL during setup.
+}
+
+// BridgeFilterOpts for setting up the IP filtering of a bridged instance device.
+type BridgeFilterOpts struct {
+	ParentName    string       // Name of the parent bridge.
+	HWAddr        string       // MAC address of the device.
+	IPv4Nets      []*net.IPNet // Allowed IPv4 subnets. All allowed if nil, all blocked if empty.
+	IPv6Nets      []*net.IPNet // Allowed IPv6 subnets. All allowed if nil, all blocked if empty.
+	ParentManaged bool         // Whether the parent bridge is managed by LXD.
+}
+
+// InstanceOpts for generating the firewall rules of an instance device.
+type InstanceOpts struct {
+	HostName     string            // Name of the host side interface.
+	BridgeFilter *BridgeFilterOpts // Enable bridged device IP filtering with specified options. Off if not provided.
+	RPFilter     bool              // Enable reverse path filtering on the host side interface.
 }
 
 // ACLRule represents an ACL rule that can be added to a firewall.
@@ -29,8 +29,18 @@ const nftablesChainSeparator = "."
 // nftablesMinVersion We need at least 0.9.1 as this was when the arp ether saddr filters were added.
 const nftablesMinVersion = "0.9.1"
 
+// nftablesNetworkChains chains used for network rules (suffixed with the network name).
+var nftablesNetworkChains = []string{
+	"fwd", "pstrt", "in", "out", // Chains used for network operation rules.
+	"aclin", "aclout", "aclfwd", "acl", // Chains used by ACL rules.
+	"fwdprert", "fwdout", "fwdpstrt", // Chains used by Address Forward rules.
+	"prxprert", "prxin", "prxfwd", // Chains used by egress proxy rules.
+}
+
 // Nftables is an implmentation of LXD firewall using nftables.
-type Nftables struct{}
+type Nftables struct {
+	generator *rulesetGenerator // Collects the generated config instead of applying it if set.
+}
 
 // String returns the driver name.
 func (d Nftables) String() string {
@@ -263,6 +273,35 @@ func (d Nftables) networkSetupOutboundNAT(networkName string, SNATV4 *SNATOpts,
 	return nil
 }
 
+// networkSetupEgressProxy redirects outbound HTTP and HTTPS traffic to the egress proxy, allows access to it and
+// drops the other outbound traffic so that it can't bypass the proxy.
+func (d Nftables) networkSetupEgressProxy(networkName string, egressProxyV4 *EgressProxyOpts, egressProxyV6 *EgressProxyOpts) error {
+	rules := make(map[string]*EgressProxyOpts, 0)
+
+	if egressProxyV4 != nil {
+		rules["ip"] = egressProxyV4
+	}
+
+	if egressProxyV6 != nil {
+		rules["ip6"] = egressProxyV6
+	}
+
+	tplFields := map[string]any{
+		"namespace":      nftablesNamespace,
+		"chainSeparator": nftablesChainSeparator,
+		"networkName":    networkName,
+		"family":         "inet",
+		"rules":          rules,
+	}
+
+	err := d.applyNftConfig(nftablesNetEgressProxy, tplFields)
+	if err != nil {
+		return fmt.Errorf("Failed adding egress proxy rules for network %q (%s): %w", networkName, tplFields["family"], err)
+	}
+
+	return nil
+}
+
 // networkSetupICMPDHCPDNSAccess sets up basic nftables overrides for ICMP, DHCP and DNS.
 func (d Nftables) networkSetupICMPDHCPDNSAccess(networkName string, ipVersions []uint) error {
 	ipFamilies := []string{}
@@ -291,12 +330,20 @@ func (d Nftables) networkSetupICMPDHCPDNSAccess(networkName string, ipVersions [
 	return nil
 }
 
-func (d Nftables) networkSetupACLChainAndJumpRules(networkName string) error {
+func (d Nftables) networkSetupACLChainAndJumpRules(networkName string, egressProxyV4 *EgressProxyOpts, egressProxyV6 *EgressProxyOpts) error {
+	var egressProxyPorts []string
+	for _, egressProxy := range []*EgressProxyOpts{egressProxyV4, egressProxyV6} {
+		if egressProxy != nil {
+			egressProxyPorts = append(egressProxyPorts, fmt.Sprintf("%d", egressProxy.HTTPPort), fmt.Sprintf("%d", egressProxy.HTTPSPort))
+		}
+	}
+
 	tplFields := map[string]any{
-		"namespace":      nftablesNamespace,
-		"chainSeparator": nftablesChainSeparator,
-		"networkName":    networkName,
-		"family":         "inet",
+		"namespace":        nftablesNamespace,
+		"chainSeparator":   nftablesChainSeparator,
+		"networkName":      networkName,
+		"family":           "inet",
+		"egressProxyPorts": strings.Join(egressProxyPorts, ", "),
 	}
 
 	config := &strings.Builder{}
@@ -305,7 +352,7 @@ func (d Nftables) networkSetupACLChainAndJumpRules(networkName string) error {
 		return fmt.Errorf("Failed running %q template: %w", nftablesNetACLSetup.Name(), err)
 	}
 
-	_, err = shared.RunCommand("nft", config.String())
+	err = d.nftApply(config.String())
 	if err != nil {
 		return err
 	}
@@ -317,7 +364,7 @@ func (d Nftables) networkSetupACLChainAndJumpRules(networkName string) error {
 func (d Nftables) NetworkSetup(networkName string, opts Opts) error {
 	// Do this first before adding other network rules, so jump to ACL rules come first.
 	if opts.ACL {
-		err := d.networkSetupACLChainAndJumpRules(networkName)
+		err := d.networkSetupACLChainAndJumpRules(networkName, opts.EgressProxyV4, opts.EgressProxyV6)
 		if err != nil {
 			return err
 		}
@@ -330,6 +377,13 @@ func (d Nftables) NetworkSetup(networkName string, opts Opts) error {
 		}
 	}
 
+	if opts.EgressProxyV4 != nil || opts.EgressProxyV6 != nil {
+		err := d.networkSetupEgressProxy(networkName, opts.EgressProxyV4, opts.EgressProxyV6)
+		if err != nil {
+			return err
+		}
+	}
+
 	dhcpDNSAccess := []uint{}
 	var ip4ForwardingAllow, ip6ForwardingAllow *bool
 
@@ -367,15 +421,9 @@ func (d Nftables) NetworkSetup(networkName string, opts Opts) error {
 // NetworkClear removes the LXD network related chains.
 // The delete and ipeVersions arguments have no effect for nftables driver.
 func (d Nftables) NetworkClear(networkName string, _ bool, _ []uint) error {
-	removeChains := []string{
-		"fwd", "pstrt", "in", "out", // Chains used for network operation rules.
-		"aclin", "aclout", "aclfwd", "acl", // Chains used by ACL rules.
-		"fwdprert", "fwdout", "fwdpstrt", // Chains used by Address Forward rules.
-	}
-
 	// Remove chains created by network rules.
 	// Remove from ip and ip6 tables to ensure cleanup for instances started before we moved to inet table
-	err := d.removeChains([]string{"inet", "ip", "ip6"}, networkName, removeChains...)
+	err := d.removeChains([]string{"inet", "ip", "ip6"}, networkName, nftablesNetworkChains...)
 	if err != nil {
 		return fmt.Errorf("Failed clearing nftables rules for network %q: %w", networkName, err)
 	}
@@ -547,7 +595,7 @@ func (d Nftables) InstanceSetupProxyNAT(projectName string, instanceName string,
 		return fmt.Errorf("Failed running %q template: %w", nftablesNetProxyNAT.Name(), err)
 	}
 
-	_, err = shared.RunCommand("nft", config.String())
+	err = d.nftApply(config.String())
 	if err != nil {
 		return err
 	}
@@ -584,7 +632,7 @@ func (d Nftables) applyNftConfig(tpl *template.Template, tplFields map[string]an
 		return fmt.Errorf("Failed running %q template: %w", tpl.Name(), err)
 	}
 
-	_, err = shared.RunCommand("nft", config.String())
+	err = d.nftApply(config.String())
 	if err != nil {
 		return fmt.Errorf("Failed apply nftables config: %w", err)
 	}
@@ -592,9 +640,26 @@ func (d Nftables) applyNftConfig(tpl *template.Template, tplFields map[string]an
 	return nil
 }
 
+// nftApply sends the config to the nft command to be atomically applied to the system.
+// When generating the ruleset, the config is only recorded.
+func (d Nftables) nftApply(config string) error {
+	if d.generator != nil {
+		d.generator.add(config)
+		return nil
+	}
+
+	_, err := shared.RunCommand("nft", config)
+	return err
+}
+
 // removeChains removes the specified chains from the specified families.
 // If not empty, chain suffix is appended to each chain name, separated with "_".
 func (d Nftables) removeChains(families []string, chainSuffix string, chains ...string) error {
+	// Nothing is loaded when generating the ruleset, so there is nothing to remove.
+	if d.generator != nil {
+		return nil
+	}
+
 	ruleset, err := d.nftParseRuleset()
 	if err != nil {
 		return err
@@ -712,7 +777,7 @@ func (d Nftables) NetworkApplyACLRules(networkName string, rules []ACLRule) erro
 		return fmt.Errorf("Failed running %q template: %w", nftablesNetACLRules.Name(), err)
 	}
 
-	_, err = shared.RunCommand("nft", config.String())
+	err = d.nftApply(config.String())
 	if err != nil {
 		return err
 	}
@@ -1044,7 +1109,7 @@ func (d Nftables) NetworkApplyForwards(networkName string, rules []AddressForwar
 			return fmt.Errorf("Failed running %q template: %w", nftablesNetProxyNAT.Name(), err)
 		}
 
-		_, err = shared.RunCommand("nft", config.String())
+		err = d.nftApply(config.String())
 		if err != nil {
 			return err
 		}
@@ -1057,3 +1122,118 @@ func (d Nftables) NetworkApplyForwards(networkName string, rules []AddressForwar
 
 	return nil
 }
+
+// listChains returns the current rules of the specified chains from the specified families.
+// If not empty, chain suffix is appended to each chain name, separated with ".".
+func (d Nftables) listChains(families []string, chainSuffix string, chains ...string) (string, error) {
+	ruleset, err := d.nftParseRuleset()
+	if err != nil {
+		return "", err
+	}
+
+	var loaded strings.Builder
+	for _, family := range families {
+		for _, chain := range chains {
+			fullChain := chain
+			if chainSuffix != "" {
+				fullChain = fmt.Sprintf("%s%s%s", chain, nftablesChainSeparator, chainSuffix)
+			}
+
+			for _, item := range ruleset {
+				if item.ItemType != "chain" || item.Family != family || item.Table != nftablesNamespace || item.Name != fullChain {
+					continue
+				}
+
+				output, err := shared.RunCommand("nft", "-nn", "list", "chain", family, nftablesNamespace, fullChain)
+				if err != nil {
+					return "", fmt.Errorf("Failed listing nftables chain %q (%s): %w", fullChain, family, err)
+				}
+
+				loaded.WriteString(output)
+
+				break
+			}
+		}
+	}
+
+	return loaded.String(), nil
+}
+
+// networkLoadedRules returns the rules currently loaded for the network.
+func (d Nftables) networkLoadedRules(networkName string) (string, error) {
+	return d.listChains([]string{"inet", "ip", "ip6"}, networkName, nftablesNetworkChains...)
+}
+
+// instanceLoadedRules returns the rules currently loaded for the instance device.
+func (d Nftables) instanceLoadedRules(deviceLabel string) (string, error) {
+	bridgeRules, err := d.listChains([]string{"bridge"}, deviceLabel, "in", "fwd")
+	if err != nil {
+		return "", err
+	}
+
+	natRules, err := d.listChains([]string{"inet", "ip", "ip6"}, deviceLabel, "out", "prert", "pstrt")
+	if err != nil {
+		return "", err
+	}
+
+	return bridgeRules + natRules, nil
+}
+
+// NetworkRuleset returns the rules generated for the network from the specified options, ACL rules and address
+// forwards alongside the rules currently loaded for it.
+func (d Nftables) NetworkRuleset(networkName string, opts Opts, aclRules []ACLRule, forwards []AddressForward) (*Ruleset, error) {
+	generator := Nftables{generator: &rulesetGenerator{}}
+
+	err := generator.NetworkSetup(networkName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ACL {
+		err = generator.NetworkApplyACLRules(networkName, aclRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = generator.NetworkApplyForwards(networkName, forwards)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := d.networkLoadedRules(networkName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing nftables rules for network %q: %w", networkName, err)
+	}
+
+	return generator.generator.ruleset(loaded, nftablesNormalizeRules), nil
+}
+
+// InstanceRuleset returns the rules generated for the instance device from the specified options alongside the
+// rules currently loaded for it.
+func (d Nftables) InstanceRuleset(projectName string, instanceName string, deviceName string, opts InstanceOpts) (*Ruleset, error) {
+	generator := Nftables{generator: &rulesetGenerator{}}
+
+	if opts.BridgeFilter != nil {
+		err := generator.InstanceSetupBridgeFilter(projectName, instanceName, deviceName, opts.BridgeFilter.ParentName, opts.HostName, opts.BridgeFilter.HWAddr, opts.BridgeFilter.IPv4Nets, opts.BridgeFilter.IPv6Nets, opts.BridgeFilter.ParentManaged)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.RPFilter {
+		err := generator.InstanceSetupRPFilter(projectName, instanceName, deviceName, opts.HostName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deviceLabel := d.instanceDeviceLabel(projectName, instanceName, deviceName)
+
+	loaded, err := d.instanceLoadedRules(deviceLabel)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing nftables rules for instance device %q: %w", deviceLabel, err)
+	}
+
+	return generator.generator.ruleset(loaded, nftablesNormalizeRules), nil
+}
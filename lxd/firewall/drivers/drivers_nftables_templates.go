@@ -40,6 +40,33 @@ chain pstrt{{.chainSeparator}}{{.networkName}} {
 }
 `))
 
+var nftablesNetEgressProxy = template.Must(template.New("nftablesNetEgressProxy").Parse(`
+chain prxprert{{.chainSeparator}}{{.networkName}} {
+	type nat hook prerouting priority -100; policy accept;
+
+	{{- range $ipFamily, $config := .rules}}
+	iifname "{{$.networkName}}" meta nfproto {{if eq $ipFamily "ip"}}ipv4{{else}}ipv6{{end}} fib daddr type != local tcp dport 80 redirect to :{{$config.HTTPPort}}
+	iifname "{{$.networkName}}" meta nfproto {{if eq $ipFamily "ip"}}ipv4{{else}}ipv6{{end}} fib daddr type != local tcp dport 443 redirect to :{{$config.HTTPSPort}}
+	{{- end}}
+}
+
+chain prxin{{.chainSeparator}}{{.networkName}} {
+	type filter hook input priority 0; policy accept;
+
+	{{- range $ipFamily, $config := .rules}}
+	iifname "{{$.networkName}}" meta nfproto {{if eq $ipFamily "ip"}}ipv4{{else}}ipv6{{end}} tcp dport { {{$config.HTTPPort}}, {{$config.HTTPSPort}} } accept
+	{{- end}}
+}
+
+chain prxfwd{{.chainSeparator}}{{.networkName}} {
+	type filter hook forward priority 0; policy accept;
+
+	{{- range $ipFamily, $config := .rules}}
+	iifname "{{$.networkName}}" oifname != "{{$.networkName}}" meta nfproto {{if eq $ipFamily "ip"}}ipv4{{else}}ipv6{{end}} ct state new drop
+	{{- end}}
+}
+`))
+
 var nftablesNetICMPDHCPDNS = template.Must(template.New("nftablesNetDHCPDNS").Parse(`
 chain in{{.chainSeparator}}{{.networkName}} {
 	type filter hook input priority 0; policy accept;
@@ -135,6 +162,11 @@ table {{.family}} {{.namespace}} {
 
 		# Allow core ICMPv6 to LXD host.
 		iifname "{{$.networkName}}" icmpv6 type {1, 2, 3, 4, 133, 135, 136, 143} accept
+		{{- if .egressProxyPorts}}
+
+		# Allow egress proxy on LXD host (it applies the ACLs to the connections it makes itself).
+		iifname "{{$.networkName}}" tcp dport { {{.egressProxyPorts}} } accept
+		{{- end}}
 
 		iifname {{.networkName}} jump acl{{.chainSeparator}}{{.networkName}}
 	}
@@ -0,0 +1,190 @@
+package drivers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ruleset represents the firewall rules generated for an object alongside the rules currently loaded for it.
+type Ruleset struct {
+	Generated string // Config LXD generates for the object from its current configuration.
+	Loaded    string // Rules currently loaded in the kernel for the object.
+	Diff      string // Differences between the generated rules and the loaded ones.
+}
+
+// rulesetGenerator collects the config generated by a driver in place of applying it.
+type rulesetGenerator struct {
+	configs []string
+}
+
+// add records generated config.
+func (g *rulesetGenerator) add(config string) {
+	g.configs = append(g.configs, strings.Trim(config, "\n"))
+}
+
+// ruleset returns a Ruleset using the generated config and the loaded rules.
+// The diff is computed on the rules returned by normalize for both the generated config and the loaded rules.
+func (g *rulesetGenerator) ruleset(loaded string, normalize func(ruleset string) []string) *Ruleset {
+	generated := strings.Join(g.configs, "\n")
+
+	return &Ruleset{
+		Generated: generated,
+		Loaded:    loaded,
+		Diff:      rulesetDiff(strings.Join(normalize(generated), "\n"), strings.Join(normalize(loaded), "\n")),
+	}
+}
+
+// rulesetCommand returns a printable command line for the specified command and arguments.
+func rulesetCommand(cmd string, args ...string) string {
+	parts := []string{cmd}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+
+		parts = append(parts, arg)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// nftablesNormalizeRules returns the rules of an nftables config or listing, one per line prefixed with the family
+// and chain they belong to. Table and chain declarations, chain types and policies are left out as are the spaces
+// inside sets so that the rules generated by LXD can be compared with the ones listed by nft.
+func nftablesNormalizeRules(ruleset string) []string {
+	rules := []string{}
+	family := ""
+	chain := ""
+
+	for _, line := range strings.Split(ruleset, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 0, strings.HasPrefix(line, "#"):
+			continue
+		case fields[0] == "add", fields[0] == "flush", fields[0] == "delete":
+			continue
+		case fields[0] == "table" && len(fields) > 1:
+			family = fields[1]
+			chain = ""
+		case fields[0] == "chain" && len(fields) > 1:
+			chain = fields[1]
+		case line == "}":
+			if chain != "" {
+				chain = ""
+			} else {
+				family = ""
+			}
+		case chain == "", fields[0] == "type", fields[0] == "policy":
+			continue
+		default:
+			line = strings.NewReplacer("{ ", "{", " }", "}", ", ", ",").Replace(line)
+			rules = append(rules, fmt.Sprintf("%s %s: %s", family, chain, line))
+		}
+	}
+
+	return rules
+}
+
+// xtablesNormalizeRules returns the rules of a list of xtables commands or listing, one per line and sorted.
+// The wait and concurrency flags are left out and inserted rules are written as appended ones so that the rules
+// generated by LXD can be compared with the ones listed by iptables and ebtables. The rules are sorted because
+// their position depends on when they were inserted.
+func xtablesNormalizeRules(ruleset string) []string {
+	rules := []string{}
+
+	for _, line := range strings.Split(ruleset, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		normalized := make([]string, 0, len(fields))
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "-w", "--wait", "--concurrent":
+				continue
+			case "-I", "--insert", "--append":
+				normalized = append(normalized, "-A")
+
+				// Skip the optional rule number following the chain name.
+				if i+2 < len(fields) {
+					_, err := strconv.Atoi(fields[i+2])
+					if err == nil {
+						normalized = append(normalized, fields[i+1])
+						i += 2
+					}
+				}
+
+				continue
+			}
+
+			normalized = append(normalized, fields[i])
+		}
+
+		rules = append(rules, strings.Join(normalized, " "))
+	}
+
+	sort.Strings(rules)
+
+	return rules
+}
+
+// rulesetDiff returns a line based diff between two rulesets.
+// Lines only present in the old ruleset are prefixed with "-" and lines only present in the new one with "+".
+// Leading and trailing whitespace as well as empty lines are ignored. Returns empty string if there is no change.
+func rulesetDiff(oldRuleset string, newRuleset string) string {
+	splitLines := func(ruleset string) []string {
+		lines := []string{}
+		for _, line := range strings.Split(ruleset, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+
+		return lines
+	}
+
+	oldLines := splitLines(oldRuleset)
+	newLines := splitLines(newRuleset)
+
+	// Compute the longest common subsequence lengths of every pair of suffixes.
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := &strings.Builder{}
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			i++
+			j++
+		case j < len(newLines) && (i >= len(oldLines) || lcs[i][j+1] > lcs[i+1][j]):
+			diff.WriteString("+" + newLines[j] + "\n")
+			j++
+		default:
+			diff.WriteString("-" + oldLines[i] + "\n")
+			i++
+		}
+	}
+
+	return diff.String()
+}
@@ -0,0 +1,149 @@
+package drivers
+
+import (
+	"log"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rulesetDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "Unchanged",
+			old:      "chain a {\n\taccept\n}\n",
+			new:      "chain a {\n\taccept\n}\n",
+			expected: "",
+		},
+		{
+			name:     "Whitespace and empty lines ignored",
+			old:      "chain a {\n\n\taccept\n}\n",
+			new:      "chain a {\n    accept\n}",
+			expected: "",
+		},
+		{
+			name:     "Rule added",
+			old:      "chain a {\n\taccept\n}\n",
+			new:      "chain a {\n\tdrop\n\taccept\n}\n",
+			expected: "+drop\n",
+		},
+		{
+			name:     "Rule removed",
+			old:      "chain a {\n\tdrop\n\taccept\n}\n",
+			new:      "chain a {\n\taccept\n}\n",
+			expected: "-drop\n",
+		},
+		{
+			name:     "Rule changed",
+			old:      "chain a {\n\tdrop\n}\n",
+			new:      "chain a {\n\taccept\n}\n",
+			expected: "-drop\n+accept\n",
+		},
+		{
+			name:     "All rules removed",
+			old:      "chain a {\n\tdrop\n}\n",
+			new:      "",
+			expected: "-chain a {\n-drop\n-}\n",
+		},
+	}
+
+	for i, tt := range tests {
+		log.Printf("Running test #%d: %s", i, tt.name)
+		assert.Equal(t, tt.expected, rulesetDiff(tt.old, tt.new))
+	}
+}
+
+func Test_rulesetGenerator(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/24")
+	require.NoError(t, err)
+
+	opts := Opts{
+		FeaturesV4: &FeatureOpts{ICMPDHCPDNSAccess: true, ForwardingAllow: true},
+		SNATV4:     &SNATOpts{Subnet: subnet},
+	}
+
+	forwards := []AddressForward{{ListenAddress: net.ParseIP("192.0.2.1"), TargetAddress: net.ParseIP("10.0.0.2")}}
+
+	// The config is generated with the same templates used to apply it, without applying anything.
+	nftables := Nftables{generator: &rulesetGenerator{}}
+	require.NoError(t, nftables.NetworkSetup("lxdbr0", opts))
+	require.NoError(t, nftables.NetworkApplyForwards("lxdbr0", forwards))
+	require.NoError(t, nftables.InstanceSetupRPFilter("default", "c1", "eth0", "veth1234"))
+
+	ruleset := nftables.generator.ruleset("", nftablesNormalizeRules)
+	assert.Contains(t, ruleset.Generated, "chain pstrt.lxdbr0")
+	assert.Contains(t, ruleset.Generated, "ip saddr 10.0.0.0/24 ip daddr != 10.0.0.0/24 masquerade")
+	assert.Contains(t, ruleset.Generated, "ip daddr 192.0.2.1  dnat to 10.0.0.2")
+	assert.Contains(t, ruleset.Generated, "iif \"veth1234\" fib saddr . iif oif missing drop")
+	assert.NotEmpty(t, ruleset.Diff)
+
+	xtables := Xtables{generator: &rulesetGenerator{}}
+	require.NoError(t, xtables.NetworkSetup("lxdbr0", opts))
+	require.NoError(t, xtables.NetworkApplyForwards("lxdbr0", forwards))
+
+	ruleset = xtables.generator.ruleset("", xtablesNormalizeRules)
+	assert.Contains(t, ruleset.Generated, `iptables -w -t nat -I POSTROUTING -s 10.0.0.0/24 ! -d 10.0.0.0/24 -j MASQUERADE -m comment --comment "generated for LXD network lxdbr0"`)
+	assert.Contains(t, ruleset.Generated, `iptables -w -t nat -I PREROUTING --destination 192.0.2.1 -j DNAT --to-destination 10.0.0.2 -m comment --comment "generated for LXD network-forward lxdbr0"`)
+
+	// Once loaded as generated, there is no difference.
+	loaded := xtables.generator.ruleset(ruleset.Generated, xtablesNormalizeRules)
+	assert.Empty(t, loaded.Diff)
+}
+
+func Test_nftablesNormalizeRules(t *testing.T) {
+	generated := `
+add table inet lxd
+add chain inet lxd prert.c1.eth0 {type nat hook prerouting priority -100; policy accept;}
+flush chain inet lxd prert.c1.eth0
+
+table inet lxd {
+chain in.lxdbr0 {
+	type filter hook input priority 0; policy accept;
+
+	iifname "lxdbr0" tcp dport 53 accept
+	iifname "lxdbr0" icmp type {3, 11, 12} accept
+}
+}
+`
+
+	loaded := `table inet lxd {
+	chain in.lxdbr0 {
+		type filter hook input priority filter; policy accept;
+		iifname "lxdbr0" tcp dport 53 accept
+		iifname "lxdbr0" icmp type { 3, 11, 12 } accept
+	}
+}
+`
+
+	expected := []string{
+		`inet in.lxdbr0: iifname "lxdbr0" tcp dport 53 accept`,
+		`inet in.lxdbr0: iifname "lxdbr0" icmp type {3,11,12} accept`,
+	}
+
+	assert.Equal(t, expected, nftablesNormalizeRules(generated))
+	assert.Equal(t, expected, nftablesNormalizeRules(loaded))
+}
+
+func Test_xtablesNormalizeRules(t *testing.T) {
+	generated := `iptables -w -t nat -I PREROUTING -p tcp -j DNAT -m comment --comment "generated for LXD network lxdbr0"
+iptables -w -t filter -I INPUT 2 -i lxdbr0 -j ACCEPT
+ebtables --concurrent -t filter -A INPUT -s ! 00:16:3e:00:00:01 -i veth1 -j DROP`
+
+	loaded := `ebtables -t filter -A INPUT -s ! 00:16:3e:00:00:01 -i veth1 -j DROP
+iptables -t filter -A INPUT -i lxdbr0 -j ACCEPT
+iptables -t nat -A PREROUTING -p tcp -j DNAT -m comment --comment "generated for LXD network lxdbr0"`
+
+	assert.Equal(t, xtablesNormalizeRules(loaded), xtablesNormalizeRules(generated))
+	assert.Equal(t, []string{
+		`ebtables -t filter -A INPUT -s ! 00:16:3e:00:00:01 -i veth1 -j DROP`,
+		`iptables -t filter -A INPUT -i lxdbr0 -j ACCEPT`,
+		`iptables -t nat -A PREROUTING -p tcp -j DNAT -m comment --comment "generated for LXD network lxdbr0"`,
+	}, xtablesNormalizeRules(generated))
+}
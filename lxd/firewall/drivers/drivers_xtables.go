@@ -31,7 +31,9 @@ const iptablesCommentPrefix = "generated for"
 var ebtablesMu sync.Mutex
 
 // Xtables is an implmentation of LXD firewall using {ip, ip6, eb}tables.
-type Xtables struct{}
+type Xtables struct {
+	generator *rulesetGenerator // Collects the generated rules instead of applying them if set.
+}
 
 // String returns the driver name.
 func (d Xtables) String() string {
@@ -378,6 +380,34 @@ func (d Xtables) networkSetupOutboundNAT(networkName string, subnet *net.IPNet,
 	return nil
 }
 
+// networkSetupEgressProxy redirects outbound HTTP and HTTPS traffic to the egress proxy, allows access to it and
+// drops the other outbound traffic so that it can't bypass the proxy.
+func (d Xtables) networkSetupEgressProxy(networkName string, ipVersion uint, opts *EgressProxyOpts) error {
+	comment := d.networkIPTablesComment(networkName)
+
+	err := d.iptablesPrepend(ipVersion, comment, "filter", "FORWARD", "-i", networkName, "!", "-o", networkName, "-m", "state", "--state", "NEW", "-j", "DROP")
+	if err != nil {
+		return err
+	}
+
+	for _, ports := range [][2]int{{80, opts.HTTPPort}, {443, opts.HTTPSPort}} {
+		dport := fmt.Sprintf("%d", ports[0])
+		proxyPort := fmt.Sprintf("%d", ports[1])
+
+		err := d.iptablesPrepend(ipVersion, comment, "nat", "PREROUTING", "-i", networkName, "-p", "tcp", "--dport", dport, "-m", "addrtype", "!", "--dst-type", "LOCAL", "-j", "REDIRECT", "--to-ports", proxyPort)
+		if err != nil {
+			return err
+		}
+
+		err = d.iptablesPrepend(ipVersion, comment, "filter", "INPUT", "-i", networkName, "-p", "tcp", "--dport", proxyPort, "-j", "ACCEPT")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // networkSetupICMPDHCPDNSAccess sets up basic iptables overrides for ICMP, DHCP and DNS.
 func (d Xtables) networkSetupICMPDHCPDNSAccess(networkName string, ipVersion uint) error {
 	var rules [][]string
@@ -507,6 +537,22 @@ func (d Xtables) NetworkSetup(networkName string, opts Opts) error {
 		}
 	}
 
+	// Must come last so that the egress proxy rules are processed before the ACL and forwarding policy rules.
+	// The egress proxy applies the ACLs to the connections it makes itself.
+	if opts.EgressProxyV4 != nil {
+		err := d.networkSetupEgressProxy(networkName, 4, opts.EgressProxyV4)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.EgressProxyV6 != nil {
+		err := d.networkSetupEgressProxy(networkName, 6, opts.EgressProxyV6)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -545,20 +591,19 @@ func (d Xtables) NetworkApplyACLRules(networkName string, rules []ACLRule) error
 
 	applyACLRules := func(cmd string, iptRules [][]string) error {
 		// Attempt to flush chain in table.
-		_, err := shared.RunCommand(cmd, "-t", "filter", "-F", chain)
-		if err != nil {
-			return fmt.Errorf("Failed flushing %q chain %q in table %q: %w", cmd, chain, "filter", err)
+		if d.generator == nil {
+			_, err := shared.RunCommand(cmd, "-t", "filter", "-F", chain)
+			if err != nil {
+				return fmt.Errorf("Failed flushing %q chain %q in table %q: %w", cmd, chain, "filter", err)
+			}
 		}
 
 		// Allow connection tracking.
-		_, err = shared.RunCommand(cmd, "-t", "filter", "-A", chain, "-m", "state", "--state", "ESTABLISHED,RELATED", "-j", "ACCEPT")
-		if err != nil {
-			return fmt.Errorf("Failed adding connection tracking rules to %q chain %q in table %q: %w", cmd, chain, "filter", err)
-		}
+		iptRules = append([][]string{{"-m", "state", "--state", "ESTABLISHED,RELATED", "-j", "ACCEPT"}}, iptRules...)
 
 		// Add rules to chain in table.
 		for _, iptRule := range iptRules {
-			_, err := shared.RunCommand(cmd, append([]string{"-t", "filter", "-A", chain}, iptRule...)...)
+			err := d.xtablesApply(cmd, append([]string{"-t", "filter", "-A", chain}, iptRule...)...)
 			if err != nil {
 				return fmt.Errorf("Failed adding rule to %q chain %q in table %q: %w", cmd, chain, "filter", err)
 			}
@@ -815,7 +860,7 @@ func (d Xtables) InstanceSetupBridgeFilter(projectName string, instanceName stri
 
 	ebtablesMu.Lock()
 	for _, rule := range rules {
-		_, err := shared.RunCommand(rule[0], rule[1:]...)
+		err := d.xtablesApply(rule[0], rule[1:]...)
 		if err != nil {
 			ebtablesMu.Unlock()
 			return err
@@ -1219,17 +1264,22 @@ func (d Xtables) iptablesAdd(ipVersion uint, comment string, table string, metho
 		return fmt.Errorf("Invalid IP version")
 	}
 
-	_, err := exec.LookPath(cmd)
-	if err != nil {
-		return fmt.Errorf("Asked to setup IPv%d firewalling but %s can't be found", ipVersion, cmd)
-	}
-
 	baseArgs := []string{"-w", "-t", table}
 
 	args := append(baseArgs, []string{method, chain}...)
 	args = append(args, rule...)
 	args = append(args, "-m", "comment", "--comment", fmt.Sprintf("%s %s", iptablesCommentPrefix, comment))
 
+	if d.generator != nil {
+		d.generator.add(rulesetCommand(cmd, args...))
+		return nil
+	}
+
+	_, err := exec.LookPath(cmd)
+	if err != nil {
+		return fmt.Errorf("Asked to setup IPv%d firewalling but %s can't be found", ipVersion, cmd)
+	}
+
 	_, err = shared.TryRunCommand(cmd, args...)
 	if err != nil {
 		return err
@@ -1238,6 +1288,17 @@ func (d Xtables) iptablesAdd(ipVersion uint, comment string, table string, metho
 	return nil
 }
 
+// xtablesApply runs the command adding a rule. When generating the rules, the command is only recorded.
+func (d Xtables) xtablesApply(cmd string, args ...string) error {
+	if d.generator != nil {
+		d.generator.add(rulesetCommand(cmd, args...))
+		return nil
+	}
+
+	_, err := shared.RunCommand(cmd, args...)
+	return err
+}
+
 // iptablesAppend appends an iptables rule.
 func (d Xtables) iptablesAppend(ipVersion uint, comment string, table string, chain string, rule ...string) error {
 	return d.iptablesAdd(ipVersion, comment, table, "-A", chain, rule...)
@@ -1262,6 +1323,11 @@ func (d Xtables) iptablesClear(ipVersion uint, comments []string, fromTables ...
 		return fmt.Errorf("Invalid IP version")
 	}
 
+	// Nothing is loaded when generating the rules, so there is nothing to clear.
+	if d.generator != nil {
+		return nil
+	}
+
 	// Detect kernels that lack IPv6 support.
 	if !shared.PathExists("/proc/sys/net/ipv6") && ipVersion == 6 {
 		return nil
@@ -1413,6 +1479,11 @@ func (d Xtables) iptablesChainCreate(ipVersion uint, table string, chain string)
 		return fmt.Errorf("Invalid IP version")
 	}
 
+	// Chains aren't part of the generated rules.
+	if d.generator != nil {
+		return nil
+	}
+
 	// Attempt to create chain in table.
 	_, err := shared.RunCommand(cmd, "-t", table, "-N", chain)
 	if err != nil {
@@ -1580,3 +1651,213 @@ func (d Xtables) NetworkApplyForwards(networkName string, rules []AddressForward
 
 	return nil
 }
+
+// iptablesListRules returns the rules from the specified tables that match any of the supplied comments.
+// If chain is not empty then all rules in that chain are returned instead.
+func (d Xtables) iptablesListRules(ipVersion uint, comments []string, chain string, fromTables ...string) ([]string, error) {
+	var cmd string
+	var tablesFile string
+	if ipVersion == 4 {
+		cmd = "iptables"
+		tablesFile = "/proc/self/net/ip_tables_names"
+	} else if ipVersion == 6 {
+		cmd = "ip6tables"
+		tablesFile = "/proc/self/net/ip6_tables_names"
+	} else {
+		return nil, fmt.Errorf("Invalid IP version")
+	}
+
+	// Detect kernels that lack IPv6 support.
+	if !shared.PathExists("/proc/sys/net/ipv6") && ipVersion == 6 {
+		return nil, nil
+	}
+
+	// Check command exists.
+	_, err := exec.LookPath(cmd)
+	if err != nil {
+		return nil, nil
+	}
+
+	// Check which tables exist.
+	content, err := os.ReadFile(tablesFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed getting list of tables from %q: %w", tablesFile, err)
+	}
+
+	tables := strings.Fields(string(content))
+
+	rules := []string{}
+	for _, fromTable := range fromTables {
+		if !shared.StringInSlice(fromTable, tables) {
+			continue
+		}
+
+		args := []string{"-w", "-t", fromTable, "-S"}
+		if chain != "" {
+			exists, _, err := d.iptablesChainExists(ipVersion, fromTable, chain)
+			if err != nil {
+				return nil, err
+			}
+
+			if !exists {
+				continue
+			}
+
+			args = append(args, chain)
+		}
+
+		output, err := shared.TryRunCommand(cmd, args...)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list IPv%d rules (table %s)", ipVersion, fromTable)
+		}
+
+		for _, line := range strings.Split(output, "\n") {
+			if line == "" {
+				continue
+			}
+
+			match := chain != ""
+			for _, comment := range comments {
+				if strings.Contains(line, fmt.Sprintf("%s %s", iptablesCommentPrefix, comment)) {
+					match = true
+					break
+				}
+			}
+
+			if match {
+				rules = append(rules, fmt.Sprintf("%s -t %s %s", cmd, fromTable, line))
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// networkLoadedRules returns the rules currently loaded for the network.
+func (d Xtables) networkLoadedRules(networkName string) (string, error) {
+	comments := []string{
+		d.networkIPTablesComment(networkName),
+		d.networkForwardIPTablesComment(networkName),
+	}
+
+	aclFilterChain := fmt.Sprintf("%s_%s", iptablesChainACLFilterPrefix, networkName)
+
+	rules := []string{}
+	for _, ipVersion := range []uint{4, 6} {
+		commentRules, err := d.iptablesListRules(ipVersion, comments, "", "filter", "mangle", "nat")
+		if err != nil {
+			return "", err
+		}
+
+		aclRules, err := d.iptablesListRules(ipVersion, nil, aclFilterChain, "filter")
+		if err != nil {
+			return "", err
+		}
+
+		rules = append(rules, commentRules...)
+		rules = append(rules, aclRules...)
+	}
+
+	if len(rules) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(rules, "\n") + "\n", nil
+}
+
+// instanceLoadedRules returns the rules currently loaded for the instance device.
+// The ebtables rules are only included if the host interface name is known.
+func (d Xtables) instanceLoadedRules(projectName string, instanceName string, deviceName string, hostName string) (string, error) {
+	comment := d.instanceDeviceIPTablesComment(projectName, instanceName, deviceName)
+	comments := []string{comment, fmt.Sprintf("%s rpfilter", comment)}
+
+	rules := []string{}
+	for _, ipVersion := range []uint{4, 6} {
+		commentRules, err := d.iptablesListRules(ipVersion, comments, "", "filter", "nat", "raw")
+		if err != nil {
+			return "", err
+		}
+
+		rules = append(rules, commentRules...)
+	}
+
+	_, err := exec.LookPath("ebtables")
+	if hostName != "" && err == nil {
+		ebtablesMu.Lock()
+		out, err := shared.RunCommand("ebtables", "-L", "--Lmac2", "--Lx")
+		ebtablesMu.Unlock()
+		if err != nil {
+			return "", fmt.Errorf("Failed to get a list of network filters for %q: %w", deviceName, err)
+		}
+
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			if shared.StringInSlice("-i", fields) && shared.StringInSlice(hostName, fields) {
+				rules = append(rules, strings.Join(fields, " "))
+			}
+		}
+	}
+
+	if len(rules) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(rules, "\n") + "\n", nil
+}
+
+// NetworkRuleset returns the rules generated for the network from the specified options, ACL rules and address
+// forwards alongside the rules currently loaded for it.
+func (d Xtables) NetworkRuleset(networkName string, opts Opts, aclRules []ACLRule, forwards []AddressForward) (*Ruleset, error) {
+	generator := Xtables{generator: &rulesetGenerator{}}
+
+	err := generator.NetworkSetup(networkName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ACL {
+		err = generator.NetworkApplyACLRules(networkName, aclRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = generator.NetworkApplyForwards(networkName, forwards)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := d.networkLoadedRules(networkName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing xtables rules for network %q: %w", networkName, err)
+	}
+
+	return generator.generator.ruleset(loaded, xtablesNormalizeRules), nil
+}
+
+// InstanceRuleset returns the rules generated for the instance device from the specified options alongside the
+// rules currently loaded for it. The host interface name is used to find the ebtables rules for the device.
+func (d Xtables) InstanceRuleset(projectName string, instanceName string, deviceName string, opts InstanceOpts) (*Ruleset, error) {
+	generator := Xtables{generator: &rulesetGenerator{}}
+
+	if opts.BridgeFilter != nil {
+		err := generator.InstanceSetupBridgeFilter(projectName, instanceName, deviceName, opts.BridgeFilter.ParentName, opts.HostName, opts.BridgeFilter.HWAddr, opts.BridgeFilter.IPv4Nets, opts.BridgeFilter.IPv6Nets, opts.BridgeFilter.ParentManaged)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.RPFilter {
+		err := generator.InstanceSetupRPFilter(projectName, instanceName, deviceName, opts.HostName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	loaded, err := d.instanceLoadedRules(projectName, instanceName, deviceName, opts.HostName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing xtables rules for instance device %q: %w", deviceName, err)
+	}
+
+	return generator.generator.ruleset(loaded, xtablesNormalizeRules), nil
+}
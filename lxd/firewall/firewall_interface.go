@@ -24,4 +24,7 @@ type Firewall interface {
 
 	InstanceSetupRPFilter(projectName string, instanceName string, deviceName string, hostName string) error
 	InstanceClearRPFilter(projectName string, instanceName string, deviceName string) error
+
+	NetworkRuleset(networkName string, opts drivers.Opts, aclRules []drivers.ACLRule, forwards []drivers.AddressForward) (*drivers.Ruleset, error)
+	InstanceRuleset(projectName string, instanceName string, deviceName string, opts drivers.InstanceOpts) (*drivers.Ruleset, error)
 }
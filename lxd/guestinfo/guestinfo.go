@@ -0,0 +1,394 @@
+// Package guestinfo collects details about the OS running in an instance.
+//
+// Files of the guest are read relative to its root directory (/proc/PID/root for containers, / in the lxd-agent),
+// without following symlinks out of it. Process and network details come from the procfs directory of the guest's
+// init process (/proc/PID for containers, /proc/1 in the lxd-agent).
+package guestinfo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// clockTicks is the number of clock ticks per second used in /proc/PID/stat (USER_HZ, 100 on all architectures).
+const clockTicks = 100
+
+// Get returns the details of the guest OS with its root filesystem at root and its init process at procPath.
+// Details which can't be retrieved are left empty.
+func Get(root string, procPath string) *api.InstanceStateOS {
+	info := &api.InstanceStateOS{
+		Users:          []api.InstanceStateOSUser{},
+		Packages:       map[string]int64{},
+		ListeningPorts: []api.InstanceStateOSPort{},
+	}
+
+	info.Kernel = readLine(filepath.Join(procPath, "..", "sys", "kernel", "osrelease"))
+	info.Uptime = uptime(procPath)
+
+	ports, err := listeningPorts(procPath)
+	if err == nil {
+		info.ListeningPorts = ports
+	}
+
+	rootFD, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return info
+	}
+
+	defer func() { _ = unix.Close(rootFD) }()
+
+	info.Name, info.Version = osRelease(rootFD)
+
+	data, err := readFile(rootFD, "/etc/hostname")
+	if err == nil {
+		info.Hostname = strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	}
+
+	data, err = readFile(rootFD, "/run/utmp")
+	if err == nil {
+		info.Users = parseUtmp(data)
+	}
+
+	info.Packages = packages(rootFD)
+
+	return info
+}
+
+// open opens path inside the root directory without following symlinks out of it.
+// Only regular files (or directories when opened with O_DIRECTORY) are opened, so that the guest can't make the
+// caller block on a FIFO or read from a device.
+func open(rootFD int, path string, flags int) (*os.File, error) {
+	flags |= unix.O_NONBLOCK
+
+	fd, err := unix.Openat2(rootFD, path, &unix.OpenHow{
+		Flags:   uint64(flags | unix.O_CLOEXEC),
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if errors.Is(err, unix.ENOSYS) {
+		// Without openat2, walk the path refusing any symlink along the way.
+		fd, err = unix.Dup(rootFD)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := strings.Split(strings.Trim(path, "/"), "/")
+		for i, part := range parts {
+			partFlags := unix.O_PATH
+			if i == len(parts)-1 {
+				partFlags = flags
+			}
+
+			nextFD, err := unix.Openat(fd, part, partFlags|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+			_ = unix.Close(fd)
+			if err != nil {
+				return nil, err
+			}
+
+			fd = nextFD
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var stat unix.Stat_t
+	err = unix.Fstat(fd, &stat)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	fileType := uint32(unix.S_IFREG)
+	if flags&unix.O_DIRECTORY != 0 {
+		fileType = unix.S_IFDIR
+	}
+
+	if stat.Mode&unix.S_IFMT != fileType {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("Unexpected file type of %q", path)
+	}
+
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// readFile reads the file at path inside the root directory, up to 64MiB.
+func readFile(rootFD int, path string) ([]byte, error) {
+	f, err := open(rootFD, path, unix.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	return io.ReadAll(io.LimitReader(f, 64*1024*1024))
+}
+
+// readLine returns the first line of the file at path (outside of the guest's root).
+func readLine(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+}
+
+// osRelease returns the name and version of the OS from os-release.
+func osRelease(rootFD int) (string, string) {
+	data, err := readFile(rootFD, "/etc/os-release")
+	if err != nil {
+		data, err = readFile(rootFD, "/usr/lib/os-release")
+		if err != nil {
+			return "", ""
+		}
+	}
+
+	fields := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found || strings.HasPrefix(key, "#") {
+			continue
+		}
+
+		unquoted, err := strconv.Unquote(value)
+		if err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+
+		fields[key] = value
+	}
+
+	version := fields["VERSION_ID"]
+	if version == "" {
+		version = fields["VERSION"]
+	}
+
+	return fields["NAME"], version
+}
+
+// uptime returns the number of seconds since the init process started.
+func uptime(procPath string) int64 {
+	uptimeFields := strings.Fields(readLine(filepath.Join(procPath, "..", "uptime")))
+	if len(uptimeFields) == 0 {
+		return 0
+	}
+
+	sinceBoot, err := strconv.ParseFloat(uptimeFields[0], 64)
+	if err != nil {
+		return 0
+	}
+
+	// The fields following the command name, which can contain spaces, start with the state (field 3).
+	stat := readLine(filepath.Join(procPath, "stat"))
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0
+	}
+
+	statFields := strings.Fields(stat[end+1:])
+	if len(statFields) < 20 {
+		return 0
+	}
+
+	startTicks, err := strconv.ParseInt(statFields[19], 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return int64(sinceBoot) - startTicks/clockTicks
+}
+
+// utmpRecordSize is the size of a glibc utmp record on 64bit architectures.
+const utmpRecordSize = 384
+
+// utmpUserProcess is the utmp record type of logged-in users.
+const utmpUserProcess = 7
+
+// parseUtmp returns the logged-in users from the content of a utmp file.
+func parseUtmp(data []byte) []api.InstanceStateOSUser {
+	users := []api.InstanceStateOSUser{}
+
+	cString := func(b []byte) string {
+		end := bytes.IndexByte(b, 0)
+		if end >= 0 {
+			b = b[:end]
+		}
+
+		return string(b)
+	}
+
+	for off := 0; off+utmpRecordSize <= len(data); off += utmpRecordSize {
+		record := data[off : off+utmpRecordSize]
+		if int16(binary.LittleEndian.Uint16(record[0:2])) != utmpUserProcess {
+			continue
+		}
+
+		users = append(users, api.InstanceStateOSUser{
+			Name:      cString(record[44:76]),
+			Terminal:  cString(record[8:40]),
+			Host:      cString(record[76:332]),
+			LoginTime: time.Unix(int64(int32(binary.LittleEndian.Uint32(record[340:344]))), 0).UTC(),
+		})
+	}
+
+	return users
+}
+
+// packages returns the number of installed packages of the known package managers.
+func packages(rootFD int) map[string]int64 {
+	counts := map[string]int64{}
+
+	countLines := func(path string, match func(line string) bool) (int64, error) {
+		f, err := open(rootFD, path, unix.O_RDONLY)
+		if err != nil {
+			return 0, err
+		}
+
+		defer func() { _ = f.Close() }()
+
+		count := int64(0)
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if match(scanner.Text()) {
+				count++
+			}
+		}
+
+		return count, scanner.Err()
+	}
+
+	count, err := countLines("/var/lib/dpkg/status", func(line string) bool { return line == "Status: install ok installed" })
+	if err == nil {
+		counts["dpkg"] = count
+	}
+
+	count, err = countLines("/lib/apk/db/installed", func(line string) bool { return strings.HasPrefix(line, "P:") })
+	if err == nil {
+		counts["apk"] = count
+	}
+
+	// Each package has its own directory in the pacman database.
+	f, err := open(rootFD, "/var/lib/pacman/local", unix.O_RDONLY|unix.O_DIRECTORY)
+	if err == nil {
+		entries, err := f.ReadDir(-1)
+		if err == nil {
+			count := int64(0)
+			for _, entry := range entries {
+				if entry.IsDir() {
+					count++
+				}
+			}
+
+			counts["pacman"] = count
+		}
+
+		_ = f.Close()
+	}
+
+	return counts
+}
+
+// listeningPorts returns the TCP ports listened on and the unconnected UDP ports bound in the network namespace
+// of the process at procPath.
+func listeningPorts(procPath string) ([]api.InstanceStateOSPort, error) {
+	// Socket states as found in the kernel's tcp_states.h.
+	const tcpListen = "0A"
+	const tcpClose = "07"
+
+	ports := []api.InstanceStateOSPort{}
+	seen := map[api.InstanceStateOSPort]bool{}
+
+	for _, table := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		data, err := os.ReadFile(filepath.Join(procPath, "net", table))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		protocol := strings.TrimSuffix(table, "6")
+		state := tcpListen
+		if protocol == "udp" {
+			state = tcpClose
+		}
+
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 4 || fields[3] != state {
+				continue
+			}
+
+			address, port, err := parseProcNetAddress(fields[1])
+			if err != nil {
+				return nil, err
+			}
+
+			entry := api.InstanceStateOSPort{Protocol: protocol, Address: address, Port: port}
+			if seen[entry] {
+				continue
+			}
+
+			seen[entry] = true
+			ports = append(ports, entry)
+		}
+	}
+
+	sort.SliceStable(ports, func(i, j int) bool {
+		if ports[i].Protocol != ports[j].Protocol {
+			return ports[i].Protocol < ports[j].Protocol
+		}
+
+		return ports[i].Port < ports[j].Port
+	})
+
+	return ports, nil
+}
+
+// parseProcNetAddress parses an ADDRESS:PORT field of /proc/net/{tcp,udp}[6], where the address is made of 32bit
+// words in host byte order (assumed little endian, as for utmp records).
+func parseProcNetAddress(field string) (string, int64, error) {
+	addressHex, portHex, found := strings.Cut(field, ":")
+	if !found {
+		return "", 0, fmt.Errorf("Invalid socket address %q", field)
+	}
+
+	port, err := strconv.ParseInt(portHex, 16, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid socket port %q: %w", field, err)
+	}
+
+	raw, err := hex.DecodeString(addressHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, fmt.Errorf("Invalid socket address %q", field)
+	}
+
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		word := binary.LittleEndian.Uint32(raw[i : i+4])
+		binary.BigEndian.PutUint32(ip[i:i+4], word)
+	}
+
+	return ip.String(), port, nil
+}
@@ -0,0 +1,109 @@
+package guestinfo
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func utmpRecord(recordType int16, user string, line string, host string, loginTime int32) []byte {
+	record := make([]byte, utmpRecordSize)
+	binary.LittleEndian.PutUint16(record[0:2], uint16(recordType))
+	copy(record[8:40], line)
+	copy(record[44:76], user)
+	copy(record[76:332], host)
+	binary.LittleEndian.PutUint32(record[340:344], uint32(loginTime))
+
+	return record
+}
+
+func TestGet(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	procPath := filepath.Join(dir, "proc", "1")
+
+	writeFile(t, filepath.Join(root, "usr", "lib", "os-release"), "NAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\n# comment\n")
+	writeFile(t, filepath.Join(root, "etc", "hostname"), "c1\n")
+	require.NoError(t, os.Symlink("/usr/lib/os-release", filepath.Join(root, "etc", "os-release")))
+	writeFile(t, filepath.Join(root, "var", "lib", "dpkg", "status"), "Package: a\nStatus: install ok installed\n\nPackage: b\nStatus: deinstall ok config-files\n\nPackage: c\nStatus: install ok installed\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "var", "lib", "pacman", "local", "bash-5.1-1"), 0755))
+	writeFile(t, filepath.Join(root, "var", "lib", "pacman", "local", "ALPM_DB_VERSION"), "9\n")
+
+	utmp := append(utmpRecord(2, "reboot", "~", "", 1000), utmpRecord(utmpUserProcess, "ubuntu", "pts/0", "10.0.0.1", 1656633600)...)
+	writeFile(t, filepath.Join(root, "run", "utmp"), string(utmp))
+
+	// Symlinks can't escape the root.
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "lib", "apk", "db"), 0755))
+	writeFile(t, filepath.Join(dir, "lib", "apk", "db", "installed"), "P:outside\n")
+	require.NoError(t, os.Symlink("../../../../lib/apk/db/installed", filepath.Join(root, "lib", "apk", "db", "installed")))
+
+	writeFile(t, filepath.Join(dir, "proc", "sys", "kernel", "osrelease"), "5.15.0-48-generic\n")
+	writeFile(t, filepath.Join(dir, "proc", "uptime"), "3700.50 1000.00\n")
+	writeFile(t, filepath.Join(procPath, "stat"), "1 (init (x)) S 0 1 1 0 -1 4194560 0 0 0 0 0 0 0 0 20 0 1 0 10000 0 0\n")
+	writeFile(t, filepath.Join(procPath, "net", "tcp"), `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1000 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0016 0100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+`)
+	writeFile(t, filepath.Join(procPath, "net", "tcp6"), `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000001000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 100 0 0 10 0
+`)
+	writeFile(t, filepath.Join(procPath, "net", "udp"), `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  0: 3500007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1003 2 0000000000000000 0
+  1: 3500007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 1004 2 0000000000000000 0
+`)
+
+	info := Get(root, procPath)
+
+	assert.Equal(t, "Ubuntu", info.Name)
+	assert.Equal(t, "22.04", info.Version)
+	assert.Equal(t, "5.15.0-48-generic", info.Kernel)
+	assert.Equal(t, "c1", info.Hostname)
+	assert.EqualValues(t, 3600, info.Uptime)
+	assert.Equal(t, map[string]int64{"dpkg": 2, "pacman": 1}, info.Packages)
+	assert.Equal(t, []api.InstanceStateOSUser{{Name: "ubuntu", Terminal: "pts/0", Host: "10.0.0.1", LoginTime: time.Unix(1656633600, 0).UTC()}}, info.Users)
+	assert.Equal(t, []api.InstanceStateOSPort{
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 22},
+		{Protocol: "tcp", Address: "::1", Port: 80},
+		{Protocol: "udp", Address: "127.0.0.53", Port: 53},
+	}, info.ListeningPorts)
+}
+
+func TestGetSpecialFiles(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+
+	// FIFOs and directories in place of the files are skipped rather than blocking or failing.
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "etc", "os-release"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "run"), 0755))
+	require.NoError(t, unix.Mkfifo(filepath.Join(root, "etc", "hostname"), 0644))
+	require.NoError(t, unix.Mkfifo(filepath.Join(root, "run", "utmp"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "var", "lib", "dpkg"), 0755))
+	require.NoError(t, unix.Mkfifo(filepath.Join(root, "var", "lib", "dpkg", "status"), 0644))
+	writeFile(t, filepath.Join(root, "var", "lib", "pacman", "local"), "")
+
+	done := make(chan *api.InstanceStateOS)
+	go func() { done <- Get(root, filepath.Join(dir, "proc", "1")) }()
+
+	select {
+	case info := <-done:
+		assert.Empty(t, info.Name)
+		assert.Empty(t, info.Hostname)
+		assert.Empty(t, info.Users)
+		assert.Empty(t, info.Packages)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reading the guest details blocked")
+	}
+}
@@ -684,21 +684,43 @@ func getImgPostInfo(d *Daemon, r *http.Request, builddir string, project string,
 			return nil, err
 		}
 
+		imageFile := post.Name()
+
 		var imageType string
-		imageMeta, imageType, err = getImageMetadata(post.Name())
+		imageMeta, imageType, err = getImageMetadata(imageFile)
 		if err != nil {
-			l.Error("Failed to get image metadata", logger.Ctx{"err": err})
-			return nil, err
+			// OCI image archives get converted into unified images.
+			archiveType := ociArchiveType(imageFile)
+			if archiveType == "" {
+				l.Error("Failed to get image metadata", logger.Ctx{"err": err})
+				return nil, err
+			}
+
+			imageFile, info.Fingerprint, imageMeta, err = imgPostOCIArchive(d, builddir, archiveType, post.Name(), info.Filename)
+			if err != nil {
+				l.Error("Failed to convert the OCI image", logger.Ctx{"err": err})
+				return nil, err
+			}
+
+			defer func() { _ = os.Remove(imageFile) }()
+
+			fi, err := os.Stat(imageFile)
+			if err != nil {
+				return nil, err
+			}
+
+			info.Size = fi.Size()
+			imageType = "container"
 		}
 
 		info.Type = imageType
 
 		imgfname := shared.VarPath("images", info.Fingerprint)
-		err = shared.FileMove(post.Name(), imgfname)
+		err = shared.FileMove(imageFile, imgfname)
 		if err != nil {
 			l.Error("Failed to move the tarfile", logger.Ctx{
 				"err":    err,
-				"source": post.Name(),
+				"source": imageFile,
 				"dest":   imgfname})
 			return nil, err
 		}
@@ -780,6 +802,37 @@ func getImgPostInfo(d *Daemon, r *http.Request, builddir string, project string,
 	return &info, nil
 }
 
+// imgPostOCIArchive converts an uploaded OCI image archive (oci-archive or docker-archive) into a unified image
+// tarball, returning its path, fingerprint and metadata.
+func imgPostOCIArchive(d *Daemon, builddir string, archiveType string, path string, filename string) (string, string, *api.ImageMetadata, error) {
+	imageFile, err := ioutil.TempFile(builddir, "lxd_oci_")
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	defer func() { _ = imageFile.Close() }()
+
+	description := filename
+	if description == "" {
+		description = "OCI image"
+	}
+
+	sha256 := sha256.New()
+	imageMeta, err := ociImageUnpack(fmt.Sprintf("%s:%s", archiveType, path), true, description, d.State().GlobalConfig.ImagesCompressionAlgorithm(), io.MultiWriter(imageFile, sha256))
+	if err != nil {
+		_ = os.Remove(imageFile.Name())
+		return "", "", nil, err
+	}
+
+	err = imageFile.Close()
+	if err != nil {
+		_ = os.Remove(imageFile.Name())
+		return "", "", nil, err
+	}
+
+	return imageFile.Name(), fmt.Sprintf("%x", sha256.Sum(nil)), imageMeta, nil
+}
+
 // imageCreateInPool() creates a new storage volume in a given storage pool for
 // the image. No entry in the images database will be created. This implies that
 // imageCreateinPool() should only be called when an image already exists in the
@@ -17,6 +17,7 @@ import (
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/instance/operationlock"
+	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
@@ -26,6 +27,7 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
 )
 
 // Helper functions
@@ -83,6 +85,20 @@ func instanceImageTransfer(d *Daemon, r *http.Request, projectName string, hash
 	return nil
 }
 
+// instanceImagePropertyConfigKey returns the instance configuration key an image property is mapped to.
+// An empty string is returned for properties which aren't mapped to the instance configuration.
+func instanceImagePropertyConfigKey(property string) string {
+	if !strings.HasPrefix(property, "oci.") || property == "oci.reference" {
+		return ""
+	}
+
+	if strings.HasPrefix(property, "oci.env.") {
+		return fmt.Sprintf("environment.%s", strings.TrimPrefix(property, "oci.env."))
+	}
+
+	return property
+}
+
 // instanceCreateFromImage creates an instance from a rootfs image.
 func instanceCreateFromImage(d *Daemon, r *http.Request, args db.InstanceArgs, hash string, op *operations.Operation) (instance.Instance, error) {
 	revert := revert.New()
@@ -133,6 +149,73 @@ func instanceCreateFromImage(d *Daemon, r *http.Request, args db.InstanceArgs, h
 		return nil, fmt.Errorf("Requested image's type '%s' doesn't match instance type '%s'", imgType, args.Type)
 	}
 
+	err = ensureImageIsLocallyAvailable(d, r, img, args.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the "image.*" keys.
+	if img.Properties != nil {
+		for k, v := range img.Properties {
+			args.Config[fmt.Sprintf("image.%s", k)] = v
+		}
+	}
+
+	// Map the configuration of OCI images to the instance configuration, unless explicitly set.
+	for k, v := range img.Properties {
+		key := instanceImagePropertyConfigKey(k)
+		if key == "" {
+			continue
+		}
+
+		_, found := args.Config[key]
+		if !found {
+			args.Config[key] = v
+		}
+	}
+
+	// Set the BaseImage field (regardless of previous value).
+	args.BaseImage = hash
+
+	// Create the instance.
+	inst, instOp, cleanup, err := instance.CreateInternal(s, args, true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed creating instance record: %w", err)
+	}
+
+	revert.Add(cleanup)
+	defer instOp.Done(nil)
+
+	err = s.DB.Cluster.UpdateImageLastUseDate(hash, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("Error updating image last use date: %s", err)
+	}
+
+	pool, err := storagePools.LoadByInstance(d.State(), inst)
+	if err != nil {
+		return nil, fmt.Errorf("Failed loading instance storage pool: %w", err)
+	}
+
+	err = pool.CreateInstanceFromImage(inst, hash, op)
+	if err != nil {
+		return nil, fmt.Errorf("Failed creating instance from image: %w", err)
+	}
+
+	revert.Add(func() { _ = inst.Delete(true) })
+
+	err = inst.UpdateBackupFile()
+	if err != nil {
+		return nil, err
+	}
+
+	revert.Success()
+	return inst, nil
+}
+
+// ensureImageIsLocallyAvailable transfers the image from another cluster member if it isn't available locally.
+func ensureImageIsLocallyAvailable(d *Daemon, r *http.Request, img *api.Image, projectName string) error {
+	s := d.State()
+
 	// Check if the image is available locally or it's on another member.
 	// Ensure we are the only ones operating on this image. Otherwise another instance created at the same
 	// time may also arrive at the conclusion that the image doesn't exist on this cluster member and then
@@ -140,73 +223,171 @@ func instanceCreateFromImage(d *Daemon, r *http.Request, args db.InstanceArgs, h
 	// duplicate record errors.
 	unlock := d.imageOperationLock(img.Fingerprint)
 
-	nodeAddress, err := s.DB.Cluster.LocateImage(hash)
+	nodeAddress, err := s.DB.Cluster.LocateImage(img.Fingerprint)
 	if err != nil {
 		unlock()
-		return nil, fmt.Errorf("Locate image %q in the cluster: %w", hash, err)
+		return fmt.Errorf("Locate image %q in the cluster: %w", img.Fingerprint, err)
 	}
 
 	if nodeAddress != "" {
 		// The image is available from another node, let's try to import it.
-		err = instanceImageTransfer(d, r, args.Project, img.Fingerprint, nodeAddress)
+		err = instanceImageTransfer(d, r, projectName, img.Fingerprint, nodeAddress)
 		if err != nil {
 			unlock()
-			return nil, fmt.Errorf("Failed transferring image %q from %q: %w", img.Fingerprint, nodeAddress, err)
+			return fmt.Errorf("Failed transferring image %q from %q: %w", img.Fingerprint, nodeAddress, err)
 		}
 
 		// As the image record already exists in the project, just add the node ID to the image.
-		err = d.db.Cluster.AddImageToLocalNode(args.Project, img.Fingerprint)
+		err = d.db.Cluster.AddImageToLocalNode(projectName, img.Fingerprint)
 		if err != nil {
 			unlock()
-			return nil, fmt.Errorf("Failed adding transferred image %q record to local cluster member: %w", img.Fingerprint, err)
+			return fmt.Errorf("Failed adding transferred image %q record to local cluster member: %w", img.Fingerprint, err)
 		}
 	}
 
 	unlock() // Image is available locally.
 
-	// Set the "image.*" keys.
-	if img.Properties != nil {
-		for k, v := range img.Properties {
-			args.Config[fmt.Sprintf("image.%s", k)] = v
-		}
+	return nil
+}
+
+// instanceRebuildFromImage replaces the root volume of a stopped instance with the image provided.
+// The instance keeps its configuration, devices, profiles, volatile keys and snapshots.
+func instanceRebuildFromImage(d *Daemon, r *http.Request, inst instance.Instance, img *api.Image, op *operations.Operation) error {
+	s := d.State()
+
+	// Validate the type and architecture of the image match the instance.
+	imgType, err := instancetype.New(img.Type)
+	if err != nil {
+		return err
 	}
 
-	// Set the BaseImage field (regardless of previous value).
-	args.BaseImage = hash
+	if imgType != inst.Type() {
+		return fmt.Errorf("Requested image's type %q doesn't match instance type %q", imgType, inst.Type())
+	}
 
-	// Create the instance.
-	inst, instOp, cleanup, err := instance.CreateInternal(s, args, true)
+	imgArch, err := osarch.ArchitectureId(img.Architecture)
 	if err != nil {
-		return nil, fmt.Errorf("Failed creating instance record: %w", err)
+		return err
+	}
+
+	if imgArch != inst.Architecture() {
+		instArch, _ := osarch.ArchitectureName(inst.Architecture())
+		return fmt.Errorf("Requested image's architecture %q doesn't match instance architecture %q", img.Architecture, instArch)
+	}
+
+	err = ensureImageIsLocallyAvailable(d, r, img, inst.Project())
+	if err != nil {
+		return err
+	}
+
+	instOp, err := operationlock.Create(inst.Project(), inst.Name(), operationlock.ActionRebuild, false, false)
+	if err != nil {
+		return fmt.Errorf("Failed creating instance rebuild operation: %w", err)
 	}
 
-	revert.Add(cleanup)
 	defer instOp.Done(nil)
 
-	err = s.DB.Cluster.UpdateImageLastUseDate(hash, time.Now().UTC())
+	if inst.IsRunning() {
+		return fmt.Errorf("The instance must be stopped to be rebuilt")
+	}
+
+	err = s.DB.Cluster.UpdateImageLastUseDate(img.Fingerprint, time.Now().UTC())
 	if err != nil {
-		return nil, fmt.Errorf("Error updating image last use date: %s", err)
+		return fmt.Errorf("Error updating image last use date: %w", err)
 	}
 
-	pool, err := storagePools.LoadByInstance(d.State(), inst)
+	pool, err := storagePools.LoadByInstance(s, inst)
 	if err != nil {
-		return nil, fmt.Errorf("Failed loading instance storage pool: %w", err)
+		return fmt.Errorf("Failed loading instance storage pool: %w", err)
 	}
 
-	err = pool.CreateInstanceFromImage(inst, hash, op)
+	postHook, revertHook, err := pool.RebuildInstance(inst, img.Fingerprint, op)
 	if err != nil {
-		return nil, fmt.Errorf("Failed creating instance from image: %w", err)
+		return err
 	}
 
-	revert.Add(func() { _ = inst.Delete(true) })
+	revert := revert.New()
+	defer revert.Fail()
 
-	err = inst.UpdateBackupFile()
+	// Restore the previous content of the volume if the instance can't be updated to match the new image.
+	revert.Add(revertHook)
+
+	// Replace the "image.*" keys, and the configuration mapped from them unless it was changed since.
+	config := make(map[string]string, len(inst.LocalConfig()))
+	oldImageProperties := map[string]string{}
+	for k, v := range inst.LocalConfig() {
+		if strings.HasPrefix(k, "image.") {
+			oldImageProperties[strings.TrimPrefix(k, "image.")] = v
+			continue
+		}
+
+		config[k] = v
+	}
+
+	for k, v := range img.Properties {
+		config[fmt.Sprintf("image.%s", k)] = v
+	}
+
+	for k, oldValue := range oldImageProperties {
+		key := instanceImagePropertyConfigKey(k)
+		if key == "" || config[key] != oldValue {
+			continue
+		}
+
+		delete(config, key)
+	}
+
+	for k, v := range img.Properties {
+		key := instanceImagePropertyConfigKey(k)
+		if key == "" {
+			continue
+		}
+
+		_, found := config[key]
+		if !found {
+			config[key] = v
+		}
+	}
+
+	// Have the templates applied and, for containers, the new root filesystem shifted on next start.
+	config["volatile.base_image"] = img.Fingerprint
+	config["volatile.apply_template"] = string(instance.TemplateTriggerCreate)
+	if inst.Type() == instancetype.Container {
+		config["volatile.last_state.idmap"] = "[]"
+	}
+
+	args := db.InstanceArgs{
+		Architecture: inst.Architecture(),
+		Config:       config,
+		Description:  inst.Description(),
+		Devices:      inst.LocalDevices(),
+		Ephemeral:    inst.IsEphemeral(),
+		Profiles:     inst.Profiles(),
+		Project:      inst.Project(),
+		Type:         inst.Type(),
+		Snapshot:     false,
+	}
+
+	err = inst.Update(args, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	revert.Success()
-	return inst, nil
+
+	err = postHook()
+	if err != nil {
+		logger.Warn("Failed cleaning up after instance rebuild", logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+	}
+
+	err = inst.UpdateBackupFile()
+	if err != nil {
+		return err
+	}
+
+	s.Events.SendLifecycle(inst.Project(), lifecycle.InstanceRebuilt.Event(inst, map[string]any{"fingerprint": img.Fingerprint}))
+
+	return nil
 }
 
 // instanceCreateAsCopyOpts options for copying an instance.
@@ -18,23 +18,33 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/lxc/lxd/lxd/db/warningtype"
 	"github.com/lxc/lxd/lxd/device"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/device/nictype"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/instance/operationlock"
+	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/maas"
+	"github.com/lxc/lxd/lxd/metrics"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/lxd/warnings"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
 )
 
+// autoRestartMaxDelay is the maximum delay between automatic restarts of an instance. Instances that ran for longer
+// than that before stopping are restarted as if they had not been restarted before.
+const autoRestartMaxDelay = 10 * time.Minute
+
 // ErrInstanceIsStopped indicates that the instance is stopped.
 var ErrInstanceIsStopped error = fmt.Errorf("The instance is already stopped")
 
@@ -71,6 +81,10 @@ type common struct {
 	snapshot        bool
 	stateful        bool
 
+	// Set when the instance record was taken over by another cluster member the instance was moved to, so that
+	// the volatile config is only changed locally while stopping the instance here.
+	recordMoved bool
+
 	// Cached handles.
 	// Do not use these variables directly, instead use their associated get functions so they
 	// will be initialised on demand.
@@ -306,7 +320,7 @@ func (d *common) VolatileSet(changes map[string]string) error {
 		err = d.state.DB.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
 			return tx.UpdateInstanceSnapshotConfig(d.id, changes)
 		})
-	} else {
+	} else if !d.recordMoved {
 		err = d.state.DB.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
 			return tx.UpdateInstanceConfig(d.id, changes)
 		})
@@ -913,6 +927,88 @@ func (d *common) onStopOperationSetup(target string) (*operationlock.InstanceOpe
 	return op, instanceInitiated, nil
 }
 
+// autoRestart restarts the instance after it stopped without being asked to, if its boot.autorestart policy
+// allows it. The failed argument indicates whether the instance stopped due to a failure rather than a shutdown.
+// Consecutive restarts are delayed exponentially, starting from boot.autorestart.delay seconds, and a warning is
+// recorded once boot.autorestart.retries consecutive restarts have been attempted.
+func (d *common) autoRestart(failed bool) {
+	policy := d.expandedConfig["boot.autorestart"]
+	if policy != "always" && (policy != "on-failure" || !failed) {
+		return
+	}
+
+	retries := 3
+	if d.expandedConfig["boot.autorestart.retries"] != "" {
+		retries, _ = strconv.Atoi(d.expandedConfig["boot.autorestart.retries"])
+	}
+
+	delay := 5 * time.Second
+	if d.expandedConfig["boot.autorestart.delay"] != "" {
+		delaySeconds, _ := strconv.Atoi(d.expandedConfig["boot.autorestart.delay"])
+		delay = time.Duration(delaySeconds) * time.Second
+	}
+
+	// Start counting again if the instance had been running long enough since its last start.
+	count, _ := strconv.Atoi(d.localConfig["volatile.autorestart.count"])
+	if time.Since(d.lastUsedDate) > autoRestartMaxDelay {
+		count = 0
+	}
+
+	for {
+		if retries > 0 && count >= retries {
+			d.logger.Error("Giving up restarting instance", logger.Ctx{"attempts": count})
+
+			err := d.state.DB.Cluster.UpsertWarningLocalNode(d.project, dbCluster.TypeInstance, d.id, warningtype.InstanceRestartFailure, fmt.Sprintf("Instance stopped and %d restart attempts failed", count))
+			if err != nil {
+				d.logger.Warn("Failed to create instance restart failure warning", logger.Ctx{"err": err})
+			}
+
+			return
+		}
+
+		wait := delay << count
+		if wait > autoRestartMaxDelay || wait < delay {
+			wait = autoRestartMaxDelay
+		}
+
+		count++
+
+		d.logger.Info("Restarting instance", logger.Ctx{"attempt": count, "delay": wait})
+		time.Sleep(wait)
+
+		// Reload the instance in case it has been changed, deleted or started in the meantime.
+		inst, err := instance.LoadByProjectAndName(d.state, d.project, d.name)
+		if err != nil {
+			d.logger.Warn("Failed loading instance to restart", logger.Ctx{"err": err})
+			return
+		}
+
+		if inst.IsRunning() || !shared.StringInSlice(inst.ExpandedConfig()["boot.autorestart"], []string{"always", "on-failure"}) {
+			return
+		}
+
+		err = inst.VolatileSet(map[string]string{"volatile.autorestart.count": strconv.Itoa(count)})
+		if err != nil {
+			d.logger.Warn("Failed recording restart attempt", logger.Ctx{"err": err})
+		}
+
+		err = inst.Start(false)
+		if err != nil {
+			d.logger.Warn("Failed restarting instance", logger.Ctx{"attempt": count, "err": err})
+			continue
+		}
+
+		err = warnings.ResolveWarningsByLocalNodeAndProjectAndTypeAndEntity(d.state.DB.Cluster, d.project, warningtype.InstanceRestartFailure, dbCluster.TypeInstance, d.id)
+		if err != nil {
+			d.logger.Warn("Failed to resolve instance restart failure warning", logger.Ctx{"err": err})
+		}
+
+		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceRestarted.Event(inst, nil))
+
+		return
+	}
+}
+
 // warningsDelete deletes any persistent warnings for the instance.
 func (d *common) warningsDelete() error {
 	err := d.state.DB.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
@@ -1132,6 +1228,103 @@ func (d *common) getStoragePool() (storagePools.Pool, error) {
 	return d.storagePool, nil
 }
 
+// healthState returns the health check state of the instance, or nil if it doesn't have a health check.
+func (d *common) healthState() *api.InstanceStateHealth {
+	if d.expandedConfig["healthcheck.type"] == "" {
+		return nil
+	}
+
+	status := d.localConfig["volatile.health.status"]
+	if status == "" {
+		status = "starting"
+	}
+
+	failures, _ := strconv.ParseInt(d.localConfig["volatile.health.failures"], 10, 64)
+
+	return &api.InstanceStateHealth{
+		Status:    status,
+		Failures:  failures,
+		LastError: d.localConfig["volatile.health.error"],
+	}
+}
+
+// networkTransferState returns the transfer accounting state of the named NIC device, or nil if its traffic
+// isn't accounted for.
+func (d *common) networkTransferState(devName string) *api.InstanceStateNetworkTransfer {
+	if d.localConfig[fmt.Sprintf("volatile.%s.transfer.rx", devName)] == "" {
+		return nil
+	}
+
+	transfer := deviceConfig.NICTransferFromVolatile(d.localConfig, devName)
+	periodStart := deviceConfig.NICTransferPeriodStart(time.Now(), deviceConfig.NICTransferResetDay(d.expandedConfig))
+
+	// Ignore invalid limits here, these are reported when the limit is applied.
+	limit, _ := units.ParseByteSizeString(d.expandedConfig["limits.network.transfer"])
+
+	state := &api.InstanceStateNetworkTransfer{
+		BytesReceived: transfer.BytesReceived,
+		BytesSent:     transfer.BytesSent,
+		PeriodStart:   periodStart,
+		Limit:         limit,
+		Limited:       transfer.Limited,
+	}
+
+	// The period counters are only relevant if they were last updated in the current period.
+	if transfer.PeriodStart.Equal(periodStart) {
+		state.PeriodBytesReceived = transfer.PeriodBytesReceived
+		state.PeriodBytesSent = transfer.PeriodBytesSent
+	}
+
+	return state
+}
+
+// networkTransferStateAdd adds the transfer accounting state of the NIC devices to the network state entries of
+// their host-side interfaces.
+func (d *common) networkTransferStateAdd(networks map[string]api.InstanceStateNetwork) {
+	for devName, m := range d.expandedDevices {
+		if m["type"] != "nic" {
+			continue
+		}
+
+		hostName := d.localConfig[fmt.Sprintf("volatile.%s.host_name", devName)]
+		if hostName == "" {
+			continue
+		}
+
+		transfer := d.networkTransferState(devName)
+		if transfer == nil {
+			continue
+		}
+
+		for netName, netStatus := range networks {
+			if netStatus.HostName == hostName {
+				netStatus.Transfer = transfer
+				networks[netName] = netStatus
+			}
+		}
+	}
+}
+
+// networkTransferMetrics adds the transfer accounting metrics of the NIC devices to the metric set.
+func (d *common) networkTransferMetrics(out *metrics.MetricSet) {
+	for devName, m := range d.expandedDevices {
+		if m["type"] != "nic" {
+			continue
+		}
+
+		transfer := d.networkTransferState(devName)
+		if transfer == nil {
+			continue
+		}
+
+		labels := map[string]string{"device": devName}
+
+		out.AddSamples(metrics.NetworkTransferReceiveBytesTotal, metrics.Sample{Value: float64(transfer.BytesReceived), Labels: labels})
+		out.AddSamples(metrics.NetworkTransferTransmitBytesTotal, metrics.Sample{Value: float64(transfer.BytesSent), Labels: labels})
+		out.AddSamples(metrics.NetworkTransferPeriodBytes, metrics.Sample{Value: float64(transfer.PeriodBytesReceived + transfer.PeriodBytesSent), Labels: labels})
+	}
+}
+
 // deviceLoad instantiates and validates a new device and returns it along with enriched config.
 func (d *common) deviceLoad(inst instance.Instance, deviceName string, rawConfig deviceConfig.Device) (device.Device, error) {
 	var configCopy deviceConfig.Device
@@ -1227,6 +1420,41 @@ func (d *common) devicesAdd(inst instance.Instance, instanceRunning bool) (rever
 	return cleanup, nil
 }
 
+// firewallRulesets returns the host firewall rules generated from the current config of each of the instance's NIC
+// devices alongside the rules loaded in the kernel.
+func (d *common) firewallRulesets(inst instance.Instance) (map[string]*firewallDrivers.Ruleset, error) {
+	rulesets := make(map[string]*firewallDrivers.Ruleset)
+
+	for _, entry := range d.expandedDevices.Sorted() {
+		if entry.Config["type"] != "nic" {
+			continue
+		}
+
+		var ruleset *firewallDrivers.Ruleset
+
+		dev, err := d.deviceLoad(inst, entry.Name, entry.Config)
+		if err != nil {
+			d.logger.Warn("Failed firewall validation for device", logger.Ctx{"device": entry.Name, "err": err})
+		}
+
+		// NICs that don't use the host firewall have no rules generated, but may have leftover rules loaded.
+		nic, ok := dev.(device.NICFirewall)
+		if ok {
+			ruleset, err = nic.FirewallRuleset()
+		} else {
+			ruleset, err = d.state.Firewall.InstanceRuleset(d.project, d.name, entry.Name, firewallDrivers.InstanceOpts{})
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed getting firewall rules for %q: %w", entry.Name, err)
+		}
+
+		rulesets[entry.Name] = ruleset
+	}
+
+	return rulesets, nil
+}
+
 // devicesRegister calls the Register() function on all of the instance's devices.
 func (d *common) devicesRegister(inst instance.Instance) {
 	for _, entry := range d.ExpandedDevices().Sorted() {
@@ -39,6 +39,9 @@ import (
 	"github.com/lxc/lxd/lxd/device"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/device/nictype"
+	"github.com/lxc/lxd/lxd/filewatch"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
+	"github.com/lxc/lxd/lxd/guestinfo"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/instance/operationlock"
@@ -204,6 +207,13 @@ func lxcCreate(s *state.State, args db.InstanceArgs) (instance.Instance, revert.
 		return nil, nil, fmt.Errorf("Invalid config: %w", err)
 	}
 
+	if !d.IsSnapshot() {
+		err = instance.ValidBootDependencies(s, d.project, d.name, d.expandedConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid config: %w", err)
+		}
+	}
+
 	err = instance.ValidDevices(s, d.Project(), d.Type(), d.expandedDevices, true)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Invalid devices: %w", err)
@@ -1003,6 +1013,31 @@ func (d *lxc) initLXC(config bool) error {
 		}
 	}
 
+	// Setup OCI application containers, run through LXC's minimal init.
+	if d.expandedConfig["oci.entrypoint"] != "" {
+		err = lxcSetConfigItem(cc, "lxc.execute.cmd", d.expandedConfig["oci.entrypoint"])
+		if err != nil {
+			return err
+		}
+
+		initKeys := map[string]string{
+			"oci.cwd": "lxc.init.cwd",
+			"oci.uid": "lxc.init.uid",
+			"oci.gid": "lxc.init.gid",
+		}
+
+		for key, lxcKey := range initKeys {
+			if d.expandedConfig[key] == "" {
+				continue
+			}
+
+			err = lxcSetConfigItem(cc, lxcKey, d.expandedConfig[key])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Setup NVIDIA runtime
 	if shared.IsTrue(d.expandedConfig["nvidia.runtime"]) {
 		hookDir := os.Getenv("LXD_LXC_HOOK")
@@ -2850,12 +2885,78 @@ func (d *lxc) onStopNS(args map[string]string) error {
 		return err
 	}
 
+	// Record whether the container got OOM killed for the onStop hook, while its cgroup still exists.
+	if target == "stop" && lxcOOMKilled(netns) {
+		lxcStopFailures.Store(d.id, true)
+	}
+
 	// Clean up devices.
 	d.cleanupDevices(false, netns)
 
 	return nil
 }
 
+// lxcStopFailures records the IDs of the containers which stopped due to a failure, from their stop hook until
+// their post-stop hook.
+var lxcStopFailures sync.Map
+
+// lxcOOMKilled returns whether the OOM killer killed any of the processes of the stopping container. The container
+// can't be queried through LXC from its stop hook, so its cgroup is found from the one of the LXC monitor process
+// that owns the netns path provided to the hook (/proc/<pid>/fd/<fd>).
+func lxcOOMKilled(netns string) bool {
+	fields := strings.Split(netns, "/")
+	if len(fields) != 5 || fields[1] != "proc" {
+		return false
+	}
+
+	content, err := os.ReadFile(filepath.Join("/proc", fields[2], "cgroup"))
+	if err != nil {
+		return false
+	}
+
+	// Prefer the cgroup1 memory controller over the unified hierarchy if both are in use.
+	var path string
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if shared.StringInSlice("memory", strings.Split(parts[1], ",")) {
+			path = filepath.Join("/sys/fs/cgroup/memory", lxcPayloadCgroup(parts[2]), "memory.oom_control")
+			break
+		}
+
+		if parts[0] == "0" && parts[1] == "" {
+			path = filepath.Join("/sys/fs/cgroup", lxcPayloadCgroup(parts[2]), "memory.events")
+		}
+	}
+
+	if path == "" {
+		return false
+	}
+
+	content, err = os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, found := strings.Cut(line, " ")
+		if found && key == "oom_kill" {
+			count, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return count > 0
+		}
+	}
+
+	return false
+}
+
+// lxcPayloadCgroup returns the path of the container's cgroup from the one of its LXC monitor process.
+func lxcPayloadCgroup(monitorCgroup string) string {
+	return filepath.Join(filepath.Dir(monitorCgroup), strings.Replace(filepath.Base(monitorCgroup), "lxc.monitor.", "lxc.payload.", 1))
+}
+
 // onStop is triggered by LXC's post-stop hook once a container is shutdown and after the
 // container's namespaces have been closed.
 func (d *lxc) onStop(args map[string]string) error {
@@ -2873,6 +2974,8 @@ func (d *lxc) onStop(args map[string]string) error {
 		return err
 	}
 
+	_, failed := lxcStopFailures.LoadAndDelete(d.id)
+
 	// Make sure we can't call go-lxc functions by mistake
 	d.fromHook = true
 
@@ -2880,6 +2983,9 @@ func (d *lxc) onStop(args map[string]string) error {
 	err = d.VolatileSet(map[string]string{
 		"volatile.last_state.power": "STOPPED",
 		"volatile.last_state.ready": "false",
+		"volatile.health.status":    "",
+		"volatile.health.failures":  "",
+		"volatile.health.error":     "",
 	})
 	if err != nil {
 		// Don't return an error here as we still want to cleanup the instance even if DB not available.
@@ -2986,6 +3092,9 @@ func (d *lxc) onStop(args map[string]string) error {
 				op.Done(fmt.Errorf("Failed deleting ephemeral container: %w", err))
 				return
 			}
+		} else if instanceInitiated {
+			// Restart the container if its policy allows it.
+			go d.autoRestart(failed)
 		}
 	}(d, target, op)
 
@@ -3298,8 +3407,11 @@ func (d *lxc) renderState(statusCode api.StatusCode) (*api.InstanceState, error)
 		status.CPU = d.cpuState()
 		status.Memory = d.memoryState()
 		status.Network = d.networkState()
+		d.networkTransferStateAdd(status.Network)
 		status.Pid = int64(pid)
 		status.Processes = d.processesState()
+		status.Health = d.healthState()
+		status.OS = d.osState(pid)
 	}
 
 	status.Disk = d.diskState()
@@ -3309,6 +3421,12 @@ func (d *lxc) renderState(statusCode api.StatusCode) (*api.InstanceState, error)
 	return &status, nil
 }
 
+// FirewallRulesets returns the host firewall rules generated for each of the instance's NIC devices alongside the
+// rules loaded in the kernel.
+func (d *lxc) FirewallRulesets() (map[string]*firewallDrivers.Ruleset, error) {
+	return d.firewallRulesets(d)
+}
+
 // RenderState renders just the running state of the instance.
 func (d *lxc) RenderState() (*api.InstanceState, error) {
 	return d.renderState(d.statusCode())
@@ -3887,7 +4005,7 @@ func (d *lxc) CGroupSet(key string, value string) error {
 // Update applies updated config.
 func (d *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 	// Setup a new operation
-	op, err := operationlock.CreateWaitGet(d.Project(), d.Name(), operationlock.ActionUpdate, []operationlock.Action{operationlock.ActionRestart, operationlock.ActionRestore}, false, false)
+	op, err := operationlock.CreateWaitGet(d.Project(), d.Name(), operationlock.ActionUpdate, []operationlock.Action{operationlock.ActionRestart, operationlock.ActionRestore, operationlock.ActionRebuild}, false, false)
 	if err != nil {
 		return fmt.Errorf("Failed to create instance update operation: %w", err)
 	}
@@ -4097,12 +4215,24 @@ func (d *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 			}
 		}
 
+		// The transfer accounting of the NICs can only be changed by LXD.
+		for _, k := range changedConfig {
+			if deviceConfig.IsNICTransferKey(k) {
+				return fmt.Errorf("Volatile network transfer keys can't be changed by the user")
+			}
+		}
+
 		// Do some validation of the config diff (allows mixed instance types for profiles).
 		err = instance.ValidConfig(d.state.OS, d.expandedConfig, true, instancetype.Any)
 		if err != nil {
 			return fmt.Errorf("Invalid expanded config: %w", err)
 		}
 
+		err = instance.ValidBootDependencies(d.state, d.project, d.name, d.expandedConfig)
+		if err != nil {
+			return fmt.Errorf("Invalid expanded config: %w", err)
+		}
+
 		// Do full expanded validation of the devices diff.
 		err = instance.ValidDevices(d.state, d.Project(), d.Type(), d.expandedDevices, true)
 		if err != nil {
@@ -4525,6 +4655,18 @@ func (d *lxc) Update(args db.InstanceArgs, userRequested bool) error {
 				}
 			}
 		}
+
+		// Apply any change to the network transfer limits.
+		for _, key := range changedConfig {
+			if strings.HasPrefix(key, "limits.network.transfer") {
+				err = device.NetworkTransferUpdate(d.state, d)
+				if err != nil {
+					return fmt.Errorf("Failed updating network transfer limits: %w", err)
+				}
+
+				break
+			}
+		}
 	}
 
 	// Re-generate the instance-id if needed.
@@ -5540,6 +5682,15 @@ func (d *lxc) FileSFTP() (*sftp.Client, error) {
 	return client, nil
 }
 
+// FileWatch watches a path inside the container for changes, from the host through the container's mount namespace.
+func (d *lxc) FileWatch(ctx context.Context, path string, recursive bool) (<-chan api.InstanceFileWatchEvent, error) {
+	if !d.IsRunning() {
+		return nil, fmt.Errorf("The container isn't running")
+	}
+
+	return filewatch.Watch(ctx, fmt.Sprintf("/proc/%d/root", d.InitPID()), path, recursive)
+}
+
 // stopForkFile attempts to send SIGINT to forkfile then waits for it to exit.
 func (d *lxc) stopForkfile() {
 	// Make sure that when the function exits, no forkfile is running by acquiring the lock (which indicates
@@ -5935,6 +6086,56 @@ func (d *lxc) networkState() map[string]api.InstanceStateNetwork {
 	return result
 }
 
+// osStateCacheTTL is how long the guest OS details of a container are reused before being collected again.
+const osStateCacheTTL = 30 * time.Second
+
+// osStateCacheEntry is the guest OS details of a container collected through its init process at a given time.
+type osStateCacheEntry struct {
+	pid       int
+	collected time.Time
+	info      *api.InstanceStateOS
+}
+
+// osStateCache holds the recently collected guest OS details of the containers keyed by instance ID.
+var osStateCache = map[int]osStateCacheEntry{}
+
+// osStateCacheLock protects osStateCache.
+var osStateCacheLock sync.Mutex
+
+// osState inspects the container's filesystem and namespaces through its init process for the guest OS details.
+// As this parses the package databases and the process' network tables, the details are cached for osStateCacheTTL.
+func (d *lxc) osState(pid int) *api.InstanceStateOS {
+	if pid < 1 {
+		return nil
+	}
+
+	now := time.Now()
+
+	osStateCacheLock.Lock()
+	entry, ok := osStateCache[d.id]
+	osStateCacheLock.Unlock()
+
+	if ok && entry.pid == pid && now.Sub(entry.collected) < osStateCacheTTL {
+		return entry.info
+	}
+
+	info := guestinfo.Get(fmt.Sprintf("/proc/%d/root", pid), fmt.Sprintf("/proc/%d", pid))
+
+	osStateCacheLock.Lock()
+	defer osStateCacheLock.Unlock()
+
+	// Drop the expired entries of the containers which stopped or were deleted since.
+	for id, entry := range osStateCache {
+		if now.Sub(entry.collected) >= osStateCacheTTL {
+			delete(osStateCache, id)
+		}
+	}
+
+	osStateCache[d.id] = osStateCacheEntry{pid: pid, collected: now, info: info}
+
+	return info
+}
+
 func (d *lxc) processesState() int64 {
 	// Return 0 if not running
 	pid := d.InitPID()
@@ -6949,6 +7150,9 @@ func (d *lxc) Metrics() (*metrics.MetricSet, error) {
 		out.AddSamples(metrics.NetworkTransmitDropTotal, metrics.Sample{Value: float64(state.Counters.PacketsDroppedOutbound), Labels: labels})
 	}
 
+	// Get network transfer accounting stats
+	d.networkTransferMetrics(out)
+
 	// Get number of processes
 	pids, err := cg.GetTotalProcesses()
 	if err != nil {
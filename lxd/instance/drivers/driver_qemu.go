@@ -10,6 +10,7 @@ import (
 	"crypto/x509"
 	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +25,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/flosch/pongo2"
@@ -44,6 +46,8 @@ import (
 	"github.com/lxc/lxd/lxd/device"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/device/nictype"
+	"github.com/lxc/lxd/lxd/filewatch"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/drivers/qmp"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
@@ -69,6 +73,7 @@ import (
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/osarch"
 	"github.com/lxc/lxd/shared/subprocess"
+	"github.com/lxc/lxd/shared/uefi"
 	"github.com/lxc/lxd/shared/units"
 	"github.com/lxc/lxd/shared/version"
 )
@@ -79,6 +84,40 @@ const qemuSerialChardevName = "qemu_serial-chardev"
 // qemuDefaultMemSize is the default memory size for VMs if not limit specified.
 const qemuDefaultMemSize = "1GiB"
 
+// qemuMemoryHotplugSlots is the number of memory slots reserved for hotplug when limits.memory.hotplug is set.
+const qemuMemoryHotplugSlots = 16
+
+// qemuMemoryHotplugBlockMB is the memory block size of x86_64 guests, hot-plugged memory is rounded up to it as the
+// guest can only bring whole blocks online (huge pages can't be combined with memory hotplug).
+const qemuMemoryHotplugBlockMB = 128
+
+// qemuMovedInstances holds the VMs being stopped after having been live moved to another cluster member, keyed by
+// project and instance name, whose instance record is now used by that member.
+var qemuMovedInstances sync.Map
+
+// qemuMigrationNBDExportName is the name the root disk is exported under during a live migration.
+const qemuMigrationNBDExportName = "lxd_root"
+
+// qemuMigrationNBDNodeName is the name of the block node mirroring the root disk to the target of a live migration.
+const qemuMigrationNBDNodeName = "lxd_migration_nbd"
+
+// qemuMigrationOverlayNodeName is the name of the block node receiving the root disk writes during a live migration.
+const qemuMigrationOverlayNodeName = "lxd_migration_overlay"
+
+// qemuMigrationMirrorJobID is the ID of the block job mirroring the root disk during a live migration.
+const qemuMigrationMirrorJobID = "lxd_migration_mirror"
+
+// qemuMigrationCommitJobID is the ID of the block job merging the root disk writes back after a failed live migration.
+const qemuMigrationCommitJobID = "lxd_migration_commit"
+
+// qemuMigrationTimeout is how long saving, restoring or live migrating the state of a VM may take before it's
+// cancelled, so that a stalled stream doesn't hang the operation forever.
+const qemuMigrationTimeout = time.Hour
+
+// qemuBlockJobTimeout is how long the block jobs of a live migration may take to be ready or gone before they're
+// cancelled.
+const qemuBlockJobTimeout = time.Hour
+
 // qemuPCIDeviceIDStart is the first PCI slot used for user configurable devices.
 const qemuPCIDeviceIDStart = 4
 
@@ -232,6 +271,13 @@ func qemuCreate(s *state.State, args db.InstanceArgs) (instance.Instance, revert
 		return nil, nil, fmt.Errorf("Invalid config: %w", err)
 	}
 
+	if !d.IsSnapshot() {
+		err = instance.ValidBootDependencies(s, d.project, d.name, d.expandedConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid config: %w", err)
+		}
+	}
+
 	err = instance.ValidDevices(s, d.Project(), d.Type(), d.expandedDevices, true)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Invalid devices: %w", err)
@@ -301,6 +347,9 @@ type qemu struct {
 	// Do not use these variables directly, instead use their associated get functions so they
 	// will be initialised on demand.
 	architectureName string
+
+	// Set while starting from the state sent by the source of a live migration.
+	migrationReceive *instance.LiveMigrateArgs
 }
 
 // getAgentClient returns the current agent client handle. To avoid TLS setup each time this
@@ -350,27 +399,37 @@ func (d *qemu) getMonitorEventHandler() func(event string, data map[string]any)
 	state := d.state
 
 	return func(event string, data map[string]any) {
-		if !shared.StringInSlice(event, []string{"SHUTDOWN", "RESET", qmp.AgentStatusStarted}) {
+		if !shared.StringInSlice(event, []string{"SHUTDOWN", "RESET", qmp.AgentStatusStarted, qmp.EventDisconnect}) {
 			return // Don't bother loading the instance from DB if we aren't going to handle the event.
 		}
 
 		var d *qemu // Redefine d as local variable inside callback to avoid keeping references around.
+		var err error
 
-		inst, err := instance.LoadByProjectAndName(state, projectName, instanceName)
-		if err != nil {
-			l := logger.AddContext(logger.Log, logger.Ctx{"project": projectName, "instance": instanceName})
-			// If DB not available, try loading from backup file.
-			l.Warn("Failed loading instance from database to handle monitor event, trying backup file", logger.Ctx{"err": err})
+		// The record of a VM stopping after being moved to another cluster member now has the config the VM
+		// runs with there, so handle its events with the instance that ran here.
+		moved, found := qemuMovedInstances.Load(project.Instance(projectName, instanceName))
+		if found {
+			d = moved.(*qemu)
+		} else {
+			var inst instance.Instance
 
-			instancePath := filepath.Join(shared.VarPath("virtual-machines"), project.Instance(projectName, instanceName))
-			inst, err = instance.LoadFromBackup(state, projectName, instancePath, false)
+			inst, err = instance.LoadByProjectAndName(state, projectName, instanceName)
 			if err != nil {
-				l.Error("Failed loading instance to handle monitor event", logger.Ctx{"err": err})
-				return
+				l := logger.AddContext(logger.Log, logger.Ctx{"project": projectName, "instance": instanceName})
+				// If DB not available, try loading from backup file.
+				l.Warn("Failed loading instance from database to handle monitor event, trying backup file", logger.Ctx{"err": err})
+
+				instancePath := filepath.Join(shared.VarPath("virtual-machines"), project.Instance(projectName, instanceName))
+				inst, err = instance.LoadFromBackup(state, projectName, instancePath, false)
+				if err != nil {
+					l.Error("Failed loading instance to handle monitor event", logger.Ctx{"err": err})
+					return
+				}
 			}
-		}
 
-		d = inst.(*qemu)
+			d = inst.(*qemu)
+		}
 
 		if event == qmp.AgentStatusStarted {
 			d.logger.Debug("Instance agent started")
@@ -402,7 +461,24 @@ func (d *qemu) getMonitorEventHandler() func(event string, data map[string]any)
 				target = "reboot"
 			}
 
-			err = d.onStop(target)
+			// Anything but a shutdown requested from within the guest or by LXD is a failure.
+			reason, _ := entry.(string)
+			failed := !shared.StringInSlice(reason, []string{"guest-shutdown", "guest-reset", "host-qmp-quit"})
+
+			err = d.onStop(target, failed)
+			if err != nil {
+				d.logger.Error("Failed to cleanly stop instance", logger.Ctx{"err": err})
+				return
+			}
+		} else if event == qmp.EventDisconnect {
+			// Ignore QEMU exiting as a result of an ongoing operation, as that will clean up itself.
+			if operationlock.Get(d.Project(), d.Name()) != nil {
+				return
+			}
+
+			d.logger.Warn("Instance stopped unexpectedly")
+
+			err = d.onStop("stop", true)
 			if err != nil {
 				d.logger.Error("Failed to cleanly stop instance", logger.Ctx{"err": err})
 				return
@@ -564,7 +640,8 @@ func (d *qemu) pidWait(timeout time.Duration, op *operationlock.InstanceOperatio
 }
 
 // onStop is run when the instance stops.
-func (d *qemu) onStop(target string) error {
+// The failed argument indicates whether the instance stopped due to a failure rather than a shutdown.
+func (d *qemu) onStop(target string, failed bool) error {
 	d.logger.Debug("onStop hook started", logger.Ctx{"target": target})
 	defer d.logger.Debug("onStop hook finished", logger.Ctx{"target": target})
 
@@ -594,6 +671,9 @@ func (d *qemu) onStop(target string) error {
 	err = d.VolatileSet(map[string]string{
 		"volatile.last_state.power": "STOPPED",
 		"volatile.last_state.ready": "false",
+		"volatile.health.status":    "",
+		"volatile.health.failures":  "",
+		"volatile.health.error":     "",
 	})
 	if err != nil {
 		// Don't return an error here as we still want to cleanup the instance even if DB not available.
@@ -646,6 +726,9 @@ func (d *qemu) onStop(target string) error {
 			op.Done(err)
 			return err
 		}
+	} else if instanceInitiated {
+		// Restart the instance if its policy allows it.
+		go d.autoRestart(failed)
 	}
 
 	return nil
@@ -860,7 +943,10 @@ func (d *qemu) restoreState(monitor *qmp.Monitor) error {
 		return err
 	}
 
-	err = monitor.MigrateIncoming("fd:migration")
+	ctx, cancel := context.WithTimeout(context.Background(), qemuMigrationTimeout)
+	defer cancel()
+
+	err = monitor.MigrateIncoming(ctx, "fd:migration")
 	if err != nil {
 		return err
 	}
@@ -906,7 +992,10 @@ func (d *qemu) saveState(monitor *qmp.Monitor) error {
 	}
 
 	// Issue the migration command.
-	err = monitor.Migrate("fd:migration")
+	ctx, cancel := context.WithTimeout(context.Background(), qemuMigrationTimeout)
+	defer cancel()
+
+	err = monitor.Migrate(ctx, "fd:migration")
 	if err != nil {
 		_ = compressedState.Close()
 		_ = stateFile.Close()
@@ -1081,6 +1170,15 @@ func (d *qemu) Start(stateful bool) error {
 		return err
 	}
 
+	// Rotate the console log, keeping the one from the previous boot.
+	if shared.PathExists(d.ConsoleBufferLogPath()) {
+		err = os.Rename(d.ConsoleBufferLogPath(), fmt.Sprintf("%s.1", d.ConsoleBufferLogPath()))
+		if err != nil {
+			op.Done(err)
+			return fmt.Errorf("Failed rotating console log: %w", err)
+		}
+	}
+
 	err = os.MkdirAll(d.DevicesPath(), 0711)
 	if err != nil {
 		op.Done(err)
@@ -1535,7 +1633,12 @@ func (d *qemu) Start(stateful bool) error {
 
 	// Restore the state.
 	if stateful {
-		err := d.restoreState(monitor)
+		if d.migrationReceive != nil {
+			err = d.migrateReceiveState(monitor, d.migrationReceive)
+		} else {
+			err = d.restoreState(monitor)
+		}
+
 		if err != nil {
 			op.Done(err)
 			return err
@@ -1646,6 +1749,31 @@ func (d *qemu) architectureSupportsUEFI() bool {
 	return shared.IntInSlice(d.architecture, []int{osarch.ARCH_64BIT_INTEL_X86, osarch.ARCH_64BIT_ARMV8_LITTLE_ENDIAN})
 }
 
+// nvramTemplatePath returns the path of the EFI firmware settings file the NVRAM is generated from.
+func (d *qemu) nvramTemplatePath() (string, error) {
+	srcOvmfFile := filepath.Join(d.ovmfPath(), "OVMF_VARS.fd")
+	if shared.IsTrueOrEmpty(d.expandedConfig["security.secureboot"]) {
+		srcOvmfFile = filepath.Join(d.ovmfPath(), "OVMF_VARS.ms.fd")
+	}
+
+	missingEFIFirmwareErr := fmt.Errorf("Required EFI firmware settings file missing %q", srcOvmfFile)
+
+	if !shared.PathExists(srcOvmfFile) {
+		return "", missingEFIFirmwareErr
+	}
+
+	srcOvmfFile, err := filepath.EvalSymlinks(srcOvmfFile)
+	if err != nil {
+		return "", fmt.Errorf("Failed resolving EFI firmware symlink %q: %w", srcOvmfFile, err)
+	}
+
+	if !shared.PathExists(srcOvmfFile) {
+		return "", missingEFIFirmwareErr
+	}
+
+	return srcOvmfFile, nil
+}
+
 func (d *qemu) setupNvram() error {
 	d.logger.Debug("Generating NVRAM")
 
@@ -1657,32 +1785,164 @@ func (d *qemu) setupNvram() error {
 
 	defer func() { _ = d.unmount() }()
 
-	srcOvmfFile := filepath.Join(d.ovmfPath(), "OVMF_VARS.fd")
-	if shared.IsTrueOrEmpty(d.expandedConfig["security.secureboot"]) {
-		srcOvmfFile = filepath.Join(d.ovmfPath(), "OVMF_VARS.ms.fd")
+	srcOvmfFile, err := d.nvramTemplatePath()
+	if err != nil {
+		return err
 	}
 
-	missingEFIFirmwareErr := fmt.Errorf("Required EFI firmware settings file missing %q", srcOvmfFile)
+	_ = os.Remove(d.nvramPath())
+	err = shared.FileCopy(srcOvmfFile, d.nvramPath())
+	if err != nil {
+		return err
+	}
 
-	if !shared.PathExists(srcOvmfFile) {
-		return missingEFIFirmwareErr
+	return nil
+}
+
+// readVarStore returns the UEFI variable store of the instance, as it will be seen on next boot.
+// The instance's config volume must be mounted.
+func (d *qemu) readVarStore() (*uefi.VarStore, error) {
+	nvramPath := d.nvramPath()
+
+	// If the NVRAM is going to be regenerated on next start, report the variables from its template.
+	if !shared.PathExists(nvramPath) || shared.IsTrue(d.localConfig["volatile.apply_nvram"]) {
+		var err error
+		nvramPath, err = d.nvramTemplatePath()
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	srcOvmfFile, err = filepath.EvalSymlinks(srcOvmfFile)
+	raw, err := ioutil.ReadFile(nvramPath)
 	if err != nil {
-		return fmt.Errorf("Failed resolving EFI firmware symlink %q: %w", srcOvmfFile, err)
+		return nil, fmt.Errorf("Failed reading NVRAM: %w", err)
 	}
 
-	if !shared.PathExists(srcOvmfFile) {
-		return missingEFIFirmwareErr
+	store, err := uefi.ParseVarStore(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing NVRAM %q: %w", nvramPath, err)
 	}
 
-	_ = os.Remove(d.nvramPath())
-	err = shared.FileCopy(srcOvmfFile, d.nvramPath())
+	return store, nil
+}
+
+// UEFIVars returns the UEFI variables stored in the instance's NVRAM.
+func (d *qemu) UEFIVars() (*api.InstanceUEFIVars, error) {
+	if !d.architectureSupportsUEFI() {
+		return nil, fmt.Errorf("UEFI isn't supported on this architecture")
+	}
+
+	// Mount the instance's config volume.
+	_, err := d.mount()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = d.unmount() }()
+
+	store, err := d.readVarStore()
+	if err != nil {
+		return nil, err
+	}
+
+	vars := &api.InstanceUEFIVars{Variables: make(map[string]api.InstanceUEFIVariable, len(store.Variables))}
+	for _, v := range store.Variables {
+		vars.Variables[v.Key()] = api.InstanceUEFIVariable{
+			Data:       hex.EncodeToString(v.Data),
+			Attributes: v.Attributes,
+		}
+	}
+
+	return vars, nil
+}
+
+// UEFIVarsUpdate replaces the UEFI variables stored in the instance's NVRAM.
+// Variables missing from newVars are removed. The instance must be stopped.
+func (d *qemu) UEFIVarsUpdate(newVars api.InstanceUEFIVars) error {
+	if !d.architectureSupportsUEFI() {
+		return fmt.Errorf("UEFI isn't supported on this architecture")
+	}
+
+	if d.IsRunning() {
+		return fmt.Errorf("UEFI variables can only be modified while the instance is stopped")
+	}
+
+	// Mount the instance's config volume.
+	_, err := d.mount()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = d.unmount() }()
+
+	// Generate the NVRAM now if it would otherwise be regenerated on next start, discarding the changes.
+	if !shared.PathExists(d.nvramPath()) || shared.IsTrue(d.localConfig["volatile.apply_nvram"]) {
+		err = d.setupNvram()
+		if err != nil {
+			return err
+		}
+
+		err = d.VolatileSet(map[string]string{"volatile.apply_nvram": ""})
+		if err != nil {
+			return fmt.Errorf("Failed setting volatile keys: %w", err)
+		}
+	}
+
+	store, err := d.readVarStore()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	variables := make([]uefi.Variable, 0, len(newVars.Variables))
+	for key, newVar := range newVars.Variables {
+		name, guid, err := uefi.ParseVariableKey(key)
+		if err != nil {
+			return err
+		}
+
+		data, err := hex.DecodeString(newVar.Data)
+		if err != nil {
+			return fmt.Errorf("Invalid data for variable %q: %w", key, err)
+		}
+
+		v := uefi.Variable{Name: name, GUID: guid, Attributes: newVar.Attributes, Data: data}
+
+		existing := store.Get(name, guid)
+		if existing != nil && existing.Attributes == v.Attributes && bytes.Equal(existing.Data, v.Data) {
+			// Keep unchanged variables as they are.
+			v = *existing
+		} else if v.Attributes&uefi.AttributeTimeBasedAuthenticatedWriteAccess != 0 {
+			v.Timestamp = uefi.Timestamp(now)
+		}
+
+		variables = append(variables, v)
+	}
+
+	// Keep the variables in a stable order.
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Key() < variables[j].Key()
+	})
+
+	store.Variables = variables
+
+	raw, err := store.Bytes()
 	if err != nil {
 		return err
 	}
 
+	tmpPath := fmt.Sprintf("%s.tmp", d.nvramPath())
+	err = ioutil.WriteFile(tmpPath, raw, 0640)
+	if err != nil {
+		return fmt.Errorf("Failed writing NVRAM: %w", err)
+	}
+
+	err = os.Rename(tmpPath, d.nvramPath())
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("Failed replacing NVRAM: %w", err)
+	}
+
 	return nil
 }
 
@@ -2553,7 +2813,7 @@ func (d *qemu) generateQemuConfigFile(mountInfo *storagePools.MountInfo, busName
 	cfg = append(cfg, qemuControlSocket(&qemuControlSocketOpts{d.monitorPath()})...)
 
 	// Console output.
-	cfg = append(cfg, qemuConsole(&qemuConsoleOpts{d.consolePath()})...)
+	cfg = append(cfg, qemuConsole(&qemuConsoleOpts{d.consolePath(), d.ConsoleBufferLogPath()})...)
 
 	// Setup the bus allocator.
 	bus := qemuNewBus(busName, &cfg)
@@ -2864,7 +3124,30 @@ func (d *qemu) addCPUMemoryConfig(cfg *[]cfgSection) (int, error) {
 		cpuOpts.cpuCores = cpuCount
 		cpuOpts.cpuThreads = 1
 		hostNodes = []uint64{0}
+
+		// Reserve vCPU slots for hotplug.
+		if d.expandedConfig["limits.cpu.hotplug"] != "" {
+			if d.architectureName != "x86_64" {
+				return -1, fmt.Errorf("CPU hotplug is only supported on x86_64")
+			}
+
+			cpuMaxCount, err := strconv.Atoi(d.expandedConfig["limits.cpu.hotplug"])
+			if err != nil {
+				return -1, fmt.Errorf("limits.cpu.hotplug invalid: %w", err)
+			}
+
+			if cpuMaxCount < cpuCount {
+				return -1, fmt.Errorf("limits.cpu.hotplug can't be lower than limits.cpu")
+			}
+
+			cpuOpts.cpuMaxCount = cpuMaxCount
+			cpuOpts.cpuCores = cpuMaxCount
+		}
 	} else {
+		if d.expandedConfig["limits.cpu.hotplug"] != "" {
+			return -1, fmt.Errorf("CPU hotplug can't be used with CPU pinning")
+		}
+
 		// Expand to a set of CPU identifiers and get the pinning map.
 		nrSockets, nrCores, nrThreads, vcpus, numaNodes, err := d.cpuTopology(cpus)
 		if err != nil {
@@ -2944,8 +3227,34 @@ func (d *qemu) addCPUMemoryConfig(cfg *[]cfgSection) (int, error) {
 	memSizeMB = nodeMemory * int64(len(hostNodes))
 	cpuOpts.memory = nodeMemory
 
+	memOpts := qemuMemoryOpts{memSizeMB: memSizeMB}
+
+	// Reserve memory slots for hotplug.
+	if d.expandedConfig["limits.memory.hotplug"] != "" {
+		if d.architectureName != "x86_64" {
+			return -1, fmt.Errorf("Memory hotplug is only supported on x86_64")
+		}
+
+		if cpuOpts.hugepages != "" {
+			return -1, fmt.Errorf("Memory hotplug can't be used with huge pages")
+		}
+
+		memMaxSizeBytes, err := units.ParseByteSizeString(d.expandedConfig["limits.memory.hotplug"])
+		if err != nil {
+			return -1, fmt.Errorf("limits.memory.hotplug invalid: %w", err)
+		}
+
+		if memMaxSizeBytes < memSizeBytes {
+			return -1, fmt.Errorf("limits.memory.hotplug can't be lower than limits.memory")
+		}
+
+		// Leave room for the hot-plugged memory being rounded up to whole memory blocks.
+		memOpts.memMaxSizeMB = memSizeMB + qemuMemoryHotplugRoundUp(memMaxSizeBytes/1024/1024-memSizeMB)
+		memOpts.memSlots = qemuMemoryHotplugSlots
+	}
+
 	if cfg != nil {
-		*cfg = append(*cfg, qemuMemory(&qemuMemoryOpts{memSizeMB})...)
+		*cfg = append(*cfg, qemuMemory(&memOpts)...)
 		*cfg = append(*cfg, qemuCPU(&cpuOpts)...)
 	}
 
@@ -3814,7 +4123,7 @@ func (d *qemu) forceStop() error {
 		}
 
 		// Wait for QEMU process to exit and perform device cleanup.
-		err = d.onStop("stop")
+		err = d.onStop("stop", false)
 		if err != nil {
 			return err
 		}
@@ -3885,6 +4194,12 @@ func (d *qemu) Stop(stateful bool) error {
 
 	// Handle stateful stop.
 	if stateful {
+		err = d.checkNoHotplugged(monitor)
+		if err != nil {
+			op.Done(err)
+			return err
+		}
+
 		// Keep resetting the timer for the next 10 minutes.
 		go d.pidWait(10*time.Minute, op)
 
@@ -3932,7 +4247,7 @@ func (d *qemu) Stop(stateful bool) error {
 		}
 
 		return errPrefix
-	} else if op.Action() == "stop" {
+	} else if op.Action() == "stop" && !d.recordMoved {
 		// If instance stopped, send lifecycle event (even if there has been an error cleaning up).
 		d.state.Events.SendLifecycle(d.project, lifecycle.InstanceStopped.Event(d, nil))
 	}
@@ -3973,11 +4288,14 @@ func (d *qemu) Snapshot(name string, expiry time.Time, stateful bool) error {
 	var err error
 	var monitor *qmp.Monitor
 
+	revert := revert.New()
+	defer revert.Fail()
+
 	// Deal with state.
 	if stateful {
 		// Confirm the instance has stateful migration enabled.
 		if shared.IsFalseOrEmpty(d.expandedConfig["migration.stateful"]) {
-			return fmt.Errorf("Stateful stop requires migration.stateful to be set to true")
+			return fmt.Errorf("Stateful snapshot requires migration.stateful to be set to true")
 		}
 
 		// Quick checks.
@@ -3991,8 +4309,28 @@ func (d *qemu) Snapshot(name string, expiry time.Time, stateful bool) error {
 			return err
 		}
 
-		// Dump the state.
+		err = d.checkNoHotplugged(monitor)
+		if err != nil {
+			return err
+		}
+
+		// Dump the state, this leaves the VM paused so that the disk state matches it.
 		err = d.saveState(monitor)
+		if err != nil {
+			_ = monitor.Start()
+			return err
+		}
+
+		revert.Add(func() {
+			_ = os.Remove(d.StatePath())
+			_ = monitor.Start()
+		})
+	}
+
+	// Freeze the guest filesystems (the VM is already paused for stateful snapshots).
+	var thaw func() error
+	if !stateful {
+		thaw, err = d.FilesystemFreeze()
 		if err != nil {
 			return err
 		}
@@ -4000,22 +4338,37 @@ func (d *qemu) Snapshot(name string, expiry time.Time, stateful bool) error {
 
 	// Create the snapshot.
 	err = d.snapshotCommon(d, name, expiry, stateful)
+	if thaw != nil {
+		thawErr := thaw()
+		if err == nil && thawErr != nil {
+			// Discard the snapshot as the guest filesystems may have been thawed on timeout while taking it.
+			snap, loadErr := instance.LoadByProjectAndName(d.state, d.project, d.name+shared.SnapshotDelimiter+name)
+			if loadErr == nil {
+				_ = snap.Delete(true)
+			}
+
+			err = thawErr
+		}
+	}
+
 	if err != nil {
 		return err
 	}
 
-	// Resume the VM once the disk state has been saved.
+	revert.Success()
+
+	// Resume the VM once the disk state has been saved, even if the state can't be removed from the main volume.
 	if stateful {
-		// Remove the state from the main volume.
-		err = os.Remove(d.StatePath())
-		if err != nil {
-			return err
-		}
+		removeErr := os.Remove(d.StatePath())
 
 		err = monitor.Start()
 		if err != nil {
 			return err
 		}
+
+		if removeErr != nil {
+			return removeErr
+		}
 	}
 
 	return nil
@@ -4023,6 +4376,10 @@ func (d *qemu) Snapshot(name string, expiry time.Time, stateful bool) error {
 
 // Restore restores an instance snapshot.
 func (d *qemu) Restore(source instance.Instance, stateful bool) error {
+	if stateful && !source.IsStateful() {
+		return fmt.Errorf("Stateful restore requires a stateful snapshot")
+	}
+
 	op, err := operationlock.Create(d.Project(), d.Name(), operationlock.ActionRestore, false, false)
 	if err != nil {
 		return fmt.Errorf("Failed to create instance restore operation: %w", err)
@@ -4308,7 +4665,7 @@ func (d *qemu) Rename(newName string, applyTemplateTrigger bool) error {
 // Update the instance config.
 func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 	// Setup a new operation.
-	op, err := operationlock.CreateWaitGet(d.Project(), d.Name(), operationlock.ActionUpdate, []operationlock.Action{operationlock.ActionRestart, operationlock.ActionRestore}, false, false)
+	op, err := operationlock.CreateWaitGet(d.Project(), d.Name(), operationlock.ActionUpdate, []operationlock.Action{operationlock.ActionRestart, operationlock.ActionRestore, operationlock.ActionRebuild}, false, false)
 	if err != nil {
 		return fmt.Errorf("Failed to create instance update operation: %w", err)
 	}
@@ -4493,12 +4850,24 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 	})
 
 	if userRequested {
+		// The transfer accounting of the NICs can only be changed by LXD.
+		for _, k := range changedConfig {
+			if deviceConfig.IsNICTransferKey(k) {
+				return fmt.Errorf("Volatile network transfer keys can't be changed by the user")
+			}
+		}
+
 		// Do some validation of the config diff (allows mixed instance types for profiles).
 		err = instance.ValidConfig(d.state.OS, d.expandedConfig, true, instancetype.Any)
 		if err != nil {
 			return fmt.Errorf("Invalid expanded config: %w", err)
 		}
 
+		err = instance.ValidBootDependencies(d.state, d.project, d.name, d.expandedConfig)
+		if err != nil {
+			return fmt.Errorf("Invalid expanded config: %w", err)
+		}
+
 		// Do full expanded validation of the devices diff.
 		err = instance.ValidDevices(d.state, d.Project(), d.Type(), d.expandedDevices, true)
 		if err != nil {
@@ -4552,10 +4921,18 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 				return true
 			}
 
+			if strings.HasPrefix(key, "healthcheck.") {
+				return true
+			}
+
 			if strings.HasPrefix(key, "image.") {
 				return true
 			}
 
+			if strings.HasPrefix(key, "limits.network.transfer") {
+				return true
+			}
+
 			if strings.HasPrefix(key, "snapshots.") {
 				return true
 			}
@@ -4572,6 +4949,10 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 				return true
 			}
 
+			if key == "limits.cpu" && d.expandedConfig["limits.cpu.hotplug"] != "" {
+				return true
+			}
+
 			return false
 		}
 
@@ -4593,6 +4974,11 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 						return fmt.Errorf("Failed updating memory limit: %w", err)
 					}
 				}
+			} else if key == "limits.cpu" {
+				err = d.updateCPULimit(value)
+				if err != nil {
+					return fmt.Errorf("Failed updating CPU limit: %w", err)
+				}
 			} else if key == "security.secureboot" {
 				// Defer rebuilding nvram until next start.
 				d.localConfig["volatile.apply_nvram"] = "true"
@@ -4603,6 +4989,18 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 				}
 			}
 		}
+
+		// Apply any change to the network transfer limits.
+		for _, key := range changedConfig {
+			if strings.HasPrefix(key, "limits.network.transfer") {
+				err = device.NetworkTransferUpdate(d.state, d)
+				if err != nil {
+					return fmt.Errorf("Failed updating network transfer limits: %w", err)
+				}
+
+				break
+			}
+		}
 	}
 
 	// Update MAAS (must run after the MAC addresses have been generated).
@@ -4694,9 +5092,10 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 	revert.Success()
 
 	if isRunning {
-		// Send devlxd notifications only for user.* key changes
+		// Send devlxd notifications only for user.* key changes and for the CPU and memory limits so the agent
+		// can bring hot-plugged resources online.
 		for _, key := range changedConfig {
-			if !strings.HasPrefix(key, "user.") {
+			if !strings.HasPrefix(key, "user.") && !shared.StringInSlice(key, []string{"limits.cpu", "limits.memory"}) {
 				continue
 			}
 
@@ -4708,6 +5107,12 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 
 			err = d.devlxdEventSend("config", msg)
 			if err != nil {
+				// The limits have already been applied, the agent may just not be running.
+				if !strings.HasPrefix(key, "user.") {
+					d.logger.Warn("Failed notifying lxd-agent of limit change", logger.Ctx{"key": key, "err": err})
+					continue
+				}
+
 				return err
 			}
 		}
@@ -4724,7 +5129,13 @@ func (d *qemu) Update(args db.InstanceArgs, userRequested bool) error {
 	return nil
 }
 
+// qemuMemoryHotplugRoundUp rounds the size in MiB up to a whole number of memory blocks.
+func qemuMemoryHotplugRoundUp(sizeMB int64) int64 {
+	return (sizeMB + qemuMemoryHotplugBlockMB - 1) / qemuMemoryHotplugBlockMB * qemuMemoryHotplugBlockMB
+}
+
 // updateMemoryLimit live updates the VM's memory limit by reszing the balloon device.
+// When memory hotplug is configured, memory is added to the VM if the new limit exceeds its current memory.
 func (d *qemu) updateMemoryLimit(newLimit string) error {
 	if newLimit == "" {
 		return nil
@@ -4753,7 +5164,12 @@ func (d *qemu) updateMemoryLimit(newLimit string) error {
 		return err
 	}
 
-	baseSizeMB := baseSizeBytes / 1024 / 1024
+	pluggedSizeBytes, err := monitor.GetMemoryPluggedSizeBytes()
+	if err != nil {
+		return err
+	}
+
+	baseSizeMB := (baseSizeBytes + pluggedSizeBytes) / 1024 / 1024
 
 	curSizeBytes, err := monitor.GetMemoryBalloonSizeBytes()
 	if err != nil {
@@ -4765,14 +5181,41 @@ func (d *qemu) updateMemoryLimit(newLimit string) error {
 	if curSizeMB == newSizeMB {
 		return nil
 	} else if baseSizeMB < newSizeMB {
-		return fmt.Errorf("Cannot increase memory size beyond boot time size when VM is running (Boot time size %dMiB, new size %dMiB)", baseSizeMB, newSizeMB)
-	}
+		if d.expandedConfig["limits.memory.hotplug"] == "" {
+			return fmt.Errorf("Cannot increase memory size beyond boot time size when VM is running (Boot time size %dMiB, new size %dMiB)", baseSizeMB, newSizeMB)
+		}
 
-	// Set effective memory size.
-	err = monitor.SetMemoryBalloonSizeBytes(newSizeBytes)
-	if err != nil {
-		return err
-	}
+		maxSizeBytes, err := units.ParseByteSizeString(d.expandedConfig["limits.memory.hotplug"])
+		if err != nil {
+			return fmt.Errorf("Invalid memory hotplug size: %w", err)
+		}
+
+		if newSizeBytes > maxSizeBytes {
+			return fmt.Errorf("Cannot increase memory size beyond hotplug size when VM is running (Hotplug size %dMiB, new size %dMiB)", maxSizeBytes/1024/1024, newSizeMB)
+		}
+
+		memoryDevices, err := monitor.GetMemoryDevices()
+		if err != nil {
+			return err
+		}
+
+		if len(memoryDevices) >= qemuMemoryHotplugSlots {
+			return fmt.Errorf("Cannot increase memory size as all %d memory slots are used", qemuMemoryHotplugSlots)
+		}
+
+		// Plug the missing memory as a new DIMM, rounded up to whole memory blocks. The balloon then brings the
+		// effective size down to the requested one.
+		err = monitor.AddMemory(fmt.Sprintf("lxd_memory%d", len(memoryDevices)), qemuMemoryHotplugRoundUp(newSizeMB-baseSizeMB)*1024*1024)
+		if err != nil {
+			return fmt.Errorf("Failed adding memory: %w", err)
+		}
+	}
+
+	// Set effective memory size.
+	err = monitor.SetMemoryBalloonSizeBytes(newSizeBytes)
+	if err != nil {
+		return err
+	}
 
 	// Changing the memory balloon can take time, so poll the effectice size to check it has shrunk within 1%
 	// of the target size, which we then take as success (it may still continue to shrink closer to target).
@@ -4801,6 +5244,106 @@ func (d *qemu) updateMemoryLimit(newLimit string) error {
 	return fmt.Errorf("Failed setting memory to %dMiB (currently %dMiB) as it was taking too long", newSizeMB, curSizeMB)
 }
 
+// updateCPULimit live updates the VM's vCPU count by hot-plugging or unplugging vCPUs.
+func (d *qemu) updateCPULimit(newLimit string) error {
+	if newLimit == "" {
+		newLimit = "1"
+	}
+
+	newCount, err := strconv.Atoi(newLimit)
+	if err != nil {
+		return fmt.Errorf("Cannot live update CPU pinning when VM is running")
+	}
+
+	maxCount, err := strconv.Atoi(d.expandedConfig["limits.cpu.hotplug"])
+	if err != nil {
+		return fmt.Errorf("Invalid CPU hotplug count: %w", err)
+	}
+
+	if newCount < 1 || newCount > maxCount {
+		return fmt.Errorf("Cannot set vCPU count beyond hotplug count when VM is running (Hotplug count %d, new count %d)", maxCount, newCount)
+	}
+
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err // The VM isn't running as no monitor socket available.
+	}
+
+	cpus, err := monitor.QueryHotpluggableCPUs()
+	if err != nil {
+		return err
+	}
+
+	used := []qmp.HotpluggableCPU{}
+	free := []qmp.HotpluggableCPU{}
+	for _, cpu := range cpus {
+		if cpu.QOMPath != "" {
+			used = append(used, cpu)
+		} else {
+			free = append(free, cpu)
+		}
+	}
+
+	// Sort the slots by core so vCPUs are added and removed in order.
+	sort.Slice(used, func(i, j int) bool { return used[i].Props["core-id"] < used[j].Props["core-id"] })
+	sort.Slice(free, func(i, j int) bool { return free[i].Props["core-id"] < free[j].Props["core-id"] })
+
+	for i := len(used); i < newCount; i++ {
+		cpu := free[i-len(used)]
+
+		err = monitor.AddCPU(cpu, fmt.Sprintf("lxd_cpu%d", cpu.Props["core-id"]))
+		if err != nil {
+			return fmt.Errorf("Failed adding vCPU: %w", err)
+		}
+	}
+
+	for i := len(used) - 1; i >= newCount; i-- {
+		// Only the vCPUs that were hot-plugged can be removed.
+		if !strings.HasPrefix(used[i].QOMPath, "/machine/peripheral/") {
+			return fmt.Errorf("Cannot remove vCPUs present at boot time when VM is running")
+		}
+
+		err = monitor.RemoveDevice(strings.TrimPrefix(used[i].QOMPath, "/machine/peripheral/"))
+		if err != nil {
+			return fmt.Errorf("Failed removing vCPU: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkNoHotplugged returns an error if vCPUs or memory were hot-plugged into the running VM.
+// The hot-plugged devices aren't recreated when starting QEMU from a saved or migrated state, so the state of the
+// VM can't be saved until it has been restarted with its new limits.
+func (d *qemu) checkNoHotplugged(monitor *qmp.Monitor) error {
+	if d.expandedConfig["limits.cpu.hotplug"] != "" {
+		cpus, err := monitor.QueryHotpluggableCPUs()
+		if err != nil {
+			return err
+		}
+
+		for _, cpu := range cpus {
+			if strings.HasPrefix(cpu.QOMPath, "/machine/peripheral/") {
+				return fmt.Errorf("Cannot save the state of a VM with hot-plugged vCPUs (stateful stop, stateful snapshots and live migration are unsupported until the VM is restarted)")
+			}
+		}
+	}
+
+	if d.expandedConfig["limits.memory.hotplug"] != "" {
+		memoryDevices, err := monitor.GetMemoryDevices()
+		if err != nil {
+			return err
+		}
+
+		if len(memoryDevices) > 0 {
+			return fmt.Errorf("Cannot save the state of a VM with hot-plugged memory (stateful stop, stateful snapshots and live migration are unsupported until the VM is restarted)")
+		}
+	}
+
+	return nil
+}
+
 func (d *qemu) removeUnixDevices() error {
 	// Check that we indeed have devices to remove.
 	if !shared.PathExists(d.DevicesPath()) {
@@ -5316,6 +5859,374 @@ func (d *qemu) Migrate(args *instance.CriuMigrationArgs) error {
 	return d.Start(true)
 }
 
+// LiveMigrateSend sends the running VM to the target of a live migration.
+// The root disk writes are redirected into an overlay while its volume is transferred and are then mirrored to the
+// target along with any further writes, while the memory is pre-copied. Once the VM has been paused and its state
+// sent, it's stopped if the target resumed it, otherwise it resumes here.
+func (d *qemu) LiveMigrateSend(args instance.LiveMigrateArgs) error {
+	d.logger.Debug("Live migration send started")
+	defer d.logger.Debug("Live migration send finished")
+
+	// Connect to the monitor.
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return err
+	}
+
+	err = d.checkNoHotplugged(monitor)
+	if err != nil {
+		return err
+	}
+
+	err = d.checkLiveMigrateDisks()
+	if err != nil {
+		return err
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	overlayPath := filepath.Join(d.LogPath(), "migration.qcow2")
+
+	if args.DiskConn != nil {
+		rootNodeName, err := d.rootDiskNodeName()
+		if err != nil {
+			return err
+		}
+
+		mountInfo, err := d.mount()
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = d.unmount() }()
+
+		rootSize, err := storageDrivers.BlockDiskSizeBytes(mountInfo.DiskPath)
+		if err != nil {
+			return fmt.Errorf("Failed getting root disk size: %w", err)
+		}
+
+		// Redirect the root disk writes into an overlay so that its volume can be sent consistently.
+		_, err = shared.RunCommand("qemu-img", "create", "-f", "qcow2", overlayPath, fmt.Sprintf("%d", rootSize))
+		if err != nil {
+			return fmt.Errorf("Failed creating root disk overlay: %w", err)
+		}
+
+		revert.Add(func() { _ = os.Remove(overlayPath) })
+
+		err = monitor.AddBlockNode(map[string]any{
+			"driver":    "qcow2",
+			"node-name": qemuMigrationOverlayNodeName,
+			"backing":   nil,
+			"file": map[string]any{
+				"driver":   "file",
+				"filename": overlayPath,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		revert.Add(func() { _ = monitor.RemoveBlockDevice(qemuMigrationOverlayNodeName) })
+
+		err = monitor.SnapshotBlockNode(rootNodeName, qemuMigrationOverlayNodeName)
+		if err != nil {
+			return err
+		}
+
+		// Merge the writes back into the root disk if the migration fails.
+		revert.Add(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), qemuBlockJobTimeout)
+			defer cancel()
+
+			err := monitor.CommitBlockNode(qemuMigrationCommitJobID, qemuMigrationOverlayNodeName)
+			if err == nil {
+				err = monitor.WaitBlockJob(ctx, qemuMigrationCommitJobID, false)
+			}
+
+			if err == nil {
+				err = monitor.CompleteBlockJob(qemuMigrationCommitJobID)
+			}
+
+			if err == nil {
+				err = monitor.WaitBlockJob(ctx, qemuMigrationCommitJobID, true)
+			}
+
+			if err != nil {
+				d.logger.Error("Failed merging root disk writes after failed live migration", logger.Ctx{"err": err})
+			}
+		})
+	}
+
+	// Send the volumes while the VM keeps running.
+	err = args.TransferVolume()
+	if err != nil {
+		return err
+	}
+
+	var diskDone <-chan error
+
+	if args.DiskConn != nil {
+		// Mirror the root disk writes to the target's root disk, exported over NBD.
+		qemuFile, conn, err := migrationSocketPair()
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = qemuFile.Close() }()
+
+		diskDone = migrationProxy(conn, args.DiskConn)
+
+		err = monitor.SendFile(qemuMigrationNBDNodeName, qemuFile)
+		if err != nil {
+			return err
+		}
+
+		err = monitor.AddBlockNode(map[string]any{
+			"driver":    "nbd",
+			"node-name": qemuMigrationNBDNodeName,
+			"export":    qemuMigrationNBDExportName,
+			"server": map[string]any{
+				"type": "fd",
+				"str":  qemuMigrationNBDNodeName,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		revert.Add(func() { _ = monitor.RemoveBlockDevice(qemuMigrationNBDNodeName) })
+
+		err = monitor.MirrorBlockNode(qemuMigrationMirrorJobID, qemuMigrationOverlayNodeName, qemuMigrationNBDNodeName)
+		if err != nil {
+			return err
+		}
+
+		revert.Add(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), qemuBlockJobTimeout)
+			defer cancel()
+
+			_ = monitor.CancelBlockJob(qemuMigrationMirrorJobID)
+			_ = monitor.WaitBlockJob(ctx, qemuMigrationMirrorJobID, true)
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), qemuBlockJobTimeout)
+		defer cancel()
+
+		err = monitor.WaitBlockJob(ctx, qemuMigrationMirrorJobID, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Pre-copy the memory while the VM keeps running, throttling its vCPUs if the copy doesn't converge.
+	err = monitor.MigrateSetCapabilities(map[string]bool{"auto-converge": true})
+	if err != nil {
+		return fmt.Errorf("Failed setting migration capabilities: %w", err)
+	}
+
+	qemuFile, conn, err := migrationSocketPair()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = qemuFile.Close() }()
+
+	_ = migrationProxy(conn, args.StateConn)
+
+	err = monitor.SendFile("migration", qemuFile)
+	if err != nil {
+		return err
+	}
+
+	migrateCtx, migrateCancel := context.WithTimeout(context.Background(), qemuMigrationTimeout)
+	defer migrateCancel()
+
+	err = monitor.Migrate(migrateCtx, "fd:migration")
+	if err != nil {
+		return err
+	}
+
+	// The VM is now paused, resume it if the target fails to.
+	revert.Add(func() { _ = monitor.Start() })
+
+	if args.DiskConn != nil {
+		// Stop mirroring, leaving the target's root disk in sync.
+		err = monitor.CancelBlockJob(qemuMigrationMirrorJobID)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), qemuBlockJobTimeout)
+		defer cancel()
+
+		err = monitor.WaitBlockJob(ctx, qemuMigrationMirrorJobID, true)
+		if err != nil {
+			return err
+		}
+
+		err = monitor.RemoveBlockDevice(qemuMigrationNBDNodeName)
+		if err != nil {
+			return err
+		}
+
+		err = <-diskDone
+		if err != nil {
+			return fmt.Errorf("Failed mirroring root disk: %w", err)
+		}
+	}
+
+	err = args.Complete()
+	if err != nil {
+		return err
+	}
+
+	revert.Success()
+
+	// The VM is now running on the target.
+	if args.ClusterMove {
+		err = d.stopMoved()
+	} else {
+		err = d.Stop(false)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(overlayPath)
+
+	return nil
+}
+
+// stopMoved stops the VM once it runs on the cluster member it was live moved to, without changing the instance
+// record now used by that member.
+func (d *qemu) stopMoved() error {
+	d.recordMoved = true
+
+	key := project.Instance(d.Project(), d.Name())
+	qemuMovedInstances.Store(key, d)
+	defer qemuMovedInstances.Delete(key)
+
+	return d.Stop(false)
+}
+
+// LiveMigrateReceive starts the instance from the running state sent by the source of a live migration.
+func (d *qemu) LiveMigrateReceive(args instance.LiveMigrateArgs) error {
+	// Although the instance technically isn't considered stateful, we set this to allow starting from the
+	// migration stream.
+	d.stateful = true
+	d.migrationReceive = &args
+	defer func() { d.migrationReceive = nil }()
+
+	return d.Start(true)
+}
+
+// migrateReceiveState receives the state of the VM from the source of a live migration, exporting the root disk
+// over NBD for the source to mirror its writes into until the VM is paused there.
+func (d *qemu) migrateReceiveState(monitor *qmp.Monitor, args *instance.LiveMigrateArgs) error {
+	var diskDone <-chan error
+
+	if args.DiskConn != nil {
+		rootNodeName, err := d.rootDiskNodeName()
+		if err != nil {
+			return err
+		}
+
+		socketPath := filepath.Join(d.LogPath(), "qemu.nbd")
+
+		err = monitor.NBDServerStart(socketPath)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = monitor.NBDServerStop()
+			_ = os.Remove(socketPath)
+		}()
+
+		err = monitor.NBDServerAdd(rootNodeName, qemuMigrationNBDExportName, true)
+		if err != nil {
+			return err
+		}
+
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			return fmt.Errorf("Failed connecting to NBD server: %w", err)
+		}
+
+		diskDone = migrationProxy(conn, args.DiskConn)
+	}
+
+	qemuFile, conn, err := migrationSocketPair()
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = qemuFile.Close() }()
+
+	_ = migrationProxy(conn, args.StateConn)
+
+	err = monitor.SendFile("migration", qemuFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), qemuMigrationTimeout)
+	defer cancel()
+
+	err = monitor.MigrateIncoming(ctx, "fd:migration")
+	if err != nil {
+		return err
+	}
+
+	// Wait for the source to be done mirroring the root disk.
+	if diskDone != nil {
+		err = <-diskDone
+		if err != nil {
+			return fmt.Errorf("Failed mirroring root disk: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkLiveMigrateDisks returns an error if disks other than the root disk aren't on shared storage, as only the
+// root disk is transferred and mirrored to the target of a live migration.
+func (d *qemu) checkLiveMigrateDisks() error {
+	for _, entry := range d.expandedDevices.Sorted() {
+		dev := entry.Config
+		if dev["type"] != "disk" || shared.IsRootDiskDevice(dev) || dev["source"] == "cloud-init:config" {
+			continue
+		}
+
+		if dev["pool"] == "" {
+			return fmt.Errorf("Cannot live migrate a VM with host disk %q", entry.Name)
+		}
+
+		pool, err := storagePools.LoadByName(d.state, dev["pool"])
+		if err != nil {
+			return err
+		}
+
+		if !pool.Driver().Info().Remote {
+			return fmt.Errorf("Cannot live migrate a VM with disk %q on local storage pool %q", entry.Name, dev["pool"])
+		}
+	}
+
+	return nil
+}
+
+// rootDiskNodeName returns the name of the block node of the root disk.
+func (d *qemu) rootDiskNodeName() (string, error) {
+	rootDiskName, _, err := d.getRootDiskDevice()
+	if err != nil {
+		return "", err
+	}
+
+	return d.blockNodeName(filesystem.PathNameEncode(rootDiskName)), nil
+}
+
 // CGroupSet is not implemented for VMs.
 func (d *qemu) CGroup() (*cgroup.CGroup, error) {
 	return nil, instance.ErrNotImplemented
@@ -5407,6 +6318,34 @@ func (d *qemu) FileSFTP() (*sftp.Client, error) {
 	return client, nil
 }
 
+// FileWatch watches a path inside the VM for changes through the lxd-agent.
+func (d *qemu) FileWatch(ctx context.Context, path string, recursive bool) (<-chan api.InstanceFileWatchEvent, error) {
+	client, err := d.getAgentClient()
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := lxd.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		d.logger.Error("Failed to connect to lxd-agent", logger.Ctx{"err": err})
+		return nil, fmt.Errorf("Failed to connect to lxd-agent")
+	}
+
+	conn, err := agent.GetInstanceFileWatch("", path, recursive)
+	if err != nil {
+		agent.Disconnect()
+		return nil, err
+	}
+
+	// Disconnect from the agent once the watch is over.
+	go func() {
+		<-ctx.Done()
+		agent.Disconnect()
+	}()
+
+	return filewatch.Receive(ctx, conn), nil
+}
+
 // Console gets access to the instance's console.
 func (d *qemu) Console(protocol string) (*os.File, chan error, error) {
 	var path string
@@ -5440,6 +6379,117 @@ func (d *qemu) Console(protocol string) (*os.File, chan error, error) {
 	return file, chDisconnect, nil
 }
 
+// AgentHasExtension returns whether the lxd-agent running in the VM supports the given API extension.
+func (d *qemu) AgentHasExtension(extension string) (bool, error) {
+	client, err := d.getAgentClient()
+	if err != nil {
+		return false, err
+	}
+
+	agent, err := lxd.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		return false, fmt.Errorf("Failed connecting to lxd-agent: %w", err)
+	}
+
+	defer agent.Disconnect()
+
+	return agent.HasExtension(extension), nil
+}
+
+// FilesystemFreeze asks the lxd-agent to freeze the guest filesystems (running its freeze hooks) if
+// snapshots.fsfreeze is enabled and the VM is running. Returns a function thawing them, or nil if nothing was frozen.
+// The lxd-agent thaws the filesystems by itself after snapshots.fsfreeze.timeout seconds, in which case the returned
+// function fails as whatever was captured since then may be inconsistent.
+func (d *qemu) FilesystemFreeze() (func() error, error) {
+	if d.IsSnapshot() || shared.IsFalseOrEmpty(d.expandedConfig["snapshots.fsfreeze"]) || !d.IsRunning() || d.IsFrozen() {
+		return nil, nil
+	}
+
+	timeout := 60
+	if d.expandedConfig["snapshots.fsfreeze.timeout"] != "" {
+		timeout, _ = strconv.Atoi(d.expandedConfig["snapshots.fsfreeze.timeout"])
+	}
+
+	client, err := d.getAgentClient()
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := lxd.ConnectLXDHTTP(nil, client)
+	if err != nil {
+		return nil, fmt.Errorf("Failed connecting to lxd-agent: %w", err)
+	}
+
+	_, _, err = agent.RawQuery("POST", "/1.0/fsfreeze", agentAPI.FilesystemFreezePost{Action: "freeze", Timeout: timeout}, "")
+	if err != nil {
+		agent.Disconnect()
+		return nil, fmt.Errorf("Failed freezing guest filesystems: %w", err)
+	}
+
+	d.logger.Debug("Froze guest filesystems", logger.Ctx{"timeout": timeout})
+
+	return func() error {
+		defer agent.Disconnect()
+
+		_, _, err := agent.RawQuery("POST", "/1.0/fsfreeze", agentAPI.FilesystemFreezePost{Action: "thaw"}, "")
+		if err != nil {
+			return fmt.Errorf("Failed thawing guest filesystems, they may not have stayed frozen (snapshots.fsfreeze.timeout of %ds): %w", timeout, err)
+		}
+
+		d.logger.Debug("Thawed guest filesystems")
+
+		return nil
+	}, nil
+}
+
+// ConsoleScreenshot returns a screenshot of the VGA console in the given format (ppm or png).
+func (d *qemu) ConsoleScreenshot(format string) ([]byte, error) {
+	if !shared.StringInSlice(format, []string{"ppm", "png"}) {
+		return nil, fmt.Errorf("Unsupported screenshot format %q", format)
+	}
+
+	if !d.IsRunning() {
+		return nil, fmt.Errorf("Instance is not running")
+	}
+
+	monitor, err := qmp.Connect(d.monitorPath(), qemuSerialChardevName, d.getMonitorEventHandler())
+	if err != nil {
+		return nil, err
+	}
+
+	// Have QEMU write the screenshot through a file descriptor as it runs unprivileged.
+	f, err := ioutil.TempFile(d.LogPath(), "screenshot_")
+	if err != nil {
+		return nil, fmt.Errorf("Failed creating screenshot file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+	defer func() { _ = f.Close() }()
+
+	// QEMU only uses the file descriptors of a FD set when opening the file with the same access mode.
+	w, err := os.OpenFile(f.Name(), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed opening screenshot file: %w", err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	fdName := "screenshot"
+	info, err := monitor.SendFileWithFDSet(fdName, w, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed sending screenshot file descriptor: %w", err)
+	}
+
+	defer func() { _ = monitor.RemoveFDFromFDSet(fdName) }()
+
+	err = monitor.Screendump(fmt.Sprintf("/dev/fdset/%d", info.ID), format)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(f)
+}
+
 // Exec a command inside the instance.
 func (d *qemu) Exec(req api.InstanceExecPost, stdin *os.File, stdout *os.File, stderr *os.File) (instance.Cmd, error) {
 	revert := revert.New()
@@ -5699,6 +6749,11 @@ func (d *qemu) renderState(statusCode api.StatusCode) (*api.InstanceState, error
 				}
 			}
 		}
+
+		// Populate transfer accounting for network devices (uses host_name populated above).
+		d.networkTransferStateAdd(status.Network)
+
+		status.Health = d.healthState()
 	}
 
 	status.Pid = int64(pid)
@@ -5712,6 +6767,12 @@ func (d *qemu) renderState(statusCode api.StatusCode) (*api.InstanceState, error
 	return status, nil
 }
 
+// FirewallRulesets returns the host firewall rules generated for each of the instance's NIC devices alongside the
+// rules loaded in the kernel.
+func (d *qemu) FirewallRulesets() (map[string]*firewallDrivers.Ruleset, error) {
+	return d.firewallRulesets(d)
+}
+
 // RenderState returns just state info about the instance.
 func (d *qemu) RenderState() (*api.InstanceState, error) {
 	return d.renderState(d.statusCode())
@@ -6270,11 +7331,22 @@ func (d *qemu) checkFeature(qemu string, args ...string) (bool, error) {
 }
 
 func (d *qemu) Metrics() (*metrics.MetricSet, error) {
+	var out *metrics.MetricSet
+	var err error
+
 	if d.agentMetricsEnabled() {
-		return d.getAgentMetrics()
+		out, err = d.getAgentMetrics()
+	} else {
+		out, err = d.getQemuMetrics()
 	}
 
-	return d.getQemuMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	d.networkTransferMetrics(out)
+
+	return out, nil
 }
 
 func (d *qemu) getAgentMetrics() (*metrics.MetricSet, error) {
@@ -6412,3 +7484,60 @@ func (d *qemu) blockNodeName(name string) string {
 	// Apply the lxd_ prefix.
 	return fmt.Sprintf("%s%s", qemuBlockDevIDPrefix, name)
 }
+
+// migrationSocketPair returns a connected pair of sockets, the file to be handed to QEMU and the connection to proxy.
+func migrationSocketPair() (*os.File, net.Conn, error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed creating socket pair: %w", err)
+	}
+
+	qemuFile := os.NewFile(uintptr(fds[0]), "qemu")
+	connFile := os.NewFile(uintptr(fds[1]), "conn")
+	defer func() { _ = connFile.Close() }()
+
+	conn, err := net.FileConn(connFile)
+	if err != nil {
+		_ = qemuFile.Close()
+		return nil, nil, fmt.Errorf("Failed creating socket connection: %w", err)
+	}
+
+	return qemuFile, conn, nil
+}
+
+// migrationProxy copies data in both directions between conn and rw until both sides are done.
+// The returned channel receives the first copy error, if any, once done.
+func migrationProxy(conn net.Conn, rw io.ReadWriteCloser) <-chan error {
+	done := make(chan error, 1)
+	errs := make(chan error, 2)
+
+	go func() {
+		_, err := io.Copy(rw, conn)
+		_ = rw.Close()
+		errs <- err
+	}()
+
+	go func() {
+		_, err := io.Copy(conn, rw)
+		unixConn, ok := conn.(*net.UnixConn)
+		if ok {
+			_ = unixConn.CloseWrite()
+		}
+
+		errs <- err
+	}()
+
+	go func() {
+		err := <-errs
+		err2 := <-errs
+		_ = conn.Close()
+
+		if err == nil {
+			err = err2
+		}
+
+		done <- err
+	}()
+
+	return done
+}
@@ -96,15 +96,22 @@ func TestQemuConfigTemplates(t *testing.T) {
 			opts     qemuMemoryOpts
 			expected string
 		}{{
-			qemuMemoryOpts{4096},
+			qemuMemoryOpts{memSizeMB: 4096},
 			`# Memory
 			[memory]
 			size = "4096M"`,
 		}, {
-			qemuMemoryOpts{8192},
+			qemuMemoryOpts{memSizeMB: 8192},
 			`# Memory
 			[memory]
 			size = "8192M"`,
+		}, {
+			qemuMemoryOpts{memSizeMB: 1024, memMaxSizeMB: 8192, memSlots: 16},
+			`# Memory
+			[memory]
+			size = "1024M"
+			slots = "16"
+			maxmem = "8192M"`,
 		}}
 		for _, tc := range testCases {
 			runTest(tc.expected, qemuMemory(&tc.opts))
@@ -404,6 +411,38 @@ func TestQemuConfigTemplates(t *testing.T) {
 			opts     qemuCPUOpts
 			expected string
 		}{{
+			qemuCPUOpts{
+				architecture:        "x86_64",
+				cpuCount:            2,
+				cpuMaxCount:         8,
+				cpuSockets:          1,
+				cpuCores:            8,
+				cpuThreads:          1,
+				cpuNumaNodes:        []uint64{},
+				cpuNumaMapping:      []qemuNumaEntry{},
+				cpuNumaHostNodes:    []uint64{},
+				hugepages:           "",
+				memory:              1024,
+				qemuMemObjectFormat: "repeated",
+			},
+			`# CPU
+			[smp-opts]
+			cpus = "2"
+			maxcpus = "8"
+			sockets = "1"
+			cores = "8"
+			threads = "1"
+
+			[object "mem0"]
+			qom-type = "memory-backend-memfd"
+			size = "1024M"
+			share = "on"
+
+			[numa]
+			type = "node"
+			nodeid = "0"
+			memdev = "mem0"`,
+		}, {
 			qemuCPUOpts{
 				architecture:        "x86_64",
 				cpuCount:            8,
@@ -695,13 +734,15 @@ func TestQemuConfigTemplates(t *testing.T) {
 			opts     qemuConsoleOpts
 			expected string
 		}{{
-			qemuConsoleOpts{"/dev/shm/console-socket"},
+			qemuConsoleOpts{"/dev/shm/console-socket", "/var/log/console.log"},
 			`# Console
 			[chardev "console"]
 			backend = "socket"
 			path = "/dev/shm/console-socket"
 			server = "on"
-			wait = "off"`,
+			wait = "off"
+			logfile = "/var/log/console.log"
+			logappend = "on"`,
 		}}
 		for _, tc := range testCases {
 			runTest(tc.expected, qemuConsole(&tc.opts))
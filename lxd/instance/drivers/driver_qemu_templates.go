@@ -101,14 +101,25 @@ func qemuBase(opts *qemuBaseOpts) []cfgSection {
 }
 
 type qemuMemoryOpts struct {
-	memSizeMB int64
+	memSizeMB    int64
+	memMaxSizeMB int64
+	memSlots     int
 }
 
 func qemuMemory(opts *qemuMemoryOpts) []cfgSection {
+	entries := []cfgEntry{{key: "size", value: fmt.Sprintf("%dM", opts.memSizeMB)}}
+
+	if opts.memMaxSizeMB > 0 {
+		entries = append(entries, []cfgEntry{
+			{key: "slots", value: fmt.Sprintf("%d", opts.memSlots)},
+			{key: "maxmem", value: fmt.Sprintf("%dM", opts.memMaxSizeMB)},
+		}...)
+	}
+
 	return []cfgSection{{
 		name:    "memory",
 		comment: "Memory",
-		entries: []cfgEntry{{key: "size", value: fmt.Sprintf("%dM", opts.memSizeMB)}},
+		entries: entries,
 	}}
 }
 
@@ -368,6 +379,7 @@ type qemuNumaEntry struct {
 type qemuCPUOpts struct {
 	architecture        string
 	cpuCount            int
+	cpuMaxCount         int
 	cpuSockets          int
 	cpuCores            int
 	cpuThreads          int
@@ -409,15 +421,22 @@ func qemuCPUNumaHostNode(opts *qemuCPUOpts, index int) []cfgSection {
 }
 
 func qemuCPU(opts *qemuCPUOpts) []cfgSection {
+	entries := []cfgEntry{{key: "cpus", value: fmt.Sprintf("%d", opts.cpuCount)}}
+
+	if opts.cpuMaxCount > 0 {
+		entries = append(entries, cfgEntry{key: "maxcpus", value: fmt.Sprintf("%d", opts.cpuMaxCount)})
+	}
+
+	entries = append(entries, []cfgEntry{
+		{key: "sockets", value: fmt.Sprintf("%d", opts.cpuSockets)},
+		{key: "cores", value: fmt.Sprintf("%d", opts.cpuCores)},
+		{key: "threads", value: fmt.Sprintf("%d", opts.cpuThreads)},
+	}...)
+
 	sections := []cfgSection{{
 		name:    "smp-opts",
 		comment: "CPU",
-		entries: []cfgEntry{
-			{key: "cpus", value: fmt.Sprintf("%d", opts.cpuCount)},
-			{key: "sockets", value: fmt.Sprintf("%d", opts.cpuSockets)},
-			{key: "cores", value: fmt.Sprintf("%d", opts.cpuCores)},
-			{key: "threads", value: fmt.Sprintf("%d", opts.cpuThreads)},
-		},
+		entries: entries,
 	}}
 
 	if opts.architecture != "x86_64" {
@@ -498,7 +517,8 @@ func qemuControlSocket(opts *qemuControlSocketOpts) []cfgSection {
 }
 
 type qemuConsoleOpts struct {
-	path string
+	path    string
+	logPath string
 }
 
 func qemuConsole(opts *qemuConsoleOpts) []cfgSection {
@@ -510,6 +530,8 @@ func qemuConsole(opts *qemuConsoleOpts) []cfgSection {
 			{key: "path", value: opts.path},
 			{key: "server", value: "on"},
 			{key: "wait", value: "off"},
+			{key: "logfile", value: opts.logPath},
+			{key: "logappend", value: "on"},
 		},
 	}}
 }
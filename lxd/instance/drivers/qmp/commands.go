@@ -1,6 +1,7 @@
 package qmp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -152,8 +153,25 @@ func (m *Monitor) RemoveFDFromFDSet(name string) error {
 	return nil
 }
 
-// Migrate starts a migration stream.
-func (m *Monitor) Migrate(uri string) error {
+// Screendump saves the content of the primary display into the file in the given format (ppm or png).
+func (m *Monitor) Screendump(filename string, format string) error {
+	args := map[string]string{"filename": filename}
+
+	// Only pass the format when needed as older versions of QEMU only support PPM.
+	if format != "ppm" {
+		args["format"] = format
+	}
+
+	err := m.run("screendump", args, nil)
+	if err != nil {
+		return fmt.Errorf("Failed taking screenshot: %w", err)
+	}
+
+	return nil
+}
+
+// Migrate starts a migration stream and waits for it to complete, cancelling it if ctx is done first.
+func (m *Monitor) Migrate(ctx context.Context, uri string) error {
 	// Query the status.
 	args := map[string]string{"uri": uri}
 	err := m.run("migrate", args, nil)
@@ -163,7 +181,12 @@ func (m *Monitor) Migrate(uri string) error {
 
 	// Wait until it completes or fails.
 	for {
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			_ = m.run("migrate_cancel", nil, nil)
+			return fmt.Errorf("Migration cancelled: %w", ctx.Err())
+		case <-time.After(time.Second):
+		}
 
 		// Prepare the response.
 		var resp struct {
@@ -189,8 +212,26 @@ func (m *Monitor) Migrate(uri string) error {
 	return nil
 }
 
-// MigrateIncoming starts the receiver of a migration stream.
-func (m *Monitor) MigrateIncoming(uri string) error {
+// MigrateSetCapabilities enables or disables the migration capabilities.
+func (m *Monitor) MigrateSetCapabilities(capabilities map[string]bool) error {
+	caps := []map[string]any{}
+	for name, state := range capabilities {
+		caps = append(caps, map[string]any{"capability": name, "state": state})
+	}
+
+	args := map[string]any{"capabilities": caps}
+	err := m.run("migrate-set-capabilities", args, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MigrateIncoming starts the receiver of a migration stream and waits for it to complete.
+// An incoming migration can't be cancelled, so if ctx is done first an error is returned and it's up to the caller
+// to stop the VM.
+func (m *Monitor) MigrateIncoming(ctx context.Context, uri string) error {
 	// Query the status.
 	args := map[string]string{"uri": uri}
 	err := m.run("migrate-incoming", args, nil)
@@ -200,7 +241,11 @@ func (m *Monitor) MigrateIncoming(uri string) error {
 
 	// Wait until it completes or fails.
 	for {
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Incoming migration not completed: %w", ctx.Err())
+		case <-time.After(time.Second):
+		}
 
 		// Preapre the response.
 		var resp struct {
@@ -311,6 +356,121 @@ func (m *Monitor) SetMemoryBalloonSizeBytes(sizeBytes int64) error {
 	return m.run("balloon", args, nil)
 }
 
+// GetMemoryPluggedSizeBytes returns the size of the hot-plugged memory in bytes.
+func (m *Monitor) GetMemoryPluggedSizeBytes() (int64, error) {
+	// Prepare the response.
+	var resp struct {
+		Return struct {
+			PluggedMemory int64 `json:"plugged-memory"`
+		} `json:"return"`
+	}
+
+	err := m.run("query-memory-size-summary", nil, &resp)
+	if err != nil {
+		return -1, err
+	}
+
+	return resp.Return.PluggedMemory, nil
+}
+
+// GetMemoryDevices returns the IDs of the hot-plugged memory devices.
+func (m *Monitor) GetMemoryDevices() ([]string, error) {
+	// Prepare the response.
+	var resp struct {
+		Return []struct {
+			Data struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		} `json:"return"`
+	}
+
+	err := m.run("query-memory-devices", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]string, 0, len(resp.Return))
+	for _, device := range resp.Return {
+		devices = append(devices, device.Data.ID)
+	}
+
+	return devices, nil
+}
+
+// AddMemory hot-plugs a memory device of the given size in bytes.
+func (m *Monitor) AddMemory(id string, sizeBytes int64) error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	backendID := fmt.Sprintf("mem-%s", id)
+
+	args := map[string]any{
+		"qom-type": "memory-backend-memfd",
+		"id":       backendID,
+		"size":     sizeBytes,
+		"share":    true,
+	}
+
+	err := m.run("object-add", &args, nil)
+	if err != nil {
+		return fmt.Errorf("Failed adding memory backend: %w", err)
+	}
+
+	revert.Add(func() {
+		_ = m.run("object-del", map[string]string{"id": backendID}, nil)
+	})
+
+	device := map[string]string{
+		"driver": "pc-dimm",
+		"id":     id,
+		"memdev": backendID,
+	}
+
+	err = m.run("device_add", device, nil)
+	if err != nil {
+		return fmt.Errorf("Failed adding memory device: %w", err)
+	}
+
+	revert.Success()
+	return nil
+}
+
+// HotpluggableCPU represents a vCPU slot.
+type HotpluggableCPU struct {
+	Type    string         `json:"type"`
+	QOMPath string         `json:"qom-path"`
+	Props   map[string]int `json:"props"`
+}
+
+// QueryHotpluggableCPUs returns the vCPU slots of the VM, QOMPath is empty for the free ones.
+func (m *Monitor) QueryHotpluggableCPUs() ([]HotpluggableCPU, error) {
+	// Prepare the response.
+	var resp struct {
+		Return []HotpluggableCPU `json:"return"`
+	}
+
+	err := m.run("query-hotpluggable-cpus", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Return, nil
+}
+
+// AddCPU hot-plugs a vCPU into the free slot.
+func (m *Monitor) AddCPU(cpu HotpluggableCPU, id string) error {
+	args := map[string]any{
+		"driver": cpu.Type,
+		"id":     id,
+	}
+
+	for k, v := range cpu.Props {
+		args[k] = v
+	}
+
+	return m.run("device_add", args, nil)
+}
+
 // AddBlockDevice adds a block device.
 func (m *Monitor) AddBlockDevice(blockDev map[string]any, device map[string]string) error {
 	revert := revert.New()
@@ -559,3 +719,185 @@ func (m *Monitor) AddSecret(id string, secret string) error {
 
 	return nil
 }
+
+// AddBlockNode adds a block node without attaching it to a device.
+func (m *Monitor) AddBlockNode(blockDev map[string]any) error {
+	err := m.run("blockdev-add", blockDev, nil)
+	if err != nil {
+		return fmt.Errorf("Failed adding block node: %w", err)
+	}
+
+	return nil
+}
+
+// SnapshotBlockNode redirects the writes to the node into the overlay node, which gets the node as its backing.
+func (m *Monitor) SnapshotBlockNode(nodeName string, overlayNodeName string) error {
+	args := map[string]string{
+		"node":    nodeName,
+		"overlay": overlayNodeName,
+	}
+
+	err := m.run("blockdev-snapshot", args, nil)
+	if err != nil {
+		return fmt.Errorf("Failed snapshotting block node: %w", err)
+	}
+
+	return nil
+}
+
+// MirrorBlockNode starts a job mirroring the writes to the node and the data of its top layer into the target node.
+// Writes are only acknowledged once mirrored so that the target stays in sync once the job is ready.
+func (m *Monitor) MirrorBlockNode(jobID string, nodeName string, targetNodeName string) error {
+	args := map[string]string{
+		"job-id":    jobID,
+		"device":    nodeName,
+		"target":    targetNodeName,
+		"sync":      "top",
+		"copy-mode": "write-blocking",
+	}
+
+	err := m.run("blockdev-mirror", args, nil)
+	if err != nil {
+		return fmt.Errorf("Failed mirroring block node: %w", err)
+	}
+
+	return nil
+}
+
+// CommitBlockNode starts a job committing the data of the node into its backing node.
+func (m *Monitor) CommitBlockNode(jobID string, nodeName string) error {
+	args := map[string]string{
+		"job-id": jobID,
+		"device": nodeName,
+	}
+
+	err := m.run("block-commit", args, nil)
+	if err != nil {
+		return fmt.Errorf("Failed committing block node: %w", err)
+	}
+
+	return nil
+}
+
+// BlockJob represents a running block job.
+type BlockJob struct {
+	ID    string `json:"device"`
+	Type  string `json:"type"`
+	Ready bool   `json:"ready"`
+}
+
+// GetBlockJobs returns the running block jobs.
+func (m *Monitor) GetBlockJobs() ([]BlockJob, error) {
+	// Prepare the response.
+	var resp struct {
+		Return []BlockJob `json:"return"`
+	}
+
+	err := m.run("query-block-jobs", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Return, nil
+}
+
+// WaitBlockJob waits for the block job to be ready or, if done is true, to be gone.
+// The block job is cancelled if ctx is done first.
+func (m *Monitor) WaitBlockJob(ctx context.Context, jobID string, done bool) error {
+	for {
+		jobs, err := m.GetBlockJobs()
+		if err != nil {
+			return err
+		}
+
+		var job *BlockJob
+		for i := range jobs {
+			if jobs[i].ID == jobID {
+				job = &jobs[i]
+				break
+			}
+		}
+
+		if job == nil {
+			if done {
+				return nil
+			}
+
+			return fmt.Errorf("Block job %q failed", jobID)
+		}
+
+		if job.Ready && !done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = m.run("block-job-cancel", map[string]any{"device": jobID, "force": true}, nil)
+			return fmt.Errorf("Block job %q cancelled: %w", jobID, ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// CompleteBlockJob switches the devices to the target of the ready block job.
+func (m *Monitor) CompleteBlockJob(jobID string) error {
+	err := m.run("block-job-complete", map[string]string{"device": jobID}, nil)
+	if err != nil {
+		return fmt.Errorf("Failed completing block job: %w", err)
+	}
+
+	return nil
+}
+
+// CancelBlockJob stops the block job, leaving the target of a ready mirror job in sync.
+func (m *Monitor) CancelBlockJob(jobID string) error {
+	err := m.run("block-job-cancel", map[string]string{"device": jobID}, nil)
+	if err != nil {
+		return fmt.Errorf("Failed cancelling block job: %w", err)
+	}
+
+	return nil
+}
+
+// NBDServerStart starts the NBD server listening on the unix socket.
+func (m *Monitor) NBDServerStart(socketPath string) error {
+	args := map[string]any{
+		"addr": map[string]any{
+			"type": "unix",
+			"data": map[string]string{"path": socketPath},
+		},
+	}
+
+	err := m.run("nbd-server-start", args, nil)
+	if err != nil {
+		return fmt.Errorf("Failed starting NBD server: %w", err)
+	}
+
+	return nil
+}
+
+// NBDServerAdd exports the block node over the NBD server under the export name.
+func (m *Monitor) NBDServerAdd(nodeName string, exportName string, writable bool) error {
+	args := map[string]any{
+		"device":   nodeName,
+		"name":     exportName,
+		"writable": writable,
+	}
+
+	err := m.run("nbd-server-add", args, nil)
+	if err != nil {
+		return fmt.Errorf("Failed exporting block node: %w", err)
+	}
+
+	return nil
+}
+
+// NBDServerStop stops the NBD server.
+func (m *Monitor) NBDServerStop() error {
+	err := m.run("nbd-server-stop", nil, nil)
+	if err != nil {
+		return fmt.Errorf("Failed stopping NBD server: %w", err)
+	}
+
+	return nil
+}
@@ -22,6 +22,10 @@ var RingbufSize = 16
 // AgentStatusStarted is the event sent once the lxd-agent has started.
 var AgentStatusStarted = "LXD-AGENT-STARTED"
 
+// EventDisconnect is the event sent when QEMU closes the monitor without having sent a SHUTDOWN event first,
+// such as when the QEMU process is killed.
+var EventDisconnect = "LXD-DISCONNECT"
+
 // Monitor represents a QMP monitor.
 type Monitor struct {
 	path string
@@ -86,6 +90,8 @@ func (m *Monitor) start() error {
 		// Initial read from the ringbuffer.
 		go checkBuffer()
 
+		shutdown := false
+
 		for {
 			// Wait for an event, disconnection or timeout.
 			select {
@@ -97,9 +103,18 @@ func (m *Monitor) start() error {
 					go m.eventHandler(e.Event, e.Data)
 				}
 
+				if e.Event == "SHUTDOWN" {
+					shutdown = true
+				}
+
 				// Event channel is closed, lets disconnect.
 				if !more {
 					m.Disconnect()
+
+					if !shutdown && m.eventHandler != nil {
+						go m.eventHandler(EventDisconnect, nil)
+					}
+
 					return
 				}
 
@@ -0,0 +1,130 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// buffer is a fixed size ring buffer of samples.
+type buffer struct {
+	samples []api.InstanceStateHistorySample
+	start   int
+	count   int
+}
+
+// newBuffer returns a buffer holding up to size samples.
+func newBuffer(size int) *buffer {
+	return &buffer{samples: make([]api.InstanceStateHistorySample, size)}
+}
+
+// add records the sample, replacing the oldest one if the buffer is full.
+func (b *buffer) add(sample api.InstanceStateHistorySample) {
+	if len(b.samples) == 0 {
+		return
+	}
+
+	if b.count < len(b.samples) {
+		b.samples[(b.start+b.count)%len(b.samples)] = sample
+		b.count++
+		return
+	}
+
+	b.samples[b.start] = sample
+	b.start = (b.start + 1) % len(b.samples)
+}
+
+// list returns the samples, oldest first.
+func (b *buffer) list() []api.InstanceStateHistorySample {
+	samples := make([]api.InstanceStateHistorySample, 0, b.count)
+	for i := 0; i < b.count; i++ {
+		samples = append(samples, b.samples[(b.start+i)%len(b.samples)])
+	}
+
+	return samples
+}
+
+// resize changes the number of samples the buffer holds, keeping the newest ones.
+func (b *buffer) resize(size int) {
+	samples := b.list()
+	if len(samples) > size {
+		samples = samples[len(samples)-size:]
+	}
+
+	b.samples = make([]api.InstanceStateHistorySample, size)
+	b.start = 0
+	b.count = copy(b.samples, samples)
+}
+
+// prune drops the samples taken before the cutoff.
+func (b *buffer) prune(cutoff time.Time) {
+	for b.count > 0 && b.samples[b.start].Time.Before(cutoff) {
+		b.samples[b.start] = api.InstanceStateHistorySample{}
+		b.start = (b.start + 1) % len(b.samples)
+		b.count--
+	}
+}
+
+// instanceKey identifies an instance.
+type instanceKey struct {
+	project string
+	name    string
+}
+
+// buffers holds the samples of the instances.
+var buffers = map[instanceKey]*buffer{}
+
+// buffersMu protects buffers.
+var buffersMu sync.Mutex
+
+// Add records a sample for the instance, keeping up to size samples for it.
+func Add(projectName string, instanceName string, sample api.InstanceStateHistorySample, size int) {
+	buffersMu.Lock()
+	defer buffersMu.Unlock()
+
+	key := instanceKey{project: projectName, name: instanceName}
+
+	b := buffers[key]
+	if b == nil {
+		b = newBuffer(size)
+		buffers[key] = b
+	} else if len(b.samples) != size {
+		b.resize(size)
+	}
+
+	b.add(sample)
+}
+
+// Get returns the samples recorded for the instance, oldest first.
+func Get(projectName string, instanceName string) []api.InstanceStateHistorySample {
+	buffersMu.Lock()
+	defer buffersMu.Unlock()
+
+	b := buffers[instanceKey{project: projectName, name: instanceName}]
+	if b == nil {
+		return []api.InstanceStateHistorySample{}
+	}
+
+	return b.list()
+}
+
+// Prune forgets the samples taken before the cutoff, as well as all the samples of the instances that keep returns
+// false for.
+func Prune(cutoff time.Time, keep func(projectName string, instanceName string) bool) {
+	buffersMu.Lock()
+	defer buffersMu.Unlock()
+
+	for key, b := range buffers {
+		if !keep(key.project, key.name) {
+			delete(buffers, key)
+			continue
+		}
+
+		b.prune(cutoff)
+
+		if b.count == 0 {
+			delete(buffers, key)
+		}
+	}
+}
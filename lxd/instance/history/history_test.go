@@ -0,0 +1,95 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// samplesAt returns samples taken at the specified minutes past the start time.
+func samplesAt(start time.Time, minutes ...int) []api.InstanceStateHistorySample {
+	samples := make([]api.InstanceStateHistorySample, 0, len(minutes))
+	for _, minute := range minutes {
+		samples = append(samples, api.InstanceStateHistorySample{Time: start.Add(time.Duration(minute) * time.Minute), MemoryUsage: int64(minute)})
+	}
+
+	return samples
+}
+
+// checkSamples fails the test if the samples aren't the ones taken at the expected minutes, in order.
+func checkSamples(t *testing.T, samples []api.InstanceStateHistorySample, minutes ...int) {
+	t.Helper()
+
+	if len(samples) != len(minutes) {
+		t.Fatalf("Expected %d samples but got %d", len(minutes), len(samples))
+	}
+
+	for i, minute := range minutes {
+		if samples[i].MemoryUsage != int64(minute) {
+			t.Fatalf("Expected sample %d to be from minute %d but got minute %d", i, minute, samples[i].MemoryUsage)
+		}
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	start := time.Now()
+	b := newBuffer(3)
+
+	for _, sample := range samplesAt(start, 0, 1) {
+		b.add(sample)
+	}
+
+	checkSamples(t, b.list(), 0, 1)
+
+	for _, sample := range samplesAt(start, 2, 3, 4) {
+		b.add(sample)
+	}
+
+	checkSamples(t, b.list(), 2, 3, 4)
+
+	b.resize(5)
+	checkSamples(t, b.list(), 2, 3, 4)
+
+	for _, sample := range samplesAt(start, 5, 6, 7) {
+		b.add(sample)
+	}
+
+	checkSamples(t, b.list(), 3, 4, 5, 6, 7)
+
+	b.resize(2)
+	checkSamples(t, b.list(), 6, 7)
+
+	b.prune(start.Add(7 * time.Minute))
+	checkSamples(t, b.list(), 7)
+
+	b.prune(start.Add(8 * time.Minute))
+	checkSamples(t, b.list())
+
+	empty := newBuffer(0)
+	empty.add(samplesAt(start, 0)[0])
+	checkSamples(t, empty.list())
+}
+
+func TestAddGetPrune(t *testing.T) {
+	start := time.Now()
+
+	for _, sample := range samplesAt(start, 0, 1, 2) {
+		Add("default", "c1", sample, 2)
+	}
+
+	Add("other", "c1", samplesAt(start, 5)[0], 2)
+
+	checkSamples(t, Get("default", "c1"), 1, 2)
+	checkSamples(t, Get("other", "c1"), 5)
+	checkSamples(t, Get("default", "c2"))
+
+	keepAll := func(projectName string, instanceName string) bool { return true }
+
+	Prune(start.Add(3*time.Minute), keepAll)
+	checkSamples(t, Get("default", "c1"))
+	checkSamples(t, Get("other", "c1"), 5)
+
+	Prune(start, func(projectName string, instanceName string) bool { return projectName != "other" })
+	checkSamples(t, Get("other", "c1"))
+}
@@ -1,6 +1,7 @@
 package instance
 
 import (
+	"context"
 	"crypto/x509"
 	"io"
 	"net"
@@ -14,6 +15,7 @@ import (
 	"github.com/lxc/lxd/lxd/cgroup"
 	"github.com/lxc/lxd/lxd/db"
 	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/instance/operationlock"
 	"github.com/lxc/lxd/lxd/metrics"
@@ -98,6 +100,7 @@ type Instance interface {
 	// File handling.
 	FileSFTPConn() (net.Conn, error)
 	FileSFTP() (*sftp.Client, error)
+	FileWatch(ctx context.Context, path string, recursive bool) (<-chan api.InstanceFileWatchEvent, error)
 
 	// Console - Allocate and run a console tty or a spice Unix socket.
 	Console(protocol string) (*os.File, chan error, error)
@@ -107,6 +110,7 @@ type Instance interface {
 	Render(options ...func(response any) error) (any, any, error)
 	RenderFull() (*api.InstanceFull, any, error)
 	RenderState() (*api.InstanceState, error)
+	FirewallRulesets() (map[string]*firewallDrivers.Ruleset, error)
 	IsRunning() bool
 	IsFrozen() bool
 	IsEphemeral() bool
@@ -177,6 +181,39 @@ type VM interface {
 	Instance
 
 	AgentCertificate() *x509.Certificate
+	AgentHasExtension(extension string) (bool, error)
+
+	LiveMigrateSend(args LiveMigrateArgs) error
+	LiveMigrateReceive(args LiveMigrateArgs) error
+
+	UEFIVars() (*api.InstanceUEFIVars, error)
+	UEFIVarsUpdate(newVars api.InstanceUEFIVars) error
+
+	ConsoleScreenshot(format string) ([]byte, error)
+
+	FilesystemFreeze() (func() error, error)
+}
+
+// LiveMigrateArgs arguments for the live migration of a running VM.
+type LiveMigrateArgs struct {
+	// StateConn carries the QEMU migration stream.
+	StateConn io.ReadWriteCloser
+
+	// DiskConn carries the mirroring of the root disk once its volume has been transferred.
+	// Nil when the root disk is on storage shared between the source and the target.
+	DiskConn io.ReadWriteCloser
+
+	// TransferVolume is called by the source to send the instance volumes once the root disk writes are
+	// redirected, so that they can be sent while the VM keeps running.
+	TransferVolume func() error
+
+	// Complete is called by the source once the VM state has been sent and returns an error if the target
+	// failed to resume the VM, in which case the VM resumes on the source.
+	Complete func() error
+
+	// ClusterMove is set when the instance record is shared with the target cluster member, in which case the
+	// source stops the VM without updating the record once it runs on the target.
+	ClusterMove bool
 }
 
 // CriuMigrationArgs arguments for CRIU migration.
@@ -704,6 +704,11 @@ func SuitableArchitectures(s *state.State, project string, req api.InstancesPost
 				return nil, nil
 			}
 
+			if req.Source.Protocol == "oci" {
+				// OCI images are resolved for the local architecture, defer to later processing.
+				return nil, nil
+			}
+
 			var remote lxd.ImageServer
 			if shared.StringInSlice(req.Source.Protocol, []string{"", "lxd"}) {
 				// Remote LXD image server.
@@ -1143,3 +1148,69 @@ func IsSameLogicalInstance(inst Instance, dbInst *db.InstanceArgs) bool {
 
 	return false
 }
+
+// BootDependencies returns the names of the instances (in the same project) that need to be started before the
+// instance with the supplied config.
+func BootDependencies(config map[string]string) []string {
+	return shared.SplitNTrimSpace(config["boot.depends_on"], ",", -1, true)
+}
+
+// BootDependencyCycle returns the chain of boot dependencies leading from the instance back to itself, if any.
+// The dependencies argument maps the instance names to the names of the instances they depend on.
+func BootDependencyCycle(instanceName string, dependencies map[string][]string) []string {
+	visited := map[string]bool{instanceName: true}
+
+	var walk func(name string, chain []string) []string
+	walk = func(name string, chain []string) []string {
+		for _, dependency := range dependencies[name] {
+			if dependency == instanceName {
+				return append(chain, dependency)
+			}
+
+			if visited[dependency] {
+				continue
+			}
+
+			visited[dependency] = true
+
+			cycle := walk(dependency, append(chain, dependency))
+			if cycle != nil {
+				return cycle
+			}
+		}
+
+		return nil
+	}
+
+	return walk(instanceName, []string{instanceName})
+}
+
+// ValidBootDependencies checks that the boot dependencies in the instance's expanded config don't lead back to the
+// instance through the other instances of its project.
+func ValidBootDependencies(s *state.State, projectName string, instanceName string, expandedConfig map[string]string) error {
+	instanceDependencies := BootDependencies(expandedConfig)
+	if len(instanceDependencies) == 0 {
+		return nil
+	}
+
+	dependencies := map[string][]string{}
+
+	filter := cluster.InstanceFilter{Project: &projectName}
+	err := s.DB.Cluster.InstanceList(&filter, func(dbInst db.InstanceArgs, p api.Project) error {
+		dependencies[dbInst.Name] = BootDependencies(db.ExpandInstanceConfig(dbInst.Config, dbInst.Profiles))
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed loading instances: %w", err)
+	}
+
+	dependencies[instanceName] = instanceDependencies
+
+	cycle := BootDependencyCycle(instanceName, dependencies)
+	if cycle != nil {
+		return fmt.Errorf("Boot dependencies form a cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
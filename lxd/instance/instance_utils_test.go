@@ -0,0 +1,85 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name         string
+		instance     string
+		dependencies map[string][]string
+		expected     []string
+	}{
+		{
+			name:         "no dependencies",
+			instance:     "c1",
+			dependencies: map[string][]string{},
+			expected:     nil,
+		},
+		{
+			name:     "chain",
+			instance: "c1",
+			dependencies: map[string][]string{
+				"c1": {"c2"},
+				"c2": {"c3"},
+			},
+			expected: nil,
+		},
+		{
+			name:     "shared dependency",
+			instance: "c1",
+			dependencies: map[string][]string{
+				"c1": {"c2", "c3"},
+				"c2": {"c4"},
+				"c3": {"c4"},
+			},
+			expected: nil,
+		},
+		{
+			name:     "self",
+			instance: "c1",
+			dependencies: map[string][]string{
+				"c1": {"c1"},
+			},
+			expected: []string{"c1", "c1"},
+		},
+		{
+			name:     "cycle",
+			instance: "c1",
+			dependencies: map[string][]string{
+				"c1": {"c2"},
+				"c2": {"c3"},
+				"c3": {"c1"},
+			},
+			expected: []string{"c1", "c2", "c3", "c1"},
+		},
+		{
+			name:     "cycle through second dependency",
+			instance: "c1",
+			dependencies: map[string][]string{
+				"c1": {"c2", "c3"},
+				"c3": {"c1"},
+			},
+			expected: []string{"c1", "c3", "c1"},
+		},
+		{
+			name:     "cycle not involving the instance",
+			instance: "c1",
+			dependencies: map[string][]string{
+				"c1": {"c2"},
+				"c2": {"c3"},
+				"c3": {"c2"},
+			},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, BootDependencyCycle(test.instance, test.dependencies))
+		})
+	}
+}
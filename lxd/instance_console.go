@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -18,16 +20,25 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/operationtype"
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/recording"
 	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 )
 
+// instanceConsoleLogMaxSize is the size above which the console log of virtual machines is trimmed, keeping the most
+// recent half of it.
+const instanceConsoleLogMaxSize = 1024 * 1024
+
 type consoleWs struct {
 	// instance currently worked on
 	instance instance.Instance
@@ -58,6 +69,9 @@ type consoleWs struct {
 
 	// channel type (either console or vga)
 	protocol string
+
+	// session recording (if enabled)
+	recorder *recording.Recorder
 }
 
 func (s *consoleWs) Metadata() any {
@@ -178,6 +192,16 @@ func (s *consoleWs) connectVGA(op *operations.Operation, r *http.Request, w http
 }
 
 func (s *consoleWs) Do(op *operations.Operation) error {
+	// Once the session ends ensure that its recording (if any) is completed.
+	if s.recorder != nil {
+		defer func() {
+			err := s.recorder.Close(-1)
+			if err != nil {
+				logger.Error("Failed completing console session recording", logger.Ctx{"err": err})
+			}
+		}()
+	}
+
 	switch s.protocol {
 	case instance.ConsoleTypeConsole:
 		return s.doConsole(op)
@@ -260,6 +284,13 @@ func (s *consoleWs) doConsole(op *operations.Operation) error {
 					continue
 				}
 
+				if s.recorder != nil {
+					err = s.recorder.Resize(winchWidth, winchHeight)
+					if err != nil {
+						logger.Error("Failed recording console window resize", logger.Ctx{"err": err})
+					}
+				}
+
 				logger.Debugf("Set window size to: %dx%d", winchWidth, winchHeight)
 			}
 		}
@@ -274,7 +305,15 @@ func (s *consoleWs) doConsole(op *operations.Operation) error {
 		s.connsLock.Unlock()
 
 		logger.Debugf("Started mirroring websocket")
-		readDone, writeDone := shared.WebsocketConsoleMirror(conn, console, console)
+
+		var w io.WriteCloser = console
+		var r io.ReadCloser = console
+		if s.recorder != nil {
+			w = s.recorder.Writer(w)
+			r = s.recorder.Reader(r)
+		}
+
+		readDone, writeDone := shared.WebsocketConsoleMirror(conn, w, r)
 
 		<-readDone
 		logger.Debugf("Finished mirroring console to websocket")
@@ -282,12 +321,21 @@ func (s *consoleWs) doConsole(op *operations.Operation) error {
 		close(mirrorDoneCh)
 	}()
 
+	// Recorded sessions can't go on once their recording fails.
+	var recordingFailedCh <-chan struct{}
+	if s.recorder != nil {
+		recordingFailedCh = s.recorder.Failed()
+	}
+
 	// Wait until either the console or the websocket is done.
 	select {
 	case <-mirrorDoneCh:
 		close(consoleDisconnectCh)
 	case <-consoleDoneCh:
 		close(consoleDisconnectCh)
+	case <-recordingFailedCh:
+		logger.Error("Console session recording failed, disconnecting", logger.Ctx{"err": s.recorder.Err()})
+		close(consoleDisconnectCh)
 	}
 
 	// Get the console and control websockets.
@@ -494,6 +542,12 @@ func instanceConsolePost(d *Daemon, r *http.Request) response.Response {
 	ws.height = post.Height
 	ws.protocol = post.Type
 
+	// The VGA console isn't a terminal, only the details of such sessions are recorded.
+	ws.recorder, err = instanceSessionRecorder(d.State(), inst, r, api.InstanceRecording{Type: api.InstanceRecordingTypeConsole, Interactive: post.Type == instance.ConsoleTypeConsole}, post.Width, post.Height, nil)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	resources := map[string][]string{}
 	resources["instances"] = []string{ws.instance.Name()}
 
@@ -503,6 +557,10 @@ func instanceConsolePost(d *Daemon, r *http.Request) response.Response {
 
 	op, err := operations.OperationCreate(d.State(), projectName, operations.OperationClassWebsocket, operationtype.ConsoleShow, resources, ws.Metadata(), ws.Do, nil, ws.Connect, r)
 	if err != nil {
+		if ws.recorder != nil {
+			_ = ws.recorder.Close(-1)
+		}
+
 		return response.InternalError(err)
 	}
 
@@ -513,20 +571,31 @@ func instanceConsolePost(d *Daemon, r *http.Request) response.Response {
 //
 // Get console log
 //
-// Gets the console log for the instance.
+// Gets the console log for the instance, or a screenshot of the VGA console of a virtual machine.
 //
 // ---
 // produces:
 //   - application/json
+//   - application/octet-stream
 // parameters:
 //   - in: query
 //     name: project
 //     description: Project name
 //     type: string
 //     example: default
+//   - in: query
+//     name: type
+//     description: Console type (console or vga)
+//     type: string
+//     example: vga
+//   - in: query
+//     name: format
+//     description: Screenshot format (png or ppm)
+//     type: string
+//     example: png
 // responses:
 //   "200":
-//      description: Raw console log
+//      description: Raw console log or screenshot
 //      content:
 //        application/octet-stream:
 //          schema:
@@ -566,8 +635,13 @@ func instanceConsoleLogGet(d *Daemon, r *http.Request) response.Response {
 		return resp
 	}
 
-	if !liblxc.RuntimeLiblxcVersionAtLeast(liblxc.Version(), 3, 0, 0) {
-		return response.BadRequest(fmt.Errorf("Querying the console buffer requires liblxc >= 3.0"))
+	consoleType := r.FormValue("type")
+	if consoleType == "" {
+		consoleType = instance.ConsoleTypeConsole
+	}
+
+	if !shared.StringInSlice(consoleType, []string{instance.ConsoleTypeConsole, instance.ConsoleTypeVGA}) {
+		return response.BadRequest(fmt.Errorf("Unknown console type %q", consoleType))
 	}
 
 	inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
@@ -575,8 +649,16 @@ func instanceConsoleLogGet(d *Daemon, r *http.Request) response.Response {
 		return response.SmartError(err)
 	}
 
-	if inst.Type() != instancetype.Container {
-		return response.SmartError(fmt.Errorf("Instance is not container type"))
+	if inst.Type() == instancetype.VM {
+		return instanceConsoleLogGetVM(r, inst.(instance.VM), consoleType)
+	}
+
+	if consoleType != instance.ConsoleTypeConsole {
+		return response.BadRequest(fmt.Errorf("Screenshots are only supported for virtual machines"))
+	}
+
+	if !liblxc.RuntimeLiblxcVersionAtLeast(liblxc.Version(), 3, 0, 0) {
+		return response.BadRequest(fmt.Errorf("Querying the console buffer requires liblxc >= 3.0"))
 	}
 
 	c := inst.(instance.Container)
@@ -619,6 +701,44 @@ func instanceConsoleLogGet(d *Daemon, r *http.Request) response.Response {
 	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil)
 }
 
+// instanceConsoleLogGetVM returns the serial console log or a VGA console screenshot of the virtual machine.
+func instanceConsoleLogGetVM(r *http.Request, vm instance.VM, consoleType string) response.Response {
+	if consoleType == instance.ConsoleTypeVGA {
+		format := r.FormValue("format")
+		if format == "" {
+			format = "png"
+		}
+
+		screenshot, err := vm.ConsoleScreenshot(format)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		ent := response.FileResponseEntry{
+			Filename:     fmt.Sprintf("screenshot.%s", format),
+			File:         bytes.NewReader(screenshot),
+			FileModified: time.Now(),
+			FileSize:     int64(len(screenshot)),
+		}
+
+		return response.FileResponse(r, []response.FileResponseEntry{ent}, nil)
+	}
+
+	// The console log is written by QEMU as it goes, so it can be returned as is.
+	ent := response.FileResponseEntry{}
+	consoleBufferLogPath := vm.ConsoleBufferLogPath()
+	if shared.PathExists(consoleBufferLogPath) {
+		ent.Path = consoleBufferLogPath
+		ent.Filename = consoleBufferLogPath
+	} else {
+		ent.Filename = "console.log"
+		ent.File = bytes.NewReader(nil)
+		ent.FileModified = time.Now()
+	}
+
+	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil)
+}
+
 // swagger:operation DELETE /1.0/instances/{name}/console instances instance_console_delete
 //
 // Clear the console log
@@ -646,8 +766,9 @@ func instanceConsoleLogGet(d *Daemon, r *http.Request) response.Response {
 //   "500":
 //     $ref: "#/responses/InternalServerError"
 func instanceConsoleLogDelete(d *Daemon, r *http.Request) response.Response {
-	if !liblxc.RuntimeLiblxcVersionAtLeast(liblxc.Version(), 3, 0, 0) {
-		return response.BadRequest(fmt.Errorf("Clearing the console buffer requires liblxc >= 3.0"))
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
 	}
 
 	name, err := url.PathUnescape(mux.Vars(r)["name"])
@@ -661,13 +782,33 @@ func instanceConsoleLogDelete(d *Daemon, r *http.Request) response.Response {
 
 	projectName := projectParam(r)
 
+	// Forward the request if the instance is remote.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if resp != nil {
+		return resp
+	}
+
 	inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
 	if err != nil {
 		return response.SmartError(err)
 	}
 
-	if inst.Type() != instancetype.Container {
-		return response.SmartError(fmt.Errorf("Instance is not container type"))
+	// The virtual machine console log is opened in append mode by QEMU, so can be truncated while running.
+	if inst.Type() == instancetype.VM {
+		err = os.Truncate(inst.ConsoleBufferLogPath(), 0)
+		if err != nil && !os.IsNotExist(err) {
+			return response.SmartError(err)
+		}
+
+		return response.EmptySyncResponse
+	}
+
+	if !liblxc.RuntimeLiblxcVersionAtLeast(liblxc.Version(), 3, 0, 0) {
+		return response.BadRequest(fmt.Errorf("Clearing the console buffer requires liblxc >= 3.0"))
 	}
 
 	c := inst.(instance.Container)
@@ -720,3 +861,76 @@ func instanceConsoleLogDelete(d *Daemon, r *http.Request) response.Response {
 
 	return response.SmartError(nil)
 }
+
+// instanceConsoleLogTrimTask trims the console logs of the local virtual machines that grew past
+// instanceConsoleLogMaxSize (minutely check).
+func instanceConsoleLogTrimTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		s := d.State()
+
+		var instances []dbCluster.Instance
+		err := s.DB.Cluster.Transaction(ctx, func(ctx context.Context, tx *db.ClusterTx) error {
+			var err error
+			instances, err = tx.GetLocalInstancesInProject(ctx, db.InstanceTypeFilter(instancetype.VM))
+
+			return err
+		})
+		if err != nil {
+			logger.Error("Failed loading instances for console log trimming", logger.Ctx{"err": err})
+			return
+		}
+
+		for _, inst := range instances {
+			logPath := shared.LogPath(project.Instance(inst.Project, inst.Name), "console.log")
+
+			err := instanceConsoleLogTrim(logPath, instanceConsoleLogMaxSize)
+			if err != nil {
+				logger.Warn("Failed trimming console log", logger.Ctx{"project": inst.Project, "instance": inst.Name, "err": err})
+			}
+		}
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// instanceConsoleLogTrim keeps the most recent half of the log if it's bigger than maxSize. QEMU writes the log in
+// append mode, so it keeps writing at its end once truncated.
+func instanceConsoleLogTrim(logPath string, maxSize int64) error {
+	f, err := os.OpenFile(logPath, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() <= maxSize {
+		return nil
+	}
+
+	tail := make([]byte, maxSize/2)
+	_, err = f.ReadAt(tail, fi.Size()-int64(len(tail)))
+	if err != nil {
+		return err
+	}
+
+	err = f.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(tail)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
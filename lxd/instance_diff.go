@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/response"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	"github.com/lxc/lxd/shared"
+)
+
+// swagger:operation GET /1.0/instances/{name}/diff instances instance_diff_get
+//
+// Get the changes made to the instance
+//
+// Compares the root filesystem of the instance with the image it was created from
+// and returns the paths which were added, changed or removed.
+// Only containers are supported.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: Changed paths
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of changed paths
+//           items:
+//             $ref: "#/definitions/InstanceDiffEntry"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceDiffGet(d *Daemon, r *http.Request) response.Response {
+	s := d.State()
+
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if resp != nil {
+		return resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(s, projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if inst.Type() != instancetype.Container {
+		return response.BadRequest(fmt.Errorf("Only containers can be compared with their image"))
+	}
+
+	fingerprint := inst.LocalConfig()["volatile.base_image"]
+	if fingerprint == "" {
+		return response.BadRequest(fmt.Errorf("Instance wasn't created from an image"))
+	}
+
+	pool, err := storagePools.LoadByInstance(s, inst)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	entries, err := pool.DiffInstance(inst, fingerprint, nil)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, entries)
+}
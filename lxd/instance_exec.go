@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -23,6 +24,7 @@ import (
 	"github.com/lxc/lxd/lxd/instance"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/recording"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
@@ -49,6 +51,7 @@ type execWs struct {
 	controlConnectedDone  func()
 	fds                   map[int]string
 	s                     *state.State
+	recorder              *recording.Recorder
 }
 
 func (s *execWs) Metadata() any {
@@ -124,6 +127,24 @@ func (s *execWs) Connect(op *operations.Operation, r *http.Request, w http.Respo
 	return os.ErrPermission
 }
 
+// recordOutput returns r wrapped to record what is read from it when the session is recorded.
+func (s *execWs) recordOutput(r io.ReadCloser) io.ReadCloser {
+	if s.recorder == nil {
+		return r
+	}
+
+	return s.recorder.Reader(r)
+}
+
+// recordInput returns w wrapped to record what is written to it when the session is recorded.
+func (s *execWs) recordInput(w io.WriteCloser) io.WriteCloser {
+	if s.recorder == nil {
+		return w
+	}
+
+	return s.recorder.Writer(w)
+}
+
 func (s *execWs) Do(op *operations.Operation) error {
 	// Once this function ends ensure that any connected websockets are closed.
 	defer func() {
@@ -136,6 +157,17 @@ func (s *execWs) Do(op *operations.Operation) error {
 		s.connsLock.Unlock()
 	}()
 
+	// Once this function ends ensure that the session recording (if any) is completed.
+	exitCode := -1
+	if s.recorder != nil {
+		defer func() {
+			err := s.recorder.Close(exitCode)
+			if err != nil {
+				logger.Error("Failed completing exec session recording", logger.Ctx{"err": err})
+			}
+		}()
+	}
+
 	// As this function only gets called when the exec request has WaitForWS enabled, we expect the client to
 	// connect to all of the required websockets within a short period of time and we won't proceed until then.
 	logger.Debug("Waiting for exec websockets to connect")
@@ -254,6 +286,8 @@ func (s *execWs) Do(op *operations.Operation) error {
 			_ = pty.Close()
 		}
 
+		exitCode = cmdResult
+
 		metadata := shared.Jmap{"return": cmdResult}
 		err = op.ExtendMetadata(metadata)
 		if err != nil {
@@ -281,6 +315,18 @@ func (s *execWs) Do(op *operations.Operation) error {
 		}
 	}
 
+	// Recorded sessions can't go on once their recording fails.
+	if s.recorder != nil {
+		go func() {
+			select {
+			case <-s.recorder.Failed():
+				l.Error("Exec session recording failed, killing command", logger.Ctx{"err": s.recorder.Err()})
+				cmdKillOnce.Do(cmdKill)
+			case <-attachedChildIsDead:
+			}
+		}()
+	}
+
 	// Now that process has started, we can start the control handler.
 	wgEOF.Add(1)
 	go func() {
@@ -363,6 +409,13 @@ func (s *execWs) Do(op *operations.Operation) error {
 					l.Debug("Failed to set window size", logger.Ctx{"err": err, "width": winchWidth, "height": winchHeight})
 					continue
 				}
+
+				if s.recorder != nil {
+					err = s.recorder.Resize(winchWidth, winchHeight)
+					if err != nil {
+						l.Error("Failed recording window resize", logger.Ctx{"err": err})
+					}
+				}
 			} else if command.Command == "signal" {
 				err := cmd.Signal(unix.Signal(command.Signal))
 				if err != nil {
@@ -391,12 +444,12 @@ func (s *execWs) Do(op *operations.Operation) error {
 			if s.instance.Type() == instancetype.Container {
 				// For containers, we are running the command via the local LXD managed PTY and so
 				// need special signal handling provided by netutils.WebsocketExecMirror.
-				readDone, writeDone = netutils.WebsocketExecMirror(conn, ptys[0], ptys[0], attachedChildIsDead, int(ptys[0].Fd()))
+				readDone, writeDone = netutils.WebsocketExecMirror(conn, s.recordInput(ptys[0]), s.recordOutput(ptys[0]), attachedChildIsDead, int(ptys[0].Fd()))
 			} else {
 				// For VMs we are just relaying the websockets between client and lxd-agent, so no
 				// need for the special signal handling provided by netutils.WebsocketExecMirror.
-				readDone = shared.WebsocketSendStream(conn, ptys[execWSStdout], -1)
-				writeDone = shared.WebsocketRecvStream(ttys[execWSStdin], conn)
+				readDone = shared.WebsocketSendStream(conn, s.recordOutput(ptys[execWSStdout]), -1)
+				writeDone = shared.WebsocketRecvStream(s.recordInput(ttys[execWSStdin]), conn)
 			}
 
 			<-readDone
@@ -438,10 +491,10 @@ func (s *execWs) Do(op *operations.Operation) error {
 				}
 
 				if i == execWSStdin {
-					<-shared.WebsocketRecvStream(ttys[i], conn)
+					<-shared.WebsocketRecvStream(s.recordInput(ttys[i]), conn)
 					_ = ttys[i].Close()
 				} else {
-					<-shared.WebsocketSendStream(conn, ptys[i], -1)
+					<-shared.WebsocketSendStream(conn, s.recordOutput(ptys[i]), -1)
 					_ = ptys[i].Close()
 					wgEOF.Done()
 				}
@@ -640,6 +693,11 @@ func instanceExecPost(d *Daemon, r *http.Request) response.Response {
 		ws.instance = inst
 		ws.req = post
 
+		ws.recorder, err = instanceSessionRecorder(d.State(), inst, r, api.InstanceRecording{Type: api.InstanceRecordingTypeExec, Command: post.Command, Interactive: post.Interactive}, post.Width, post.Height, post.Environment)
+		if err != nil {
+			return response.SmartError(err)
+		}
+
 		resources := map[string][]string{}
 		resources["instances"] = []string{ws.instance.Name()}
 
@@ -649,15 +707,39 @@ func instanceExecPost(d *Daemon, r *http.Request) response.Response {
 
 		op, err := operations.OperationCreate(d.State(), projectName, operations.OperationClassWebsocket, operationtype.CommandExec, resources, ws.Metadata(), ws.Do, nil, ws.Connect, r)
 		if err != nil {
+			if ws.recorder != nil {
+				_ = ws.recorder.Close(-1)
+			}
+
 			return response.InternalError(err)
 		}
 
 		return operations.OperationResponse(op)
 	}
 
+	// Sessions without websockets have no terminal I/O to record, only the command and its result are.
+	recorder, err := instanceSessionRecorder(d.State(), inst, r, api.InstanceRecording{Type: api.InstanceRecordingTypeExec, Command: post.Command}, 0, 0, post.Environment)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
 	run := func(op *operations.Operation) error {
 		metadata := shared.Jmap{}
 
+		if recorder != nil {
+			defer func() {
+				exitCode, ok := metadata["return"].(int)
+				if !ok {
+					exitCode = -1
+				}
+
+				err := recorder.Close(exitCode)
+				if err != nil {
+					logger.Error("Failed completing exec session recording", logger.Ctx{"err": err})
+				}
+			}()
+		}
+
 		var err error
 		var stdout, stderr *os.File
 
@@ -718,6 +800,10 @@ func instanceExecPost(d *Daemon, r *http.Request) response.Response {
 
 	op, err := operations.OperationCreate(d.State(), projectName, operations.OperationClassTask, operationtype.CommandExec, resources, nil, run, nil, nil, r)
 	if err != nil {
+		if recorder != nil {
+			_ = recorder.Close(-1)
+		}
+
 		return response.InternalError(err)
 	}
 
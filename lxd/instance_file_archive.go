@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/filearchive"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+func instanceFileArchiveHandler(d *Daemon, r *http.Request) response.Response {
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Redirect to correct server if needed.
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if resp != nil {
+		return resp
+	}
+
+	// Load the instance.
+	inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Older lxd-agents would take the archive request for the start of an SFTP session.
+	vm, ok := inst.(instance.VM)
+	if ok {
+		supported, err := vm.AgentHasExtension("instance_file_archive")
+		if err != nil {
+			return response.SmartError(err)
+		}
+
+		if !supported {
+			return response.NotImplemented(fmt.Errorf("The instance's lxd-agent doesn't support archive transfers"))
+		}
+	}
+
+	// Parse and cleanup the path.
+	path := r.FormValue("path")
+	if path == "" {
+		return response.BadRequest(fmt.Errorf("Missing path argument"))
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	switch r.Method {
+	case "GET":
+		return instanceFileArchiveGet(d.State(), inst, path, r)
+	case "POST":
+		return instanceFileArchivePost(d.State(), inst, path, r)
+	default:
+		return response.NotFound(fmt.Errorf("Method %q not found", r.Method))
+	}
+}
+
+// swagger:operation GET /1.0/instances/{name}/files/archive instances instance_files_archive_get
+//
+// Get a directory as an archive
+//
+// Streams a tar archive of the directory (or file) at the given path.
+// Entry names are relative to the path, which itself is the "." entry.
+// Ownership (as seen from inside the instance), permissions, modification times, xattrs, symlinks and hard links are preserved.
+//
+// ---
+// produces:
+//   - application/x-tar
+// parameters:
+//   - in: query
+//     name: path
+//     description: Path to the directory
+//     type: string
+//     example: /etc
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: Tar archive
+//     content:
+//       application/x-tar:
+//         schema:
+//           type: string
+//           format: binary
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceFileArchiveGet(s *state.State, inst instance.Instance, path string, r *http.Request) response.Response {
+	conn, err := inst.FileSFTPConn()
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	// The archive is generated inside the instance, errors until the transfer starts are reported here.
+	reader, err := filearchive.Pull(conn, path)
+	if err != nil {
+		_ = conn.Close()
+		return response.SmartError(err)
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		defer func() { _ = reader.Close() }()
+
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.WriteHeader(http.StatusOK)
+
+		_, err := io.Copy(w, reader)
+		if err != nil {
+			return fmt.Errorf("Failed sending archive of %q: %w", path, err)
+		}
+
+		s.Events.SendLifecycle(inst.Project(), lifecycle.InstanceFileRetrieved.Event(inst, logger.Ctx{"path": path}))
+
+		return nil
+	})
+}
+
+// swagger:operation POST /1.0/instances/{name}/files/archive instances instance_files_archive_post
+//
+// Extract an archive into a directory
+//
+// Extracts the tar archive in the request body into the directory at the given path, which is created if missing.
+// Existing files are replaced. Ownership (as seen from inside the instance), permissions, modification times,
+// xattrs, symlinks and hard links are restored from the archive.
+//
+// ---
+// consumes:
+//   - application/x-tar
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: path
+//     description: Path to the directory
+//     type: string
+//     example: /root/data
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: body
+//     name: raw_archive
+//     description: Tar archive
+//     required: true
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceFileArchivePost(s *state.State, inst instance.Instance, path string, r *http.Request) response.Response {
+	conn, err := inst.FileSFTPConn()
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	err = filearchive.Push(conn, path, r.Body)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	s.Events.SendLifecycle(inst.Project(), lifecycle.InstanceFilePushed.Event(inst, logger.Ctx{"path": path}))
+	return response.EmptySyncResponse
+}
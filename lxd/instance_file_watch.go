@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/filewatch"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// swagger:operation GET /1.0/instances/{name}/files/watch instances instance_files_watch_get
+//
+// Watch a path for changes
+//
+// Upgrades the connection to a websocket streaming the changes (create, modify or delete) to the path.
+// If the path is a directory, changes to its entries are sent (and to those of its subdirectories if recursive).
+// Each change is sent as a JSON encoded InstanceFileWatchEvent message.
+// Recursive watches don't descend into other filesystems and are limited in the number of directories.
+// An overflow event is sent when changes may have been missed.
+// The websocket is closed when the path itself is deleted.
+//
+// The instance must be running. Containers are watched from the host through inotify, VMs through the lxd-agent.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: path
+//     description: Path to watch
+//     type: string
+//     example: /etc
+//   - in: query
+//     name: recursive
+//     description: Whether to watch subdirectories
+//     type: boolean
+//     example: true
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "101":
+//     description: Switching protocols to websocket
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceFileWatchGet(d *Daemon, r *http.Request) response.Response {
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Parse and cleanup the path.
+	path := r.FormValue("path")
+	if path == "" {
+		return response.BadRequest(fmt.Errorf("Missing path argument"))
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	recursive := shared.IsTrue(queryParam(r, "recursive"))
+
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Start watching before upgrading the connection, so that errors are reported as regular responses.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var events <-chan api.InstanceFileWatchEvent
+
+	// Relay the remote watch if the instance is on another member.
+	client, err := cluster.ConnectIfInstanceIsRemote(d.db.Cluster, projectName, name, d.endpoints.NetworkCert(), d.serverCert(), r, instanceType)
+	if err != nil {
+		cancel()
+		return response.SmartError(err)
+	}
+
+	if client != nil {
+		conn, err := client.GetInstanceFileWatch(name, path, recursive)
+		if err != nil {
+			cancel()
+			return response.SmartError(err)
+		}
+
+		events = filewatch.Receive(ctx, conn)
+	} else {
+		inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
+		if err != nil {
+			cancel()
+			return response.SmartError(err)
+		}
+
+		if !inst.IsRunning() {
+			cancel()
+			return response.BadRequest(fmt.Errorf("Instance is not running"))
+		}
+
+		events, err = inst.FileWatch(ctx, path, recursive)
+		if err != nil {
+			cancel()
+			return response.SmartError(err)
+		}
+	}
+
+	return response.ManualResponse(func(w http.ResponseWriter) error {
+		defer cancel()
+
+		conn, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = conn.Close() }()
+
+		// Stop watching when the client goes away.
+		go func() {
+			for {
+				_, _, err := conn.NextReader()
+				if err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+
+		for event := range events {
+			err = conn.WriteJSON(event)
+			if err != nil {
+				return nil
+			}
+		}
+
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// swagger:operation GET /1.0/instances/{name}/firewall instances instance_firewall_get
+//
+// Get the firewall rules
+//
+// Returns the firewall rules LXD generated for each of the instance's NIC devices alongside the rules
+// currently loaded in the kernel.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: Firewall rules keyed on NIC device name
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: object
+//           additionalProperties:
+//             $ref: "#/definitions/NetworkFirewallRuleset"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceFirewallGet(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if resp != nil {
+		return resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	fwRulesets, err := inst.FirewallRulesets()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	rulesets := make(map[string]api.NetworkFirewallRuleset, len(fwRulesets))
+	for devName, ruleset := range fwRulesets {
+		rulesets[devName] = api.NetworkFirewallRuleset{
+			Driver:    d.State().Firewall.String(),
+			Generated: ruleset.Generated,
+			Loaded:    ruleset.Loaded,
+			Diff:      ruleset.Diff,
+		}
+	}
+
+	return response.SyncResponse(true, rulesets)
+}
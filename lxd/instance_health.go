@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// instanceHealthRestartTimeout is how long unhealthy instances have to shutdown cleanly when restarted.
+const instanceHealthRestartTimeout = 30 * time.Second
+
+// instanceHealthRestartBackoff is how long to wait before restarting an instance that stayed unhealthy after being
+// restarted. It doubles with each restart up to instanceHealthRestartBackoffMax.
+const instanceHealthRestartBackoff = time.Minute
+
+// instanceHealthRestartBackoffMax is the maximum delay between the restarts of an instance that stays unhealthy.
+const instanceHealthRestartBackoffMax = time.Hour
+
+// instanceHealthCheck tracks the health checks of a local instance.
+type instanceHealthCheck struct {
+	lastRun      time.Time
+	running      bool
+	failures     int
+	restarts     int       // Restarts since the instance was last healthy.
+	restartAfter time.Time // Time before which the instance isn't restarted again.
+}
+
+// instanceHealthChecks holds the health check tracking of the local instances keyed by project and instance name.
+var instanceHealthChecks = map[string]*instanceHealthCheck{}
+
+// instanceHealthChecksLock protects instanceHealthChecks.
+var instanceHealthChecksLock sync.Mutex
+
+// instanceHealthCheckTask runs the health checks of the running local instances that are due.
+func instanceHealthCheckTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		s := d.State()
+
+		// Avoid loading the instances when none of them has a health check.
+		var configured bool
+		err := s.DB.Cluster.Transaction(ctx, func(ctx context.Context, tx *db.ClusterTx) error {
+			var err error
+			configured, err = tx.LocalInstancesHaveConfigKey(ctx, "healthcheck.type")
+
+			return err
+		})
+		if err != nil {
+			logger.Error("Failed checking for instance health checks", logger.Ctx{"err": err})
+			return
+		}
+
+		if !configured {
+			instanceHealthChecksLock.Lock()
+			instanceHealthChecksForget(nil)
+			instanceHealthChecksLock.Unlock()
+
+			return
+		}
+
+		instances, err := instance.LoadNodeAll(s, instancetype.Any)
+		if err != nil {
+			logger.Error("Failed loading instances for health checks", logger.Ctx{"err": err})
+			return
+		}
+
+		instanceHealthChecksLock.Lock()
+		defer instanceHealthChecksLock.Unlock()
+
+		seen := make(map[string]bool, len(instances))
+
+		for _, inst := range instances {
+			config := inst.ExpandedConfig()
+			if config["healthcheck.type"] == "" || !inst.IsRunning() {
+				continue
+			}
+
+			key := project.Instance(inst.Project(), inst.Name())
+			seen[key] = true
+
+			check := instanceHealthChecks[key]
+			if check == nil {
+				// Give the instance a full interval to start before checking it.
+				failures, _ := strconv.Atoi(inst.LocalConfig()["volatile.health.failures"])
+				check = &instanceHealthCheck{lastRun: time.Now(), failures: failures}
+				instanceHealthChecks[key] = check
+			}
+
+			interval := 30 * time.Second
+			if config["healthcheck.interval"] != "" {
+				seconds, _ := strconv.Atoi(config["healthcheck.interval"])
+				interval = time.Duration(seconds) * time.Second
+			}
+
+			if check.running || time.Since(check.lastRun) < interval {
+				continue
+			}
+
+			check.running = true
+			check.lastRun = time.Now()
+
+			go func(inst instance.Instance, check *instanceHealthCheck) {
+				instanceHealthUpdate(s, inst, check, instanceHealthRun(inst))
+
+				instanceHealthChecksLock.Lock()
+				check.running = false
+				instanceHealthChecksLock.Unlock()
+			}(inst, check)
+		}
+
+		instanceHealthChecksForget(seen)
+	}
+
+	return f, task.Every(5 * time.Second)
+}
+
+// instanceHealthChecksForget forgets the instances that stopped or no longer have a health check, that is the
+// instances not in seen that aren't being checked. The caller must hold instanceHealthChecksLock.
+func instanceHealthChecksForget(seen map[string]bool) {
+	for key, check := range instanceHealthChecks {
+		if !seen[key] && !check.running {
+			delete(instanceHealthChecks, key)
+		}
+	}
+}
+
+// instanceHealthRun runs the health check of the instance and returns an error if it failed.
+func instanceHealthRun(inst instance.Instance) error {
+	config := inst.ExpandedConfig()
+
+	timeout := 10 * time.Second
+	if config["healthcheck.timeout"] != "" {
+		seconds, _ := strconv.Atoi(config["healthcheck.timeout"])
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	switch config["healthcheck.type"] {
+	case "exec":
+		return instanceHealthRunExec(inst, config["healthcheck.command"], timeout)
+	case "tcp", "http":
+		port := config["healthcheck.port"]
+		if port == "" {
+			return fmt.Errorf("No health check port configured")
+		}
+
+		addresses, err := instanceGlobalAddresses(inst)
+		if err != nil {
+			return err
+		}
+
+		if len(addresses) == 0 {
+			return fmt.Errorf("Instance doesn't have any global address")
+		}
+
+		// The check succeeds if any of the addresses of the instance passes it.
+		for _, address := range addresses {
+			if config["healthcheck.type"] == "tcp" {
+				var conn net.Conn
+				conn, err = net.DialTimeout("tcp", net.JoinHostPort(address, port), timeout)
+				if err == nil {
+					_ = conn.Close()
+				}
+			} else {
+				err = instanceHealthRunHTTP(net.JoinHostPort(address, port), config["healthcheck.path"], timeout)
+			}
+
+			if err == nil {
+				return nil
+			}
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("Unknown health check type %q", config["healthcheck.type"])
+}
+
+// instanceHealthRunExec runs the command in the instance and returns an error if it doesn't exit successfully.
+func instanceHealthRunExec(inst instance.Instance, command string, timeout time.Duration) error {
+	args, err := shellquote.Split(command)
+	if err != nil {
+		return fmt.Errorf("Invalid health check command: %w", err)
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("No health check command configured")
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = devNull.Close() }()
+
+	env := map[string]string{
+		"PATH": "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"HOME": "/root",
+		"USER": "root",
+		"LANG": "C.UTF-8",
+	}
+
+	for k, v := range inst.ExpandedConfig() {
+		if strings.HasPrefix(k, "environment.") {
+			env[strings.TrimPrefix(k, "environment.")] = v
+		}
+	}
+
+	req := api.InstanceExecPost{
+		Command:     args,
+		Environment: env,
+	}
+
+	cmd, err := inst.Exec(req, devNull, devNull, devNull)
+	if err != nil {
+		return fmt.Errorf("Failed running command: %w", err)
+	}
+
+	type result struct {
+		exitCode int
+		err      error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		exitCode, err := cmd.Wait()
+		done <- result{exitCode: exitCode, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return fmt.Errorf("Failed running command: %w", res.err)
+		}
+
+		if res.exitCode != 0 {
+			return fmt.Errorf("Command exited with status %d", res.exitCode)
+		}
+
+		return nil
+	case <-time.After(timeout):
+		_ = cmd.Signal(unix.SIGKILL)
+		return fmt.Errorf("Command timed out after %s", timeout)
+	}
+}
+
+// instanceHealthRunHTTP requests the path on the address and returns an error if the response status isn't 2xx
+// or 3xx.
+func instanceHealthRunHTTP(address string, path string, timeout time.Duration) error {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(fmt.Sprintf("http://%s%s", address, path))
+	if err != nil {
+		return err
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP request returned status %q", resp.Status)
+	}
+
+	return nil
+}
+
+// instanceHealthRestartDelay returns how long to wait before restarting an instance again after the specified
+// number of restarts that didn't make it healthy.
+func instanceHealthRestartDelay(restarts int) time.Duration {
+	delay := instanceHealthRestartBackoff
+	for i := 1; i < restarts && delay < instanceHealthRestartBackoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > instanceHealthRestartBackoffMax {
+		delay = instanceHealthRestartBackoffMax
+	}
+
+	return delay
+}
+
+// instanceHealthUpdate records the result of the health check of the instance, emitting lifecycle events when its
+// health status changes and restarting it when it becomes unhealthy if configured to. Instances that stay unhealthy
+// are restarted again with an increasing delay. The consecutive failures are counted in the health check tracking
+// and the volatile keys are only updated when the health status changes or the instance is restarted.
+func instanceHealthUpdate(s *state.State, inst instance.Instance, check *instanceHealthCheck, checkErr error) {
+	instLogger := logger.AddContext(logger.Log, logger.Ctx{"project": inst.Project(), "instance": inst.Name()})
+
+	// The instance may have been stopped while being checked.
+	if !inst.IsRunning() {
+		return
+	}
+
+	config := inst.ExpandedConfig()
+	status := inst.LocalConfig()["volatile.health.status"]
+
+	if checkErr == nil {
+		check.failures = 0
+		check.restarts = 0
+		check.restartAfter = time.Time{}
+
+		if status == "healthy" {
+			return
+		}
+
+		err := inst.VolatileSet(map[string]string{
+			"volatile.health.status":   "healthy",
+			"volatile.health.failures": "",
+			"volatile.health.error":    "",
+		})
+		if err != nil {
+			instLogger.Warn("Failed recording health status", logger.Ctx{"err": err})
+		}
+
+		s.Events.SendLifecycle(inst.Project(), lifecycle.InstanceHealthy.Event(inst, nil))
+
+		return
+	}
+
+	check.failures++
+
+	retries := 3
+	if config["healthcheck.retries"] != "" {
+		retries, _ = strconv.Atoi(config["healthcheck.retries"])
+	}
+
+	if check.failures < retries {
+		return
+	}
+
+	restart := shared.IsTrue(config["healthcheck.restart"]) && !time.Now().Before(check.restartAfter)
+
+	// Instances that are already unhealthy are only reported again when restarted again.
+	if status == "unhealthy" && !restart {
+		return
+	}
+
+	err := inst.VolatileSet(map[string]string{
+		"volatile.health.status":   "unhealthy",
+		"volatile.health.failures": strconv.Itoa(check.failures),
+		"volatile.health.error":    checkErr.Error(),
+	})
+	if err != nil {
+		instLogger.Warn("Failed recording health status", logger.Ctx{"err": err})
+	}
+
+	metadata := map[string]any{"failures": check.failures, "error": checkErr.Error()}
+	if restart {
+		metadata["restarts"] = check.restarts + 1
+	}
+
+	instLogger.Warn("Instance is unhealthy", logger.Ctx{"failures": check.failures, "restarts": check.restarts, "err": checkErr})
+	s.Events.SendLifecycle(inst.Project(), lifecycle.InstanceUnhealthy.Event(inst, metadata))
+
+	if !restart {
+		return
+	}
+
+	// Count the failures again from the restart, and wait longer before each further restart.
+	check.failures = 0
+	check.restarts++
+	check.restartAfter = time.Now().Add(instanceHealthRestartDelay(check.restarts))
+
+	instLogger.Info("Restarting unhealthy instance", logger.Ctx{"restarts": check.restarts, "nextRestart": check.restartAfter})
+
+	err = inst.Restart(instanceHealthRestartTimeout)
+	if err != nil {
+		instLogger.Error("Failed restarting unhealthy instance", logger.Ctx{"err": err})
+		return
+	}
+
+	// Give the instance a full interval to start before checking it again.
+	check.lastRun = time.Now()
+
+	err = inst.VolatileSet(map[string]string{"volatile.health.failures": ""})
+	if err != nil {
+		instLogger.Warn("Failed resetting health check failures", logger.Ctx{"err": err})
+	}
+}
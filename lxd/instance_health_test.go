@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceHealthRestartDelay(t *testing.T) {
+	tests := []struct {
+		restarts int
+		delay    time.Duration
+	}{
+		{restarts: 1, delay: time.Minute},
+		{restarts: 2, delay: 2 * time.Minute},
+		{restarts: 3, delay: 4 * time.Minute},
+		{restarts: 7, delay: 60 * time.Minute},
+		{restarts: 100, delay: time.Hour},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.delay, instanceHealthRestartDelay(tt.restarts), "restarts %d", tt.restarts)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
+)
+
+// instanceIdle tracks the activity of a local instance with boot.idle_stop set.
+type instanceIdle struct {
+	sample    api.InstanceStateHistorySample
+	idleSince time.Time
+	stopping  bool
+}
+
+// instanceIdles holds the activity tracking of the local instances keyed by project and instance name.
+var instanceIdles = map[string]*instanceIdle{}
+
+// instanceIdlesLock protects instanceIdles.
+var instanceIdlesLock sync.Mutex
+
+// instanceIdleThresholds returns the CPU usage (percentage of a CPU) and network traffic (bytes per second) under
+// which the instance is considered idle.
+func instanceIdleThresholds(config map[string]string) (float64, float64) {
+	cpu := 5.0
+	if config["boot.idle_stop.cpu"] != "" {
+		cpu, _ = strconv.ParseFloat(config["boot.idle_stop.cpu"], 64)
+	}
+
+	network := 1024.0
+	if config["boot.idle_stop.network"] != "" {
+		value, _ := units.ParseByteSizeString(config["boot.idle_stop.network"])
+		network = float64(value)
+	}
+
+	return cpu, network
+}
+
+// instanceIdleUpdate records a new activity sample of the tracked instance and returns whether the instance has been
+// idle for longer than its boot.idle_stop period, along with the reason for stopping it.
+func instanceIdleUpdate(idle *instanceIdle, sample api.InstanceStateHistorySample, config map[string]string) (bool, string) {
+	elapsed := sample.Time.Sub(idle.sample.Time).Seconds()
+	cpuUsage := (sample.CPUSeconds - idle.sample.CPUSeconds) / elapsed * 100
+	networkUsage := float64(sample.NetworkReceivedBytes+sample.NetworkSentBytes-idle.sample.NetworkReceivedBytes-idle.sample.NetworkSentBytes) / elapsed
+	idle.sample = sample
+
+	// Any activity above the thresholds (or counters going backwards after a restart) resets the idle period.
+	cpuThreshold, networkThreshold := instanceIdleThresholds(config)
+	if elapsed <= 0 || cpuUsage < 0 || networkUsage < 0 || cpuUsage > cpuThreshold || networkUsage > networkThreshold {
+		idle.idleSince = sample.Time
+		return false, ""
+	}
+
+	minutes, _ := strconv.Atoi(config["boot.idle_stop"])
+	period := time.Duration(minutes) * time.Minute
+	if sample.Time.Sub(idle.idleSince) < period {
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("CPU usage below %g%% and network traffic below %s/s for %s", cpuThreshold, units.GetByteSizeString(int64(networkThreshold), 0), period)
+}
+
+// instancesIdleStopTask stops the running local instances that have been idle for longer than their boot.idle_stop
+// period (minutely check).
+func instancesIdleStopTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		s := d.State()
+
+		instances, err := instance.LoadNodeAll(s, instancetype.Any)
+		if err != nil {
+			logger.Error("Failed loading instances for idle detection", logger.Ctx{"err": err})
+			return
+		}
+
+		seen := make(map[string]bool, len(instances))
+		samples := make(map[string]api.InstanceStateHistorySample, len(instances))
+		candidates := make([]instance.Instance, 0, len(instances))
+
+		// Sample the activity of the instances without holding the lock, as getting their metrics can be slow.
+		for _, inst := range instances {
+			if ctx.Err() != nil {
+				return
+			}
+
+			minutes, _ := strconv.Atoi(inst.ExpandedConfig()["boot.idle_stop"])
+			if minutes <= 0 || !inst.IsRunning() {
+				continue
+			}
+
+			key := project.Instance(inst.Project(), inst.Name())
+			seen[key] = true
+
+			instanceIdlesLock.Lock()
+			idle := instanceIdles[key]
+			stopping := idle != nil && idle.stopping
+			instanceIdlesLock.Unlock()
+
+			if stopping {
+				continue
+			}
+
+			metrics, err := inst.Metrics()
+			if err != nil {
+				logger.Debug("Failed getting instance metrics for idle detection", logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+				continue
+			}
+
+			samples[key] = instanceHistorySample(metrics)
+			candidates = append(candidates, inst)
+		}
+
+		instanceIdlesLock.Lock()
+		defer instanceIdlesLock.Unlock()
+
+		for _, inst := range candidates {
+			key := project.Instance(inst.Project(), inst.Name())
+			sample := samples[key]
+
+			// Start tracking the instance, it's only considered idle from now on.
+			idle := instanceIdles[key]
+			if idle == nil {
+				instanceIdles[key] = &instanceIdle{sample: sample, idleSince: sample.Time}
+				continue
+			}
+
+			if idle.stopping {
+				continue
+			}
+
+			stop, reason := instanceIdleUpdate(idle, sample, inst.ExpandedConfig())
+			if !stop {
+				continue
+			}
+
+			idle.stopping = true
+
+			go func(inst instance.Instance, idle *instanceIdle) {
+				instanceIdleStop(s, inst, reason)
+
+				// Track the instance again from scratch, should the stop have failed.
+				instanceIdlesLock.Lock()
+				idle.stopping = false
+				idle.idleSince = time.Now()
+				instanceIdlesLock.Unlock()
+			}(inst, idle)
+		}
+
+		// Forget the instances that stopped or no longer have boot.idle_stop set.
+		for key, idle := range instanceIdles {
+			if !seen[key] && !idle.stopping {
+				delete(instanceIdles, key)
+			}
+		}
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+// instanceIdleStop stops the idle instance, statefully if boot.idle_stop.stateful is enabled, and emits the
+// instance-idle-stopped lifecycle event with the reason.
+func instanceIdleStop(s *state.State, inst instance.Instance, reason string) {
+	instLogger := logger.AddContext(logger.Log, logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "reason": reason})
+
+	stateful := shared.IsTrue(inst.ExpandedConfig()["boot.idle_stop.stateful"])
+	if stateful {
+		instLogger.Info("Statefully stopping idle instance")
+
+		err := inst.Stop(true)
+		if err != nil {
+			instLogger.Error("Failed statefully stopping idle instance", logger.Ctx{"err": err})
+			return
+		}
+	} else {
+		instLogger.Info("Stopping idle instance")
+
+		timeoutSeconds := 30
+		value, ok := inst.ExpandedConfig()["boot.host_shutdown_timeout"]
+		if ok {
+			timeoutSeconds, _ = strconv.Atoi(value)
+		}
+
+		err := inst.Shutdown(time.Second * time.Duration(timeoutSeconds))
+		if err != nil {
+			instLogger.Warn("Failed shutting down idle instance, forcefully stopping", logger.Ctx{"err": err})
+
+			err = inst.Stop(false)
+			if err != nil {
+				instLogger.Error("Failed stopping idle instance", logger.Ctx{"err": err})
+				return
+			}
+		}
+	}
+
+	s.Events.SendLifecycle(inst.Project(), lifecycle.InstanceIdleStopped.Event(inst, map[string]any{"reason": reason, "stateful": stateful}))
+}
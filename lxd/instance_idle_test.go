@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestInstanceIdleThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]string
+		cpu     float64
+		network float64
+	}{
+		{
+			name:    "defaults",
+			config:  map[string]string{},
+			cpu:     5,
+			network: 1024,
+		},
+		{
+			name:    "overrides",
+			config:  map[string]string{"boot.idle_stop.cpu": "0.5", "boot.idle_stop.network": "10KiB"},
+			cpu:     0.5,
+			network: 10240,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, network := instanceIdleThresholds(tt.config)
+			assert.Equal(t, tt.cpu, cpu)
+			assert.Equal(t, tt.network, network)
+		})
+	}
+}
+
+func TestInstanceIdleUpdate(t *testing.T) {
+	start := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	config := map[string]string{"boot.idle_stop": "10"}
+
+	tests := []struct {
+		name          string
+		idleSince     time.Time
+		sample        api.InstanceStateHistorySample
+		stop          bool
+		wantIdleSince time.Time
+	}{
+		{
+			name:          "idle within the period",
+			idleSince:     start,
+			sample:        api.InstanceStateHistorySample{Time: start.Add(time.Minute), CPUSeconds: 1, NetworkReceivedBytes: 1000},
+			wantIdleSince: start,
+		},
+		{
+			name:          "idle past the period",
+			idleSince:     start.Add(-10 * time.Minute),
+			sample:        api.InstanceStateHistorySample{Time: start.Add(time.Minute), CPUSeconds: 1, NetworkReceivedBytes: 1000},
+			stop:          true,
+			wantIdleSince: start.Add(-10 * time.Minute),
+		},
+		{
+			name:          "CPU activity",
+			idleSince:     start.Add(-10 * time.Minute),
+			sample:        api.InstanceStateHistorySample{Time: start.Add(time.Minute), CPUSeconds: 10},
+			wantIdleSince: start.Add(time.Minute),
+		},
+		{
+			name:          "network activity",
+			idleSince:     start.Add(-10 * time.Minute),
+			sample:        api.InstanceStateHistorySample{Time: start.Add(time.Minute), NetworkSentBytes: 1024 * 60 * 2},
+			wantIdleSince: start.Add(time.Minute),
+		},
+		{
+			name:          "counters going backwards",
+			idleSince:     start.Add(-10 * time.Minute),
+			sample:        api.InstanceStateHistorySample{Time: start.Add(time.Minute), CPUSeconds: -1},
+			wantIdleSince: start.Add(time.Minute),
+		},
+		{
+			name:          "no time elapsed",
+			idleSince:     start.Add(-10 * time.Minute),
+			sample:        api.InstanceStateHistorySample{Time: start},
+			wantIdleSince: start,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idle := &instanceIdle{sample: api.InstanceStateHistorySample{Time: start}, idleSince: tt.idleSince}
+
+			stop, reason := instanceIdleUpdate(idle, tt.sample, config)
+			assert.Equal(t, tt.stop, stop)
+			assert.Equal(t, tt.sample, idle.sample)
+			assert.Equal(t, tt.wantIdleSince, idle.idleSince)
+
+			if tt.stop {
+				assert.Equal(t, "CPU usage below 5% and network traffic below 1kB/s for 10m0s", reason)
+			} else {
+				assert.Empty(t, reason)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 
 	"github.com/gorilla/mux"
 
@@ -23,6 +24,8 @@ import (
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/revert"
+	"github.com/lxc/lxd/lxd/state"
 	storagePools "github.com/lxc/lxd/lxd/storage"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -34,6 +37,12 @@ var internalClusterInstanceMovedCmd = APIEndpoint{
 	Post: APIEndpointAction{Handler: internalClusterInstanceMovedPost},
 }
 
+var internalClusterInstanceMoveCmd = APIEndpoint{
+	Path: "cluster/instance-move/{name}",
+
+	Post: APIEndpointAction{Handler: internalClusterInstanceMovePost},
+}
+
 // swagger:operation POST /1.0/instances/{name} instances instance_post
 //
 // Rename or move/migrate an instance
@@ -738,6 +747,201 @@ func instancePostClusteringMigrate(d *Daemon, r *http.Request, inst instance.Ins
 	return run, nil
 }
 
+// instancePostClusteringCanMigrateLive returns whether the instance can be live moved to another cluster member.
+// Only running VMs with migration.stateful enabled are supported. Ephemeral instances are excluded as stopping them
+// on the source would delete them. Unless on shared storage, instances with snapshots are excluded as their volumes
+// are only created on the target member for the instance itself. Instances with other disks than the root disk
+// that aren't on shared storage are excluded as only the root disk is transferred.
+func instancePostClusteringCanMigrateLive(s *state.State, inst instance.Instance, pool storagePools.Pool) bool {
+	if inst.Type() != instancetype.VM || inst.IsEphemeral() || !inst.IsRunning() {
+		return false
+	}
+
+	if shared.IsFalseOrEmpty(inst.ExpandedConfig()["migration.stateful"]) {
+		return false
+	}
+
+	if !pool.Driver().Info().Remote {
+		snapshots, err := inst.Snapshots()
+		if err != nil || len(snapshots) > 0 {
+			return false
+		}
+	}
+
+	for _, entry := range inst.ExpandedDevices().Sorted() {
+		dev := entry.Config
+		if dev["type"] != "disk" || shared.IsRootDiskDevice(dev) || dev["source"] == "cloud-init:config" {
+			continue
+		}
+
+		if dev["pool"] == "" {
+			return false
+		}
+
+		diskPool, err := storagePools.LoadByName(s, dev["pool"])
+		if err != nil || !diskPool.Driver().Info().Remote {
+			return false
+		}
+	}
+
+	return true
+}
+
+// instancePostClusteringMigrateLive moves a running VM to the target member, keeping it running.
+// Unless on shared storage, its volume is created on the target member. The target member takes over the instance
+// record once the VM runs there.
+func instancePostClusteringMigrateLive(d *Daemon, r *http.Request, inst instance.Instance, pool storagePools.Pool, newNode string, op *operations.Operation) error {
+	s := d.State()
+
+	var targetAddress string
+	err := s.DB.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
+		node, err := tx.GetNodeByName(newNode)
+		if err != nil {
+			return fmt.Errorf("Failed to get new member address: %w", err)
+		}
+
+		targetAddress = node.Address
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dest, err := cluster.Connect(targetAddress, d.endpoints.NetworkCert(), d.serverCert(), r, false)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to destination server %q: %w", targetAddress, err)
+	}
+
+	dest = dest.UseTarget(newNode).UseProject(inst.Project())
+
+	ws, err := newMigrationSource(inst, true, true, false)
+	if err != nil {
+		return fmt.Errorf("Failed setting up instance migration on source: %w", err)
+	}
+
+	ws.clusterMove = true
+
+	// Setup the migration sink on the target member.
+	moveURL := api.NewURL().Project(inst.Project()).Path("internal", "cluster", "instance-move", inst.Name())
+	targetOp, _, err := dest.RawOperation("POST", moveURL.String(), nil, "")
+	if err != nil {
+		return fmt.Errorf("Failed setting up instance migration on target member: %w", err)
+	}
+
+	targetOpAPI := targetOp.Get()
+
+	websockets := map[string]string{}
+	for name, value := range targetOpAPI.Metadata {
+		secret, ok := value.(string)
+		if ok {
+			websockets[name] = secret
+		}
+	}
+
+	connInfo, err := dest.GetConnectionInfo()
+	if err != nil {
+		return err
+	}
+
+	// Cluster members share the same certificate.
+	target := api.InstancePostTarget{
+		Certificate: string(d.endpoints.NetworkPublicKey()),
+		Operation:   fmt.Sprintf("%s/1.0/operations/%s", connInfo.URL, url.PathEscape(targetOpAPI.ID)),
+		Websockets:  websockets,
+	}
+
+	err = ws.ConnectContainerTarget(target)
+	if err != nil {
+		_ = targetOp.Cancel()
+		return fmt.Errorf("Failed connecting to target member: %w", err)
+	}
+
+	err = ws.Do(s, op)
+	if err != nil {
+		return fmt.Errorf("Failed live migrating instance: %w", err)
+	}
+
+	err = targetOp.Wait()
+	if err != nil {
+		return fmt.Errorf("Failed live migrating instance on target member: %w", err)
+	}
+
+	// The instance now runs on the target member, remove what's left of it here.
+	if !pool.Driver().Info().Remote {
+		err = pool.DeleteInstance(inst, op)
+		if err != nil {
+			return fmt.Errorf("Failed deleting instance volume from source member: %w", err)
+		}
+	}
+
+	_ = os.RemoveAll(inst.LogPath())
+
+	return nil
+}
+
+// Receive a running VM being moved from another cluster member.
+//
+// The instance record is shared with the source member, so only the instance volume is created here (unless on
+// shared storage) and the instance record is moved to this member once the VM runs here.
+func internalClusterInstanceMovePost(d *Daemon, r *http.Request) response.Response {
+	s := d.State()
+
+	projectName := projectParam(r)
+	instanceName, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	inst, err := instance.LoadByProjectAndName(s, projectName, instanceName)
+	if err != nil {
+		return response.SmartError(fmt.Errorf("Failed loading instance on target node: %w", err))
+	}
+
+	sink, err := newMigrationSink(&MigrationSinkArgs{
+		Instance:     inst,
+		InstanceOnly: true,
+		Live:         true,
+		Push:         true,
+		ClusterMove:  true,
+	})
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	run := func(op *operations.Operation) error {
+		revert := revert.New()
+		defer revert.Fail()
+
+		err := sink.Do(s, revert, op)
+		if err != nil {
+			return fmt.Errorf("Error transferring instance data: %w", err)
+		}
+
+		// The instance now runs here.
+		revert.Success()
+
+		err = s.DB.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
+			return tx.UpdateInstanceNodeToLocal(ctx, projectName, instanceName)
+		})
+		if err != nil {
+			return fmt.Errorf("Failed moving instance record to target member: %w", err)
+		}
+
+		return nil
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{instanceName}
+
+	op, err := operations.OperationCreate(s, projectName, operations.OperationClassWebsocket, operationtype.InstanceMigrate, resources, sink.Metadata(), run, nil, sink.Connect, r)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
+
 // Special case migrating a container backed by ceph across two cluster nodes.
 func instancePostClusteringMigrateWithCeph(d *Daemon, r *http.Request, inst instance.Instance, pool storagePools.Pool, newName string, sourceNodeOffline bool, newNode string, stateful bool) (func(op *operations.Operation) error, error) {
 	if pool.Driver().Info().Name != "ceph" {
@@ -956,6 +1160,12 @@ func migrateInstance(d *Daemon, r *http.Request, inst instance.Instance, targetN
 		return fmt.Errorf("Failed loading instance storage pool: %w", err)
 	}
 
+	// Running VMs are moved live when possible, instead of being stopped and started again.
+	sameName := req.Name == "" || req.Name == inst.Name()
+	if req.Live && sameName && !sourceNodeOffline && instancePostClusteringCanMigrateLive(d.State(), inst, pool) {
+		return instancePostClusteringMigrateLive(d, r, inst, pool, targetNode, op)
+	}
+
 	if pool.Driver().Info().Name == "ceph" {
 		f, err := instancePostClusteringMigrateWithCeph(d, r, inst, pool, req.Name, sourceNodeOffline, targetNode, req.Live)
 		if err != nil {
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	storagePools "github.com/lxc/lxd/lxd/storage"
+	storageDrivers "github.com/lxc/lxd/lxd/storage/drivers"
+)
+
+// liveMoveInstance is an instance with only the properties used to decide whether it can be live moved.
+type liveMoveInstance struct {
+	instance.Instance
+
+	instanceType instancetype.Type
+	ephemeral    bool
+	running      bool
+	config       map[string]string
+	devices      deviceConfig.Devices
+	snapshots    int
+}
+
+func (inst *liveMoveInstance) Type() instancetype.Type {
+	return inst.instanceType
+}
+
+func (inst *liveMoveInstance) IsEphemeral() bool {
+	return inst.ephemeral
+}
+
+func (inst *liveMoveInstance) IsRunning() bool {
+	return inst.running
+}
+
+func (inst *liveMoveInstance) ExpandedConfig() map[string]string {
+	return inst.config
+}
+
+func (inst *liveMoveInstance) ExpandedDevices() deviceConfig.Devices {
+	return inst.devices
+}
+
+func (inst *liveMoveInstance) Snapshots() ([]instance.Instance, error) {
+	return make([]instance.Instance, inst.snapshots), nil
+}
+
+// liveMovePool is a storage pool with only the driver info used to decide whether an instance can be live moved.
+type liveMovePool struct {
+	storagePools.Pool
+
+	remote bool
+}
+
+func (pool *liveMovePool) Driver() storageDrivers.Driver {
+	return &liveMoveDriver{remote: pool.remote}
+}
+
+// liveMoveDriver is a storage driver with only the info used to decide whether an instance can be live moved.
+type liveMoveDriver struct {
+	storageDrivers.Driver
+
+	remote bool
+}
+
+func (driver *liveMoveDriver) Info() storageDrivers.Info {
+	return storageDrivers.Info{Remote: driver.remote}
+}
+
+func TestInstancePostClusteringCanMigrateLive(t *testing.T) {
+	rootDisk := deviceConfig.Devices{
+		"root":   {"type": "disk", "path": "/", "pool": "default"},
+		"config": {"type": "disk", "source": "cloud-init:config"},
+		"eth0":   {"type": "nic", "network": "lxdbr0"},
+	}
+
+	tests := []struct {
+		name     string
+		inst     liveMoveInstance
+		remote   bool
+		expected bool
+	}{
+		{
+			name:     "running VM",
+			inst:     liveMoveInstance{instanceType: instancetype.VM, running: true, config: map[string]string{"migration.stateful": "true"}, devices: rootDisk},
+			expected: true,
+		},
+		{
+			name:     "container",
+			inst:     liveMoveInstance{instanceType: instancetype.Container, running: true, config: map[string]string{"migration.stateful": "true"}, devices: rootDisk},
+			expected: false,
+		},
+		{
+			name:     "stopped VM",
+			inst:     liveMoveInstance{instanceType: instancetype.VM, config: map[string]string{"migration.stateful": "true"}, devices: rootDisk},
+			expected: false,
+		},
+		{
+			name:     "ephemeral VM",
+			inst:     liveMoveInstance{instanceType: instancetype.VM, ephemeral: true, running: true, config: map[string]string{"migration.stateful": "true"}, devices: rootDisk},
+			expected: false,
+		},
+		{
+			name:     "VM without stateful migration",
+			inst:     liveMoveInstance{instanceType: instancetype.VM, running: true, config: map[string]string{}, devices: rootDisk},
+			expected: false,
+		},
+		{
+			name:     "VM with snapshots on local storage",
+			inst:     liveMoveInstance{instanceType: instancetype.VM, running: true, config: map[string]string{"migration.stateful": "true"}, devices: rootDisk, snapshots: 1},
+			expected: false,
+		},
+		{
+			name:     "VM with snapshots on shared storage",
+			inst:     liveMoveInstance{instanceType: instancetype.VM, running: true, config: map[string]string{"migration.stateful": "true"}, devices: rootDisk, snapshots: 1},
+			remote:   true,
+			expected: true,
+		},
+		{
+			name: "VM with host disk",
+			inst: liveMoveInstance{instanceType: instancetype.VM, running: true, config: map[string]string{"migration.stateful": "true"}, devices: deviceConfig.Devices{
+				"root": {"type": "disk", "path": "/", "pool": "default"},
+				"data": {"type": "disk", "path": "/data", "source": "/srv/data"},
+			}},
+			remote:   true,
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inst := test.inst
+			assert.Equal(t, test.expected, instancePostClusteringCanMigrateLive(nil, &inst, &liveMovePool{remote: test.remote}))
+		})
+	}
+}
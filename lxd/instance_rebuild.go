@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db/operationtype"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/operations"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// swagger:operation POST /1.0/instances/{name}/rebuild instances instance_rebuild_post
+//
+// Rebuild the instance
+//
+// Replaces the root disk of the instance with a fresh copy of the image provided.
+// The instance keeps its configuration, devices, profiles, volatile keys and snapshots.
+// The instance must be stopped.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: body
+//     name: rebuild
+//     description: Rebuild request
+//     required: true
+//     schema:
+//       $ref: "#/definitions/InstanceRebuildPost"
+// responses:
+//   "202":
+//     $ref: "#/responses/Operation"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceRebuildPost(d *Daemon, r *http.Request) response.Response {
+	s := d.State()
+
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if resp != nil {
+		return resp
+	}
+
+	req := api.InstanceRebuildPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if req.Source.Type != "image" {
+		return response.BadRequest(fmt.Errorf("Unsupported rebuild source type %q", req.Source.Type))
+	}
+
+	inst, err := instance.LoadByProjectAndName(s, projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if inst.IsRunning() {
+		return response.BadRequest(fmt.Errorf("The instance must be stopped to be rebuilt"))
+	}
+
+	hash, err := instance.ResolveImage(s, projectName, req.Source)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	run := func(op *operations.Operation) error {
+		img, err := instanceImageFromSource(d, r, op, projectName, req.Source, hash, api.InstanceType(inst.Type().String()))
+		if err != nil {
+			return err
+		}
+
+		return instanceRebuildFromImage(d, r, inst, img, op)
+	}
+
+	resources := map[string][]string{}
+	resources["instances"] = []string{name}
+
+	if inst.Type() == instancetype.Container {
+		resources["containers"] = resources["instances"]
+	}
+
+	op, err := operations.OperationCreate(s, projectName, operations.OperationClassTask, operationtype.InstanceRebuild, resources, nil, run, nil, nil, r)
+	if err != nil {
+		return response.InternalError(err)
+	}
+
+	return operations.OperationResponse(op)
+}
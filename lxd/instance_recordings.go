@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/recording"
+	"github.com/lxc/lxd/lxd/request"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+// instanceRecordingsPath returns the directory holding the session recordings of an instance.
+// Recordings are kept when the instance is deleted.
+func instanceRecordingsPath(projectName string, instanceName string) string {
+	return shared.VarPath("recordings", project.Instance(projectName, instanceName))
+}
+
+// instanceSessionRecorder starts recording a session of the instance if session recording is enabled for the
+// server or the instance's project. Returns nil if it isn't.
+func instanceSessionRecorder(s *state.State, inst instance.Instance, r *http.Request, info api.InstanceRecording, width int, height int, env map[string]string) (*recording.Recorder, error) {
+	if !s.GlobalConfig.InstancesSessionRecording() {
+		var p *api.Project
+		err := s.DB.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
+			dbProject, err := dbCluster.GetProject(ctx, tx.Tx(), inst.Project())
+			if err != nil {
+				return err
+			}
+
+			p, err = dbProject.ToAPI(ctx, tx.Tx())
+
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if !shared.IsTrue(p.Config["instances.session_recording"]) {
+			return nil, nil
+		}
+	}
+
+	requestor := request.CreateRequestor(r)
+	info.Username = requestor.Username
+	info.Protocol = requestor.Protocol
+	info.Address = requestor.Address
+
+	rec, err := recording.New(instanceRecordingsPath(inst.Project(), inst.Name()), info, width, height, env)
+	if err != nil {
+		return nil, fmt.Errorf("Failed starting session recording: %w", err)
+	}
+
+	return rec, nil
+}
+
+// swagger:operation GET /1.0/instances/{name}/recordings instances instance_recordings_get
+//
+// Get the session recordings
+//
+// Returns a list of recorded exec and console sessions of the instance (URLs).
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of endpoints
+//           items:
+//             type: string
+//           example: |-
+//             [
+//               "/1.0/instances/foo/recordings/6d2e6b4c-2b39-4a4f-8f35-6b0c7c8ed0f1"
+//             ]
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+
+// swagger:operation GET /1.0/instances/{name}/recordings?recursion=1 instances instance_recordings_get_recursion1
+//
+// Get the session recordings
+//
+// Returns a list of recorded exec and console sessions of the instance (structs).
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           type: array
+//           description: List of session recordings
+//           items:
+//             $ref: "#/definitions/InstanceRecording"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceRecordingsGet(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if resp != nil {
+		return resp
+	}
+
+	// Check the instance exists.
+	_, err = instance.LoadByProjectAndName(d.State(), projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	recordings, err := recording.List(instanceRecordingsPath(projectName, name))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if !util.IsRecursionRequest(r) {
+		urls := []string{}
+		for _, rec := range recordings {
+			urls = append(urls, api.NewURL().Path(version.APIVersion, "instances", name, "recordings", rec.Name).String())
+		}
+
+		return response.SyncResponse(true, urls)
+	}
+
+	return response.SyncResponse(true, recordings)
+}
+
+// swagger:operation GET /1.0/instances/{name}/recordings/{recording} instances instance_recording_get
+//
+// Get the session recording
+//
+// Gets the details of a specific recorded session.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: Session recording
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/InstanceRecording"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceRecordingGet(d *Daemon, r *http.Request) response.Response {
+	projectName, name, recordingName, resp := instanceRecordingParams(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	info, err := recording.Load(instanceRecordingsPath(projectName, name), recordingName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return response.NotFound(fmt.Errorf("Recording not found"))
+		}
+
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, info)
+}
+
+// swagger:operation GET /1.0/instances/{name}/recordings/{recording}/export instances instance_recording_export
+//
+// Get the raw session recording
+//
+// Download the recorded session in the asciicast v2 format.
+//
+// ---
+// produces:
+//   - application/x-asciicast
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: Raw asciicast data
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "404":
+//     $ref: "#/responses/NotFound"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceRecordingExportGet(d *Daemon, r *http.Request) response.Response {
+	projectName, name, recordingName, resp := instanceRecordingParams(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	path, err := recording.CastPath(instanceRecordingsPath(projectName, name), recordingName)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if !shared.PathExists(path) {
+		return response.NotFound(fmt.Errorf("Recording not found"))
+	}
+
+	ent := response.FileResponseEntry{
+		Path:     path,
+		Filename: recordingName + ".cast",
+	}
+
+	return response.FileResponse(r, []response.FileResponseEntry{ent}, nil)
+}
+
+// instanceRecordingParams parses the instance and recording names of a recording request, returning a response
+// if the request should be answered directly (error or forwarding to another member).
+func instanceRecordingParams(d *Daemon, r *http.Request) (string, string, string, response.Response) {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return "", "", "", response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return "", "", "", response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return "", "", "", response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	recordingName, err := url.PathUnescape(mux.Vars(r)["recordingName"])
+	if err != nil {
+		return "", "", "", response.SmartError(err)
+	}
+
+	err = recording.ValidName(recordingName)
+	if err != nil {
+		return "", "", "", response.BadRequest(err)
+	}
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return "", "", "", response.SmartError(err)
+	}
+
+	if resp != nil {
+		return "", "", "", resp
+	}
+
+	return projectName, name, recordingName, nil
+}
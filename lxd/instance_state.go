@@ -11,6 +11,7 @@ import (
 
 	"github.com/lxc/lxd/lxd/db/operationtype"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/history"
 	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/response"
 	"github.com/lxc/lxd/shared"
@@ -101,6 +102,86 @@ func instanceState(d *Daemon, r *http.Request) response.Response {
 	return response.SyncResponse(true, state)
 }
 
+// swagger:operation GET /1.0/instances/{name}/state/history instances instance_state_history_get
+//
+// Get the resource usage history
+//
+// Gets the resource usage samples recorded for the instance over the retention period.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+// responses:
+//   "200":
+//     description: State history
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/InstanceStateHistory"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceStateHistoryGet(d *Daemon, r *http.Request) response.Response {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	if resp != nil {
+		return resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	stateHistory := api.InstanceStateHistory{
+		Interval: d.State().GlobalConfig.InstancesHistoryInterval(),
+		Samples:  history.Get(inst.Project(), inst.Name()),
+	}
+
+	return response.SyncResponse(true, stateHistory)
+}
+
 // swagger:operation PUT /1.0/instances/{name}/state instances instance_state_put
 //
 // Change the state
@@ -223,6 +304,14 @@ func instanceActionToOptype(action string) (operationtype.Type, error) {
 func doInstanceStatePut(inst instance.Instance, req api.InstanceStatePut) error {
 	switch shared.InstanceAction(req.Action) {
 	case shared.Start:
+		// Give the instance a fresh set of automatic restart attempts.
+		if inst.LocalConfig()["volatile.autorestart.count"] != "" {
+			err := inst.VolatileSet(map[string]string{"volatile.autorestart.count": ""})
+			if err != nil {
+				return err
+			}
+		}
+
 		return inst.Start(req.Stateful)
 	case shared.Stop:
 		if req.Stateful {
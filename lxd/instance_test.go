@@ -494,6 +494,44 @@ func (suite *containerTestSuite) TestContainer_findIdmap_maxed() {
 	}
 }
 
+func (suite *containerTestSuite) TestContainer_ValidBootDependencies() {
+	for _, args := range []db.InstanceArgs{
+		{Name: "boot-1"},
+		{Name: "boot-2", Config: map[string]string{"boot.depends_on": "boot-1"}},
+		{Name: "boot-3", Config: map[string]string{"boot.depends_on": "boot-2"}},
+	} {
+		args.Type = instancetype.Container
+		c, op, _, err := instance.CreateInternal(suite.d.State(), args, true)
+		suite.Req.Nil(err)
+		op.Done(nil)
+		defer func() { _ = c.Delete(true) }()
+	}
+
+	tests := []struct {
+		name      string
+		instance  string
+		dependsOn string
+		valid     bool
+	}{
+		{"no dependencies", "boot-1", "", true},
+		{"existing dependencies", "boot-3", "boot-1,boot-2", true},
+		{"new instance", "boot-4", "boot-3", true},
+		{"missing instance", "boot-1", "missing", true},
+		{"self", "boot-1", "boot-1", false},
+		{"direct cycle", "boot-1", "boot-2", false},
+		{"indirect cycle", "boot-1", "boot-3", false},
+	}
+
+	for _, test := range tests {
+		err := instance.ValidBootDependencies(suite.d.State(), "default", test.instance, map[string]string{"boot.depends_on": test.dependsOn})
+		if test.valid {
+			suite.Req.Nil(err, test.name)
+		} else {
+			suite.Req.NotNil(err, test.name)
+		}
+	}
+}
+
 func TestContainerTestSuite(t *testing.T) {
 	suite.Run(t, new(containerTestSuite))
 }
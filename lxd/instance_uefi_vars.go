@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/lifecycle"
+	"github.com/lxc/lxd/lxd/response"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// instanceUEFIVarsLoad loads the virtual machine targeted by the request.
+// A non-nil response is returned if the request was forwarded or failed.
+func instanceUEFIVarsLoad(d *Daemon, r *http.Request) (instance.VM, response.Response) {
+	instanceType, err := urlInstanceTypeDetect(r)
+	if err != nil {
+		return nil, response.SmartError(err)
+	}
+
+	projectName := projectParam(r)
+	name, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return nil, response.SmartError(err)
+	}
+
+	if shared.IsSnapshot(name) {
+		return nil, response.BadRequest(fmt.Errorf("Invalid instance name"))
+	}
+
+	// Handle requests targeted to an instance on a different node.
+	resp, err := forwardedResponseIfInstanceIsRemote(d, r, projectName, name, instanceType)
+	if err != nil {
+		return nil, response.SmartError(err)
+	}
+
+	if resp != nil {
+		return nil, resp
+	}
+
+	inst, err := instance.LoadByProjectAndName(d.State(), projectName, name)
+	if err != nil {
+		return nil, response.SmartError(err)
+	}
+
+	if inst.Type() != instancetype.VM {
+		return nil, response.BadRequest(fmt.Errorf("UEFI variables are only available for virtual machines"))
+	}
+
+	return inst.(instance.VM), nil
+}
+
+// swagger:operation GET /1.0/instances/{name}/uefi-vars instances instance_uefi_vars_get
+//
+// Get the UEFI variables
+//
+// Gets the UEFI variables stored in the NVRAM of the virtual machine.
+// If the NVRAM is going to be regenerated on next start, the variables it will be regenerated with are returned.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+// responses:
+//   "200":
+//     description: UEFI variables
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/InstanceUEFIVars"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceUEFIVarsGet(d *Daemon, r *http.Request) response.Response {
+	vm, resp := instanceUEFIVarsLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	vars, err := vm.UEFIVars()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponseETag(true, vars, vars)
+}
+
+// swagger:operation PUT /1.0/instances/{name}/uefi-vars instances instance_uefi_vars_put
+//
+// Update the UEFI variables
+//
+// Replaces the UEFI variables stored in the NVRAM of the virtual machine.
+// Variables which aren't provided are removed. The virtual machine must be stopped.
+//
+// ---
+// consumes:
+//   - application/json
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: body
+//     name: uefi-vars
+//     description: UEFI variables
+//     required: true
+//     schema:
+//       $ref: "#/definitions/InstanceUEFIVars"
+// responses:
+//   "200":
+//     $ref: "#/responses/EmptySyncResponse"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "412":
+//     $ref: "#/responses/PreconditionFailed"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func instanceUEFIVarsPut(d *Daemon, r *http.Request) response.Response {
+	vm, resp := instanceUEFIVarsLoad(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	// Validate the ETag.
+	vars, err := vm.UEFIVars()
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	err = util.EtagCheck(r, vars)
+	if err != nil {
+		return response.PreconditionFailed(err)
+	}
+
+	newVars := api.InstanceUEFIVars{}
+	err = json.NewDecoder(r.Body).Decode(&newVars)
+	if err != nil {
+		return response.BadRequest(err)
+	}
+
+	if vm.IsRunning() {
+		return response.BadRequest(fmt.Errorf("UEFI variables can only be modified while the instance is stopped"))
+	}
+
+	err = vm.UEFIVarsUpdate(newVars)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	d.State().Events.SendLifecycle(vm.Project(), lifecycle.InstanceUpdated.Event(vm, nil))
+
+	return response.EmptySyncResponse
+}
@@ -1,23 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/db/cluster"
 	"github.com/lxc/lxd/lxd/db/warningtype"
+	"github.com/lxc/lxd/lxd/device"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/instance/history"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/metrics"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
 	"github.com/lxc/lxd/lxd/warnings"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -64,6 +71,78 @@ var instanceStateCmd = APIEndpoint{
 	Put: APIEndpointAction{Handler: instanceStatePut, AccessHandler: allowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceStateHistoryCmd = APIEndpoint{
+	Name: "instanceStateHistory",
+	Path: "instances/{name}/state/history",
+
+	Get: APIEndpointAction{Handler: instanceStateHistoryGet, AccessHandler: allowProjectPermission("containers", "view")},
+}
+
+var instanceUEFIVarsCmd = APIEndpoint{
+	Name: "instanceUEFIVars",
+	Path: "instances/{name}/uefi-vars",
+
+	Get: APIEndpointAction{Handler: instanceUEFIVarsGet, AccessHandler: allowProjectPermission("containers", "view")},
+	Put: APIEndpointAction{Handler: instanceUEFIVarsPut, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceFirewallCmd = APIEndpoint{
+	Name: "instanceFirewall",
+	Path: "instances/{name}/firewall",
+
+	Get: APIEndpointAction{Handler: instanceFirewallGet, AccessHandler: allowProjectPermission("containers", "view")},
+}
+
+var instanceRebuildCmd = APIEndpoint{
+	Name: "instanceRebuild",
+	Path: "instances/{name}/rebuild",
+
+	Post: APIEndpointAction{Handler: instanceRebuildPost, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceDiffCmd = APIEndpoint{
+	Name: "instanceDiff",
+	Path: "instances/{name}/diff",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerDiff", Path: "containers/{name}/diff"},
+	},
+
+	Get: APIEndpointAction{Handler: instanceDiffGet, AccessHandler: allowProjectPermission("containers", "view")},
+}
+
+var instanceRecordingsCmd = APIEndpoint{
+	Name: "instanceRecordings",
+	Path: "instances/{name}/recordings",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerRecordings", Path: "containers/{name}/recordings"},
+		{Name: "vmRecordings", Path: "virtual-machines/{name}/recordings"},
+	},
+
+	Get: APIEndpointAction{Handler: instanceRecordingsGet, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceRecordingCmd = APIEndpoint{
+	Name: "instanceRecording",
+	Path: "instances/{name}/recordings/{recordingName}",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerRecording", Path: "containers/{name}/recordings/{recordingName}"},
+		{Name: "vmRecording", Path: "virtual-machines/{name}/recordings/{recordingName}"},
+	},
+
+	Get: APIEndpointAction{Handler: instanceRecordingGet, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
+var instanceRecordingExportCmd = APIEndpoint{
+	Name: "instanceRecordingExport",
+	Path: "instances/{name}/recordings/{recordingName}/export",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerRecordingExport", Path: "containers/{name}/recordings/{recordingName}/export"},
+		{Name: "vmRecordingExport", Path: "virtual-machines/{name}/recordings/{recordingName}/export"},
+	},
+
+	Get: APIEndpointAction{Handler: instanceRecordingExportGet, AccessHandler: allowProjectPermission("containers", "manage-containers")},
+}
+
 var instanceSFTPCmd = APIEndpoint{
 	Name: "instanceFile",
 	Path: "instances/{name}/sftp",
@@ -89,6 +168,29 @@ var instanceFileCmd = APIEndpoint{
 	Delete: APIEndpointAction{Handler: instanceFileHandler, AccessHandler: allowProjectPermission("containers", "operate-containers")},
 }
 
+var instanceFileArchiveCmd = APIEndpoint{
+	Name: "instanceFileArchive",
+	Path: "instances/{name}/files/archive",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerFileArchive", Path: "containers/{name}/files/archive"},
+		{Name: "vmFileArchive", Path: "virtual-machines/{name}/files/archive"},
+	},
+
+	Get:  APIEndpointAction{Handler: instanceFileArchiveHandler, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+	Post: APIEndpointAction{Handler: instanceFileArchiveHandler, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+}
+
+var instanceFileWatchCmd = APIEndpoint{
+	Name: "instanceFileWatch",
+	Path: "instances/{name}/files/watch",
+	Aliases: []APIEndpointAlias{
+		{Name: "containerFileWatch", Path: "containers/{name}/files/watch"},
+		{Name: "vmFileWatch", Path: "virtual-machines/{name}/files/watch"},
+	},
+
+	Get: APIEndpointAction{Handler: instanceFileWatchGet, AccessHandler: allowProjectPermission("containers", "operate-containers")},
+}
+
 var instanceSnapshotsCmd = APIEndpoint{
 	Name: "instanceSnapshots",
 	Path: "instances/{name}/snapshots",
@@ -232,6 +334,7 @@ func instancesStart(s *state.State, instances []instance.Instance) {
 	defer instancesStartMu.Unlock()
 
 	sort.Sort(instanceAutostartList(instances))
+	instances = instancesBootOrder(instances, false)
 
 	maxAttempts := 3
 
@@ -256,6 +359,14 @@ func instancesStart(s *state.State, instances []instance.Instance) {
 
 		instLogger := logger.AddContext(logger.Log, logger.Ctx{"project": inst.Project(), "instance": inst.Name()})
 
+		// Wait for the instances it depends on to be ready.
+		for _, dependency := range instance.BootDependencies(config) {
+			err := instanceWaitReady(s, inst.Project(), dependency)
+			if err != nil {
+				instLogger.Warn("Boot dependency isn't ready, starting anyway", logger.Ctx{"dependency": dependency, "err": err})
+			}
+		}
+
 		// Try to start the instance.
 		var attempt = 0
 		for {
@@ -302,6 +413,163 @@ func instancesStart(s *state.State, instances []instance.Instance) {
 	}
 }
 
+// instancesBootOrder returns the instances ordered so that each instance comes after the instances it depends on
+// to boot (or before them if reverse is true), otherwise keeping their existing order.
+func instancesBootOrder(instances []instance.Instance, reverse bool) []instance.Instance {
+	indexes := make(map[string]int, len(instances))
+	for i, inst := range instances {
+		indexes[project.Instance(inst.Project(), inst.Name())] = i
+	}
+
+	// List the instances that need to come before each instance.
+	before := make([][]int, len(instances))
+	for i, inst := range instances {
+		for _, dependency := range instance.BootDependencies(inst.ExpandedConfig()) {
+			j, found := indexes[project.Instance(inst.Project(), dependency)]
+			if !found || j == i {
+				continue
+			}
+
+			if reverse {
+				before[j] = append(before[j], i)
+			} else {
+				before[i] = append(before[i], j)
+			}
+		}
+	}
+
+	ordered := make([]instance.Instance, 0, len(instances))
+	placed := make([]bool, len(instances))
+	for len(ordered) < len(instances) {
+		next := -1
+		for i := range instances {
+			if placed[i] {
+				continue
+			}
+
+			ready := true
+			for _, j := range before[i] {
+				if !placed[j] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 {
+			// Dependency cycle, keep the existing order of the remaining instances.
+			for i := range instances {
+				if !placed[i] {
+					placed[i] = true
+					ordered = append(ordered, instances[i])
+				}
+			}
+
+			break
+		}
+
+		placed[next] = true
+		ordered = append(ordered, instances[next])
+	}
+
+	return ordered
+}
+
+// instanceReady returns whether the instance meets its boot.ready condition.
+func instanceReady(inst instance.Instance) (bool, error) {
+	if !inst.IsRunning() {
+		return false, nil
+	}
+
+	condition := inst.ExpandedConfig()["boot.ready"]
+
+	switch {
+	case condition == "" || condition == "running":
+		return true, nil
+	case condition == "ready":
+		return shared.IsTrue(inst.LocalConfig()["volatile.last_state.ready"]), nil
+	case strings.HasPrefix(condition, "tcp:"):
+		port := strings.TrimPrefix(condition, "tcp:")
+
+		addresses, err := instanceGlobalAddresses(inst)
+		if err != nil {
+			return false, err
+		}
+
+		for _, address := range addresses {
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(address, port), time.Second)
+			if err == nil {
+				_ = conn.Close()
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	return false, fmt.Errorf("Invalid boot.ready condition %q", condition)
+}
+
+// instanceGlobalAddresses returns the global IP addresses of the running instance.
+func instanceGlobalAddresses(inst instance.Instance) ([]string, error) {
+	instState, err := inst.RenderState()
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, network := range instState.Network {
+		for _, addr := range network.Addresses {
+			if addr.Scope == "global" {
+				addresses = append(addresses, addr.Address)
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// instanceWaitReady waits for the instance to meet its boot.ready condition, for up to boot.ready.timeout seconds.
+func instanceWaitReady(s *state.State, projectName string, instanceName string) error {
+	timeout := 300 * time.Second
+
+	for start := time.Now(); ; time.Sleep(time.Second) {
+		// Reload the instance to get its current state.
+		inst, err := instance.LoadByProjectAndName(s, projectName, instanceName)
+		if err != nil {
+			return err
+		}
+
+		if !inst.IsRunning() {
+			return fmt.Errorf("Instance isn't running")
+		}
+
+		value := inst.ExpandedConfig()["boot.ready.timeout"]
+		if value != "" {
+			seconds, _ := strconv.Atoi(value)
+			timeout = time.Duration(seconds) * time.Second
+		}
+
+		ready, err := instanceReady(inst)
+		if err != nil {
+			return err
+		}
+
+		if ready {
+			return nil
+		}
+
+		if time.Since(start) > timeout {
+			return fmt.Errorf("Instance not ready after %s", timeout)
+		}
+	}
+}
+
 type instanceStopList []instance.Instance
 
 func (slice instanceStopList) Len() int {
@@ -391,32 +659,97 @@ func instancesOnDisk(s *state.State) ([]instance.Instance, error) {
 	return instances, nil
 }
 
-func instancesShutdown(s *state.State, instances []instance.Instance) {
-	var wg sync.WaitGroup
-
+// instancesStopGroups returns the instances grouped by stop priority, highest first, with each group ordered so
+// that instances come before the instances they depend on to boot. As instances must stop before the instances they
+// depend on, an instance depending on an instance with a higher priority is placed in the group of that instance.
+func instancesStopGroups(instances []instance.Instance) [][]instance.Instance {
 	sort.Sort(instanceStopList(instances))
 
-	var lastPriority int
+	indexes := make(map[string]int, len(instances))
+	priorities := make([]int, len(instances))
+	for i, inst := range instances {
+		indexes[project.Instance(inst.Project(), inst.Name())] = i
+		priorities[i], _ = strconv.Atoi(inst.ExpandedConfig()["boot.stop.priority"])
+	}
+
+	// Raise the priority of the instances to the priority of the instances they depend on. Each pass propagates
+	// the priorities one level further up the dependency chains, so the number of passes is bounded by the number
+	// of instances even when the dependencies form a cycle.
+	for pass := 0; pass < len(instances); pass++ {
+		changed := false
+		for i, inst := range instances {
+			for _, dependency := range instance.BootDependencies(inst.ExpandedConfig()) {
+				j, found := indexes[project.Instance(inst.Project(), dependency)]
+				if found && priorities[i] < priorities[j] {
+					priorities[i] = priorities[j]
+					changed = true
+				}
+			}
+		}
 
-	if len(instances) != 0 {
-		lastPriority, _ = strconv.Atoi(instances[0].ExpandedConfig()["boot.stop.priority"])
+		if !changed {
+			break
+		}
 	}
 
-	for _, inst := range instances {
-		priority, _ := strconv.Atoi(inst.ExpandedConfig()["boot.stop.priority"])
+	sorted := make([]int, len(instances))
+	for i := range sorted {
+		sorted[i] = i
+	}
 
-		// Enforce shutdown priority
-		if priority != lastPriority {
-			lastPriority = priority
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return priorities[sorted[a]] > priorities[sorted[b]]
+	})
 
-			// Wait for instances with higher priority to finish
-			wg.Wait()
+	var groups [][]instance.Instance
+	for k, i := range sorted {
+		if k == 0 || priorities[i] != priorities[sorted[k-1]] {
+			groups = append(groups, nil)
 		}
 
-		// Stop the instance if running.
-		if inst.IsRunning() {
+		groups[len(groups)-1] = append(groups[len(groups)-1], instances[i])
+	}
+
+	for k := range groups {
+		groups[k] = instancesBootOrder(groups[k], true)
+	}
+
+	return groups
+}
+
+func instancesShutdown(s *state.State, instances []instance.Instance) {
+	for _, group := range instancesStopGroups(instances) {
+		var wg sync.WaitGroup
+
+		stopped := make([]chan struct{}, len(group))
+		for i := range group {
+			stopped[i] = make(chan struct{})
+		}
+
+		for i, inst := range group {
+			// Wait for the instances of the group depending on this one to stop first. They come before it in
+			// the group unless the dependencies form a cycle, in which case the group order is kept.
+			var waitFor []chan struct{}
+			for j, prev := range group[:i] {
+				if prev.Project() == inst.Project() && shared.StringInSlice(inst.Name(), instance.BootDependencies(prev.ExpandedConfig())) {
+					waitFor = append(waitFor, stopped[j])
+				}
+			}
+
 			wg.Add(1)
-			go func(inst instance.Instance) {
+			go func(inst instance.Instance, waitFor []chan struct{}, done chan struct{}) {
+				defer wg.Done()
+				defer close(done)
+
+				for _, ch := range waitFor {
+					<-ch
+				}
+
+				// Stop the instance if running.
+				if !inst.IsRunning() {
+					return
+				}
+
 				// Determine how long to wait for the instance to shutdown cleanly.
 				timeoutSeconds := 30
 				value, ok := inst.ExpandedConfig()["boot.host_shutdown_timeout"]
@@ -439,10 +772,213 @@ func instancesShutdown(s *state.State, instances []instance.Instance) {
 					// when LXD restarts the instance will be started again.
 					_ = inst.VolatileSet(map[string]string{"volatile.last_state.power": "RUNNING"})
 				}
+			}(inst, waitFor, stopped[i])
+		}
+
+		// Wait for the instances of the group to stop before stopping the instances with a lower priority.
+		wg.Wait()
+	}
+}
+
+// instancesScheduledPowerTask starts and stops the local instances according to their boot.schedule.start and
+// boot.schedule.stop schedules (minutely check of configurable cron expressions).
+func instancesScheduledPowerTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		s := d.State()
+
+		instances, err := instance.LoadNodeAll(s, instancetype.Any)
+		if err != nil {
+			logger.Error("Failed loading instances for scheduled power actions", logger.Ctx{"err": err})
+			return
+		}
+
+		var wg sync.WaitGroup
+
+		for _, inst := range instances {
+			config := inst.ExpandedConfig()
+
+			start := config["boot.schedule.start"] != "" && snapshotIsScheduledNow(config["boot.schedule.start"], int64(inst.ID()))
+			stop := config["boot.schedule.stop"] != "" && snapshotIsScheduledNow(config["boot.schedule.stop"], int64(inst.ID()))
+
+			// Leave the instance as is if both schedules match.
+			if start == stop {
+				continue
+			}
+
+			instLogger := logger.AddContext(logger.Log, logger.Ctx{"project": inst.Project(), "instance": inst.Name()})
 
-				wg.Done()
-			}(inst)
+			if start && !inst.IsRunning() {
+				// Don't start instances on an evacuated member.
+				if s.DB.Cluster.LocalNodeIsEvacuated() {
+					continue
+				}
+
+				wg.Add(1)
+				go func(inst instance.Instance) {
+					defer wg.Done()
+
+					instLogger.Info("Starting instance on schedule")
+
+					err := inst.Start(false)
+					if err != nil {
+						instLogger.Error("Failed starting instance on schedule", logger.Ctx{"err": err})
+					}
+				}(inst)
+			} else if stop && inst.IsRunning() {
+				wg.Add(1)
+				go func(inst instance.Instance) {
+					defer wg.Done()
+
+					instLogger.Info("Stopping instance on schedule")
+
+					timeoutSeconds := 30
+					value, ok := inst.ExpandedConfig()["boot.host_shutdown_timeout"]
+					if ok {
+						timeoutSeconds, _ = strconv.Atoi(value)
+					}
+
+					err := inst.Shutdown(time.Second * time.Duration(timeoutSeconds))
+					if err != nil {
+						instLogger.Warn("Failed shutting down instance on schedule, forcefully stopping", logger.Ctx{"err": err})
+
+						err = inst.Stop(false)
+						if err != nil {
+							instLogger.Error("Failed stopping instance on schedule", logger.Ctx{"err": err})
+						}
+					}
+				}(inst)
+			}
 		}
+
+		wg.Wait()
 	}
-	wg.Wait()
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+// instancesHistoryTask records the resource usage of the running local instances every instances.history.interval
+// seconds, keeping the samples for instances.history.retention hours.
+func instancesHistoryTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		s := d.State()
+
+		instances, err := instance.LoadNodeAll(s, instancetype.Any)
+		if err != nil {
+			logger.Error("Failed loading instances for resource usage history", logger.Ctx{"err": err})
+			return
+		}
+
+		interval := s.GlobalConfig.InstancesHistoryInterval()
+		retention := time.Duration(s.GlobalConfig.InstancesHistoryRetentionHours()) * time.Hour
+
+		// Forget the old samples and those of the instances that were deleted or moved to another member.
+		local := make(map[string]bool, len(instances))
+		for _, inst := range instances {
+			local[project.Instance(inst.Project(), inst.Name())] = true
+		}
+
+		history.Prune(time.Now().Add(-retention), func(projectName string, instanceName string) bool {
+			return interval > 0 && local[project.Instance(projectName, instanceName)]
+		})
+
+		if interval <= 0 || retention <= 0 {
+			return
+		}
+
+		size := int(retention / (time.Duration(interval) * time.Second))
+
+		for _, inst := range instances {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if !inst.IsRunning() {
+				continue
+			}
+
+			metrics, err := inst.Metrics()
+			if err != nil {
+				logger.Debug("Failed getting instance metrics for resource usage history", logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+				continue
+			}
+
+			history.Add(inst.Project(), inst.Name(), instanceHistorySample(metrics), size)
+		}
+	}
+
+	schedule := func() (time.Duration, error) {
+		interval := d.State().GlobalConfig.InstancesHistoryInterval()
+		if interval <= 0 {
+			// Check again later whether the history got enabled.
+			return time.Minute, nil
+		}
+
+		return time.Duration(interval) * time.Second, nil
+	}
+
+	return f, schedule
+}
+
+// instanceHistorySample returns the resource usage history sample matching the instance metrics.
+func instanceHistorySample(metricSet *metrics.MetricSet) api.InstanceStateHistorySample {
+	busy := func(labels map[string]string) bool {
+		return labels["mode"] != "idle" && labels["mode"] != "iowait"
+	}
+
+	notLoopback := func(labels map[string]string) bool {
+		return labels["device"] != "lo"
+	}
+
+	return api.InstanceStateHistorySample{
+		Time:                 time.Now().UTC(),
+		CPUSeconds:           metricSet.Sum(metrics.CPUSecondsTotal, busy),
+		MemoryUsage:          int64(metricSet.Sum(metrics.MemoryMemTotalBytes, nil) - metricSet.Sum(metrics.MemoryMemAvailableBytes, nil)),
+		DiskReadBytes:        int64(metricSet.Sum(metrics.DiskReadBytesTotal, nil)),
+		DiskWrittenBytes:     int64(metricSet.Sum(metrics.DiskWrittenBytesTotal, nil)),
+		NetworkReceivedBytes: int64(metricSet.Sum(metrics.NetworkReceiveBytesTotal, notLoopback)),
+		NetworkSentBytes:     int64(metricSet.Sum(metrics.NetworkTransmitBytesTotal, notLoopback)),
+	}
+}
+
+// networkTransferUpdateTask periodically accounts for the traffic of the NICs of the running instances and applies
+// any network transfer limit they have reached.
+func networkTransferUpdateTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		s := d.State()
+
+		instances, err := instance.LoadNodeAll(s, instancetype.Any)
+		if err != nil {
+			logger.Error("Failed loading instances for network transfer accounting", logger.Ctx{"err": err})
+			return
+		}
+
+		for _, inst := range instances {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if !inst.IsRunning() {
+				continue
+			}
+
+			err = device.NetworkTransferUpdate(s, inst)
+			if err != nil {
+				logger.Warn("Failed updating network transfer accounting", logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "err": err})
+			}
+		}
+	}
+
+	return f, task.Every(5 * time.Minute)
 }
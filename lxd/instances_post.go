@@ -38,6 +38,71 @@ import (
 	"github.com/lxc/lxd/shared/osarch"
 )
 
+// instanceImageFromSource returns the image referenced by the image source of an instance request.
+// Images from remote servers are downloaded (or taken from the cache) into the project.
+func instanceImageFromSource(d *Daemon, r *http.Request, op *operations.Operation, projectName string, source api.InstanceSource, hash string, instType api.InstanceType) (*api.Image, error) {
+	if source.Server == "" {
+		_, info, err := d.db.Cluster.GetImage(hash, dbCluster.ImageFilter{Project: &projectName})
+		if err != nil {
+			return nil, err
+		}
+
+		return info, nil
+	}
+
+	var autoUpdate bool
+	var p *api.Project
+	err := d.db.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
+		project, err := dbCluster.GetProject(ctx, tx.Tx(), projectName)
+		if err != nil {
+			return err
+		}
+
+		p, err = project.ToAPI(ctx, tx.Tx())
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Config["images.auto_update_cached"] != "" {
+		autoUpdate = shared.IsTrue(p.Config["images.auto_update_cached"])
+	} else {
+		autoUpdate = d.State().GlobalConfig.ImagesAutoUpdateCached()
+	}
+
+	// Detect image type based on instance type requested.
+	imgType := "container"
+	if instType == "virtual-machine" {
+		imgType = "virtual-machine"
+	}
+
+	var budget int64
+	err = d.db.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
+		budget, err = project.GetImageSpaceBudget(tx, projectName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.ImageDownload(r, op, &ImageDownloadArgs{
+		Server:       source.Server,
+		Protocol:     source.Protocol,
+		Certificate:  source.Certificate,
+		Secret:       source.Secret,
+		Alias:        hash,
+		SetCached:    true,
+		Type:         imgType,
+		AutoUpdate:   autoUpdate,
+		Public:       false,
+		PreferCached: true,
+		ProjectName:  projectName,
+		Budget:       budget,
+	})
+}
+
 func createFromImage(d *Daemon, r *http.Request, projectName string, req *api.InstancesPost) response.Response {
 	if d.db.Cluster.LocalNodeIsEvacuated() {
 		return response.Forbidden(fmt.Errorf("Cluster member is evacuated"))
@@ -78,67 +143,9 @@ func createFromImage(d *Daemon, r *http.Request, projectName string, req *api.In
 			return err
 		}
 
-		var info *api.Image
-		if req.Source.Server != "" {
-			var autoUpdate bool
-			var p *api.Project
-			err := d.db.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
-				project, err := dbCluster.GetProject(ctx, tx.Tx(), projectName)
-				if err != nil {
-					return err
-				}
-
-				p, err = project.ToAPI(ctx, tx.Tx())
-
-				return err
-			})
-			if err != nil {
-				return err
-			}
-
-			if p.Config["images.auto_update_cached"] != "" {
-				autoUpdate = shared.IsTrue(p.Config["images.auto_update_cached"])
-			} else {
-				autoUpdate = d.State().GlobalConfig.ImagesAutoUpdateCached()
-			}
-
-			// Detect image type based on instance type requested.
-			imgType := "container"
-			if req.Type == "virtual-machine" {
-				imgType = "virtual-machine"
-			}
-
-			var budget int64
-			err = d.db.Cluster.Transaction(context.TODO(), func(ctx context.Context, tx *db.ClusterTx) error {
-				budget, err = project.GetImageSpaceBudget(tx, projectName)
-				return err
-			})
-			if err != nil {
-				return err
-			}
-
-			info, err = d.ImageDownload(r, op, &ImageDownloadArgs{
-				Server:       req.Source.Server,
-				Protocol:     req.Source.Protocol,
-				Certificate:  req.Source.Certificate,
-				Secret:       req.Source.Secret,
-				Alias:        hash,
-				SetCached:    true,
-				Type:         imgType,
-				AutoUpdate:   autoUpdate,
-				Public:       false,
-				PreferCached: true,
-				ProjectName:  projectName,
-				Budget:       budget,
-			})
-			if err != nil {
-				return err
-			}
-		} else {
-			_, info, err = d.db.Cluster.GetImage(hash, dbCluster.ImageFilter{Project: &projectName})
-			if err != nil {
-				return err
-			}
+		info, err := instanceImageFromSource(d, r, op, projectName, req.Source, hash, req.Type)
+		if err != nil {
+			return err
 		}
 
 		args.Architecture, err = osarch.ArchitectureId(info.Architecture)
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/instance"
+)
+
+// bootOrderInstance is an instance with only the name, project and expanded config used to order instances.
+type bootOrderInstance struct {
+	instance.Instance
+
+	name    string
+	project string
+	config  map[string]string
+}
+
+func (inst *bootOrderInstance) Name() string {
+	return inst.name
+}
+
+func (inst *bootOrderInstance) Project() string {
+	return inst.project
+}
+
+func (inst *bootOrderInstance) ExpandedConfig() map[string]string {
+	return inst.config
+}
+
+// bootOrderInstances returns instances of the default project from a map of names to "boot.depends_on" and
+// "boot.stop.priority" values, in the order of the names.
+func bootOrderInstances(names []string, config map[string][2]string) []instance.Instance {
+	instances := make([]instance.Instance, 0, len(names))
+	for _, name := range names {
+		instances = append(instances, &bootOrderInstance{
+			name:    name,
+			project: "default",
+			config: map[string]string{
+				"boot.depends_on":    config[name][0],
+				"boot.stop.priority": config[name][1],
+			},
+		})
+	}
+
+	return instances
+}
+
+func bootOrderNames(instances []instance.Instance) []string {
+	names := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		names = append(names, inst.Name())
+	}
+
+	return names
+}
+
+func TestInstancesBootOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		config   map[string][2]string
+		reverse  bool
+		expected []string
+	}{
+		{
+			name:     "no dependencies",
+			names:    []string{"c1", "c2", "c3"},
+			expected: []string{"c1", "c2", "c3"},
+		},
+		{
+			name:     "chain",
+			names:    []string{"c1", "c2", "c3"},
+			config:   map[string][2]string{"c1": {"c2"}, "c2": {"c3"}},
+			expected: []string{"c3", "c2", "c1"},
+		},
+		{
+			name:     "chain reversed",
+			names:    []string{"c3", "c2", "c1"},
+			config:   map[string][2]string{"c1": {"c2"}, "c2": {"c3"}},
+			reverse:  true,
+			expected: []string{"c1", "c2", "c3"},
+		},
+		{
+			name:     "multiple dependencies",
+			names:    []string{"c1", "c2", "c3", "c4"},
+			config:   map[string][2]string{"c1": {"c3,c4"}, "c2": {"c4"}},
+			expected: []string{"c3", "c4", "c1", "c2"},
+		},
+		{
+			name:     "missing dependency",
+			names:    []string{"c1", "c2"},
+			config:   map[string][2]string{"c1": {"missing,c2"}},
+			expected: []string{"c2", "c1"},
+		},
+		{
+			name:     "cycle",
+			names:    []string{"c1", "c2", "c3", "c4"},
+			config:   map[string][2]string{"c1": {"c2"}, "c2": {"c1"}, "c3": {"c4"}},
+			expected: []string{"c4", "c3", "c1", "c2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instances := bootOrderInstances(test.names, test.config)
+			assert.Equal(t, test.expected, bootOrderNames(instancesBootOrder(instances, test.reverse)))
+		})
+	}
+}
+
+func TestInstancesStopGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		config   map[string][2]string
+		expected [][]string
+	}{
+		{
+			name:     "priorities",
+			names:    []string{"c1", "c2", "c3"},
+			config:   map[string][2]string{"c1": {"", "1"}, "c2": {"", "2"}, "c3": {"", "1"}},
+			expected: [][]string{{"c2"}, {"c1", "c3"}},
+		},
+		{
+			name:     "dependencies within a group",
+			names:    []string{"c1", "c2", "c3"},
+			config:   map[string][2]string{"c2": {"c3"}, "c3": {"c1"}},
+			expected: [][]string{{"c2", "c3", "c1"}},
+		},
+		{
+			name:     "dependency with a higher priority",
+			names:    []string{"c1", "c2", "c3"},
+			config:   map[string][2]string{"c1": {"", "2"}, "c2": {"c1", "1"}, "c3": {"", "1"}},
+			expected: [][]string{{"c2", "c1"}, {"c3"}},
+		},
+		{
+			name:     "dependency with a lower priority",
+			names:    []string{"c1", "c2", "c3", "c4"},
+			config:   map[string][2]string{"c1": {"c2", "2"}, "c2": {"c4", "0"}, "c3": {"", "1"}},
+			expected: [][]string{{"c1"}, {"c3"}, {"c2", "c4"}},
+		},
+		{
+			name:     "dependency chain with a higher priority",
+			names:    []string{"c1", "c2", "c3", "c4"},
+			config:   map[string][2]string{"c1": {"c2", "0"}, "c2": {"c3", "0"}, "c3": {"", "2"}, "c4": {"", "1"}},
+			expected: [][]string{{"c1", "c2", "c3"}, {"c4"}},
+		},
+		{
+			name:     "cycle",
+			names:    []string{"c1", "c2", "c3"},
+			config:   map[string][2]string{"c1": {"c2", "1"}, "c2": {"c1", "0"}, "c3": {"", "2"}},
+			expected: [][]string{{"c3"}, {"c1", "c2"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var groups [][]string
+			for _, group := range instancesStopGroups(bootOrderInstances(test.names, test.config)) {
+				groups = append(groups, bootOrderNames(group))
+			}
+
+			assert.Equal(t, test.expected, groups)
+		})
+	}
+}
@@ -39,6 +39,25 @@ func (a *ActionPolice) AddAction() []string {
 	return result
 }
 
+// ActionMirred represents an action of 'mirred' type.
+type ActionMirred struct {
+	Direction string
+	Action    string
+	Dev       string
+	Control   string
+}
+
+// AddAction generates a part of command specific for 'mirred' action.
+// Unlike police, mirred can only be used through the generic action syntax so it's prefixed with "action".
+func (a *ActionMirred) AddAction() []string {
+	result := []string{"action", "mirred", a.Direction, a.Action, "dev", a.Dev}
+	if a.Control != "" {
+		result = append(result, a.Control)
+	}
+
+	return result
+}
+
 // Filter represents filter object.
 type Filter struct {
 	Dev      string
@@ -81,3 +81,21 @@ func (qdisc *QdiscHTB) Add() error {
 
 	return nil
 }
+
+// QdiscPrio represents the priority qdisc object.
+type QdiscPrio struct {
+	Qdisc
+}
+
+// Add adds qdisc to a node.
+func (qdisc *QdiscPrio) Add() error {
+	cmd := qdisc.mainCmd()
+	cmd = append(cmd, "prio")
+
+	_, err := shared.RunCommand("tc", cmd...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
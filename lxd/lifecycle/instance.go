@@ -26,6 +26,7 @@ const (
 	InstancePaused           = InstanceAction(api.EventLifecycleInstancePaused)
 	InstanceResumed          = InstanceAction(api.EventLifecycleInstanceResumed)
 	InstanceRestored         = InstanceAction(api.EventLifecycleInstanceRestored)
+	InstanceRebuilt          = InstanceAction(api.EventLifecycleInstanceRebuilt)
 	InstanceDeleted          = InstanceAction(api.EventLifecycleInstanceDeleted)
 	InstanceRenamed          = InstanceAction(api.EventLifecycleInstanceRenamed)
 	InstanceUpdated          = InstanceAction(api.EventLifecycleInstanceUpdated)
@@ -36,6 +37,9 @@ const (
 	InstanceFileRetrieved    = InstanceAction(api.EventLifecycleInstanceFileRetrieved)
 	InstanceFilePushed       = InstanceAction(api.EventLifecycleInstanceFilePushed)
 	InstanceFileDeleted      = InstanceAction(api.EventLifecycleInstanceFileDeleted)
+	InstanceHealthy          = InstanceAction(api.EventLifecycleInstanceHealthy)
+	InstanceUnhealthy        = InstanceAction(api.EventLifecycleInstanceUnhealthy)
+	InstanceIdleStopped      = InstanceAction(api.EventLifecycleInstanceIdleStopped)
 )
 
 // Event creates the lifecycle event for an action on an instance.
@@ -20,6 +20,9 @@ const (
 	NetworkDeleted = NetworkAction(api.EventLifecycleNetworkDeleted)
 	NetworkUpdated = NetworkAction(api.EventLifecycleNetworkUpdated)
 	NetworkRenamed = NetworkAction(api.EventLifecycleNetworkRenamed)
+
+	NetworkEgressAllowed = NetworkAction(api.EventLifecycleNetworkEgressAllowed)
+	NetworkEgressDenied  = NetworkAction(api.EventLifecycleNetworkEgressDenied)
 )
 
 // Event creates the lifecycle event for an action on a network device.
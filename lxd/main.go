@@ -124,6 +124,10 @@ func main() {
 	forkDNSCmd := cmdForkDNS{global: &globalCmd}
 	app.AddCommand(forkDNSCmd.Command())
 
+	// forkdns64 sub-command
+	forkDNS64Cmd := cmdForkDNS64{global: &globalCmd}
+	app.AddCommand(forkDNS64Cmd.Command())
+
 	// forkexec sub-command
 	forkexecCmd := cmdForkexec{global: &globalCmd}
 	app.AddCommand(forkexecCmd.Command())
@@ -152,6 +156,10 @@ func main() {
 	forkmountCmd := cmdForkmount{global: &globalCmd}
 	app.AddCommand(forkmountCmd.Command())
 
+	// forknat64 sub-command
+	forkNAT64Cmd := cmdForkNAT64{global: &globalCmd}
+	app.AddCommand(forkNAT64Cmd.Command())
+
 	// forknet sub-command
 	forknetCmd := cmdForknet{global: &globalCmd}
 	app.AddCommand(forknetCmd.Command())
@@ -168,6 +176,10 @@ func main() {
 	forkueventCmd := cmdForkuevent{global: &globalCmd}
 	app.AddCommand(forkueventCmd.Command())
 
+	// forkvsockproxy sub-command
+	forkvsockproxyCmd := cmdForkvsockproxy{global: &globalCmd}
+	app.AddCommand(forkvsockproxyCmd.Command())
+
 	// forkzfs sub-command
 	forkzfsCmd := cmdForkZFS{global: &globalCmd}
 	app.AddCommand(forkzfsCmd.Command())
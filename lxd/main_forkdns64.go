@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+
+	"github.com/lxc/lxd/lxd/network/nat64"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+type cmdForkDNS64 struct {
+	global *cmdGlobal
+
+	resolvConfLock    sync.Mutex
+	resolvConfModTime time.Time
+	resolvConf        *dns.ClientConfig
+}
+
+func (c *cmdForkDNS64) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkdns64 <listen address> <prefix>"
+	cmd.Short = "Internal DNS64 proxy"
+	cmd.Long = `Description:
+  Spawns a DNS server relaying queries to the host's resolvers which synthesises AAAA records
+  within the NAT64 prefix for names that only have A records.
+  It is used as the upstream server of the dnsmasq process of networks with DNS64 enabled.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkDNS64) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	if len(args) < 2 {
+		_ = cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	err := logger.InitLogger("", "lxd-forkdns64", c.global.flagLogVerbose, c.global.flagLogDebug, nil)
+	if err != nil {
+		return err
+	}
+
+	_, prefix, err := net.ParseCIDR(args[1])
+	if err != nil {
+		return fmt.Errorf("Invalid prefix %q: %w", args[1], err)
+	}
+
+	handler := &nat64.DNS64{Prefix: prefix, Exchange: c.exchange}
+
+	logger.Info("Started")
+
+	errCh := make(chan error, 2)
+	for _, proto := range []string{"udp", "tcp"} {
+		srv := &dns.Server{
+			Addr:    args[0],
+			Net:     proto,
+			Handler: handler,
+		}
+
+		go func(proto string) {
+			err := srv.ListenAndServe()
+			errCh <- fmt.Errorf("Failed to set %s listener: %w", proto, err)
+		}(proto)
+	}
+
+	return <-errCh
+}
+
+// exchange relays the request to the host's resolvers.
+func (c *cmdForkDNS64) exchange(req *dns.Msg) (*dns.Msg, error) {
+	config, err := c.loadResolvConf()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *dns.Msg
+	for _, server := range config.Servers {
+		address := net.JoinHostPort(server, config.Port)
+
+		client := &dns.Client{Net: "udp", Timeout: time.Duration(config.Timeout) * time.Second}
+		resp, _, err = client.Exchange(req, address)
+		if err == nil && resp.Truncated {
+			client.Net = "tcp"
+			resp, _, err = client.Exchange(req, address)
+		}
+
+		if err == nil {
+			return resp, nil
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("No resolvers configured")
+	}
+
+	return nil, err
+}
+
+// loadResolvConf returns the host's resolver config, reloading it if it has changed.
+func (c *cmdForkDNS64) loadResolvConf() (*dns.ClientConfig, error) {
+	c.resolvConfLock.Lock()
+	defer c.resolvConfLock.Unlock()
+
+	fi, err := os.Stat("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+
+	if c.resolvConf == nil || !fi.ModTime().Equal(c.resolvConfModTime) {
+		config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, err
+		}
+
+		c.resolvConf = config
+		c.resolvConfModTime = fi.ModTime()
+	}
+
+	return c.resolvConf, nil
+}
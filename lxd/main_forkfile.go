@@ -11,6 +11,8 @@ import (
 	"github.com/pkg/sftp"
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/filearchive"
 )
 
 /*
@@ -228,13 +230,15 @@ func (c *cmdForkfile) Run(cmd *cobra.Command, args []string) error {
 			connections += 1
 			mu.Unlock()
 
-			// Spawn the server.
-			server, err := sftp.NewServer(conn)
-			if err != nil {
-				return
-			}
+			// Spawn the server (or handle an archive transfer).
+			_ = filearchive.Serve(conn, func(conn net.Conn) error {
+				server, err := sftp.NewServer(conn)
+				if err != nil {
+					return err
+				}
 
-			_ = server.Serve()
+				return server.Serve()
+			})
 
 			// Sync the filesystem.
 			_ = unix.Syncfs(int(rootfsFD))
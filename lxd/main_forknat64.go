@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/network/nat64"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+type cmdForkNAT64 struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkNAT64) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forknat64 <tun device> <prefix> <pool>"
+	cmd.Short = "Internal NAT64 translator"
+	cmd.Long = `Description:
+  Translates the IPv6 packets sent to addresses within the NAT64 prefix to IPv4 packets and back.
+  The packets are read from and written to the specified TUN device which the IPv6 prefix and the
+  IPv4 pool are routed to. Each IPv6 client is mapped to an address of the pool which is expected
+  to be masqueraded by the host.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkNAT64) Run(cmd *cobra.Command, args []string) error {
+	// Quick checks.
+	if len(args) < 3 {
+		_ = cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	err := logger.InitLogger("", "lxd-forknat64", c.global.flagLogVerbose, c.global.flagLogDebug, nil)
+	if err != nil {
+		return err
+	}
+
+	_, prefix, err := net.ParseCIDR(args[1])
+	if err != nil {
+		return fmt.Errorf("Invalid prefix %q: %w", args[1], err)
+	}
+
+	_, pool, err := net.ParseCIDR(args[2])
+	if err != nil {
+		return fmt.Errorf("Invalid pool %q: %w", args[2], err)
+	}
+
+	translator, err := nat64.NewTranslator(prefix, pool, 2*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	tun, err := c.openTun(args[0])
+	if err != nil {
+		return fmt.Errorf("Failed opening TUN device %q: %w", args[0], err)
+	}
+
+	defer func() { _ = tun.Close() }()
+
+	// Release unused mappings.
+	go func() {
+		for range time.Tick(time.Minute) {
+			translator.Expire(time.Now())
+		}
+	}()
+
+	logger.Info("Started")
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := tun.Read(buf)
+		if err != nil {
+			return fmt.Errorf("Failed reading from TUN device: %w", err)
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		var out []byte
+		switch buf[0] >> 4 {
+		case 6:
+			out, err = translator.Translate6to4(buf[:n])
+		case 4:
+			out, err = translator.Translate4to6(buf[:n])
+		default:
+			continue
+		}
+
+		if err != nil {
+			if !errors.Is(err, nat64.ErrUnsupported) {
+				logger.Debug("Failed translating packet", logger.Ctx{"err": err})
+			}
+
+			continue
+		}
+
+		_, err = tun.Write(out)
+		if err != nil {
+			logger.Debug("Failed writing to TUN device", logger.Ctx{"err": err})
+		}
+	}
+}
+
+// openTun attaches to the existing TUN device.
+func (c *cmdForkNAT64) openTun(name string) (*os.File, error) {
+	fd, err := unix.Open("/dev/net/tun", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ifr, err := unix.NewIfreq(name)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	ifr.SetUint16(unix.IFF_TUN | unix.IFF_NO_PI)
+
+	err = unix.IoctlIfreq(fd, unix.TUNSETIFF, ifr)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), "/dev/net/tun"), nil
+}
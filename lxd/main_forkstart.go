@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/kballard/go-shellquote"
 	liblxc "github.com/lxc/go-lxc"
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
@@ -89,5 +90,16 @@ func (c *cmdForkstart) Run(cmd *cobra.Command, args []string) error {
 		_ = unix.Dup3(int(logFile.Fd()), 2, 0)
 	}
 
+	// Application containers (OCI images) get their entrypoint run through LXC's minimal init.
+	entrypoint := d.ConfigItem("lxc.execute.cmd")
+	if len(entrypoint) > 0 && entrypoint[0] != "" {
+		args, err := shellquote.Split(entrypoint[0])
+		if err != nil {
+			return fmt.Errorf("Invalid entrypoint %q: %w", entrypoint[0], err)
+		}
+
+		return d.StartExecute(args)
+	}
+
 	return d.Start()
 }
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/device"
+	deviceConfig "github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/vsock"
+	"github.com/lxc/lxd/shared"
+)
+
+type cmdForkvsockproxy struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkvsockproxy) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkvsockproxy <listen address> <connect address> <vsock ID> <agent path> <listen gid> <listen uid> <listen mode> <security gid> <security uid> <proxy protocol>"
+	cmd.Short = "Setup network connection proxying to a VM through its agent"
+	cmd.Long = `Description:
+  Setup network connection proxying to a VM through its agent
+
+  This internal command will spawn a new proxy process for a particular
+  virtual machine, listening on the host and connecting to the virtual
+  machine through the lxd-agent over vsock.
+`
+	cmd.Args = cobra.ExactArgs(10)
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkvsockproxy) Run(cmd *cobra.Command, args []string) error {
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	lAddr, err := device.ProxyParseAddr(args[0])
+	if err != nil {
+		return err
+	}
+
+	cAddr, err := device.ProxyParseAddr(args[1])
+	if err != nil {
+		return err
+	}
+
+	vsockID, err := strconv.Atoi(args[2])
+	if err != nil {
+		return fmt.Errorf("Invalid vsock ID %q: %w", args[2], err)
+	}
+
+	// The connection uses mutual authentication, so use the VM's agent certificates.
+	certs := map[string]string{}
+	for _, name := range []string{"agent.crt", "agent-client.crt", "agent-client.key"} {
+		content, err := os.ReadFile(filepath.Join(args[3], name))
+		if err != nil {
+			return err
+		}
+
+		certs[name] = string(content)
+	}
+
+	client, err := vsock.HTTPClient(vsockID, shared.HTTPSDefaultPort, certs["agent-client.crt"], certs["agent-client.key"], certs["agent.crt"])
+	if err != nil {
+		return err
+	}
+
+	// The proxy is started along with the VM, long before its agent is listening, so only connect to the agent
+	// when relaying connections.
+	agent, err := lxd.ConnectLXDHTTP(&lxd.ConnectionArgs{SkipGetServer: true}, client)
+	if err != nil {
+		return err
+	}
+
+	if lAddr.ConnType == "unix" && !lAddr.Abstract {
+		err := os.Remove(lAddr.Address)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	var listenAddresses []string
+	if lAddr.ConnType == "unix" {
+		listenAddresses = []string{lAddr.Address}
+	} else {
+		listenAddresses = make([]string, 0, len(lAddr.Ports))
+		for _, port := range lAddr.Ports {
+			listenAddresses = append(listenAddresses, net.JoinHostPort(lAddr.Address, fmt.Sprintf("%d", port)))
+		}
+	}
+
+	listeners := make([]net.Listener, 0, len(listenAddresses))
+	for _, listenAddress := range listenAddresses {
+		listener, err := tryListen(lAddr.ConnType, listenAddress)
+		if err != nil {
+			return fmt.Errorf("Failed to listen on %s: %w", listenAddress, err)
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	if lAddr.ConnType == "unix" && !lAddr.Abstract {
+		defer func() { _ = os.Remove(lAddr.Address) }()
+
+		listenAddrGID := -1
+		if args[4] != "" {
+			listenAddrGID, err = strconv.Atoi(args[4])
+			if err != nil {
+				return err
+			}
+		}
+
+		listenAddrUID := -1
+		if args[5] != "" {
+			listenAddrUID, err = strconv.Atoi(args[5])
+			if err != nil {
+				return err
+			}
+		}
+
+		if listenAddrGID != -1 || listenAddrUID != -1 {
+			err = os.Chown(lAddr.Address, listenAddrUID, listenAddrGID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if args[6] != "" {
+			mode, err := strconv.ParseUint(args[6], 8, 0)
+			if err != nil {
+				return err
+			}
+
+			err = os.Chmod(lAddr.Address, os.FileMode(mode))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Drop privilege if requested
+	gid := uint64(0)
+	if args[7] != "" {
+		gid, err = strconv.ParseUint(args[7], 10, 32)
+		if err != nil {
+			return err
+		}
+	}
+
+	uid := uint64(0)
+	if args[8] != "" {
+		uid, err = strconv.ParseUint(args[8], 10, 32)
+		if err != nil {
+			return err
+		}
+	}
+
+	if uid != 0 || gid != 0 {
+		err = unix.Setgid(int(gid))
+		if err == nil {
+			err = unix.Setuid(int(uid))
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to switch to uid %d and gid %d: %w", uid, gid, err)
+		}
+	}
+
+	// Handle SIGTERM which is sent when the proxy is to be removed
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGTERM)
+
+	for i, listener := range listeners {
+		go vsockProxyAccept(agent, listener, lAddr, cAddr, i, args[9] == "true")
+	}
+
+	// This line is used by LXD to check forkvsockproxy has started OK.
+	fmt.Println("Status: Started")
+
+	<-sigs
+
+	for _, listener := range listeners {
+		_ = listener.Close()
+	}
+
+	fmt.Printf("Status: Stopping proxy\n")
+	return nil
+}
+
+// vsockProxyAccept accepts the connections on the listener and relays each of them to the connect address through
+// the agent. The connect port is picked the same way as forkproxy does for multiple listen ports.
+func vsockProxyAccept(agent lxd.InstanceServer, listener net.Listener, lAddr *deviceConfig.ProxyAddress, cAddr *deviceConfig.ProxyAddress, lAddrIndex int, proxy bool) {
+	connectAddr := fmt.Sprintf("%s:%s", cAddr.ConnType, cAddr.Address)
+	if cAddr.ConnType != "unix" {
+		connectPort := cAddr.Ports[0]
+		if lAddr.ConnType != "unix" && len(cAddr.Ports) > 1 {
+			// multiple port -> multiple port
+			connectPort = cAddr.Ports[lAddrIndex]
+		}
+
+		connectAddr = fmt.Sprintf("%s:%s", cAddr.ConnType, net.JoinHostPort(cAddr.Address, fmt.Sprintf("%d", connectPort)))
+	}
+
+	for {
+		srcConn, err := listener.Accept()
+		if err != nil {
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				return
+			}
+
+			fmt.Printf("Warning: Failed to accept new connection: %v\n", err)
+			continue
+		}
+
+		go func() {
+			err := vsockProxyRelay(agent, srcConn, lAddr, cAddr, connectAddr, proxy)
+			if err != nil {
+				fmt.Printf("Warning: Failed to connect to target: %v\n", err)
+			}
+		}()
+	}
+}
+
+// vsockProxyRelay connects to connectAddr through the agent and relays srcConn to it until either side is done.
+func vsockProxyRelay(agent lxd.InstanceServer, srcConn net.Conn, lAddr *deviceConfig.ProxyAddress, cAddr *deviceConfig.ProxyAddress, connectAddr string, proxy bool) error {
+	defer func() { _ = srcConn.Close() }()
+
+	conn, err := agent.RawWebsocket(fmt.Sprintf("/proxy?connect=%s", url.QueryEscape(connectAddr)))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	if proxy && cAddr.ConnType == "tcp" {
+		header := "PROXY UNKNOWN\r\n"
+		if lAddr.ConnType != "unix" {
+			cHost, cPort, err := net.SplitHostPort(srcConn.RemoteAddr().String())
+			if err != nil {
+				return err
+			}
+
+			dHost, dPort, err := net.SplitHostPort(srcConn.LocalAddr().String())
+			if err != nil {
+				return err
+			}
+
+			proto := strings.ToUpper(srcConn.LocalAddr().Network())
+			if strings.Contains(cHost, ":") {
+				proto = fmt.Sprintf("%s6", proto)
+			} else {
+				proto = fmt.Sprintf("%s4", proto)
+			}
+
+			header = fmt.Sprintf("PROXY %s %s %s %s %s\r\n", proto, cHost, dHost, cPort, dPort)
+		}
+
+		err = conn.WriteMessage(websocket.BinaryMessage, []byte(header))
+		if err != nil {
+			return err
+		}
+	}
+
+	readDone, writeDone := shared.WebsocketMirror(conn, srcConn, srcConn, nil, nil)
+
+	// Stop relaying as soon as either side is done.
+	select {
+	case <-readDone:
+	case <-writeDone:
+	}
+
+	_ = srcConn.Close()
+	_ = conn.Close()
+
+	<-readDone
+	<-writeDone
+
+	return nil
+}
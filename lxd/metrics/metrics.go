@@ -48,6 +48,20 @@ func (m *MetricSet) Merge(metricSet *MetricSet) {
 	}
 }
 
+// Sum returns the sum of the values of the samples of the type metricType, only considering the samples whose labels
+// are accepted by the filter if not nil.
+func (m *MetricSet) Sum(metricType MetricType, filter func(labels map[string]string) bool) float64 {
+	var sum float64
+
+	for _, sample := range m.set[metricType] {
+		if filter == nil || filter(sample.Labels) {
+			sum += sample.Value
+		}
+	}
+
+	return sum
+}
+
 func (m *MetricSet) String() string {
 	var out strings.Builder
 	metricTypes := []MetricType{}
@@ -86,86 +86,98 @@ const (
 	NetworkTransmitErrsTotal
 	// NetworkTransmitPacketsTotal represents the amount of transmitted packets on a given interface.
 	NetworkTransmitPacketsTotal
+	// NetworkTransferReceiveBytesTotal represents the amount of received bytes accounted for on a given NIC.
+	NetworkTransferReceiveBytesTotal
+	// NetworkTransferTransmitBytesTotal represents the amount of transmitted bytes accounted for on a given NIC.
+	NetworkTransferTransmitBytesTotal
+	// NetworkTransferPeriodBytes represents the amount of bytes transferred on a given NIC in the current billing period.
+	NetworkTransferPeriodBytes
 	// ProcsTotal represents the number of running processes.
 	ProcsTotal
 )
 
 // MetricNames associates a metric type to its name.
 var MetricNames = map[MetricType]string{
-	CPUSecondsTotal:             "lxd_cpu_seconds_total",
-	DiskReadBytesTotal:          "lxd_disk_read_bytes_total",
-	DiskReadsCompletedTotal:     "lxd_disk_reads_completed_total",
-	DiskWrittenBytesTotal:       "lxd_disk_written_bytes_total",
-	DiskWritesCompletedTotal:    "lxd_disk_writes_completed_total",
-	FilesystemAvailBytes:        "lxd_filesystem_avail_bytes",
-	FilesystemFreeBytes:         "lxd_filesystem_free_bytes",
-	FilesystemSizeBytes:         "lxd_filesystem_size_bytes",
-	MemoryActiveAnonBytes:       "lxd_memory_Active_anon_bytes",
-	MemoryActiveFileBytes:       "lxd_memory_Active_file_bytes",
-	MemoryActiveBytes:           "lxd_memory_Active_bytes",
-	MemoryCachedBytes:           "lxd_memory_Cached_bytes",
-	MemoryDirtyBytes:            "lxd_memory_Dirty_bytes",
-	MemoryHugePagesFreeBytes:    "lxd_memory_HugepagesFree_bytes",
-	MemoryHugePagesTotalBytes:   "lxd_memory_HugepagesTotal_bytes",
-	MemoryInactiveAnonBytes:     "lxd_memory_Inactive_anon_bytes",
-	MemoryInactiveFileBytes:     "lxd_memory_Inactive_file_bytes",
-	MemoryInactiveBytes:         "lxd_memory_Inactive_bytes",
-	MemoryMappedBytes:           "lxd_memory_Mapped_bytes",
-	MemoryMemAvailableBytes:     "lxd_memory_MemAvailable_bytes",
-	MemoryMemFreeBytes:          "lxd_memory_MemFree_bytes",
-	MemoryMemTotalBytes:         "lxd_memory_MemTotal_bytes",
-	MemoryRSSBytes:              "lxd_memory_RSS_bytes",
-	MemoryShmemBytes:            "lxd_memory_Shmem_bytes",
-	MemorySwapBytes:             "lxd_memory_Swap_bytes",
-	MemoryUnevictableBytes:      "lxd_memory_Unevictable_bytes",
-	MemoryWritebackBytes:        "lxd_memory_Writeback_bytes",
-	NetworkReceiveBytesTotal:    "lxd_network_receive_bytes_total",
-	NetworkReceiveDropTotal:     "lxd_network_receive_drop_total",
-	NetworkReceiveErrsTotal:     "lxd_network_receive_errs_total",
-	NetworkReceivePacketsTotal:  "lxd_network_receive_packets_total",
-	NetworkTransmitBytesTotal:   "lxd_network_transmit_bytes_total",
-	NetworkTransmitDropTotal:    "lxd_network_transmit_drop_total",
-	NetworkTransmitErrsTotal:    "lxd_network_transmit_errs_total",
-	NetworkTransmitPacketsTotal: "lxd_network_transmit_packets_total",
-	ProcsTotal:                  "lxd_procs_total",
+	CPUSecondsTotal:                   "lxd_cpu_seconds_total",
+	DiskReadBytesTotal:                "lxd_disk_read_bytes_total",
+	DiskReadsCompletedTotal:           "lxd_disk_reads_completed_total",
+	DiskWrittenBytesTotal:             "lxd_disk_written_bytes_total",
+	DiskWritesCompletedTotal:          "lxd_disk_writes_completed_total",
+	FilesystemAvailBytes:              "lxd_filesystem_avail_bytes",
+	FilesystemFreeBytes:               "lxd_filesystem_free_bytes",
+	FilesystemSizeBytes:               "lxd_filesystem_size_bytes",
+	MemoryActiveAnonBytes:             "lxd_memory_Active_anon_bytes",
+	MemoryActiveFileBytes:             "lxd_memory_Active_file_bytes",
+	MemoryActiveBytes:                 "lxd_memory_Active_bytes",
+	MemoryCachedBytes:                 "lxd_memory_Cached_bytes",
+	MemoryDirtyBytes:                  "lxd_memory_Dirty_bytes",
+	MemoryHugePagesFreeBytes:          "lxd_memory_HugepagesFree_bytes",
+	MemoryHugePagesTotalBytes:         "lxd_memory_HugepagesTotal_bytes",
+	MemoryInactiveAnonBytes:           "lxd_memory_Inactive_anon_bytes",
+	MemoryInactiveFileBytes:           "lxd_memory_Inactive_file_bytes",
+	MemoryInactiveBytes:               "lxd_memory_Inactive_bytes",
+	MemoryMappedBytes:                 "lxd_memory_Mapped_bytes",
+	MemoryMemAvailableBytes:           "lxd_memory_MemAvailable_bytes",
+	MemoryMemFreeBytes:                "lxd_memory_MemFree_bytes",
+	MemoryMemTotalBytes:               "lxd_memory_MemTotal_bytes",
+	MemoryRSSBytes:                    "lxd_memory_RSS_bytes",
+	MemoryShmemBytes:                  "lxd_memory_Shmem_bytes",
+	MemorySwapBytes:                   "lxd_memory_Swap_bytes",
+	MemoryUnevictableBytes:            "lxd_memory_Unevictable_bytes",
+	MemoryWritebackBytes:              "lxd_memory_Writeback_bytes",
+	NetworkReceiveBytesTotal:          "lxd_network_receive_bytes_total",
+	NetworkReceiveDropTotal:           "lxd_network_receive_drop_total",
+	NetworkReceiveErrsTotal:           "lxd_network_receive_errs_total",
+	NetworkReceivePacketsTotal:        "lxd_network_receive_packets_total",
+	NetworkTransmitBytesTotal:         "lxd_network_transmit_bytes_total",
+	NetworkTransmitDropTotal:          "lxd_network_transmit_drop_total",
+	NetworkTransmitErrsTotal:          "lxd_network_transmit_errs_total",
+	NetworkTransmitPacketsTotal:       "lxd_network_transmit_packets_total",
+	NetworkTransferReceiveBytesTotal:  "lxd_network_transfer_receive_bytes_total",
+	NetworkTransferTransmitBytesTotal: "lxd_network_transfer_transmit_bytes_total",
+	NetworkTransferPeriodBytes:        "lxd_network_transfer_period_bytes",
+	ProcsTotal:                        "lxd_procs_total",
 }
 
 // MetricHeaders represents the metric headers which contain help messages as specified by OpenMetrics.
 var MetricHeaders = map[MetricType]string{
-	CPUSecondsTotal:             "# HELP lxd_cpu_seconds_total The total number of CPU seconds used in milliseconds.",
-	DiskReadBytesTotal:          "# HELP lxd_disk_read_bytes_total The total number of bytes read.",
-	DiskReadsCompletedTotal:     "# HELP lxd_disk_reads_completed_total The total number of completed reads.",
-	DiskWrittenBytesTotal:       "# HELP lxd_disk_written_bytes_total The total number of bytes written.",
-	DiskWritesCompletedTotal:    "# HELP lxd_disk_writes_completed_total The total number of completed writes.",
-	FilesystemAvailBytes:        "# HELP lxd_filesystem_avail_bytes The number of available space in bytes.",
-	FilesystemFreeBytes:         "# HELP lxd_filesystem_free_bytes The number of free space in bytes.",
-	FilesystemSizeBytes:         "# HELP lxd_filesystem_size_bytes The size of the filesystem in bytes.",
-	MemoryActiveAnonBytes:       "# HELP lxd_memory_Active_anon_bytes The amount of anonymous memory on active LRU list.",
-	MemoryActiveFileBytes:       "# HELP lxd_memory_Active_file_bytes The amount of file-backed memory on active LRU list.",
-	MemoryActiveBytes:           "# HELP lxd_memory_Active_bytes The amount of memory on active LRU list.",
-	MemoryCachedBytes:           "# HELP lxd_memory_Cached_bytes The amount of cached memory.",
-	MemoryDirtyBytes:            "# HELP lxd_memory_Dirty_bytes The amount of memory waiting to get written back to the disk.",
-	MemoryHugePagesFreeBytes:    "# HELP lxd_memory_HugepagesFree_bytes The amount of free memory for hugetlb.",
-	MemoryHugePagesTotalBytes:   "# HELP lxd_memory_HugepagesTotal_bytes The amount of used memory for hugetlb.",
-	MemoryInactiveAnonBytes:     "# HELP lxd_memory_Inactive_anon_bytes The amount of file-backed memory on inactive LRU list.",
-	MemoryInactiveFileBytes:     "# HELP lxd_memory_Inactive_file_bytes The amount of file-backed memory on inactive LRU list.",
-	MemoryInactiveBytes:         "# HELP lxd_memory_Inactive_bytes The amount of memory on inactive LRU list.",
-	MemoryMappedBytes:           "# HELP lxd_memory_Mapped_bytes The amount of mapped memory.",
-	MemoryMemAvailableBytes:     "# HELP lxd_memory_MemAvailable_bytes The amount of available memory.",
-	MemoryMemFreeBytes:          "# HELP lxd_memory_MemFree_bytes The amount of free memory.",
-	MemoryMemTotalBytes:         "# HELP lxd_memory_MemTotal_bytes The amount of used memory.",
-	MemoryRSSBytes:              "# HELP lxd_memory_RSS_bytes The amount of anonymous and swap cache memory.",
-	MemoryShmemBytes:            "# HELP lxd_memory_Shmem_bytes The amount of cached filesystem data that is swap-backed.",
-	MemorySwapBytes:             "# HELP lxd_memory_Swap_bytes The amount of used swap memory.",
-	MemoryUnevictableBytes:      "# HELP lxd_memory_Unevictable_bytes The amount of unevictable memory.",
-	MemoryWritebackBytes:        "# HELP lxd_memory_Writeback_bytes The amount of memory queued for syncing to disk.",
-	NetworkReceiveBytesTotal:    "# HELP lxd_network_receive_bytes_total The amount of received bytes on a given interface.",
-	NetworkReceiveDropTotal:     "# HELP lxd_network_receive_drop_total The amount of received dropped bytes on a given interface.",
-	NetworkReceiveErrsTotal:     "# HELP lxd_network_receive_errs_total The amount of received errors on a given interface.",
-	NetworkReceivePacketsTotal:  "# HELP lxd_network_receive_packets_total The amount of received packets on a given interface.",
-	NetworkTransmitBytesTotal:   "# HELP lxd_network_transmit_bytes_total The amount of transmitted bytes on a given interface.",
-	NetworkTransmitDropTotal:    "# HELP lxd_network_transmit_drop_total The amount of transmitted dropped bytes on a given interface.",
-	NetworkTransmitErrsTotal:    "# HELP lxd_network_transmit_errs_total The amount of transmitted errors on a given interface.",
-	NetworkTransmitPacketsTotal: "# HELP lxd_network_transmit_packets_total The amount of transmitted packets on a given interface.",
-	ProcsTotal:                  "# HELP lxd_procs_total The number of running processes.",
+	CPUSecondsTotal:                   "# HELP lxd_cpu_seconds_total The total number of CPU seconds used in milliseconds.",
+	DiskReadBytesTotal:                "# HELP lxd_disk_read_bytes_total The total number of bytes read.",
+	DiskReadsCompletedTotal:           "# HELP lxd_disk_reads_completed_total The total number of completed reads.",
+	DiskWrittenBytesTotal:             "# HELP lxd_disk_written_bytes_total The total number of bytes written.",
+	DiskWritesCompletedTotal:          "# HELP lxd_disk_writes_completed_total The total number of completed writes.",
+	FilesystemAvailBytes:              "# HELP lxd_filesystem_avail_bytes The number of available space in bytes.",
+	FilesystemFreeBytes:               "# HELP lxd_filesystem_free_bytes The number of free space in bytes.",
+	FilesystemSizeBytes:               "# HELP lxd_filesystem_size_bytes The size of the filesystem in bytes.",
+	MemoryActiveAnonBytes:             "# HELP lxd_memory_Active_anon_bytes The amount of anonymous memory on active LRU list.",
+	MemoryActiveFileBytes:             "# HELP lxd_memory_Active_file_bytes The amount of file-backed memory on active LRU list.",
+	MemoryActiveBytes:                 "# HELP lxd_memory_Active_bytes The amount of memory on active LRU list.",
+	MemoryCachedBytes:                 "# HELP lxd_memory_Cached_bytes The amount of cached memory.",
+	MemoryDirtyBytes:                  "# HELP lxd_memory_Dirty_bytes The amount of memory waiting to get written back to the disk.",
+	MemoryHugePagesFreeBytes:          "# HELP lxd_memory_HugepagesFree_bytes The amount of free memory for hugetlb.",
+	MemoryHugePagesTotalBytes:         "# HELP lxd_memory_HugepagesTotal_bytes The amount of used memory for hugetlb.",
+	MemoryInactiveAnonBytes:           "# HELP lxd_memory_Inactive_anon_bytes The amount of file-backed memory on inactive LRU list.",
+	MemoryInactiveFileBytes:           "# HELP lxd_memory_Inactive_file_bytes The amount of file-backed memory on inactive LRU list.",
+	MemoryInactiveBytes:               "# HELP lxd_memory_Inactive_bytes The amount of memory on inactive LRU list.",
+	MemoryMappedBytes:                 "# HELP lxd_memory_Mapped_bytes The amount of mapped memory.",
+	MemoryMemAvailableBytes:           "# HELP lxd_memory_MemAvailable_bytes The amount of available memory.",
+	MemoryMemFreeBytes:                "# HELP lxd_memory_MemFree_bytes The amount of free memory.",
+	MemoryMemTotalBytes:               "# HELP lxd_memory_MemTotal_bytes The amount of used memory.",
+	MemoryRSSBytes:                    "# HELP lxd_memory_RSS_bytes The amount of anonymous and swap cache memory.",
+	MemoryShmemBytes:                  "# HELP lxd_memory_Shmem_bytes The amount of cached filesystem data that is swap-backed.",
+	MemorySwapBytes:                   "# HELP lxd_memory_Swap_bytes The amount of used swap memory.",
+	MemoryUnevictableBytes:            "# HELP lxd_memory_Unevictable_bytes The amount of unevictable memory.",
+	MemoryWritebackBytes:              "# HELP lxd_memory_Writeback_bytes The amount of memory queued for syncing to disk.",
+	NetworkReceiveBytesTotal:          "# HELP lxd_network_receive_bytes_total The amount of received bytes on a given interface.",
+	NetworkReceiveDropTotal:           "# HELP lxd_network_receive_drop_total The amount of received dropped bytes on a given interface.",
+	NetworkReceiveErrsTotal:           "# HELP lxd_network_receive_errs_total The amount of received errors on a given interface.",
+	NetworkReceivePacketsTotal:        "# HELP lxd_network_receive_packets_total The amount of received packets on a given interface.",
+	NetworkTransmitBytesTotal:         "# HELP lxd_network_transmit_bytes_total The amount of transmitted bytes on a given interface.",
+	NetworkTransmitDropTotal:          "# HELP lxd_network_transmit_drop_total The amount of transmitted dropped bytes on a given interface.",
+	NetworkTransmitErrsTotal:          "# HELP lxd_network_transmit_errs_total The amount of transmitted errors on a given interface.",
+	NetworkTransmitPacketsTotal:       "# HELP lxd_network_transmit_packets_total The amount of transmitted packets on a given interface.",
+	NetworkTransferReceiveBytesTotal:  "# HELP lxd_network_transfer_receive_bytes_total The amount of received bytes accounted for on a given NIC.",
+	NetworkTransferTransmitBytesTotal: "# HELP lxd_network_transfer_transmit_bytes_total The amount of transmitted bytes accounted for on a given NIC.",
+	NetworkTransferPeriodBytes:        "# HELP lxd_network_transfer_period_bytes The amount of bytes transferred on a given NIC in the current billing period.",
+	ProcsTotal:                        "# HELP lxd_procs_total The number of running processes.",
 }
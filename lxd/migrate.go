@@ -147,6 +147,7 @@ type migrationSourceWs struct {
 	migrationFields
 
 	allConnected chan struct{}
+	clusterMove  bool // Sending an instance whose DB record is shared with the target cluster member.
 }
 
 func (s *migrationSourceWs) Metadata() any {
@@ -291,6 +292,7 @@ type migrationSink struct {
 	allConnected chan struct{}
 	push         bool
 	refresh      bool
+	clusterMove  bool
 }
 
 // MigrationSinkArgs arguments to configure migration sink.
@@ -308,6 +310,7 @@ type MigrationSinkArgs struct {
 	Live         bool
 	Refresh      bool
 	Snapshots    []*migration.Snapshot
+	ClusterMove  bool // Receiving an instance whose DB record is shared with the source cluster member.
 
 	// Storage specific fields
 	VolumeOnly bool
@@ -375,7 +378,7 @@ func (s *migrationSink) Connect(op *operations.Operation, r *http.Request, w htt
 	*conn = c
 
 	// Check criteria for considering all channels to be connected.
-	if s.src.instance != nil && s.src.instance.Type() == instancetype.Container && s.dest.live && s.dest.criuConn == nil {
+	if s.src.instance != nil && s.dest.live && s.dest.criuConn == nil {
 		return nil
 	}
 
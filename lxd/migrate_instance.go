@@ -61,11 +61,12 @@ func newMigrationSource(inst instance.Instance, stateful bool, instanceOnly bool
 			if err != nil {
 				return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the source server")
 			}
+		}
 
-			ret.criuSecret, err = shared.RandomCryptoString()
-			if err != nil {
-				return nil, err
-			}
+		// For VMs the state is sent over the same connection as for containers.
+		ret.criuSecret, err = shared.RandomCryptoString()
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -445,6 +446,12 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 		offerHeader.Predump = proto.Bool(offerUsePreDumps)
 	}
 
+	// Offer to live migrate running VMs, targets not supporting it will respond with the CRIU type they
+	// expect for a stateful stop instead.
+	if s.instance.Type() == instancetype.VM && s.live {
+		offerHeader.Criu = migration.CRIUType_VM_QEMU.Enum()
+	}
+
 	srcConfig, err := pool.GenerateInstanceBackupConfig(s.instance, !s.instanceOnly, migrateOp)
 	if err != nil {
 		return abort(fmt.Errorf("Failed generating instance migration config: %w", err))
@@ -536,6 +543,51 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 		volSourceArgs.MultiSync = s.live || (respHeader.Criu != nil && *respHeader.Criu == migration.CRIUType_NONE)
 	}
 
+	if s.instance.Type() == instancetype.VM && s.live && respHeader.GetCriu() == migration.CRIUType_VM_QEMU {
+		if s.criuConn == nil {
+			return abort(fmt.Errorf("Got no state connection for VM live migration"))
+		}
+
+		// The root disk writes are redirected into an overlay while its volume is sent, so the volume
+		// can be sent while the VM is running.
+		volSourceArgs.AllowInconsistent = true
+
+		liveMigrateArgs := instance.LiveMigrateArgs{
+			StateConn:   &shared.WebsocketIO{Conn: s.criuConn},
+			DiskConn:    &shared.WebsocketIO{Conn: s.fsConn},
+			ClusterMove: s.clusterMove,
+			TransferVolume: func() error {
+				return pool.MigrateInstance(s.instance, &shared.WebsocketIO{Conn: s.fsConn}, volSourceArgs, migrateOp)
+			},
+			Complete: func() error {
+				msg := migration.MigrationControl{}
+				err := s.recv(&msg)
+				if err != nil {
+					return err
+				}
+
+				if !msg.GetSuccess() {
+					return fmt.Errorf(msg.GetMessage())
+				}
+
+				return nil
+			},
+		}
+
+		// During a cluster move on shared storage the target member already has access to the volume.
+		if s.clusterMove && pool.Driver().Info().Remote {
+			liveMigrateArgs.DiskConn = nil
+			liveMigrateArgs.TransferVolume = func() error { return nil }
+		}
+
+		err = s.instance.(instance.VM).LiveMigrateSend(liveMigrateArgs)
+		if err != nil {
+			return abort(fmt.Errorf("Failed live migrating instance: %w", err))
+		}
+
+		return nil
+	}
+
 	if s.instance.Type() == instancetype.VM && s.live {
 		err = s.instance.Stop(true)
 		if err != nil {
@@ -766,14 +818,33 @@ func (s *migrationSourceWs) Do(state *state.State, migrateOp *operations.Operati
 	return nil
 }
 
+// migrationSinkCRIUType returns the CRIU type the sink responds with to the source's offer. Running VMs are live
+// migrated when the source offers it, otherwise the sink expects the state to be sent along with the volume.
+func migrationSinkCRIUType(offerHeader *migration.MigrationHeader, live bool, instanceType instancetype.Type) *migration.CRIUType {
+	if offerHeader.Criu != nil && *offerHeader.Criu == migration.CRIUType_NONE {
+		return migration.CRIUType_NONE.Enum()
+	}
+
+	if offerHeader.GetCriu() == migration.CRIUType_VM_QEMU && live && instanceType == instancetype.VM {
+		return migration.CRIUType_VM_QEMU.Enum()
+	}
+
+	if !live {
+		return nil
+	}
+
+	return migration.CRIUType_CRIU_RSYNC.Enum()
+}
+
 func newMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 	sink := migrationSink{
-		src:     migrationFields{instance: args.Instance, instanceOnly: args.InstanceOnly},
-		dest:    migrationFields{instanceOnly: args.InstanceOnly},
-		url:     args.URL,
-		dialer:  args.Dialer,
-		push:    args.Push,
-		refresh: args.Refresh,
+		src:         migrationFields{instance: args.Instance, instanceOnly: args.InstanceOnly},
+		dest:        migrationFields{instanceOnly: args.InstanceOnly},
+		url:         args.URL,
+		dialer:      args.Dialer,
+		push:        args.Push,
+		refresh:     args.Refresh,
+		clusterMove: args.ClusterMove,
 	}
 
 	if sink.push {
@@ -856,6 +927,16 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 
 		defer c.src.disconnect()
 
+		// The source of a VM live migration doesn't wait for the state connection, so connect it before
+		// the fs connection.
+		if c.src.criuSecret != "" && c.src.instance.Type() == instancetype.VM {
+			c.src.criuConn, err = c.connectWithSecret(c.src.criuSecret)
+			if err != nil {
+				c.src.sendControl(err)
+				return err
+			}
+		}
+
 		c.src.fsConn, err = c.connectWithSecret(c.src.fsSecret)
 		if err != nil {
 			c.src.sendControl(err)
@@ -902,14 +983,7 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 		live = c.dest.live
 	}
 
-	criuType := migration.CRIUType_CRIU_RSYNC.Enum()
-	if offerHeader.Criu != nil && *offerHeader.Criu == migration.CRIUType_NONE {
-		criuType = migration.CRIUType_NONE.Enum()
-	} else {
-		if !live {
-			criuType = nil
-		}
-	}
+	criuType := migrationSinkCRIUType(offerHeader, live, c.src.instance.Type())
 
 	// The function that will be executed to receive the sender's migration data.
 	var myTarget func(conn *websocket.Conn, op *operations.Operation, args MigrationSinkArgs) error
@@ -1015,7 +1089,10 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 
 		// Only delete entire instance on error if the pool volume creation has succeeded to avoid
 		// deleting an existing conflicting volume.
-		if !volTargetArgs.Refresh {
+		if !volTargetArgs.Refresh && c.clusterMove {
+			// The instance record is still used by the source cluster member, only delete the volume.
+			revert.Add(func() { _ = pool.DeleteInstance(args.Instance, nil) })
+		} else if !volTargetArgs.Refresh {
 			revert.Add(func() { _ = args.Instance.Delete(true) })
 		}
 
@@ -1167,6 +1244,12 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 				VolumeSize:    offerHeader.GetVolumeSize(), // Block size setting override.
 			}
 
+			// During a cluster move on shared storage the volume is already there, only its mount point is needed.
+			if c.clusterMove && pool.Driver().Info().Remote {
+				fsTransfer <- pool.ImportInstance(c.src.instance, nil, migrateOp)
+				return
+			}
+
 			err = myTarget(fsConn, migrateOp, args)
 			if err != nil {
 				fsTransfer <- err
@@ -1278,7 +1361,31 @@ func (c *migrationSink) Do(state *state.State, revert *revert.Reverter, migrateO
 				}
 			}
 
-			if c.src.instance.Type() == instancetype.VM {
+			if c.src.instance.Type() == instancetype.VM && *criuType == migration.CRIUType_VM_QEMU {
+				var stateConn, fsConn *websocket.Conn
+				if c.push {
+					stateConn = c.dest.criuConn
+					fsConn = c.dest.fsConn
+				} else {
+					stateConn = c.src.criuConn
+					fsConn = c.src.fsConn
+				}
+
+				liveMigrateArgs := instance.LiveMigrateArgs{
+					StateConn: &shared.WebsocketIO{Conn: stateConn},
+					DiskConn:  &shared.WebsocketIO{Conn: fsConn},
+				}
+
+				if c.clusterMove && pool.Driver().Info().Remote {
+					liveMigrateArgs.DiskConn = nil
+				}
+
+				err = c.src.instance.(instance.VM).LiveMigrateReceive(liveMigrateArgs)
+				if err != nil {
+					restore <- err
+					return
+				}
+			} else if c.src.instance.Type() == instancetype.VM {
 				err = c.src.instance.Migrate(nil)
 				if err != nil {
 					restore <- err
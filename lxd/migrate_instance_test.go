@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/instance/instancetype"
+	"github.com/lxc/lxd/lxd/migration"
+)
+
+func TestMigrationSinkCRIUType(t *testing.T) {
+	tests := []struct {
+		name         string
+		offer        *migration.CRIUType
+		live         bool
+		instanceType instancetype.Type
+		expected     *migration.CRIUType
+	}{
+		{"stopped container", nil, false, instancetype.Container, nil},
+		{"running container without state", migration.CRIUType_NONE.Enum(), false, instancetype.Container, migration.CRIUType_NONE.Enum()},
+		{"live container", migration.CRIUType_CRIU_RSYNC.Enum(), true, instancetype.Container, migration.CRIUType_CRIU_RSYNC.Enum()},
+		{"stopped VM", nil, false, instancetype.VM, nil},
+		{"live VM", migration.CRIUType_VM_QEMU.Enum(), true, instancetype.VM, migration.CRIUType_VM_QEMU.Enum()},
+		{"stateful VM from older source", nil, true, instancetype.VM, migration.CRIUType_CRIU_RSYNC.Enum()},
+		{"live VM offer without live sink", migration.CRIUType_VM_QEMU.Enum(), false, instancetype.VM, nil},
+		{"live VM offer for a container", migration.CRIUType_VM_QEMU.Enum(), true, instancetype.Container, migration.CRIUType_CRIU_RSYNC.Enum()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offer := &migration.MigrationHeader{Criu: test.offer}
+			assert.Equal(t, test.expected, migrationSinkCRIUType(offer, test.live, test.instanceType))
+		})
+	}
+}
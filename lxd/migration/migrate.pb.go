@@ -94,6 +94,7 @@ const (
 	CRIUType_CRIU_RSYNC CRIUType = 0
 	CRIUType_PHAUL      CRIUType = 1
 	CRIUType_NONE       CRIUType = 2
+	CRIUType_VM_QEMU    CRIUType = 3
 )
 
 // Enum value maps for CRIUType.
@@ -102,12 +103,14 @@ var (
 		0: "CRIU_RSYNC",
 		1: "PHAUL",
 		2: "NONE",
+		3: "VM_QEMU",
 	}
 
 	CRIUType_value = map[string]int32{
 		"CRIU_RSYNC": 0,
 		"PHAUL":      1,
 		"NONE":       2,
+		"VM_QEMU":    3,
 	}
 )
 
@@ -1395,11 +1398,12 @@ var file_lxd_migration_migrate_proto_rawDesc = []byte{
 	0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x42, 0x54, 0x52, 0x46, 0x53, 0x10, 0x01, 0x12, 0x07, 0x0a,
 	0x03, 0x5a, 0x46, 0x53, 0x10, 0x02, 0x12, 0x07, 0x0a, 0x03, 0x52, 0x42, 0x44, 0x10, 0x03, 0x12,
 	0x13, 0x0a, 0x0f, 0x42, 0x4c, 0x4f, 0x43, 0x4b, 0x5f, 0x41, 0x4e, 0x44, 0x5f, 0x52, 0x53, 0x59,
-	0x4e, 0x43, 0x10, 0x04, 0x2a, 0x2f, 0x0a, 0x08, 0x43, 0x52, 0x49, 0x55, 0x54, 0x79, 0x70, 0x65,
+	0x4e, 0x43, 0x10, 0x04, 0x2a, 0x3c, 0x0a, 0x08, 0x43, 0x52, 0x49, 0x55, 0x54, 0x79, 0x70, 0x65,
 	0x12, 0x0e, 0x0a, 0x0a, 0x43, 0x52, 0x49, 0x55, 0x5f, 0x52, 0x53, 0x59, 0x4e, 0x43, 0x10, 0x00,
 	0x12, 0x09, 0x0a, 0x05, 0x50, 0x48, 0x41, 0x55, 0x4c, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x4e,
-	0x4f, 0x4e, 0x45, 0x10, 0x02, 0x42, 0x0f, 0x5a, 0x0d, 0x6c, 0x78, 0x64, 0x2f, 0x6d, 0x69, 0x67,
-	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x4f, 0x4e, 0x45, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x56, 0x4d, 0x5f, 0x51, 0x45, 0x4d, 0x55,
+	0x10, 0x03, 0x42, 0x0f, 0x5a, 0x0d, 0x6c, 0x78, 0x64, 0x2f, 0x6d, 0x69, 0x67, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e,
 }
 
 var (
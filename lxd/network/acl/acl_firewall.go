@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
+	"github.com/lxc/lxd/lxd/network/egressproxy"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -12,6 +13,40 @@ import (
 
 // FirewallApplyACLRules applies ACL rules to network firewall.
 func FirewallApplyACLRules(s *state.State, logger logger.Logger, aclProjectName string, aclNet NetworkACLUsage) error {
+	rules, err := FirewallACLRules(s, logger, aclProjectName, aclNet)
+	if err != nil {
+		return err
+	}
+
+	err = s.Firewall.NetworkApplyACLRules(aclNet.Name, rules)
+	if err != nil {
+		return err
+	}
+
+	// The connections made by the network's egress proxy don't go through the firewall, so give it the rules too.
+	proxyRules := make([]egressproxy.ACLRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Direction != "egress" {
+			continue
+		}
+
+		proxyRules = append(proxyRules, egressproxy.ACLRule{
+			Action:          rule.Action,
+			Source:          rule.Source,
+			Destination:     rule.Destination,
+			Protocol:        rule.Protocol,
+			SourcePort:      rule.SourcePort,
+			DestinationPort: rule.DestinationPort,
+		})
+	}
+
+	egressproxy.SetNetworkACLRules(aclNet.Name, proxyRules)
+
+	return nil
+}
+
+// FirewallACLRules returns the firewall rules of the ACLs applied to the network.
+func FirewallACLRules(s *state.State, logger logger.Logger, aclProjectName string, aclNet NetworkACLUsage) ([]firewallDrivers.ACLRule, error) {
 	var dropRules []firewallDrivers.ACLRule
 	var rejectRules []firewallDrivers.ACLRule
 	var allowRules []firewallDrivers.ACLRule
@@ -62,17 +97,17 @@ func FirewallApplyACLRules(s *state.State, logger logger.Logger, aclProjectName
 	for _, aclName := range shared.SplitNTrimSpace(aclNet.Config["security.acls"], ",", -1, true) {
 		_, aclInfo, err := s.DB.Cluster.GetNetworkACL(aclProjectName, aclName)
 		if err != nil {
-			return fmt.Errorf("Failed loading ACL %q for network %q: %w", aclName, aclNet.Name, err)
+			return nil, fmt.Errorf("Failed loading ACL %q for network %q: %w", aclName, aclNet.Name, err)
 		}
 
 		err = convertACLRules("ingress", logPrefix, aclInfo.Ingress...)
 		if err != nil {
-			return fmt.Errorf("Failed converting ACL %q ingress rules for network %q: %w", aclInfo.Name, aclNet.Name, err)
+			return nil, fmt.Errorf("Failed converting ACL %q ingress rules for network %q: %w", aclInfo.Name, aclNet.Name, err)
 		}
 
 		err = convertACLRules("egress", logPrefix, aclInfo.Egress...)
 		if err != nil {
-			return fmt.Errorf("Failed converting ACL %q egress rules for network %q: %w", aclInfo.Name, aclNet.Name, err)
+			return nil, fmt.Errorf("Failed converting ACL %q egress rules for network %q: %w", aclInfo.Name, aclNet.Name, err)
 		}
 	}
 
@@ -99,7 +134,7 @@ func FirewallApplyACLRules(s *state.State, logger logger.Logger, aclProjectName
 		LogName:   fmt.Sprintf("%s-ingress", logPrefix),
 	})
 
-	return s.Firewall.NetworkApplyACLRules(aclNet.Name, rules)
+	return rules, nil
 }
 
 // firewallACLDefaults returns the action and logging mode to use for the specified direction's default rule.
@@ -31,7 +31,10 @@ import (
 	"github.com/lxc/lxd/lxd/dnsmasq/dhcpalloc"
 	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/ip"
+	"github.com/lxc/lxd/lxd/lifecycle"
 	"github.com/lxc/lxd/lxd/network/acl"
+	"github.com/lxc/lxd/lxd/network/egressproxy"
+	"github.com/lxc/lxd/lxd/network/nat64"
 	"github.com/lxc/lxd/lxd/network/openvswitch"
 	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/project"
@@ -259,6 +262,7 @@ func (n *bridge) Validate(config map[string]string) error {
 		"ipv6.nat":                             validate.Optional(validate.IsBool),
 		"ipv6.nat.order":                       validate.Optional(validate.IsOneOf("before", "after")),
 		"ipv6.nat.address":                     validate.Optional(validate.IsNetworkAddressV6),
+		"ipv6.nat64":                           validate.Optional(validate.IsBool),
 		"ipv6.dhcp":                            validate.Optional(validate.IsBool),
 		"ipv6.dhcp.expiry":                     validate.IsAny,
 		"ipv6.dhcp.stateful":                   validate.Optional(validate.IsBool),
@@ -267,6 +271,7 @@ func (n *bridge) Validate(config map[string]string) error {
 		"ipv6.routing":                         validate.Optional(validate.IsBool),
 		"ipv6.ovn.ranges":                      validate.Optional(validate.IsNetworkRangeV6List),
 		"dns.domain":                           validate.IsAny,
+		"dns.dns64":                            validate.Optional(validate.IsBool),
 		"dns.mode":                             validate.Optional(validate.IsOneOf("dynamic", "managed", "none")),
 		"dns.search":                           validate.IsAny,
 		"dns.zone.forward":                     validate.Optional(n.validateZoneName),
@@ -280,6 +285,8 @@ func (n *bridge) Validate(config map[string]string) error {
 		"security.acls.default.egress.action":  validate.Optional(validate.IsOneOf(acl.ValidActions...)),
 		"security.acls.default.ingress.logged": validate.Optional(validate.IsBool),
 		"security.acls.default.egress.logged":  validate.Optional(validate.IsBool),
+		"security.egress_proxy":                validate.Optional(validate.IsBool),
+		"security.egress_proxy.hosts":          validate.Optional(validate.IsListOf(egressproxy.ValidHost)),
 	}
 
 	// Add dynamic validation rules.
@@ -398,6 +405,24 @@ func (n *bridge) Validate(config map[string]string) error {
 		}
 	}
 
+	// Check NAT64 and DNS64 are used on IPv6-only networks.
+	if shared.IsTrue(config["ipv6.nat64"]) || shared.IsTrue(config["dns.dns64"]) {
+		if shared.StringInSlice(config["ipv6.address"], []string{"", "none"}) {
+			return fmt.Errorf(`"ipv6.nat64" and "dns.dns64" require "ipv6.address" to be set`)
+		}
+
+		if shared.IsTrue(config["ipv6.nat64"]) && !shared.StringInSlice(config["ipv4.address"], []string{"", "none"}) {
+			return fmt.Errorf(`"ipv6.nat64" can only be used on networks without IPv4 ("ipv4.address" must be "none")`)
+		}
+	}
+
+	// Check the egress proxy has a bridge address to listen on.
+	if shared.IsTrue(config["security.egress_proxy"]) {
+		if shared.StringInSlice(config["ipv4.address"], []string{"", "none"}) && shared.StringInSlice(config["ipv6.address"], []string{"", "none"}) {
+			return fmt.Errorf(`"security.egress_proxy" requires "ipv4.address" or "ipv6.address" to be set`)
+		}
+	}
+
 	// Check IPv4 OVN ranges.
 	if config["ipv4.ovn.ranges"] != "" {
 		dhcpSubnet := n.DHCPv4Subnet()
@@ -835,21 +860,6 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 		}
 	}
 
-	// Initialise a new firewall option set.
-	fwOpts := firewallDrivers.Opts{}
-
-	if n.hasIPv4Firewall() {
-		fwOpts.FeaturesV4 = &firewallDrivers.FeatureOpts{}
-	}
-
-	if n.hasIPv6Firewall() {
-		fwOpts.FeaturesV6 = &firewallDrivers.FeatureOpts{}
-	}
-
-	if n.config["security.acls"] != "" {
-		fwOpts.ACL = true
-	}
-
 	// Snapshot container specific IPv4 routes (added with boot proto) before removing IPv4 addresses.
 	// This is because the kernel removes any static routes on an interface when all addresses removed.
 	ctRoutes, err := n.bootRoutesV4()
@@ -882,20 +892,12 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 
 	// Configure IPv4 firewall (includes fan).
 	if n.config["bridge.mode"] == "fan" || !shared.StringInSlice(n.config["ipv4.address"], []string{"", "none"}) {
-		if n.hasDHCPv4() && n.hasIPv4Firewall() {
-			fwOpts.FeaturesV4.ICMPDHCPDNSAccess = true
-		}
-
 		// Allow forwarding.
 		if n.config["bridge.mode"] == "fan" || n.config["ipv4.routing"] == "" || shared.IsTrue(n.config["ipv4.routing"]) {
 			err = util.SysctlSet("net/ipv4/ip_forward", "1")
 			if err != nil {
 				return err
 			}
-
-			if n.hasIPv4Firewall() {
-				fwOpts.FeaturesV4.ForwardingAllow = true
-			}
 		}
 	}
 
@@ -982,24 +984,6 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 			return err
 		}
 
-		// Configure NAT.
-		if shared.IsTrue(n.config["ipv4.nat"]) {
-			//If a SNAT source address is specified, use that, otherwise default to MASQUERADE mode.
-			var srcIP net.IP
-			if n.config["ipv4.nat.address"] != "" {
-				srcIP = net.ParseIP(n.config["ipv4.nat.address"])
-			}
-
-			fwOpts.SNATV4 = &firewallDrivers.SNATOpts{
-				SNATAddress: srcIP,
-				Subnet:      subnet,
-			}
-
-			if n.config["ipv4.nat.order"] == "after" {
-				fwOpts.SNATV4.Append = true
-			}
-		}
-
 		// Add additional routes.
 		if n.config["ipv4.routes"] != "" {
 			for _, route := range strings.Split(n.config["ipv4.routes"], ",") {
@@ -1085,10 +1069,6 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 		// Update the dnsmasq config.
 		dnsmasqCmd = append(dnsmasqCmd, []string{fmt.Sprintf("--listen-address=%s", ipAddress.String()), "--enable-ra"}...)
 		if n.DHCPv6Subnet() != nil {
-			if n.hasIPv6Firewall() {
-				fwOpts.FeaturesV6.ICMPDHCPDNSAccess = true
-			}
-
 			// Build DHCP configuration.
 			if !shared.StringInSlice("--dhcp-no-override", dnsmasqCmd) {
 				dnsmasqCmd = append(dnsmasqCmd, []string{"--dhcp-no-override", "--dhcp-authoritative", fmt.Sprintf("--dhcp-leasefile=%s", shared.VarPath("networks", n.name, "dnsmasq.leases")), fmt.Sprintf("--dhcp-hostsfile=%s", shared.VarPath("networks", n.name, "dnsmasq.hosts"))}...)
@@ -1143,10 +1123,6 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 					return err
 				}
 			}
-
-			if n.hasIPv6Firewall() {
-				fwOpts.FeaturesV6.ForwardingAllow = true
-			}
 		}
 
 		// Add the address.
@@ -1161,24 +1137,6 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 			return err
 		}
 
-		// Configure NAT.
-		if shared.IsTrue(n.config["ipv6.nat"]) {
-			//If a SNAT source address is specified, use that, otherwise default to MASQUERADE mode.
-			var srcIP net.IP
-			if n.config["ipv6.nat.address"] != "" {
-				srcIP = net.ParseIP(n.config["ipv6.nat.address"])
-			}
-
-			fwOpts.SNATV6 = &firewallDrivers.SNATOpts{
-				SNATAddress: srcIP,
-				Subnet:      subnet,
-			}
-
-			if n.config["ipv6.nat.order"] == "after" {
-				fwOpts.SNATV6.Append = true
-			}
-		}
-
 		// Add additional routes.
 		if n.config["ipv6.routes"] != "" {
 			for _, route := range strings.Split(n.config["ipv6.routes"], ",") {
@@ -1201,6 +1159,32 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 		n.applyBootRoutesV6(ctRoutes)
 	}
 
+	// Configure NAT64.
+	if shared.IsTrue(n.config["ipv6.nat64"]) {
+		_, err = nat64Start(n.state, n.name)
+		if err != nil {
+			return fmt.Errorf("Failed starting NAT64: %w", err)
+		}
+	} else {
+		err = nat64Stop(n.name)
+		if err != nil {
+			return fmt.Errorf("Failed stopping NAT64: %w", err)
+		}
+	}
+
+	// Configure the egress proxy.
+	if shared.IsTrue(n.config["security.egress_proxy"]) {
+		err = n.egressProxyStart()
+		if err != nil {
+			return fmt.Errorf("Failed starting egress proxy: %w", err)
+		}
+	} else {
+		err = egressProxyStop(n.name)
+		if err != nil {
+			return fmt.Errorf("Failed stopping egress proxy: %w", err)
+		}
+	}
+
 	// Configure the fan.
 	dnsClustered := false
 	dnsClusteredAddress := ""
@@ -1366,18 +1350,6 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 			}
 		}
 
-		// Configure NAT.
-		if shared.IsTrue(n.config["ipv4.nat"]) {
-			fwOpts.SNATV4 = &firewallDrivers.SNATOpts{
-				SNATAddress: nil, // Use MASQUERADE mode.
-				Subnet:      overlaySubnet,
-			}
-
-			if n.config["ipv4.nat.order"] == "after" {
-				fwOpts.SNATV4.Append = true
-			}
-		}
-
 		// Setup clustered DNS.
 		clusterAddress, err := node.ClusterAddress(n.state.DB.Node)
 		if err != nil {
@@ -1525,6 +1497,11 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 		return err
 	}
 
+	err = n.killForkDNS64()
+	if err != nil {
+		return err
+	}
+
 	// Configure dnsmasq.
 	if n.UsesDNSMasq() {
 		// Setup the dnsmasq domain.
@@ -1545,6 +1522,17 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 			}
 		}
 
+		// Relay upstream queries through the DNS64 proxy.
+		var dns64Address net.IP
+		if shared.IsTrue(n.config["dns.dns64"]) {
+			dns64Address, _, err = net.ParseCIDR(n.config["ipv6.address"])
+			if err != nil {
+				return fmt.Errorf("Failed parsing ipv6.address: %w", err)
+			}
+
+			dnsmasqCmd = append(dnsmasqCmd, "--no-resolv", fmt.Sprintf("--server=%s#1053", dns64Address.String()))
+		}
+
 		// Create a config file to contain additional config (and to prevent dnsmasq from reading /etc/dnsmasq.conf)
 		err = ioutil.WriteFile(shared.VarPath("networks", n.name, "dnsmasq.raw"), []byte(fmt.Sprintf("%s\n", n.config["raw.dnsmasq"])), 0644)
 		if err != nil {
@@ -1659,6 +1647,14 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 				return err
 			}
 		}
+
+		// Spawn DNS64 proxy if needed.
+		if dns64Address != nil {
+			err = n.spawnForkDNS64(dns64Address)
+			if err != nil {
+				return err
+			}
+		}
 	} else {
 		// Clean up old dnsmasq config if exists and we are not starting dnsmasq.
 		leasesPath := shared.VarPath("networks", n.name, "dnsmasq.leases")
@@ -1681,6 +1677,11 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 
 	// Setup firewall.
 	n.logger.Debug("Setting up firewall")
+	fwOpts, err := n.firewallOpts()
+	if err != nil {
+		return err
+	}
+
 	err = n.state.Firewall.NetworkSetup(n.name, fwOpts)
 	if err != nil {
 		return fmt.Errorf("Failed to setup firewall: %w", err)
@@ -1699,6 +1700,8 @@ func (n *bridge) setup(oldConfig map[string]string) error {
 		if err != nil {
 			return err
 		}
+	} else {
+		egressproxy.SetNetworkACLRules(n.name, nil)
 	}
 
 	// Setup network address forwards.
@@ -1776,6 +1779,21 @@ func (n *bridge) Stop() error {
 		return err
 	}
 
+	err = n.killForkDNS64()
+	if err != nil {
+		return err
+	}
+
+	err = nat64Stop(n.name)
+	if err != nil {
+		return err
+	}
+
+	err = egressProxyStop(n.name)
+	if err != nil {
+		return err
+	}
+
 	// Get a list of interfaces
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -1938,6 +1956,46 @@ func (n *bridge) spawnForkDNS(listenAddress string) error {
 	return nil
 }
 
+// spawnForkDNS64 starts the DNS64 proxy used as the upstream server of dnsmasq.
+func (n *bridge) spawnForkDNS64(listenAddress net.IP) error {
+	// Spawn the daemon using subprocess
+	command := n.state.OS.ExecPath
+	forkdnsargs := []string{"forkdns64",
+		net.JoinHostPort(listenAddress.String(), "1053"),
+		nat64.WellKnownPrefix}
+
+	logPath := shared.LogPath(fmt.Sprintf("forkdns64.%s.log", n.name))
+
+	p, err := subprocess.NewProcess(command, forkdnsargs, logPath, logPath)
+	if err != nil {
+		return fmt.Errorf("Failed to create subprocess: %s", err)
+	}
+
+	// Drop privileges.
+	p.SetCreds(n.state.OS.UnprivUID, n.state.OS.UnprivGID)
+
+	// Apply AppArmor profile.
+	p.SetApparmor(apparmor.ForkdnsProfileName(n))
+
+	err = p.Start()
+	if err != nil {
+		return fmt.Errorf("Failed to run: %s %s: %w", command, strings.Join(forkdnsargs, " "), err)
+	}
+
+	err = p.Save(shared.VarPath("networks", n.name, "forkdns64.pid"))
+	if err != nil {
+		// Kill Process if started, but could not save the file
+		err2 := p.Stop()
+		if err != nil {
+			return fmt.Errorf("Could not kill subprocess while handling saving error: %s: %s", err, err2)
+		}
+
+		return fmt.Errorf("Failed to save subprocess details: %s", err)
+	}
+
+	return nil
+}
+
 // HandleHeartbeat refreshes forkdns servers. Retrieves the IPv4 address of each cluster node (excluding ourselves)
 // for this network. It then updates the forkdns server list file if there are changes.
 func (n *bridge) HandleHeartbeat(heartbeatData *cluster.APIHeartbeat) error {
@@ -2194,6 +2252,168 @@ func (n *bridge) killForkDNS() error {
 	return nil
 }
 
+func (n *bridge) killForkDNS64() error {
+	// Check if we have a running DNS64 proxy at all.
+	pidPath := shared.VarPath("networks", n.name, "forkdns64.pid")
+
+	// If the pid file doesn't exist, there is no process to kill.
+	if !shared.PathExists(pidPath) {
+		return nil
+	}
+
+	p, err := subprocess.ImportProcess(pidPath)
+	if err != nil {
+		return fmt.Errorf("Could not read pid file: %s", err)
+	}
+
+	err = p.Stop()
+	if err != nil && err != subprocess.ErrNotRunning {
+		return fmt.Errorf("Unable to kill DNS64 proxy: %s", err)
+	}
+
+	return os.Remove(pidPath)
+}
+
+// egressProxyStart (re)starts the egress proxy on the bridge addresses and records the firewall options needed to
+// redirect the outbound HTTP and HTTPS traffic of each IP family to it.
+func (n *bridge) egressProxyStart() error {
+	revert := revert.New()
+	defer revert.Fail()
+
+	hosts := shared.SplitNTrimSpace(n.config["security.egress_proxy.hosts"], ",", -1, true)
+	events := newEgressProxyEvents()
+	proxy := egressproxy.New(n.name, hosts, func(req egressproxy.Request) { n.egressProxyLog(events, req) })
+	revert.Add(func() { _ = proxy.Close() })
+
+	// Connect from the SNAT addresses the instances' own traffic would use.
+	var snatV4, snatV6 net.IP
+	if shared.IsTrue(n.config["ipv4.nat"]) && n.config["ipv4.nat.address"] != "" {
+		snatV4 = net.ParseIP(n.config["ipv4.nat.address"])
+	}
+
+	if shared.IsTrue(n.config["ipv6.nat"]) && n.config["ipv6.nat.address"] != "" {
+		snatV6 = net.ParseIP(n.config["ipv6.nat.address"])
+	}
+
+	proxy.SetSNAT(snatV4, snatV6)
+
+	opts := make(map[string]*firewallDrivers.EgressProxyOpts, 2)
+	for _, key := range []string{"ipv4.address", "ipv6.address"} {
+		if shared.StringInSlice(n.config[key], []string{"", "none"}) {
+			continue
+		}
+
+		address, _, err := net.ParseCIDR(n.config[key])
+		if err != nil {
+			return err
+		}
+
+		listenAddress := net.JoinHostPort(address.String(), "0")
+		familyOpts := &firewallDrivers.EgressProxyOpts{}
+
+		familyOpts.HTTPPort, err = proxy.Listen(listenAddress, egressproxy.ProtocolHTTP)
+		if err != nil {
+			return fmt.Errorf("Failed listening on %q: %w", address, err)
+		}
+
+		familyOpts.HTTPSPort, err = proxy.Listen(listenAddress, egressproxy.ProtocolHTTPS)
+		if err != nil {
+			return fmt.Errorf("Failed listening on %q: %w", address, err)
+		}
+
+		opts[key] = familyOpts
+	}
+
+	err := egressProxySet(n.name, &egressProxy{proxy: proxy, optsV4: opts["ipv4.address"], optsV6: opts["ipv6.address"]})
+	if err != nil {
+		n.logger.Warn("Failed stopping previous egress proxy", logger.Ctx{"err": err})
+	}
+
+	revert.Success()
+	return nil
+}
+
+// egressProxyLog emits a lifecycle event for the connections handled by the egress proxy. Repeated connections of
+// a client to the same host with the same outcome are only reported once per egressProxyEventInterval.
+func (n *bridge) egressProxyLog(events *egressProxyEvents, req egressproxy.Request) {
+	clientAddr, ok := req.Client.(*net.TCPAddr)
+	if !ok {
+		return
+	}
+
+	if !events.send(fmt.Sprintf("%s %s %s %t", clientAddr.IP, req.Protocol, req.Host, req.Allowed)) {
+		return
+	}
+
+	action := lifecycle.NetworkEgressDenied
+	if req.Allowed {
+		action = lifecycle.NetworkEgressAllowed
+	}
+
+	ctx := map[string]any{
+		"protocol": req.Protocol,
+		"host":     req.Host,
+		"client":   req.Client.String(),
+	}
+
+	// Identify the instance in the background to avoid delaying the connection.
+	go func() {
+		instProject, instName, err := events.instance(clientAddr.IP, n.egressProxyInstance)
+		if err != nil {
+			n.logger.Debug("Failed identifying egress proxy client", logger.Ctx{"client": req.Client.String(), "err": err})
+		} else if instName != "" {
+			ctx["project"] = instProject
+			ctx["instance"] = instName
+		}
+
+		n.state.Events.SendLifecycle(n.project, action.Event(n, nil, ctx))
+	}()
+}
+
+// egressProxyInstance returns the project and name of the instance using the address on the bridge, if any.
+func (n *bridge) egressProxyInstance(address net.IP) (string, string, error) {
+	neigh := &ip.Neigh{DevName: n.name}
+	neighbours, err := neigh.Show()
+	if err != nil {
+		return "", "", err
+	}
+
+	var hwAddr net.HardwareAddr
+	for _, neighbour := range neighbours {
+		if neighbour.Addr.Equal(address) {
+			hwAddr = neighbour.MAC
+			break
+		}
+	}
+
+	if hwAddr == nil {
+		return "", "", nil
+	}
+
+	var instProject, instName string
+	err = usedByInstanceDevices(n.state, n.project, n.name, func(inst db.InstanceArgs, nicName string, nicConfig map[string]string) error {
+		nicHwAddr := nicConfig["hwaddr"]
+		if nicHwAddr == "" {
+			nicHwAddr = inst.Config[fmt.Sprintf("volatile.%s.hwaddr", nicName)]
+		}
+
+		mac, _ := net.ParseMAC(nicHwAddr)
+		if mac == nil || mac.String() != hwAddr.String() {
+			return nil
+		}
+
+		instProject = inst.Project
+		instName = inst.Name
+
+		return db.ErrInstanceListStop
+	})
+	if err != nil && err != db.ErrInstanceListStop {
+		return "", "", err
+	}
+
+	return instProject, instName, nil
+}
+
 // updateForkdnsServersFile takes a list of node addresses and writes them atomically to
 // the forkdns.servers file ready for forkdns to notice and re-apply its config.
 func (n *bridge) updateForkdnsServersFile(addresses []string) error {
@@ -2233,6 +2453,127 @@ func (n *bridge) updateForkdnsServersFile(addresses []string) error {
 	return nil
 }
 
+// firewallOpts returns the firewall options of the network from its current config and the state of the NAT64
+// translator and egress proxy it uses.
+func (n *bridge) firewallOpts() (firewallDrivers.Opts, error) {
+	fwOpts := firewallDrivers.Opts{}
+
+	if n.hasIPv4Firewall() {
+		fwOpts.FeaturesV4 = &firewallDrivers.FeatureOpts{}
+	}
+
+	if n.hasIPv6Firewall() {
+		fwOpts.FeaturesV6 = &firewallDrivers.FeatureOpts{}
+	}
+
+	if n.config["security.acls"] != "" {
+		fwOpts.ACL = true
+	}
+
+	// IPv4 firewall (includes fan).
+	if n.hasIPv4Firewall() && (n.config["bridge.mode"] == "fan" || !shared.StringInSlice(n.config["ipv4.address"], []string{"", "none"})) {
+		fwOpts.FeaturesV4.ICMPDHCPDNSAccess = n.hasDHCPv4()
+		fwOpts.FeaturesV4.ForwardingAllow = n.config["bridge.mode"] == "fan" || n.config["ipv4.routing"] == "" || shared.IsTrue(n.config["ipv4.routing"])
+	}
+
+	if !shared.StringInSlice(n.config["ipv4.address"], []string{"", "none"}) && shared.IsTrue(n.config["ipv4.nat"]) {
+		_, subnet, err := net.ParseCIDR(n.config["ipv4.address"])
+		if err != nil {
+			return fwOpts, err
+		}
+
+		//If a SNAT source address is specified, use that, otherwise default to MASQUERADE mode.
+		fwOpts.SNATV4 = &firewallDrivers.SNATOpts{
+			SNATAddress: net.ParseIP(n.config["ipv4.nat.address"]),
+			Subnet:      subnet,
+			Append:      n.config["ipv4.nat.order"] == "after",
+		}
+	}
+
+	// IPv6 firewall.
+	if !shared.StringInSlice(n.config["ipv6.address"], []string{"", "none"}) {
+		if n.hasIPv6Firewall() {
+			fwOpts.FeaturesV6.ICMPDHCPDNSAccess = n.DHCPv6Subnet() != nil
+			fwOpts.FeaturesV6.ForwardingAllow = n.config["ipv6.routing"] == "" || shared.IsTrue(n.config["ipv6.routing"])
+		}
+
+		if shared.IsTrue(n.config["ipv6.nat"]) {
+			_, subnet, err := net.ParseCIDR(n.config["ipv6.address"])
+			if err != nil {
+				return fwOpts, err
+			}
+
+			fwOpts.SNATV6 = &firewallDrivers.SNATOpts{
+				SNATAddress: net.ParseIP(n.config["ipv6.nat.address"]),
+				Subnet:      subnet,
+				Append:      n.config["ipv6.nat.order"] == "after",
+			}
+		}
+	}
+
+	// Masquerade the addresses the userspace NAT64 translator maps the instances to.
+	if shared.IsTrue(n.config["ipv6.nat64"]) && nat64UserspaceRunning() {
+		_, pool, err := net.ParseCIDR(nat64Pool)
+		if err != nil {
+			return fwOpts, err
+		}
+
+		fwOpts.SNATV4 = &firewallDrivers.SNATOpts{Subnet: pool}
+	}
+
+	// Redirect the outbound HTTP and HTTPS traffic to the egress proxy.
+	if shared.IsTrue(n.config["security.egress_proxy"]) {
+		fwOpts.EgressProxyV4, fwOpts.EgressProxyV6 = egressProxyOpts(n.name)
+	}
+
+	// Fan NAT.
+	if n.config["bridge.mode"] == "fan" && shared.IsTrue(n.config["ipv4.nat"]) {
+		overlay := n.config["fan.overlay_subnet"]
+		if overlay == "" {
+			overlay = "240.0.0.0/8"
+		}
+
+		_, overlaySubnet, err := net.ParseCIDR(overlay)
+		if err != nil {
+			return fwOpts, fmt.Errorf("Failed parsing fan.overlay_subnet: %w", err)
+		}
+
+		fwOpts.SNATV4 = &firewallDrivers.SNATOpts{
+			SNATAddress: nil, // Use MASQUERADE mode.
+			Subnet:      overlaySubnet,
+			Append:      n.config["ipv4.nat.order"] == "after",
+		}
+	}
+
+	return fwOpts, nil
+}
+
+// FirewallRuleset returns the firewall rules generated from the current config of the network, its ACLs and
+// address forwards alongside the rules loaded in the kernel.
+func (n *bridge) FirewallRuleset() (*firewallDrivers.Ruleset, error) {
+	fwOpts, err := n.firewallOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	var aclRules []firewallDrivers.ACLRule
+	if fwOpts.ACL {
+		aclNet := acl.NetworkACLUsage{Name: n.Name(), Type: n.Type(), ID: n.ID(), Config: n.Config()}
+
+		aclRules, err = acl.FirewallACLRules(n.state, n.logger, n.Project(), aclNet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fwForwards, _, err := n.forwardFirewallRules()
+	if err != nil {
+		return nil, err
+	}
+
+	return n.state.Firewall.NetworkRuleset(n.name, fwOpts, aclRules, fwForwards)
+}
+
 // hasIPv4Firewall indicates whether the network has IPv4 firewall enabled.
 func (n *bridge) hasIPv4Firewall() bool {
 	// IPv4 firewall is only enabled if there is a bridge ipv4.address or fan mode, and ipv4.firewall enabled.
@@ -2819,38 +3160,12 @@ func (n *bridge) ForwardDelete(listenAddress string, clientType request.ClientTy
 
 // forwardSetupFirewall applies all network address forwards defined for this network and this member.
 func (n *bridge) forwardSetupFirewall() error {
-	memberSpecific := true // Get all forwards for this cluster member.
-	forwards, err := n.state.DB.Cluster.GetNetworkForwards(context.TODO(), n.ID(), memberSpecific)
+	fwForwards, ipVersions, err := n.forwardFirewallRules()
 	if err != nil {
-		return fmt.Errorf("Failed loading network forwards: %w", err)
-	}
-
-	var fwForwards []firewallDrivers.AddressForward
-	ipVersions := make(map[uint]struct{})
-
-	for _, forward := range forwards {
-		// Convert listen address to subnet so we can check its valid and can be used.
-		listenAddressNet, err := ParseIPToNet(forward.ListenAddress)
-		if err != nil {
-			return fmt.Errorf("Failed parsing address forward listen address %q: %w", forward.ListenAddress, err)
-		}
-
-		// Track which IP versions we are using.
-		if listenAddressNet.IP.To4() == nil {
-			ipVersions[6] = struct{}{}
-		} else {
-			ipVersions[4] = struct{}{}
-		}
-
-		portMaps, err := n.forwardValidate(listenAddressNet.IP, &forward.NetworkForwardPut)
-		if err != nil {
-			return fmt.Errorf("Failed validating firewall address forward for listen address %q: %w", forward.ListenAddress, err)
-		}
-
-		fwForwards = append(fwForwards, n.forwardConvertToFirewallForwards(listenAddressNet.IP, net.ParseIP(forward.Config["target_address"]), portMaps)...)
+		return err
 	}
 
-	if len(forwards) > 0 {
+	if len(ipVersions) > 0 {
 		// Check if br_netfilter is enabled to, and warn if not.
 		brNetfilterWarning := false
 		for ipVersion := range ipVersions {
@@ -2882,6 +3197,43 @@ func (n *bridge) forwardSetupFirewall() error {
 	return nil
 }
 
+// forwardFirewallRules returns the firewall address forwards of this network and this member, along with the IP
+// versions of their listen addresses.
+func (n *bridge) forwardFirewallRules() ([]firewallDrivers.AddressForward, map[uint]struct{}, error) {
+	memberSpecific := true // Get all forwards for this cluster member.
+	forwards, err := n.state.DB.Cluster.GetNetworkForwards(context.TODO(), n.ID(), memberSpecific)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed loading network forwards: %w", err)
+	}
+
+	var fwForwards []firewallDrivers.AddressForward
+	ipVersions := make(map[uint]struct{})
+
+	for _, forward := range forwards {
+		// Convert listen address to subnet so we can check its valid and can be used.
+		listenAddressNet, err := ParseIPToNet(forward.ListenAddress)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed parsing address forward listen address %q: %w", forward.ListenAddress, err)
+		}
+
+		// Track which IP versions we are using.
+		if listenAddressNet.IP.To4() == nil {
+			ipVersions[6] = struct{}{}
+		} else {
+			ipVersions[4] = struct{}{}
+		}
+
+		portMaps, err := n.forwardValidate(listenAddressNet.IP, &forward.NetworkForwardPut)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed validating firewall address forward for listen address %q: %w", forward.ListenAddress, err)
+		}
+
+		fwForwards = append(fwForwards, n.forwardConvertToFirewallForwards(listenAddressNet.IP, net.ParseIP(forward.Config["target_address"]), portMaps)...)
+	}
+
+	return fwForwards, ipVersions, nil
+}
+
 // Leases returns a list of leases for the bridged network. It will reach out to other cluster members as needed.
 // The projectName passed here refers to the initial project from the API request which may differ from the network's project.
 func (n *bridge) Leases(projectName string, clientType request.ClientType) ([]api.NetworkLease, error) {
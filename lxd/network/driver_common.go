@@ -14,6 +14,7 @@ import (
 	"github.com/lxc/lxd/lxd/cluster/request"
 	"github.com/lxc/lxd/lxd/db"
 	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/network/acl"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/resources"
@@ -916,6 +917,11 @@ func (n *common) forwardValidate(listenAddress net.IP, forward *api.NetworkForwa
 	return portMaps, err
 }
 
+// FirewallRuleset returns ErrNotImplemented for drivers that don't apply their rules through the host firewall.
+func (n *common) FirewallRuleset() (*firewallDrivers.Ruleset, error) {
+	return nil, ErrNotImplemented
+}
+
 // ForwardCreate returns ErrNotImplemented for drivers that do not support forwards.
 func (n *common) ForwardCreate(forward api.NetworkForwardsPost, clientType request.ClientType) error {
 	return ErrNotImplemented
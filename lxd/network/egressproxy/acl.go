@@ -0,0 +1,150 @@
+package egressproxy
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ACLRule represents an egress ACL rule of the network applied to the connections made by the proxy.
+// Subjects are comma separated lists of IP addresses, CIDR subnets or ranges and ports are comma separated lists
+// of ports or port ranges, as used by the firewall ACL rules.
+type ACLRule struct {
+	Action          string // Either "allow", "reject" or "drop".
+	Source          string
+	Destination     string
+	Protocol        string
+	SourcePort      string
+	DestinationPort string
+}
+
+// networkACLRules holds the egress ACL rules of each network, in the order the firewall evaluates them.
+var networkACLRules = map[string][]ACLRule{}
+
+// networkACLRulesMu protects networkACLRules.
+var networkACLRulesMu sync.RWMutex
+
+// SetNetworkACLRules sets the egress ACL rules applied to the connections made by the proxy of the network.
+// The rules must be in the order the firewall evaluates them. Passing nil rules disables ACL checks.
+func SetNetworkACLRules(networkName string, rules []ACLRule) {
+	networkACLRulesMu.Lock()
+	defer networkACLRulesMu.Unlock()
+
+	if rules == nil {
+		delete(networkACLRules, networkName)
+		return
+	}
+
+	networkACLRules[networkName] = rules
+}
+
+// aclAllowed returns whether the first of the network's egress ACL rules matching a TCP connection from the client
+// to the destination allows it. Connections are allowed when the network has no ACL rules.
+func aclAllowed(networkName string, client *net.TCPAddr, destination *net.TCPAddr) bool {
+	networkACLRulesMu.RLock()
+	rules, found := networkACLRules[networkName]
+	networkACLRulesMu.RUnlock()
+
+	if !found {
+		return true
+	}
+
+	for _, rule := range rules {
+		if aclRuleMatch(rule, client, destination) {
+			return rule.Action == "allow"
+		}
+	}
+
+	return false
+}
+
+// aclRuleMatch returns whether the rule matches a TCP connection from the client to the destination.
+func aclRuleMatch(rule ACLRule, client *net.TCPAddr, destination *net.TCPAddr) bool {
+	if rule.Protocol != "" && rule.Protocol != "tcp" {
+		return false
+	}
+
+	if rule.Source != "" && !aclSubjectMatch(rule.Source, client.IP) {
+		return false
+	}
+
+	if rule.Destination != "" && !aclSubjectMatch(rule.Destination, destination.IP) {
+		return false
+	}
+
+	if rule.SourcePort != "" && !aclPortMatch(rule.SourcePort, client.Port) {
+		return false
+	}
+
+	if rule.DestinationPort != "" && !aclPortMatch(rule.DestinationPort, destination.Port) {
+		return false
+	}
+
+	return true
+}
+
+// aclSubjectMatch returns whether the address matches one of the IP addresses, subnets or ranges of the subject.
+func aclSubjectMatch(subject string, address net.IP) bool {
+	for _, criterion := range strings.Split(subject, ",") {
+		criterion = strings.TrimSpace(criterion)
+
+		start, end, isRange := strings.Cut(criterion, "-")
+		if isRange {
+			startIP := net.ParseIP(start)
+			endIP := net.ParseIP(end)
+			if startIP == nil || endIP == nil || (startIP.To4() == nil) != (address.To4() == nil) {
+				continue
+			}
+
+			if bytes.Compare(address.To16(), startIP.To16()) >= 0 && bytes.Compare(address.To16(), endIP.To16()) <= 0 {
+				return true
+			}
+
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(criterion)
+		if err == nil {
+			if subnet.Contains(address) {
+				return true
+			}
+
+			continue
+		}
+
+		ip := net.ParseIP(criterion)
+		if ip != nil && ip.Equal(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aclPortMatch returns whether the port matches one of the ports or port ranges of the criteria.
+func aclPortMatch(criteria string, port int) bool {
+	for _, criterion := range strings.Split(criteria, ",") {
+		start, end, isRange := strings.Cut(strings.TrimSpace(criterion), "-")
+		if !isRange {
+			end = start
+		}
+
+		startPort, err := strconv.Atoi(start)
+		if err != nil {
+			continue
+		}
+
+		endPort, err := strconv.Atoi(end)
+		if err != nil {
+			continue
+		}
+
+		if port >= startPort && port <= endPort {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,214 @@
+package egressproxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestPeekServerName(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+
+	go func() {
+		conn := tls.Client(client, &tls.Config{ServerName: "www.example.com"})
+		_ = conn.Handshake()
+		_ = client.Close()
+	}()
+
+	reader := bufio.NewReaderSize(server, bufferSize)
+	name, err := peekServerName(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "www.example.com" {
+		t.Fatalf("Unexpected server name %q", name)
+	}
+
+	// The ClientHello must not have been consumed.
+	header, err := reader.Peek(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header[0] != 0x16 {
+		t.Fatalf("ClientHello consumed")
+	}
+
+	_, err = parseServerName([]byte{0x01, 0x00, 0x00, 0x10, 0x03})
+	if err == nil {
+		t.Fatal("Expected error for truncated ClientHello")
+	}
+}
+
+func TestPeekHTTPHost(t *testing.T) {
+	tests := map[string]string{
+		"GET / HTTP/1.1\r\nHost: www.example.com\r\n\r\n":                         "www.example.com",
+		"GET / HTTP/1.1\r\nHost: www.example.com:8080\r\nAccept: */*\r\n\r\n":     "www.example.com",
+		"GET / HTTP/1.1\r\nHost: [2001:db8::1]:80\r\n\r\n":                        "2001:db8::1",
+		"GET http://other.example.com/ HTTP/1.1\r\nHost: www.example.com\r\n\r\n": "other.example.com",
+	}
+
+	for req, expected := range tests {
+		reader := bufio.NewReaderSize(bytes.NewReader([]byte(req+"body")), bufferSize)
+		host, err := peekHTTPHost(reader)
+		if err != nil {
+			t.Fatalf("Failed parsing %q: %v", req, err)
+		}
+
+		if host != expected {
+			t.Fatalf("Expected %q for %q but got %q", expected, req, host)
+		}
+
+		// The request must not have been consumed.
+		data, _ := io.ReadAll(reader)
+		if string(data) != req+"body" {
+			t.Fatalf("Request consumed")
+		}
+	}
+
+	reader := bufio.NewReaderSize(bytes.NewReader([]byte("GET / HTTP/1.0\r\n\r\n")), bufferSize)
+	_, err := peekHTTPHost(reader)
+	if err == nil {
+		t.Fatal("Expected error for request without host")
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	hosts := []string{"example.com", "*.example.net", "192.0.2.1"}
+
+	tests := map[string]bool{
+		"example.com":      true,
+		"EXAMPLE.com.":     true,
+		"www.example.com":  false,
+		"example.net":      false,
+		"www.example.net":  true,
+		"a.b.example.net":  true,
+		"badexample.net":   false,
+		"192.0.2.1":        true,
+		"192.0.2.2":        false,
+		"example.com.evil": false,
+	}
+
+	for host, expected := range tests {
+		if HostAllowed(hosts, host) != expected {
+			t.Errorf("Expected HostAllowed(%q) to be %v", host, expected)
+		}
+	}
+}
+
+func TestValidHost(t *testing.T) {
+	for _, host := range []string{"example.com", "*.example.com", "_srv.example.com", "192.0.2.1", "2001:db8::1", "localhost"} {
+		err := ValidHost(host)
+		if err != nil {
+			t.Errorf("Expected %q to be valid: %v", host, err)
+		}
+	}
+
+	for _, host := range []string{"", "*.", "exa mple.com", "-example.com", "example..com", "*.*.example.com"} {
+		err := ValidHost(host)
+		if err == nil {
+			t.Errorf("Expected %q to be invalid", host)
+		}
+	}
+}
+
+func TestACLAllowed(t *testing.T) {
+	client := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 40000}
+
+	// Connections are allowed without ACL rules.
+	if !aclAllowed("test", client, &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 443}) {
+		t.Fatal("Expected connection to be allowed without ACL rules")
+	}
+
+	SetNetworkACLRules("test", []ACLRule{
+		{Action: "drop", Destination: "192.0.2.10-192.0.2.20"},
+		{Action: "reject", Protocol: "tcp", DestinationPort: "80"},
+		{Action: "reject", Protocol: "udp"},
+		{Action: "allow", Source: "10.0.0.0/24", Destination: "192.0.2.0/24,2001:db8::1"},
+		{Action: "allow", Protocol: "tcp", SourcePort: "1-1024", DestinationPort: "400-500"},
+		{Action: "reject"},
+	})
+
+	defer SetNetworkACLRules("test", nil)
+
+	tests := []struct {
+		client      string
+		clientPort  int
+		destination string
+		port        int
+		allowed     bool
+	}{
+		{"10.0.0.2", 40000, "192.0.2.1", 443, true},
+		{"10.0.0.2", 40000, "192.0.2.15", 443, false}, // Dropped range.
+		{"10.0.0.2", 40000, "192.0.2.1", 80, false},   // Rejected port.
+		{"10.0.1.2", 40000, "192.0.2.1", 443, false},  // Source outside subnet.
+		{"10.0.0.2", 40000, "2001:db8::1", 443, true},
+		{"10.0.0.2", 40000, "2001:db8::2", 443, false},
+		{"10.0.1.2", 1000, "198.51.100.1", 443, true}, // Port ranges.
+		{"10.0.1.2", 2000, "198.51.100.1", 443, false},
+	}
+
+	for _, test := range tests {
+		clientAddr := &net.TCPAddr{IP: net.ParseIP(test.client), Port: test.clientPort}
+		destination := &net.TCPAddr{IP: net.ParseIP(test.destination), Port: test.port}
+		if aclAllowed("test", clientAddr, destination) != test.allowed {
+			t.Errorf("Expected connection from %s to %s to be allowed=%v", clientAddr, destination, test.allowed)
+		}
+	}
+
+	// Other networks aren't affected.
+	if !aclAllowed("other", client, &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 80}) {
+		t.Fatal("Expected connection on other network to be allowed")
+	}
+}
+
+func TestConnectionLimits(t *testing.T) {
+	p := New("test", nil, nil)
+
+	newConn := func(client string) net.Conn {
+		return &testConn{remote: &net.TCPAddr{IP: net.ParseIP(client), Port: 40000}}
+	}
+
+	conn := newConn("10.0.0.2")
+	for i := 0; i < maxClientConnections; i++ {
+		if !p.connAcquire(conn) {
+			t.Fatalf("Expected connection %d to be accepted", i)
+		}
+	}
+
+	if p.connAcquire(conn) {
+		t.Fatal("Expected connection over the client limit to be refused")
+	}
+
+	// Other clients are still accepted until the overall limit.
+	otherConn := newConn("10.0.0.3")
+	if !p.connAcquire(otherConn) {
+		t.Fatal("Expected connection from other client to be accepted")
+	}
+
+	p.connRelease(otherConn)
+	p.connRelease(conn)
+	if !p.connAcquire(conn) {
+		t.Fatal("Expected connection to be accepted once another one ended")
+	}
+
+	p.conns = maxConnections
+	if p.connAcquire(otherConn) {
+		t.Fatal("Expected connection over the overall limit to be refused")
+	}
+}
+
+// testConn is a net.Conn with a fixed remote address.
+type testConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *testConn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,194 @@
+package egressproxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// errInvalidClientHello is returned when the TLS ClientHello cannot be parsed.
+var errInvalidClientHello = fmt.Errorf("Invalid TLS ClientHello")
+
+// peekHTTPHost returns the host of the HTTP request buffered by the reader without consuming it.
+func peekHTTPHost(reader *bufio.Reader) (string, error) {
+	for {
+		buf, _ := reader.Peek(reader.Buffered())
+
+		end := bytes.Index(buf, []byte("\r\n\r\n"))
+		if end < 0 {
+			if len(buf) >= reader.Size() {
+				return "", fmt.Errorf("HTTP request headers too large")
+			}
+
+			// Wait for more data.
+			_, err := reader.Peek(len(buf) + 1)
+			if err != nil {
+				return "", err
+			}
+
+			continue
+		}
+
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:end+4])))
+		if err != nil {
+			return "", err
+		}
+
+		host := req.Host
+		if strings.Contains(host, ":") {
+			host, _, err = net.SplitHostPort(host)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if host == "" {
+			return "", fmt.Errorf("HTTP request without host")
+		}
+
+		return host, nil
+	}
+}
+
+// peekServerName returns the server name indicated in the TLS ClientHello buffered by the reader without
+// consuming it. The ClientHello must fit in the first TLS record.
+func peekServerName(reader *bufio.Reader) (string, error) {
+	header, err := reader.Peek(5)
+	if err != nil {
+		return "", err
+	}
+
+	// Handshake record.
+	if header[0] != 0x16 {
+		return "", errInvalidClientHello
+	}
+
+	record, err := reader.Peek(5 + int(binary.BigEndian.Uint16(header[3:5])))
+	if err != nil {
+		return "", err
+	}
+
+	return parseServerName(record[5:])
+}
+
+// helloReader reads the fields of a TLS ClientHello.
+type helloReader []byte
+
+// skip skips n bytes.
+func (h *helloReader) skip(n int) bool {
+	if len(*h) < n {
+		return false
+	}
+
+	*h = (*h)[n:]
+	return true
+}
+
+// uint16 reads a 16 bit integer.
+func (h *helloReader) uint16() (int, bool) {
+	if len(*h) < 2 {
+		return 0, false
+	}
+
+	v := int(binary.BigEndian.Uint16(*h))
+	*h = (*h)[2:]
+	return v, true
+}
+
+// vector reads a variable length field whose length is encoded on lenBytes bytes.
+func (h *helloReader) vector(lenBytes int) (helloReader, bool) {
+	if len(*h) < lenBytes {
+		return nil, false
+	}
+
+	length := 0
+	for _, b := range (*h)[:lenBytes] {
+		length = length<<8 | int(b)
+	}
+
+	*h = (*h)[lenBytes:]
+	if len(*h) < length {
+		return nil, false
+	}
+
+	v := (*h)[:length]
+	*h = (*h)[length:]
+	return v, true
+}
+
+// parseServerName returns the server name from the server_name extension of a ClientHello handshake message.
+func parseServerName(msg []byte) (string, error) {
+	h := helloReader(msg)
+
+	// Handshake type must be ClientHello.
+	if len(h) < 1 || h[0] != 0x01 {
+		return "", errInvalidClientHello
+	}
+
+	h = h[1:]
+	body, ok := h.vector(3)
+	if !ok {
+		return "", errInvalidClientHello
+	}
+
+	// Skip the version and random.
+	if !body.skip(2 + 32) {
+		return "", errInvalidClientHello
+	}
+
+	// Skip the session ID, cipher suites and compression methods.
+	for _, lenBytes := range []int{1, 2, 1} {
+		_, ok = body.vector(lenBytes)
+		if !ok {
+			return "", errInvalidClientHello
+		}
+	}
+
+	extensions, ok := body.vector(2)
+	if !ok {
+		return "", errInvalidClientHello
+	}
+
+	for len(extensions) > 0 {
+		extType, ok := extensions.uint16()
+		if !ok {
+			return "", errInvalidClientHello
+		}
+
+		data, ok := extensions.vector(2)
+		if !ok {
+			return "", errInvalidClientHello
+		}
+
+		// Look for the server_name extension.
+		if extType != 0 {
+			continue
+		}
+
+		names, ok := data.vector(2)
+		if !ok {
+			return "", errInvalidClientHello
+		}
+
+		for len(names) > 0 {
+			nameType := names[0]
+			names = names[1:]
+
+			name, ok := names.vector(2)
+			if !ok {
+				return "", errInvalidClientHello
+			}
+
+			// Host name.
+			if nameType == 0 && len(name) > 0 {
+				return string(name), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("TLS ClientHello without server name")
+}
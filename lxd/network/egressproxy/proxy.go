@@ -0,0 +1,386 @@
+package egressproxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocols handled by the proxy.
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+)
+
+// peekTimeout is how long clients have to send the request headers or the TLS ClientHello.
+const peekTimeout = 10 * time.Second
+
+// dialTimeout is how long connecting to the requested host can take.
+const dialTimeout = 10 * time.Second
+
+// maxConnections is the maximum number of connections handled concurrently by a proxy.
+const maxConnections = 1024
+
+// maxClientConnections is the maximum number of connections handled concurrently for a single client address.
+const maxClientConnections = 128
+
+// bufferSize is the size of the buffer used to peek at the start of the connection, large enough to hold a full
+// TLS record or the request headers.
+const bufferSize = 5 + 16384
+
+// Request represents a connection handled by the proxy.
+type Request struct {
+	Client   net.Addr
+	Protocol string
+	Host     string
+	Allowed  bool
+}
+
+// Proxy is a transparent forward proxy for HTTP and HTTPS connections redirected to it by the firewall.
+// The destination host is taken from the Host header of HTTP requests and from the server name indication of TLS
+// connections, and only hosts matching the allowlist are connected to.
+// The network's egress ACL rules (see SetNetworkACLRules) are applied to the addresses the host names resolve to.
+type Proxy struct {
+	mu          sync.RWMutex
+	networkName string
+	hosts       []string
+	listeners   []net.Listener
+	snatV4      net.IP
+	snatV6      net.IP
+
+	connsMu     sync.Mutex
+	conns       int
+	clientConns map[string]int
+
+	logFunc func(Request)
+}
+
+// New returns a new Proxy for the network allowing the specified hosts and calling logFunc (if not nil) for each
+// connection.
+func New(networkName string, hosts []string, logFunc func(Request)) *Proxy {
+	return &Proxy{
+		networkName: networkName,
+		hosts:       hosts,
+		clientConns: map[string]int{},
+		logFunc:     logFunc,
+	}
+}
+
+// SetSNAT sets the source addresses used to connect to IPv4 and IPv6 hosts. Nil uses the host's address.
+func (p *Proxy) SetSNAT(ipv4 net.IP, ipv6 net.IP) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.snatV4 = ipv4
+	p.snatV6 = ipv6
+}
+
+// SetHosts replaces the allowlist used for new connections.
+func (p *Proxy) SetHosts(hosts []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.hosts = hosts
+}
+
+// Listen starts serving connections for the protocol on the address and returns the port listened on.
+func (p *Proxy) Listen(address string, protocol string) (int, error) {
+	if protocol != ProtocolHTTP && protocol != ProtocolHTTPS {
+		return -1, fmt.Errorf("Unsupported protocol %q", protocol)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return -1, err
+	}
+
+	p.mu.Lock()
+	p.listeners = append(p.listeners, listener)
+	p.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			if !p.connAcquire(conn) {
+				_ = conn.Close()
+				continue
+			}
+
+			go func() {
+				defer p.connRelease(conn)
+				p.handle(conn, protocol)
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Close stops listening. Established connections are left to complete.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []string
+	for _, listener := range p.listeners {
+		err := listener.Close()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	p.listeners = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Failed closing listeners: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// connAcquire accounts for a new connection and returns false if it exceeds the connection limits.
+func (p *Proxy) connAcquire(conn net.Conn) bool {
+	client := connClient(conn)
+
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+
+	if p.conns >= maxConnections || p.clientConns[client] >= maxClientConnections {
+		return false
+	}
+
+	p.conns++
+	p.clientConns[client]++
+
+	return true
+}
+
+// connRelease accounts for the end of a connection accepted by connAcquire.
+func (p *Proxy) connRelease(conn net.Conn) {
+	client := connClient(conn)
+
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+
+	p.conns--
+	p.clientConns[client]--
+	if p.clientConns[client] <= 0 {
+		delete(p.clientConns, client)
+	}
+}
+
+// connClient returns the client address of the connection without its port.
+func connClient(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+
+	return host
+}
+
+// handle proxies the connection if its destination host is allowed.
+func (p *Proxy) handle(conn net.Conn, protocol string) {
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReaderSize(conn, bufferSize)
+
+	var host string
+	var err error
+	port := 80
+
+	_ = conn.SetReadDeadline(time.Now().Add(peekTimeout))
+	if protocol == ProtocolHTTPS {
+		port = 443
+		host, err = peekServerName(reader)
+	} else {
+		host, err = peekHTTPHost(reader)
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		return
+	}
+
+	p.mu.RLock()
+	allowed := HostAllowed(p.hosts, host)
+	snatV4 := p.snatV4
+	snatV6 := p.snatV6
+	p.mu.RUnlock()
+
+	// Only connect to the addresses of the host the network's ACLs allow.
+	var addresses []*net.TCPAddr
+	if allowed {
+		addresses, err = p.resolve(conn.RemoteAddr(), host, port)
+		if err != nil {
+			if protocol == ProtocolHTTP {
+				_, _ = fmt.Fprintf(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+			}
+
+			return
+		}
+
+		allowed = len(addresses) > 0
+	}
+
+	if p.logFunc != nil {
+		p.logFunc(Request{Client: conn.RemoteAddr(), Protocol: protocol, Host: host, Allowed: allowed})
+	}
+
+	if !allowed {
+		if protocol == ProtocolHTTP {
+			body := "Host not allowed by egress proxy\n"
+			_, _ = fmt.Fprintf(conn, "HTTP/1.1 403 Forbidden\r\nContent-Type: text/plain\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(body), body)
+		}
+
+		return
+	}
+
+	var upstream net.Conn
+	for _, address := range addresses {
+		dialer := net.Dialer{Timeout: dialTimeout}
+		if address.IP.To4() != nil && snatV4 != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: snatV4}
+		} else if address.IP.To4() == nil && snatV6 != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: snatV6}
+		}
+
+		upstream, err = dialer.Dial("tcp", address.String())
+		if err == nil {
+			break
+		}
+	}
+
+	if upstream == nil {
+		if protocol == ProtocolHTTP {
+			_, _ = fmt.Fprintf(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		}
+
+		return
+	}
+
+	defer func() { _ = upstream.Close() }()
+
+	// Relay the peeked data along with the rest of the connection.
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(upstream, reader)
+		closeWrite(upstream)
+		close(done)
+	}()
+
+	_, _ = io.Copy(conn, upstream)
+	closeWrite(conn)
+	<-done
+}
+
+// resolve returns the addresses of the host the network's egress ACLs allow the client to connect to.
+func (p *Proxy) resolve(client net.Addr, host string, port int) ([]*net.TCPAddr, error) {
+	clientAddr, ok := client.(*net.TCPAddr)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported client address %q", client.String())
+	}
+
+	var ips []net.IP
+	ip := net.ParseIP(host)
+	if ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+		defer cancel()
+
+		ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ipAddr := range ipAddrs {
+			ips = append(ips, ipAddr.IP)
+		}
+	}
+
+	addresses := make([]*net.TCPAddr, 0, len(ips))
+	for _, ip := range ips {
+		address := &net.TCPAddr{IP: ip, Port: port}
+		if aclAllowed(p.networkName, clientAddr, address) {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses, nil
+}
+
+// closeWrite shuts down the writing side of TCP connections and closes other connections.
+func closeWrite(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if ok {
+		_ = tcpConn.CloseWrite()
+		return
+	}
+
+	_ = conn.Close()
+}
+
+// HostAllowed returns whether the host matches one of the allowlist entries.
+// Entries starting with "*." match any subdomain of the domain that follows.
+func HostAllowed(hosts []string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, entry := range hosts {
+		entry = strings.ToLower(strings.TrimSuffix(entry, "."))
+
+		if strings.HasPrefix(entry, "*.") {
+			if strings.HasSuffix(host, entry[1:]) {
+				return true
+			}
+
+			continue
+		}
+
+		if host == entry {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidHost validates an allowlist entry, either an IP address, a domain name or a domain name wildcard.
+func ValidHost(value string) error {
+	if net.ParseIP(value) != nil {
+		return nil
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(value, "*."), ".")
+	if len(name) < 1 || len(name) > 253 {
+		return fmt.Errorf("Domain name must be 1-253 characters long")
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) < 1 || len(label) > 63 {
+			return fmt.Errorf("Domain name labels must be 1-63 characters long")
+		}
+
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf(`Domain name labels must not start or end with "-" character`)
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '-' && r != '_' {
+				return fmt.Errorf("Domain name labels can only contain alphanumeric, hyphen and underscore characters")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package nat64
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// DNS64 is a DNS handler that relays queries using Exchange and synthesises AAAA records from the A records of
+// names that have no AAAA records (RFC 6147), using the prefix to represent their IPv4 addresses.
+type DNS64 struct {
+	Prefix   *net.IPNet
+	Exchange func(req *dns.Msg) (*dns.Msg, error)
+}
+
+// ServeDNS answers a DNS request.
+func (d *DNS64) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	resp, err := d.Resolve(r)
+	if err != nil {
+		resp = &dns.Msg{}
+		resp.SetRcode(r, dns.RcodeServerFailure)
+	}
+
+	_ = w.WriteMsg(resp)
+}
+
+// Resolve relays the request and synthesises AAAA records in the response if needed.
+func (d *DNS64) Resolve(r *dns.Msg) (*dns.Msg, error) {
+	resp, err := d.Exchange(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only AAAA queries that succeeded without any AAAA records are synthesised. Validating clients that
+	// disabled checking expect unmodified responses.
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeAAAA || r.Question[0].Qclass != dns.ClassINET || r.CheckingDisabled || resp.Rcode != dns.RcodeSuccess {
+		return resp, nil
+	}
+
+	for _, rr := range resp.Answer {
+		aaaa, ok := rr.(*dns.AAAA)
+		if ok && aaaa.AAAA.To4() == nil {
+			return resp, nil
+		}
+	}
+
+	req := r.Copy()
+	req.Id = dns.Id()
+	req.Question[0].Qtype = dns.TypeA
+
+	aResp, err := d.Exchange(req)
+	if err != nil || aResp.Rcode != dns.RcodeSuccess {
+		return resp, nil
+	}
+
+	answers := make([]dns.RR, 0, len(aResp.Answer))
+	found := false
+	for _, rr := range aResp.Answer {
+		a, ok := rr.(*dns.A)
+		if !ok {
+			// Keep any CNAME chain leading to the A records.
+			answers = append(answers, rr)
+			continue
+		}
+
+		hdr := a.Hdr
+		hdr.Rrtype = dns.TypeAAAA
+		hdr.Rdlength = 0
+
+		answers = append(answers, &dns.AAAA{Hdr: hdr, AAAA: Synthesize(d.Prefix, a.A)})
+		found = true
+	}
+
+	if !found {
+		return resp, nil
+	}
+
+	resp.Answer = answers
+	resp.Ns = nil
+	resp.AuthenticatedData = false
+
+	return resp, nil
+}
+
+// Synthesize returns the IPv6 address representing the IPv4 address within the /96 prefix.
+func Synthesize(prefix *net.IPNet, addr4 net.IP) net.IP {
+	addr6 := make(net.IP, net.IPv6len)
+	copy(addr6, prefix.IP.To16()[:12])
+	copy(addr6[12:], addr4.To4())
+
+	return addr6
+}
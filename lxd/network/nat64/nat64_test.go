@@ -0,0 +1,169 @@
+package nat64
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// udp6Packet returns an IPv6 UDP packet with a valid checksum.
+func udp6Packet(src net.IP, dst net.IP, data []byte) []byte {
+	pkt := make([]byte, ipv6HeaderLen+8+len(data))
+	pkt[0] = 0x60
+	binary.BigEndian.PutUint16(pkt[4:6], uint16(8+len(data)))
+	pkt[6] = protoUDP
+	pkt[7] = 64
+	copy(pkt[8:24], src.To16())
+	copy(pkt[24:40], dst.To16())
+
+	udp := pkt[ipv6HeaderLen:]
+	binary.BigEndian.PutUint16(udp[0:2], 40000)
+	binary.BigEndian.PutUint16(udp[2:4], 53)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(len(udp)))
+	copy(udp[8:], data)
+	binary.BigEndian.PutUint16(udp[6:8], checksum(udp, pseudoHeader6(pkt[8:24], pkt[24:40], protoUDP, len(udp))))
+
+	return pkt
+}
+
+func TestTranslator(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR(WellKnownPrefix)
+	_, pool, _ := net.ParseCIDR("198.18.0.0/30")
+
+	tr, err := NewTranslator(prefix, pool, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := net.ParseIP("fd42::10")
+	server := net.ParseIP("64:ff9b::192.0.2.1")
+
+	pkt4, err := tr.Translate6to4(udp6Packet(client, server, []byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pkt4) != ipv4HeaderLen+8+5 || pkt4[9] != protoUDP || pkt4[8] != 63 {
+		t.Fatalf("Unexpected IPv4 packet: %v", pkt4)
+	}
+
+	if !net.IP(pkt4[12:16]).Equal(net.ParseIP("198.18.0.1")) || !net.IP(pkt4[16:20]).Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("Unexpected IPv4 addresses: %v -> %v", net.IP(pkt4[12:16]), net.IP(pkt4[16:20]))
+	}
+
+	if checksum(pkt4[:ipv4HeaderLen], 0) != 0 {
+		t.Errorf("Invalid IPv4 header checksum")
+	}
+
+	if checksum(pkt4[ipv4HeaderLen:], pseudoHeader4(pkt4[12:16], pkt4[16:20], protoUDP, len(pkt4)-ipv4HeaderLen)) != 0 {
+		t.Errorf("Invalid UDP checksum after translation to IPv4")
+	}
+
+	// Reply from the server to the client's pool address.
+	reply := make([]byte, len(pkt4))
+	copy(reply, pkt4)
+	copy(reply[12:16], pkt4[16:20])
+	copy(reply[16:20], pkt4[12:16])
+
+	pkt6, err := tr.Translate4to6(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !net.IP(pkt6[8:24]).Equal(server) || !net.IP(pkt6[24:40]).Equal(client) {
+		t.Fatalf("Unexpected IPv6 addresses: %v -> %v", net.IP(pkt6[8:24]), net.IP(pkt6[24:40]))
+	}
+
+	if checksum(pkt6[ipv6HeaderLen:], pseudoHeader6(pkt6[8:24], pkt6[24:40], protoUDP, len(pkt6)-ipv6HeaderLen)) != 0 {
+		t.Errorf("Invalid UDP checksum after translation to IPv6")
+	}
+
+	// The pool only has two usable addresses.
+	_, err = tr.Translate6to4(udp6Packet(net.ParseIP("fd42::11"), server, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tr.Translate6to4(udp6Packet(net.ParseIP("fd42::12"), server, nil))
+	if err == nil {
+		t.Errorf("Expected the pool to be exhausted")
+	}
+
+	// Expired mappings are released.
+	tr.Expire(time.Now().Add(2 * time.Minute))
+
+	_, err = tr.Translate4to6(reply)
+	if err == nil {
+		t.Errorf("Expected packets to expired mappings to be dropped")
+	}
+
+	_, err = tr.Translate6to4(udp6Packet(net.ParseIP("fd42::12"), server, nil))
+	if err != nil {
+		t.Errorf("Expected an address to be available after expiry: %v", err)
+	}
+
+	// Destinations outside of the prefix are dropped.
+	_, err = tr.Translate6to4(udp6Packet(client, net.ParseIP("2001:db8::1"), nil))
+	if err == nil {
+		t.Errorf("Expected packets outside of the prefix to be dropped")
+	}
+}
+
+func TestDNS64(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR(WellKnownPrefix)
+
+	records := map[uint16][]string{
+		dns.TypeA:    {"ipv4only.example. 300 IN CNAME host.example.", "host.example. 300 IN A 192.0.2.1"},
+		dns.TypeAAAA: {},
+	}
+
+	d := &DNS64{
+		Prefix: prefix,
+		Exchange: func(req *dns.Msg) (*dns.Msg, error) {
+			resp := &dns.Msg{}
+			resp.SetReply(req)
+			for _, record := range records[req.Question[0].Qtype] {
+				rr, err := dns.NewRR(record)
+				if err != nil {
+					return nil, err
+				}
+
+				resp.Answer = append(resp.Answer, rr)
+			}
+
+			return resp, nil
+		},
+	}
+
+	req := &dns.Msg{}
+	req.SetQuestion("ipv4only.example.", dns.TypeAAAA)
+
+	resp, err := d.Resolve(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Answer) != 2 {
+		t.Fatalf("Expected a CNAME and a synthesised AAAA record, got %v", resp.Answer)
+	}
+
+	aaaa, ok := resp.Answer[1].(*dns.AAAA)
+	if !ok || !aaaa.AAAA.Equal(net.ParseIP("64:ff9b::c000:201")) || aaaa.Hdr.Name != "host.example." {
+		t.Errorf("Unexpected synthesised record %v", resp.Answer[1])
+	}
+
+	// Names with AAAA records are left alone.
+	records[dns.TypeAAAA] = []string{"ipv4only.example. 300 IN AAAA 2001:db8::1"}
+
+	resp, err = d.Resolve(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.AAAA).AAAA.String() != "2001:db8::1" {
+		t.Errorf("Expected the real AAAA record, got %v", resp.Answer)
+	}
+}
@@ -0,0 +1,348 @@
+package nat64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// WellKnownPrefix is the NAT64 well-known prefix (RFC 6052) used to represent IPv4 addresses in IPv6.
+const WellKnownPrefix = "64:ff9b::/96"
+
+// ErrUnsupported is returned for packets that cannot be translated and should be dropped.
+var ErrUnsupported = errors.New("Unsupported packet")
+
+const (
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+)
+
+// mapping represents the association between an IPv6 client and an address of the IPv4 pool.
+type mapping struct {
+	addr4    [4]byte
+	addr6    [16]byte
+	lastUsed time.Time
+}
+
+// Translator is a stateful NAT64 translator (RFC 6146).
+// Each IPv6 client is dynamically mapped to an address of the IPv4 pool and packets exchanged between the client
+// and IPv4 hosts are translated as per RFC 7915. The pool is expected to be masqueraded by the host so that many
+// clients can share the host's IPv4 addresses.
+type Translator struct {
+	prefix  [12]byte
+	base    uint32
+	size    uint32
+	timeout time.Duration
+
+	mu   sync.Mutex
+	by6  map[[16]byte]*mapping
+	by4  map[[4]byte]*mapping
+	next uint32
+}
+
+// NewTranslator returns a new Translator using the specified /96 IPv6 prefix and IPv4 pool.
+// Mappings that have not been used for longer than timeout are removed when expired or when the pool is exhausted.
+func NewTranslator(prefix *net.IPNet, pool *net.IPNet, timeout time.Duration) (*Translator, error) {
+	ones, bits := prefix.Mask.Size()
+	if bits != 128 || ones != 96 || prefix.IP.To4() != nil {
+		return nil, fmt.Errorf("NAT64 prefix must be an IPv6 /96 subnet")
+	}
+
+	ones, bits = pool.Mask.Size()
+	if bits != 32 || ones > 30 || pool.IP.To4() == nil {
+		return nil, fmt.Errorf("NAT64 pool must be an IPv4 subnet of at least 4 addresses")
+	}
+
+	t := &Translator{
+		base:    binary.BigEndian.Uint32(pool.IP.To4()) + 1,
+		size:    (uint32(1) << uint(32-ones)) - 2, // Exclude the network and broadcast addresses.
+		timeout: timeout,
+		by6:     map[[16]byte]*mapping{},
+		by4:     map[[4]byte]*mapping{},
+	}
+
+	copy(t.prefix[:], prefix.IP.To16()[:12])
+
+	return t, nil
+}
+
+// Expire removes the mappings that have not been used since now minus the translator's timeout.
+func (t *Translator) Expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for addr6, m := range t.by6 {
+		if now.Sub(m.lastUsed) > t.timeout {
+			delete(t.by6, addr6)
+			delete(t.by4, m.addr4)
+		}
+	}
+}
+
+// mapping6 returns the mapping for the IPv6 client address, allocating one from the pool if needed.
+func (t *Translator) mapping6(addr6 [16]byte, now time.Time) (*mapping, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := t.by6[addr6]
+	if m != nil {
+		m.lastUsed = now
+		return m, nil
+	}
+
+	for i := uint32(0); i < t.size; i++ {
+		var addr4 [4]byte
+		binary.BigEndian.PutUint32(addr4[:], t.base+(t.next+i)%t.size)
+
+		existing := t.by4[addr4]
+		if existing != nil {
+			if now.Sub(existing.lastUsed) <= t.timeout {
+				continue
+			}
+
+			delete(t.by6, existing.addr6)
+		}
+
+		m = &mapping{addr4: addr4, addr6: addr6, lastUsed: now}
+		t.by6[addr6] = m
+		t.by4[addr4] = m
+		t.next = (t.next + i + 1) % t.size
+
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("NAT64 pool exhausted")
+}
+
+// mapping4 returns the mapping for the IPv4 pool address, if any.
+func (t *Translator) mapping4(addr4 [4]byte, now time.Time) *mapping {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := t.by4[addr4]
+	if m != nil {
+		m.lastUsed = now
+	}
+
+	return m
+}
+
+// Translate6to4 translates an IPv6 packet sent by a client to an address within the prefix into an IPv4 packet.
+func (t *Translator) Translate6to4(pkt []byte) ([]byte, error) {
+	if len(pkt) < ipv6HeaderLen || pkt[0]>>4 != 6 {
+		return nil, ErrUnsupported
+	}
+
+	payloadLen := int(binary.BigEndian.Uint16(pkt[4:6]))
+	if len(pkt) < ipv6HeaderLen+payloadLen {
+		return nil, ErrUnsupported
+	}
+
+	// Packets with extension headers (including fragments) aren't supported.
+	nextHeader := pkt[6]
+	hopLimit := pkt[7]
+	if hopLimit <= 1 || !bytes.Equal(pkt[24:36], t.prefix[:]) {
+		return nil, ErrUnsupported
+	}
+
+	payload := pkt[ipv6HeaderLen : ipv6HeaderLen+payloadLen]
+
+	proto := nextHeader
+	switch nextHeader {
+	case protoTCP, protoUDP:
+	case protoICMPv6:
+		proto = protoICMP
+	default:
+		return nil, ErrUnsupported
+	}
+
+	var addr6 [16]byte
+	copy(addr6[:], pkt[8:24])
+
+	m, err := t.mapping6(addr6, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, ipv4HeaderLen+len(payload))
+	out[0] = 0x45
+	out[1] = pkt[0]<<4 | pkt[1]>>4 // Traffic class.
+	binary.BigEndian.PutUint16(out[2:4], uint16(len(out)))
+
+	// Set the don't fragment flag on packets that could not have been fragmented in IPv6.
+	if len(out) > 1260 {
+		out[6] = 0x40
+	}
+
+	out[8] = hopLimit - 1
+	out[9] = proto
+	copy(out[12:16], m.addr4[:])
+	copy(out[16:20], pkt[36:40])
+	binary.BigEndian.PutUint16(out[10:12], checksum(out[:ipv4HeaderLen], 0))
+
+	copy(out[ipv4HeaderLen:], payload)
+
+	err = translatePayload(out[ipv4HeaderLen:], proto, pseudoHeader4(out[12:16], out[16:20], proto, len(payload)))
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Translate4to6 translates an IPv4 packet sent to an address of the pool into an IPv6 packet for its client.
+func (t *Translator) Translate4to6(pkt []byte) ([]byte, error) {
+	if len(pkt) < ipv4HeaderLen || pkt[0]>>4 != 4 {
+		return nil, ErrUnsupported
+	}
+
+	headerLen := int(pkt[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(pkt[2:4]))
+	if headerLen < ipv4HeaderLen || totalLen < headerLen || len(pkt) < totalLen {
+		return nil, ErrUnsupported
+	}
+
+	// Fragments aren't supported.
+	if binary.BigEndian.Uint16(pkt[6:8])&0x3fff != 0 {
+		return nil, ErrUnsupported
+	}
+
+	ttl := pkt[8]
+	if ttl <= 1 {
+		return nil, ErrUnsupported
+	}
+
+	payload := pkt[headerLen:totalLen]
+
+	nextHeader := pkt[9]
+	switch pkt[9] {
+	case protoTCP, protoUDP:
+	case protoICMP:
+		nextHeader = protoICMPv6
+	default:
+		return nil, ErrUnsupported
+	}
+
+	var addr4 [4]byte
+	copy(addr4[:], pkt[16:20])
+
+	m := t.mapping4(addr4, time.Now())
+	if m == nil {
+		return nil, ErrUnsupported
+	}
+
+	out := make([]byte, ipv6HeaderLen+len(payload))
+	out[0] = 0x60 | pkt[1]>>4 // Version and traffic class.
+	out[1] = pkt[1] << 4
+	binary.BigEndian.PutUint16(out[4:6], uint16(len(payload)))
+	out[6] = nextHeader
+	out[7] = ttl - 1
+	copy(out[8:20], t.prefix[:])
+	copy(out[20:24], pkt[12:16])
+	copy(out[24:40], m.addr6[:])
+
+	copy(out[ipv6HeaderLen:], payload)
+
+	err := translatePayload(out[ipv6HeaderLen:], nextHeader, pseudoHeader6(out[8:24], out[24:40], nextHeader, len(payload)))
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// translatePayload translates the ICMP message types of the payload if needed and recomputes its checksum using
+// the specified pseudo header sum.
+func translatePayload(payload []byte, proto uint8, pseudoSum uint32) error {
+	switch proto {
+	case protoTCP:
+		if len(payload) < 20 {
+			return ErrUnsupported
+		}
+
+		payload[16], payload[17] = 0, 0
+		binary.BigEndian.PutUint16(payload[16:18], checksum(payload, pseudoSum))
+	case protoUDP:
+		if len(payload) < 8 {
+			return ErrUnsupported
+		}
+
+		payload[6], payload[7] = 0, 0
+		sum := checksum(payload, pseudoSum)
+		if sum == 0 {
+			sum = 0xffff
+		}
+
+		binary.BigEndian.PutUint16(payload[6:8], sum)
+	case protoICMP, protoICMPv6:
+		if len(payload) < 8 {
+			return ErrUnsupported
+		}
+
+		// Only echo requests and replies are translated.
+		icmpTypes := map[uint8]uint8{128: 8, 129: 0} // ICMPv6 to ICMP.
+		if proto == protoICMPv6 {
+			icmpTypes = map[uint8]uint8{8: 128, 0: 129} // ICMP to ICMPv6.
+		}
+
+		icmpType, ok := icmpTypes[payload[0]]
+		if !ok {
+			return ErrUnsupported
+		}
+
+		payload[0] = icmpType
+		payload[2], payload[3] = 0, 0
+
+		// ICMPv4 checksums don't include a pseudo header.
+		if proto == protoICMP {
+			pseudoSum = 0
+		}
+
+		binary.BigEndian.PutUint16(payload[2:4], checksum(payload, pseudoSum))
+	}
+
+	return nil
+}
+
+// pseudoHeader4 returns the sum of the IPv4 pseudo header used in upper layer checksums.
+func pseudoHeader4(src []byte, dst []byte, proto uint8, length int) uint32 {
+	return sum16(src) + sum16(dst) + uint32(proto) + uint32(length)
+}
+
+// pseudoHeader6 returns the sum of the IPv6 pseudo header used in upper layer checksums.
+func pseudoHeader6(src []byte, dst []byte, nextHeader uint8, length int) uint32 {
+	return sum16(src) + sum16(dst) + uint32(nextHeader) + uint32(length)
+}
+
+// sum16 returns the sum of the 16 bit words of data (padded with a zero byte if needed).
+func sum16(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+
+	return sum
+}
+
+// checksum returns the internet checksum of data starting from the initial sum.
+func checksum(data []byte, initial uint32) uint16 {
+	sum := initial + sum16(data)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return ^uint16(sum)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/cluster/request"
 	"github.com/lxc/lxd/lxd/db"
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -57,6 +58,7 @@ type Network interface {
 	// Status.
 	State() (*api.NetworkState, error)
 	Leases(projectName string, clientType request.ClientType) ([]api.NetworkLease, error)
+	FirewallRuleset() (*firewallDrivers.Ruleset, error)
 
 	// Address Forwards.
 	ForwardCreate(forward api.NetworkForwardsPost, clientType request.ClientType) error
@@ -866,7 +866,7 @@ func usesIPv4Firewall(netConfig map[string]string) bool {
 		return true
 	}
 
-	if shared.IsTrue(netConfig["ipv4.nat"]) {
+	if shared.IsTrue(netConfig["ipv4.nat"]) || shared.IsTrue(netConfig["ipv6.nat64"]) || shared.IsTrue(netConfig["security.egress_proxy"]) {
 		return true
 	}
 
@@ -883,7 +883,7 @@ func usesIPv6Firewall(netConfig map[string]string) bool {
 		return true
 	}
 
-	if shared.IsTrue(netConfig["ipv6.nat"]) {
+	if shared.IsTrue(netConfig["ipv6.nat"]) || shared.IsTrue(netConfig["security.egress_proxy"]) {
 		return true
 	}
 
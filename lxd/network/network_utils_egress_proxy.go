@@ -0,0 +1,148 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	firewallDrivers "github.com/lxc/lxd/lxd/firewall/drivers"
+	"github.com/lxc/lxd/lxd/network/egressproxy"
+)
+
+// egressProxy is a running egress proxy along with the firewall options redirecting traffic to it.
+type egressProxy struct {
+	proxy  *egressproxy.Proxy
+	optsV4 *firewallDrivers.EgressProxyOpts
+	optsV6 *firewallDrivers.EgressProxyOpts
+}
+
+// egressProxies holds the running egress proxies keyed by network name.
+var egressProxies = map[string]*egressProxy{}
+
+// egressProxiesLock protects egressProxies.
+var egressProxiesLock sync.Mutex
+
+// egressProxySet records the running egress proxy of the network, stopping the one it replaces if any.
+func egressProxySet(networkName string, proxy *egressProxy) error {
+	egressProxiesLock.Lock()
+	defer egressProxiesLock.Unlock()
+
+	existing := egressProxies[networkName]
+	if proxy != nil {
+		egressProxies[networkName] = proxy
+	} else {
+		delete(egressProxies, networkName)
+	}
+
+	if existing != nil {
+		return existing.proxy.Close()
+	}
+
+	return nil
+}
+
+// egressProxyOpts returns the firewall options of the running egress proxy of the network, if any.
+func egressProxyOpts(networkName string) (*firewallDrivers.EgressProxyOpts, *firewallDrivers.EgressProxyOpts) {
+	egressProxiesLock.Lock()
+	defer egressProxiesLock.Unlock()
+
+	existing := egressProxies[networkName]
+	if existing == nil {
+		return nil, nil
+	}
+
+	return existing.optsV4, existing.optsV6
+}
+
+// egressProxyStop stops the egress proxy of the network if running.
+func egressProxyStop(networkName string) error {
+	return egressProxySet(networkName, nil)
+}
+
+// egressProxyEventInterval is the minimum interval between the lifecycle events of a client's connections to the
+// same host with the same outcome.
+const egressProxyEventInterval = time.Minute
+
+// egressProxyClientTTL is how long the instance using a client address is cached for.
+const egressProxyClientTTL = time.Minute
+
+// egressProxyClient is the cached project and name of the instance using a client address.
+type egressProxyClient struct {
+	project  string
+	instance string
+	expiry   time.Time
+}
+
+// egressProxyEvents rate-limits the lifecycle events of an egress proxy and caches the instances of its clients.
+type egressProxyEvents struct {
+	mu        sync.Mutex
+	clients   map[string]egressProxyClient
+	sent      map[string]time.Time
+	lastPrune time.Time
+}
+
+// newEgressProxyEvents returns a new egressProxyEvents.
+func newEgressProxyEvents() *egressProxyEvents {
+	return &egressProxyEvents{
+		clients:   map[string]egressProxyClient{},
+		sent:      map[string]time.Time{},
+		lastPrune: time.Now(),
+	}
+}
+
+// send returns whether an event should be sent for key, recording that it was if so.
+func (e *egressProxyEvents) send(key string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+
+	// Forget about expired entries from time to time.
+	if now.Sub(e.lastPrune) > egressProxyEventInterval {
+		for k, sent := range e.sent {
+			if now.Sub(sent) > egressProxyEventInterval {
+				delete(e.sent, k)
+			}
+		}
+
+		for k, client := range e.clients {
+			if now.After(client.expiry) {
+				delete(e.clients, k)
+			}
+		}
+
+		e.lastPrune = now
+	}
+
+	sent, found := e.sent[key]
+	if found && now.Sub(sent) < egressProxyEventInterval {
+		return false
+	}
+
+	e.sent[key] = now
+
+	return true
+}
+
+// instance returns the project and name of the instance using the client address, calling lookup if it isn't
+// cached. Empty names are cached too so that unknown clients aren't looked up on every connection.
+func (e *egressProxyEvents) instance(address net.IP, lookup func(address net.IP) (string, string, error)) (string, string, error) {
+	e.mu.Lock()
+	client, found := e.clients[address.String()]
+	e.mu.Unlock()
+
+	if found && time.Now().Before(client.expiry) {
+		return client.project, client.instance, nil
+	}
+
+	instProject, instName, err := lookup(address)
+	if err != nil {
+		return "", "", err
+	}
+
+	e.mu.Lock()
+	e.clients[address.String()] = egressProxyClient{project: instProject, instance: instName, expiry: time.Now().Add(egressProxyClientTTL)}
+	e.mu.Unlock()
+
+	return instProject, instName, nil
+}
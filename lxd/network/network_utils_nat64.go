@@ -0,0 +1,208 @@
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/ip"
+	"github.com/lxc/lxd/lxd/network/nat64"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/subprocess"
+)
+
+// nat64TunName is the name of the TUN device used by the userspace NAT64 translator.
+const nat64TunName = "lxdnat64"
+
+// nat64Pool is the IPv4 pool the userspace NAT64 translator maps IPv6 clients to (masqueraded by the host).
+const nat64Pool = "198.18.0.0/16"
+
+// nat64JoolInstance is the name of the Jool instance used when the kernel module is available.
+const nat64JoolInstance = "lxd"
+
+// nat64Lock serialises the starting and stopping of the host's NAT64 translator.
+var nat64Lock sync.Mutex
+
+// nat64PidPath returns the path of the PID file of the userspace NAT64 translator.
+func nat64PidPath() string {
+	return shared.VarPath("networks", "nat64.pid")
+}
+
+// nat64UsedPath returns the path of the file indicating the network uses the host's NAT64 translator.
+func nat64UsedPath(networkName string) string {
+	return shared.VarPath("networks", networkName, "nat64.used")
+}
+
+// nat64JoolRunning returns whether LXD's Jool instance exists.
+func nat64JoolRunning() bool {
+	_, err := exec.LookPath("jool")
+	if err != nil {
+		return false
+	}
+
+	_, err = shared.RunCommand("jool", "--instance", nat64JoolInstance, "global", "display")
+	return err == nil
+}
+
+// nat64UserspaceRunning returns whether the userspace NAT64 translator is running.
+func nat64UserspaceRunning() bool {
+	p, err := subprocess.ImportProcess(nat64PidPath())
+	if err != nil {
+		return false
+	}
+
+	return p.Signal(0) == nil
+}
+
+// nat64Start marks the network as using NAT64 and starts the host's NAT64 translator if not already running.
+// The Jool kernel module is used when available, otherwise LXD's userspace translator is started.
+// Returns the IPv4 pool that needs masquerading when the userspace translator is used.
+func nat64Start(s *state.State, networkName string) (*net.IPNet, error) {
+	nat64Lock.Lock()
+	defer nat64Lock.Unlock()
+
+	_, pool, err := net.ParseCIDR(nat64Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ioutil.WriteFile(nat64UsedPath(networkName), nil, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = util.SysctlSet("net/ipv4/ip_forward", "1")
+	if err != nil {
+		return nil, err
+	}
+
+	if nat64UserspaceRunning() {
+		return pool, nil
+	}
+
+	if nat64JoolRunning() {
+		return nil, nil
+	}
+
+	// Use the kernel translator if available. It translates all traffic to the prefix using the host's
+	// addresses so no masquerading is needed.
+	_, err = exec.LookPath("jool")
+	if err == nil && util.LoadModule("jool") == nil {
+		_, err = shared.RunCommand("jool", "instance", "add", nat64JoolInstance, "--netfilter", "--pool6", nat64.WellKnownPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("Failed creating Jool instance: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	// Otherwise start the userspace translator.
+	if !InterfaceExists(nat64TunName) {
+		tuntap := &ip.Tuntap{Name: nat64TunName, Mode: "tun"}
+		err = tuntap.Add()
+		if err != nil {
+			return nil, fmt.Errorf("Failed creating NAT64 TUN device: %w", err)
+		}
+	}
+
+	link := &ip.Link{Name: nat64TunName}
+	err = link.SetUp()
+	if err != nil {
+		return nil, err
+	}
+
+	for family, route := range map[string]string{ip.FamilyV6: nat64.WellKnownPrefix, ip.FamilyV4: nat64Pool} {
+		r := &ip.Route{DevName: nat64TunName, Proto: "static", Family: family}
+		err = r.Replace([]string{route})
+		if err != nil {
+			return nil, fmt.Errorf("Failed adding NAT64 route %q: %w", route, err)
+		}
+	}
+
+	command := s.OS.ExecPath
+	args := []string{"forknat64", nat64TunName, nat64.WellKnownPrefix, nat64Pool}
+	logPath := shared.LogPath("nat64.log")
+
+	p, err := subprocess.NewProcess(command, args, logPath, logPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create subprocess: %w", err)
+	}
+
+	err = p.Start()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run: %s %s: %w", command, strings.Join(args, " "), err)
+	}
+
+	err = p.Save(nat64PidPath())
+	if err != nil {
+		_ = p.Stop()
+		return nil, fmt.Errorf("Failed to save subprocess details: %w", err)
+	}
+
+	return pool, nil
+}
+
+// nat64Stop marks the network as no longer using NAT64 and stops the host's NAT64 translator if no other network
+// uses it anymore.
+func nat64Stop(networkName string) error {
+	nat64Lock.Lock()
+	defer nat64Lock.Unlock()
+
+	err := os.Remove(nat64UsedPath(networkName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	used, err := filepath.Glob(nat64UsedPath("*"))
+	if err != nil {
+		return err
+	}
+
+	if len(used) > 0 {
+		return nil
+	}
+
+	if nat64JoolRunning() {
+		_, err = shared.RunCommand("jool", "instance", "remove", nat64JoolInstance)
+		if err != nil {
+			return fmt.Errorf("Failed removing Jool instance: %w", err)
+		}
+	}
+
+	if shared.PathExists(nat64PidPath()) {
+		p, err := subprocess.ImportProcess(nat64PidPath())
+		if err != nil {
+			return err
+		}
+
+		err = p.Stop()
+		if err != nil && err != subprocess.ErrNotRunning {
+			return fmt.Errorf("Unable to kill NAT64 translator: %w", err)
+		}
+
+		err = os.Remove(nat64PidPath())
+		if err != nil {
+			return err
+		}
+	}
+
+	if InterfaceExists(nat64TunName) {
+		err = InterfaceRemove(nat64TunName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
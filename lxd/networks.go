@@ -72,6 +72,13 @@ var networkStateCmd = APIEndpoint{
 	Get: APIEndpointAction{Handler: networkStateGet, AccessHandler: allowProjectPermission("networks", "view")},
 }
 
+var networkFirewallCmd = APIEndpoint{
+	Name: "networkFirewall",
+	Path: "networks/{name}/firewall",
+
+	Get: APIEndpointAction{Handler: networkFirewallGet, AccessHandler: allowProjectPermission("networks", "view")},
+}
+
 // API endpoints
 
 // swagger:operation GET /1.0/networks networks networks_get
@@ -1727,3 +1734,91 @@ func networkStateGet(d *Daemon, r *http.Request) response.Response {
 
 	return response.SyncResponse(true, state)
 }
+
+// swagger:operation GET /1.0/networks/{name}/firewall networks networks_firewall_get
+//
+// Get the network firewall rules
+//
+// Returns the firewall rules LXD generated for the network, its forwards and ACLs alongside the rules
+// currently loaded in the kernel.
+//
+// ---
+// produces:
+//   - application/json
+// parameters:
+//   - in: query
+//     name: project
+//     description: Project name
+//     type: string
+//     example: default
+//   - in: query
+//     name: target
+//     description: Cluster member name
+//     type: string
+//     example: lxd01
+// responses:
+//   "200":
+//     description: API endpoints
+//     schema:
+//       type: object
+//       description: Sync response
+//       properties:
+//         type:
+//           type: string
+//           description: Response type
+//           example: sync
+//         status:
+//           type: string
+//           description: Status description
+//           example: Success
+//         status_code:
+//           type: integer
+//           description: Status code
+//           example: 200
+//         metadata:
+//           $ref: "#/definitions/NetworkFirewallRuleset"
+//   "400":
+//     $ref: "#/responses/BadRequest"
+//   "403":
+//     $ref: "#/responses/Forbidden"
+//   "500":
+//     $ref: "#/responses/InternalServerError"
+func networkFirewallGet(d *Daemon, r *http.Request) response.Response {
+	// If a target was specified, forward the request to the relevant node.
+	resp := forwardedResponseIfTargetIsRemote(d, r)
+	if resp != nil {
+		return resp
+	}
+
+	networkName, err := url.PathUnescape(mux.Vars(r)["name"])
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	projectName, _, err := project.NetworkProject(d.State().DB.Cluster, projectParam(r))
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	n, err := network.LoadByName(d.State(), projectName, networkName)
+	if err != nil {
+		return response.SmartError(err)
+	}
+
+	// Only bridge networks have their rules applied through the host firewall.
+	ruleset, err := n.FirewallRuleset()
+	if err != nil {
+		if errors.Is(err, network.ErrNotImplemented) {
+			return response.BadRequest(fmt.Errorf("Firewall rules are only available for bridge networks"))
+		}
+
+		return response.SmartError(err)
+	}
+
+	return response.SyncResponse(true, api.NetworkFirewallRuleset{
+		Driver:    d.State().Firewall.String(),
+		Generated: ruleset.Generated,
+		Loaded:    ruleset.Loaded,
+		Diff:      ruleset.Diff,
+	})
+}
@@ -107,6 +107,11 @@ func (c *Config) StorageImagesVolume() string {
 	return c.m.GetString("storage.images_volume")
 }
 
+// StorageRecordingsVolume returns the name of the pool/volume to use for storing session recordings.
+func (c *Config) StorageRecordingsVolume() string {
+	return c.m.GetString("storage.recordings_volume")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]any {
@@ -280,7 +285,8 @@ var ConfigSchema = config.Schema{
 	// MAAS machine this LXD instance is associated with
 	"maas.machine": {},
 
-	// Storage volumes to store backups/images on
-	"storage.backups_volume": {},
-	"storage.images_volume":  {},
+	// Storage volumes to store backups/images/session recordings on
+	"storage.backups_volume":    {},
+	"storage.images_volume":     {},
+	"storage.recordings_volume": {},
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 )
 
@@ -75,3 +76,41 @@ func TestParseHostIDMapRange(t *testing.T) {
 		assert.Equal(t, idmaps, expected)
 	}
 }
+
+func TestCheckRestrictionsNICMirror(t *testing.T) {
+	tests := []struct {
+		name        string
+		restriction string
+		mirror      string
+		wantErr     bool
+	}{
+		{name: "instance NIC", restriction: "managed", mirror: "c2/eth0"},
+		{name: "host interface", restriction: "managed", mirror: "eth0", wantErr: true},
+		{name: "host interface allowed", restriction: "allow", mirror: "eth0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := api.Project{Name: "p1", ProjectPut: api.ProjectPut{Config: map[string]string{
+				"restricted":             "true",
+				"restricted.devices.nic": tt.restriction,
+			}}}
+
+			instances := []api.Instance{{
+				Name: "c1",
+				Type: "container",
+				InstancePut: api.InstancePut{
+					Config:  map[string]string{},
+					Devices: map[string]map[string]string{"eth0": {"type": "nic", "network": "lxdbr0", "mirror": tt.mirror}},
+				},
+			}}
+
+			err := checkRestrictions(project, instances, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
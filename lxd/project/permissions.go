@@ -583,6 +583,11 @@ func checkRestrictions(project api.Project, instances []api.Instance, profiles [
 					if device["network"] == "" {
 						return fmt.Errorf("Only managed network devices are allowed")
 					}
+
+					// Traffic can only be mirrored to the NICs of instances in the same project.
+					if device["mirror"] != "" && !strings.Contains(device["mirror"], "/") {
+						return fmt.Errorf("Mirroring network traffic to host interfaces is forbidden")
+					}
 				}
 				return nil
 			}
@@ -3,8 +3,10 @@ package project
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/lxc/lxd/lxd/db"
+	deviceconfig "github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/shared/api"
 )
@@ -91,5 +93,31 @@ func GetCurrentAllocations(tx *db.ClusterTx, projectName string) (map[string]api
 		Usage: int64(len(networks[projectName])),
 	}
 
+	// Get the network transfer usage within the current billing period.
+	result["network-transfer"] = api.ProjectStateResource{
+		Limit: -1,
+		Usage: getNetworkTransferUsage(info.Instances),
+	}
+
 	return result, nil
 }
+
+// getNetworkTransferUsage returns the bytes transferred by the NICs of the instances within their current billing period.
+func getNetworkTransferUsage(instances []api.Instance) int64 {
+	now := time.Now()
+	usage := int64(0)
+
+	for _, inst := range instances {
+		periodStart := deviceconfig.NICTransferPeriodStart(now, deviceconfig.NICTransferResetDay(inst.Config))
+
+		for devName, dev := range inst.Devices {
+			if dev["type"] != "nic" {
+				continue
+			}
+
+			usage += deviceconfig.NICTransferFromVolatile(inst.Config, devName).PeriodUsage(periodStart)
+		}
+	}
+
+	return usage
+}
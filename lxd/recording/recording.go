@@ -0,0 +1,365 @@
+// Package recording records terminal sessions in the asciicast v2 format.
+//
+// Each recording is stored in a directory as NAME.cast, along with its metadata in NAME.json.
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pborman/uuid"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// header is the first line of an asciicast v2 recording.
+type header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder records a session.
+type Recorder struct {
+	dir  string
+	info api.InstanceRecording
+
+	mu      sync.Mutex
+	file    *os.File
+	pending map[string][]byte
+	err     error
+	failed  chan struct{}
+}
+
+// New starts a recording of the session described by info in dir.
+// The name, start time and exit code of info are set by the recorder.
+func New(dir string, info api.InstanceRecording, width int, height int, env map[string]string) (*Recorder, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("Failed creating recordings directory %q: %w", dir, err)
+	}
+
+	info.Name = uuid.New()
+	info.StartedAt = time.Now().UTC()
+	info.ExitCode = -1
+
+	r := &Recorder{
+		dir:     dir,
+		info:    info,
+		pending: map[string][]byte{},
+		failed:  make(chan struct{}),
+	}
+
+	r.file, err = os.OpenFile(filepath.Join(dir, info.Name+".cast"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed creating recording: %w", err)
+	}
+
+	// Terminal size is required, use the usual default for sessions without one.
+	if width <= 0 || height <= 0 {
+		width = 80
+		height = 24
+	}
+
+	h := header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: info.StartedAt.Unix(),
+		Command:   strings.Join(info.Command, " "),
+		Title:     fmt.Sprintf("%s session by %s", info.Type, info.Username),
+	}
+
+	if env != nil && env["TERM"] != "" {
+		h.Env = map[string]string{"TERM": env["TERM"]}
+	}
+
+	err = r.writeLine(h)
+	if err == nil {
+		err = r.writeInfo()
+	}
+
+	if err != nil {
+		_ = r.file.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Info returns the metadata of the recording.
+func (r *Recorder) Info() api.InstanceRecording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.info
+}
+
+// Failed returns a channel closed when writing the recording fails, after which the session can't be recorded
+// anymore and must be stopped.
+func (r *Recorder) Failed() <-chan struct{} {
+	return r.failed
+}
+
+// Err returns the error which made the recording fail, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+func (r *Recorder) writeLine(v any) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	data, err := json.Marshal(v)
+	if err == nil {
+		_, err = r.file.Write(append(data, '\n'))
+	}
+
+	if err != nil {
+		r.err = fmt.Errorf("Failed writing recording: %w", err)
+		close(r.failed)
+		return r.err
+	}
+
+	return nil
+}
+
+func (r *Recorder) writeInfo() error {
+	data, err := json.Marshal(r.info)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so the metadata is never seen partially written.
+	path := filepath.Join(r.dir, r.info.Name+".json")
+	err = os.WriteFile(path+".tmp", data, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed writing recording metadata: %w", err)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// event records data on the given asciicast event stream ("o" for output, "i" for input).
+func (r *Recorder) event(stream string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	// Only record complete UTF-8 sequences, keeping any incomplete trailing one for the next event.
+	data = append(r.pending[stream], data...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+
+			break
+		}
+	}
+
+	r.pending[stream] = append([]byte(nil), data[end:]...)
+	if end == 0 {
+		return r.err
+	}
+
+	elapsed := time.Since(r.info.StartedAt).Seconds()
+	return r.writeLine([]any{elapsed, stream, string(data[:end])})
+}
+
+// Resize records a change of the terminal size.
+func (r *Recorder) Resize(width int, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	elapsed := time.Since(r.info.StartedAt).Seconds()
+	return r.writeLine([]any{elapsed, "r", fmt.Sprintf("%dx%d", width, height)})
+}
+
+// Close ends the recording, storing the exit code of the session (-1 if there is none).
+func (r *Recorder) Close(exitCode int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	r.info.EndedAt = time.Now().UTC()
+	r.info.ExitCode = exitCode
+
+	// Record what's left of incomplete UTF-8 sequences, their invalid bytes being replaced when encoded.
+	elapsed := r.info.EndedAt.Sub(r.info.StartedAt).Seconds()
+	for _, stream := range []string{"o", "i"} {
+		if len(r.pending[stream]) == 0 {
+			continue
+		}
+
+		_ = r.writeLine([]any{elapsed, stream, string(r.pending[stream])})
+		delete(r.pending, stream)
+	}
+
+	fi, err := r.file.Stat()
+	if err == nil {
+		r.info.Size = fi.Size()
+	}
+
+	err = r.file.Close()
+	r.file = nil
+	if err != nil {
+		return fmt.Errorf("Failed closing recording: %w", err)
+	}
+
+	err = r.writeInfo()
+	if err != nil {
+		return err
+	}
+
+	return r.err
+}
+
+type outputReader struct {
+	io.ReadCloser
+	r *Recorder
+}
+
+func (o *outputReader) Read(p []byte) (int, error) {
+	n, err := o.ReadCloser.Read(p)
+	if n > 0 {
+		recordErr := o.r.event("o", p[:n])
+		if recordErr != nil {
+			return 0, recordErr
+		}
+	}
+
+	return n, err
+}
+
+// Reader returns rc wrapped so that everything read from it is recorded as output.
+// Reads fail once the recording fails, so that nothing goes unrecorded.
+func (r *Recorder) Reader(rc io.ReadCloser) io.ReadCloser {
+	return &outputReader{ReadCloser: rc, r: r}
+}
+
+type inputWriter struct {
+	io.WriteCloser
+	r *Recorder
+}
+
+func (i *inputWriter) Write(p []byte) (int, error) {
+	err := i.r.event("i", p)
+	if err != nil {
+		return 0, err
+	}
+
+	return i.WriteCloser.Write(p)
+}
+
+// Writer returns wc wrapped so that everything written to it is recorded as input.
+// Writes fail once the recording fails, so that nothing goes unrecorded.
+func (r *Recorder) Writer(wc io.WriteCloser) io.WriteCloser {
+	return &inputWriter{WriteCloser: wc, r: r}
+}
+
+// ValidName checks that name is a plain recording name.
+func ValidName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("Invalid recording name %q", name)
+	}
+
+	return nil
+}
+
+// Load returns the metadata of the recording name in dir.
+func Load(dir string, name string) (*api.InstanceRecording, error) {
+	err := ValidName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	info := api.InstanceRecording{}
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing recording metadata %q: %w", name, err)
+	}
+
+	// Report the current size of recordings still in progress.
+	if info.EndedAt.IsZero() {
+		fi, err := os.Stat(filepath.Join(dir, name+".cast"))
+		if err == nil {
+			info.Size = fi.Size()
+		}
+	}
+
+	return &info, nil
+}
+
+// List returns the metadata of all the recordings in dir, oldest first.
+func List(dir string) ([]api.InstanceRecording, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []api.InstanceRecording{}, nil
+		}
+
+		return nil, err
+	}
+
+	recordings := []api.InstanceRecording{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if name == entry.Name() || entry.IsDir() {
+			continue
+		}
+
+		info, err := Load(dir, name)
+		if err != nil {
+			return nil, err
+		}
+
+		recordings = append(recordings, *info)
+	}
+
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].StartedAt.Before(recordings[j].StartedAt) })
+
+	return recordings, nil
+}
+
+// CastPath returns the path of the asciicast file of the recording name in dir.
+func CastPath(dir string, name string) (string, error) {
+	err := ValidName(name)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name+".cast"), nil
+}
@@ -0,0 +1,140 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+
+	r, err := New(dir, api.InstanceRecording{Type: api.InstanceRecordingTypeExec, Command: []string{"bash"}, Username: "user"}, 0, 0, map[string]string{"TERM": "xterm"})
+	require.NoError(t, err)
+
+	name := r.Info().Name
+
+	// The recording is listed while it's in progress.
+	recordings, err := List(dir)
+	require.NoError(t, err)
+	require.Len(t, recordings, 1)
+	assert.Equal(t, name, recordings[0].Name)
+	assert.True(t, recordings[0].EndedAt.IsZero())
+
+	// A multi-byte character split across reads is recorded as a whole.
+	euro := []byte("€")
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write(append([]byte("a"), euro[:1]...))
+		_, _ = pw.Write(euro[1:])
+		_ = pw.Close()
+	}()
+
+	_, err = io.ReadAll(r.Reader(pr))
+	require.NoError(t, err)
+
+	_, err = r.Writer(nopWriteCloser{io.Discard}).Write([]byte("ls\r"))
+	require.NoError(t, err)
+
+	require.NoError(t, r.Resize(100, 50))
+
+	// An incomplete multi-byte character is recorded when the session ends.
+	_, err = r.Writer(nopWriteCloser{io.Discard}).Write(euro[:2])
+	require.NoError(t, err)
+
+	require.NoError(t, r.Close(2))
+
+	info, err := Load(dir, name)
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.ExitCode)
+	assert.False(t, info.EndedAt.IsZero())
+	assert.Equal(t, "user", info.Username)
+
+	path, err := CastPath(dir, name)
+	require.NoError(t, err)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+
+	h := header{}
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &h))
+	assert.Equal(t, 2, h.Version)
+	assert.Equal(t, 80, h.Width)
+	assert.Equal(t, "bash", h.Command)
+
+	events := [][]string{}
+	for scanner.Scan() {
+		event := []any{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Len(t, event, 3)
+		events = append(events, []string{event[1].(string), event[2].(string)})
+	}
+
+	assert.Equal(t, [][]string{{"o", "a"}, {"o", "€"}, {"i", "ls\r"}, {"r", "100x50"}, {"i", "\ufffd\ufffd"}}, events)
+	assert.EqualValues(t, info.Size, mustSize(t, path))
+
+	// Names can't escape the directory.
+	_, err = CastPath(dir, "../foo")
+	assert.Error(t, err)
+}
+
+func TestRecorderFailure(t *testing.T) {
+	dir := t.TempDir()
+
+	r, err := New(dir, api.InstanceRecording{Type: api.InstanceRecordingTypeConsole}, 0, 0, nil)
+	require.NoError(t, err)
+
+	// Make writing the recording fail.
+	require.NoError(t, r.file.Close())
+
+	select {
+	case <-r.Failed():
+		t.Fatal("Recording failed before writing to it")
+	default:
+	}
+
+	n, err := r.Writer(nopWriteCloser{io.Discard}).Write([]byte("ls\r"))
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+
+	select {
+	case <-r.Failed():
+	default:
+		t.Fatal("Recording didn't fail")
+	}
+
+	assert.Error(t, r.Err())
+	assert.Error(t, r.Resize(100, 50))
+
+	// Nothing is read anymore once the recording failed.
+	n, err = r.Reader(io.NopCloser(strings.NewReader("output"))).Read(make([]byte, 10))
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func mustSize(t *testing.T, path string) int64 {
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+
+	return fi.Size()
+}
@@ -10,13 +10,16 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
 
+	"github.com/pborman/uuid"
 	"gopkg.in/yaml.v2"
 
+	"github.com/lxc/lxd/lxd/archive"
 	"github.com/lxc/lxd/lxd/backup"
 	backupConfig "github.com/lxc/lxd/lxd/backup/config"
 	"github.com/lxc/lxd/lxd/cluster/request"
@@ -1726,6 +1729,259 @@ func (b *lxdBackend) CreateInstanceFromImage(inst instance.Instance, fingerprint
 	return nil
 }
 
+// RebuildInstance replaces the contents of an existing instance volume with the image requested.
+// The volume, its configuration and its snapshots are kept, and its content is restored if rebuilding fails.
+// On success, returns a post hook deleting the temporary snapshot kept of the previous content and a revert hook
+// restoring that content, one of which must be called once the caller is done updating the instance.
+func (b *lxdBackend) RebuildInstance(inst instance.Instance, fingerprint string, op *operations.Operation) (func() error, revert.Hook, error) {
+	l := logger.AddContext(b.logger, logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "fingerprint": fingerprint})
+	l.Debug("RebuildInstance started")
+	defer l.Debug("RebuildInstance finished")
+
+	err := b.isStatusReady()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if inst.IsSnapshot() {
+		return nil, nil, fmt.Errorf("Instance must not be a snapshot")
+	}
+
+	volType, err := InstanceTypeToVolumeType(inst.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contentType := InstanceContentType(inst)
+
+	// Load storage volume from database.
+	dbVol, err := VolumeDBGet(b, inst.Project(), inst.Name(), volType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Generate the effective root device volume for instance.
+	volStorageName := project.Instance(inst.Project(), inst.Name())
+	vol := b.GetVolume(volType, contentType, volStorageName, dbVol.Config)
+	err = b.applyInstanceRootDiskOverrides(inst, &vol)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Take a temporary snapshot of the volume so its content can be restored if rebuilding fails.
+	snapshotName := fmt.Sprintf("rebuild-%s", uuid.New())
+	snapVol := b.GetVolume(volType, contentType, drivers.GetSnapshotVolumeName(volStorageName, snapshotName), vol.Config())
+
+	err = b.driver.CreateVolumeSnapshot(snapVol, op)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed creating temporary snapshot of instance volume: %w", err)
+	}
+
+	deleteSnapshot := func() error {
+		err := b.driver.DeleteVolumeSnapshot(snapVol, op)
+		if err != nil {
+			return fmt.Errorf("Failed deleting temporary snapshot of instance volume: %w", err)
+		}
+
+		return nil
+	}
+
+	restoreVolume := func() {
+		err := b.driver.RestoreVolume(vol, snapshotName, op)
+		if err != nil {
+			l.Error("Failed restoring instance volume after failed rebuild", logger.Ctx{"err": err})
+		}
+
+		err = deleteSnapshot()
+		if err != nil {
+			l.Error("Failed cleaning up after failed rebuild", logger.Ctx{"err": err})
+		}
+	}
+
+	revert := revert.New()
+	defer revert.Fail()
+
+	revert.Add(restoreVolume)
+
+	err = vol.MountTask(func(mountPath string, op *operations.Operation) error {
+		rootBlockPath := ""
+		if contentType == drivers.ContentTypeBlock {
+			rootBlockPath, err = b.driver.GetVolumeDiskPath(vol)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Remove the existing content of the volume, except for the root disk file of drivers which
+		// store it inside the volume's mount path.
+		entries, err := os.ReadDir(mountPath)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			entryPath := filepath.Join(mountPath, entry.Name())
+			if entryPath == rootBlockPath {
+				continue
+			}
+
+			err = os.RemoveAll(entryPath)
+			if err != nil {
+				return fmt.Errorf("Failed removing %q: %w", entryPath, err)
+			}
+		}
+
+		// Unpack the image into the emptied volume, overwriting the root disk of virtual machines.
+		_, err = b.imageFiller(fingerprint, op)(vol, rootBlockPath, false)
+		return err
+	}, op)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed rebuilding instance volume: %w", err)
+	}
+
+	revert.Success()
+	return deleteSnapshot, restoreVolume, nil
+}
+
+// DiffInstance returns the paths of the root filesystem of a container which differ from the image it was created
+// from. The driver's native clone relationship between the instance and image volumes is used where possible,
+// otherwise the content of the instance volume is compared with the image (unpacking it if needed).
+func (b *lxdBackend) DiffInstance(inst instance.Instance, fingerprint string, op *operations.Operation) ([]api.InstanceDiffEntry, error) {
+	l := logger.AddContext(b.logger, logger.Ctx{"project": inst.Project(), "instance": inst.Name(), "fingerprint": fingerprint})
+	l.Debug("DiffInstance started")
+	defer l.Debug("DiffInstance finished")
+
+	err := b.isStatusReady()
+	if err != nil {
+		return nil, err
+	}
+
+	if inst.IsSnapshot() {
+		return nil, fmt.Errorf("Instance must not be a snapshot")
+	}
+
+	ct, ok := inst.(instance.Container)
+	if !ok || inst.Type() != instancetype.Container {
+		return nil, fmt.Errorf("Only containers can be compared with their image")
+	}
+
+	// Load storage volume from database.
+	dbVol, err := VolumeDBGet(b, inst.Project(), inst.Name(), drivers.VolumeTypeContainer)
+	if err != nil {
+		return nil, err
+	}
+
+	volStorageName := project.Instance(inst.Project(), inst.Name())
+	vol := b.GetVolume(drivers.VolumeTypeContainer, drivers.ContentTypeFS, volStorageName, dbVol.Config)
+
+	// Get the idmap used to shift the instance volume on disk (if any), to compare the ownership of paths.
+	diskIdmap, err := ct.DiskIdmap()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes map[string]string
+
+	// Use the cached image volume if there is one.
+	var imgVol *drivers.Volume
+	if b.driver.Info().OptimizedImages {
+		_, imgDBVol, err := b.state.DB.Cluster.GetLocalStoragePoolVolume(project.Default, fingerprint, db.StoragePoolVolumeTypeImage, b.ID())
+		if err != nil && !response.IsNotFoundError(err) {
+			return nil, err
+		}
+
+		if imgDBVol != nil {
+			v := b.GetVolume(drivers.VolumeTypeImage, drivers.ContentTypeFS, fingerprint, imgDBVol.Config)
+			if b.driver.HasVolume(v) {
+				imgVol = &v
+			}
+		}
+	}
+
+	// Try the driver's native diff, unless ownership was shifted on disk which would show every path as changed.
+	if imgVol != nil && diskIdmap == nil {
+		changes, err = b.driver.DiffVolume(vol, *imgVol, op)
+		if err != nil && !errors.Is(err, drivers.ErrNotSupported) {
+			return nil, err
+		}
+	}
+
+	if changes == nil {
+		// Compare the root filesystem trees, keeping paths relative to the volume like the native diff.
+		diffRootfs := func(srcPath string, dstPath string) error {
+			rootfsChanges, err := diffTrees(filepath.Join(srcPath, "rootfs"), filepath.Join(dstPath, "rootfs"), diskIdmap)
+			if err != nil {
+				return err
+			}
+
+			changes = make(map[string]string, len(rootfsChanges))
+			for path, change := range rootfsChanges {
+				changes[filepath.Join("rootfs", path)] = change
+			}
+
+			return nil
+		}
+
+		err = vol.MountTask(func(mountPath string, op *operations.Operation) error {
+			if imgVol != nil {
+				return imgVol.MountTask(func(imgMountPath string, op *operations.Operation) error {
+					return diffRootfs(imgMountPath, mountPath)
+				}, op)
+			}
+
+			// Unpack the image into a temporary directory.
+			imageFile := shared.VarPath("images", fingerprint)
+			if !shared.PathExists(imageFile) {
+				return fmt.Errorf("Base image %q isn't available anymore", fingerprint)
+			}
+
+			tmpDir, err := os.MkdirTemp(shared.VarPath("images"), "lxd_diff_")
+			if err != nil {
+				return err
+			}
+
+			defer func() { _ = os.RemoveAll(tmpDir) }()
+
+			err = archive.Unpack(imageFile, tmpDir, false, b.state.OS, nil)
+			if err != nil {
+				return err
+			}
+
+			// Check for separate root file.
+			if shared.PathExists(imageFile + ".rootfs") {
+				err = os.MkdirAll(filepath.Join(tmpDir, "rootfs"), 0755)
+				if err != nil {
+					return err
+				}
+
+				err = archive.Unpack(imageFile+".rootfs", filepath.Join(tmpDir, "rootfs"), false, b.state.OS, nil)
+				if err != nil {
+					return err
+				}
+			}
+
+			return diffRootfs(tmpDir, mountPath)
+		}, op)
+		if err != nil {
+			return nil, fmt.Errorf("Failed comparing instance with image: %w", err)
+		}
+	}
+
+	// Only report the paths of the root filesystem, relative to it.
+	entries := []api.InstanceDiffEntry{}
+	for path, change := range changes {
+		if !strings.HasPrefix(path, "rootfs/") {
+			continue
+		}
+
+		entries = append(entries, api.InstanceDiffEntry{Path: strings.TrimPrefix(path, "rootfs"), Change: change})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	return entries, nil
+}
+
 // CreateInstanceFromMigration receives an instance being migrated.
 // The args.Name and args.Config fields are ignored and, instance properties are used instead.
 func (b *lxdBackend) CreateInstanceFromMigration(inst instance.Instance, conn io.ReadWriteCloser, args migration.VolumeTargetArgs, op *operations.Operation) error {
@@ -2249,8 +2505,9 @@ func (b *lxdBackend) MigrateInstance(inst instance.Instance, conn io.ReadWriteCl
 	l.Debug("MigrateInstance started")
 	defer l.Debug("MigrateInstance finished")
 
-	// rsync+dd can't handle running source instances
-	if inst.IsRunning() && args.MigrationType.FSType == migration.MigrationFSType_BLOCK_AND_RSYNC {
+	// rsync+dd can't handle running source instances, unless the instance isn't writing to its volume
+	// (such as during a VM live migration).
+	if inst.IsRunning() && args.MigrationType.FSType == migration.MigrationFSType_BLOCK_AND_RSYNC && !args.AllowInconsistent {
 		return fmt.Errorf("Rsync based migration doesn't support running virtual machines")
 	}
 
@@ -2375,6 +2632,41 @@ func (b *lxdBackend) BackupInstance(inst instance.Instance, tarWriter *instancew
 		}
 	}
 
+	// Export running VMs with snapshots.fsfreeze enabled from a temporary snapshot taken while their guest
+	// filesystems are frozen, so that they're only frozen for as long as it takes to create the snapshot.
+	vm, ok := inst.(instance.VM)
+	if ok {
+		thaw, err := vm.FilesystemFreeze()
+		if err != nil {
+			return err
+		}
+
+		if thaw != nil {
+			snapshotName := fmt.Sprintf("backup-%s", uuid.New())
+			snapVol := b.GetVolume(volType, contentType, drivers.GetSnapshotVolumeName(volStorageName, snapshotName), vol.Config())
+
+			err = b.driver.CreateVolumeSnapshot(snapVol, op)
+			thawErr := thaw()
+			if err != nil {
+				return fmt.Errorf("Failed creating temporary snapshot of instance volume: %w", err)
+			}
+
+			defer func() {
+				err := b.driver.DeleteVolumeSnapshot(snapVol, op)
+				if err != nil {
+					l.Error("Failed deleting temporary snapshot of instance volume", logger.Ctx{"err": err})
+				}
+			}()
+
+			// Don't export a snapshot taken after the guest filesystems may have been thawed on timeout.
+			if thawErr != nil {
+				return thawErr
+			}
+
+			vol = snapVol
+		}
+	}
+
 	err = b.driver.BackupVolume(vol, tarWriter, optimized, snapNames, op)
 	if err != nil {
 		return err
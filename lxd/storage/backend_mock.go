@@ -122,6 +122,14 @@ func (b *mockBackend) CreateInstanceFromImage(inst instance.Instance, fingerprin
 	return nil
 }
 
+func (b *mockBackend) RebuildInstance(inst instance.Instance, fingerprint string, op *operations.Operation) (func() error, revert.Hook, error) {
+	return nil, nil, nil
+}
+
+func (b *mockBackend) DiffInstance(inst instance.Instance, fingerprint string, op *operations.Operation) ([]api.InstanceDiffEntry, error) {
+	return nil, nil
+}
+
 func (b *mockBackend) CreateInstanceFromMigration(inst instance.Instance, conn io.ReadWriteCloser, args migration.VolumeTargetArgs, op *operations.Operation) error {
 	return nil
 }
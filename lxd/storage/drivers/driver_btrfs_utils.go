@@ -485,9 +485,10 @@ type BTRFSMetaDataHeader struct {
 func (d *btrfs) restorationHeader(vol Volume, snapshots []string) (*BTRFSMetaDataHeader, error) {
 	var migrationHeader BTRFSMetaDataHeader
 
-	// Add snapshots to volumes list.
+	// Add snapshots to volumes list (those of the parent volume when vol is a snapshot itself).
+	parentVol, _ := vol.backupParent(snapshots)
 	for _, snapName := range snapshots {
-		snapVol, _ := vol.NewSnapshot(snapName)
+		snapVol, _ := parentVol.NewSnapshot(snapName)
 
 		// Add snapshot root volume to volumes list.
 		subVols, err := d.getSubvolumesMetaData(snapVol)
@@ -1446,7 +1446,7 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 		// Because the generic backup method will not take a consistent backup if files are being modified
 		// as they are copied to the tarball, as BTRFS allows us to take a quick snapshot without impacting
 		// the parent volume we do so here to ensure the backup taken is consistent.
-		if vol.contentType == ContentTypeFS {
+		if vol.contentType == ContentTypeFS && !vol.IsSnapshot() {
 			snapshotPath, cleanup, err := d.readonlySnapshot(vol)
 			if err != nil {
 				return err
@@ -1464,9 +1464,11 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 
 	// Optimized backup.
 
+	parentVol, storageSnapshots := vol.backupParent(snapshots)
+
 	if len(snapshots) > 0 {
 		// Check requested snapshot match those in storage.
-		err := vol.SnapshotsMatch(snapshots, op)
+		err := parentVol.SnapshotsMatch(storageSnapshots, op)
 		if err != nil {
 			return err
 		}
@@ -1615,7 +1617,7 @@ func (d *btrfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWr
 	// Backup snapshots if populated.
 	lastVolPath := "" // Used as parent for differential exports.
 	for _, snapName := range snapshots {
-		snapVol, _ := vol.NewSnapshot(snapName)
+		snapVol, _ := parentVol.NewSnapshot(snapName)
 
 		// Make a binary btrfs backup.
 		snapDir := "snapshots"
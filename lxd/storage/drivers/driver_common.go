@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/lxc/lxd/lxd/migration"
+	"github.com/lxc/lxd/lxd/operations"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
@@ -206,6 +207,11 @@ func (d *common) MigrationTypes(contentType ContentType, refresh bool) []migrati
 	}
 }
 
+// DiffVolume isn't supported by default, callers are expected to compare the volumes' content instead.
+func (d *common) DiffVolume(vol Volume, srcVol Volume, op *operations.Operation) (map[string]string, error) {
+	return nil, ErrNotSupported
+}
+
 // Name returns the pool name.
 func (d *common) Name() string {
 	return d.name
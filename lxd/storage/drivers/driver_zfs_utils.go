@@ -4,14 +4,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/pborman/uuid"
 
 	"github.com/lxc/lxd/lxd/migration"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/units"
 )
@@ -458,3 +462,103 @@ func (d *zfs) datasetHeader(vol Volume, snapshots []string) (*ZFSMetaDataHeader,
 
 	return &migrationHeader, nil
 }
+
+// zfsDiffUnescape returns the path printed by "zfs diff", whose special characters are escaped as \0ooo (octal).
+func zfsDiffUnescape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 < len(path) && path[i+1] == '0' {
+			c, err := strconv.ParseUint(path[i+2:i+5], 8, 8)
+			if err == nil {
+				b.WriteByte(byte(c))
+				i += 4
+				continue
+			}
+		}
+
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
+
+// zfsDiffParse parses the output of "zfs diff -H -F" for a dataset mounted at mountPath.
+// It returns the added, removed and changed paths (relative to mountPath) and separately the modified directories,
+// as "zfs diff" reports directories as modified whenever their entries are.
+func zfsDiffParse(output string, mountPath string) (map[string]string, []string) {
+	changes := map[string]string{}
+	modifiedDirs := []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+
+		relPath, err := filepath.Rel(mountPath, zfsDiffUnescape(fields[2]))
+		if err != nil {
+			continue
+		}
+
+		switch fields[0] {
+		case "+":
+			changes[relPath] = api.InstanceDiffAdded
+		case "-":
+			changes[relPath] = api.InstanceDiffRemoved
+		case "M":
+			if fields[1] == "/" {
+				modifiedDirs = append(modifiedDirs, relPath)
+				continue
+			}
+
+			changes[relPath] = api.InstanceDiffChanged
+		case "R":
+			if len(fields) < 4 {
+				continue
+			}
+
+			newRelPath, err := filepath.Rel(mountPath, zfsDiffUnescape(fields[3]))
+			if err != nil {
+				continue
+			}
+
+			changes[relPath] = api.InstanceDiffRemoved
+			changes[newRelPath] = api.InstanceDiffAdded
+		}
+	}
+
+	return changes, modifiedDirs
+}
+
+// zfsDiffMetadataChanged returns whether the type, permissions or ownership of dstPath differ from srcPath.
+func zfsDiffMetadataChanged(srcPath string, dstPath string) (bool, error) {
+	srcInfo, err := os.Lstat(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	dstInfo, err := os.Lstat(dstPath)
+	if err != nil {
+		return false, err
+	}
+
+	if srcInfo.Mode() != dstInfo.Mode() {
+		return true, nil
+	}
+
+	srcStat, ok := srcInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("Failed getting ownership of %q", srcPath)
+	}
+
+	dstStat, ok := dstInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("Failed getting ownership of %q", dstPath)
+	}
+
+	return srcStat.Uid != dstStat.Uid || srcStat.Gid != dstStat.Gid, nil
+}
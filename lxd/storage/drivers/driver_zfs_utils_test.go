@@ -0,0 +1,78 @@
+package drivers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Test zfsDiffUnescape.
+func Test_zfsDiffUnescape(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/mnt/rootfs/etc/hosts", want: "/mnt/rootfs/etc/hosts"},
+		{path: `/mnt/rootfs/with\0040space`, want: "/mnt/rootfs/with space"},
+		{path: `/mnt/rootfs/tab\0011and\0134backslash`, want: "/mnt/rootfs/tab\tand\\backslash"},
+		{path: `/mnt/rootfs/utf8\0303\0251`, want: "/mnt/rootfs/utf8é"},
+		{path: `/mnt/rootfs/not\0escaped`, want: `/mnt/rootfs/not\0escaped`},
+		{path: `/mnt/rootfs/truncated\004`, want: `/mnt/rootfs/truncated\004`},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, zfsDiffUnescape(test.path), test.path)
+	}
+}
+
+// Test zfsDiffParse.
+func Test_zfsDiffParse(t *testing.T) {
+	output := "M\t/\t/mnt/vol/rootfs/etc\n" +
+		"M\tF\t/mnt/vol/rootfs/etc/hosts\n" +
+		"+\tF\t/mnt/vol/rootfs/etc/new\\0040file\n" +
+		"-\t@\t/mnt/vol/rootfs/etc/old-link\n" +
+		"R\tF\t/mnt/vol/rootfs/root/a\t/mnt/vol/rootfs/root/b\n" +
+		"M\t/\t/mnt/vol/rootfs/root\n" +
+		"\n"
+
+	changes, modifiedDirs := zfsDiffParse(output, "/mnt/vol")
+
+	assert.Equal(t, map[string]string{
+		"rootfs/etc/hosts":    api.InstanceDiffChanged,
+		"rootfs/etc/new file": api.InstanceDiffAdded,
+		"rootfs/etc/old-link": api.InstanceDiffRemoved,
+		"rootfs/root/a":       api.InstanceDiffRemoved,
+		"rootfs/root/b":       api.InstanceDiffAdded,
+	}, changes)
+
+	assert.Equal(t, []string{"rootfs/etc", "rootfs/root"}, modifiedDirs)
+}
+
+// Test zfsDiffMetadataChanged.
+func Test_zfsDiffMetadataChanged(t *testing.T) {
+	srcPath := t.TempDir()
+	dstPath := t.TempDir()
+
+	require.NoError(t, os.Chmod(srcPath, 0755))
+	require.NoError(t, os.Chmod(dstPath, 0755))
+
+	changed, err := zfsDiffMetadataChanged(srcPath, dstPath)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	require.NoError(t, os.Chmod(dstPath, 0700))
+
+	changed, err = zfsDiffMetadataChanged(srcPath, dstPath)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// Paths missing from the source changed.
+	changed, err = zfsDiffMetadataChanged(filepath.Join(srcPath, "missing"), dstPath)
+	require.NoError(t, err)
+	assert.True(t, changed)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/lxc/lxd/lxd/revert"
 	"github.com/lxc/lxd/lxd/storage/filesystem"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/instancewriter"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
@@ -2135,7 +2136,7 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 		// Because the generic backup method will not take a consistent backup if files are being modified
 		// as they are copied to the tarball, as ZFS allows us to take a quick snapshot without impacting
 		// the parent volume we do so here to ensure the backup taken is consistent.
-		if vol.contentType == ContentTypeFS {
+		if vol.contentType == ContentTypeFS && !vol.IsSnapshot() {
 			snapshotPath, cleanup, err := d.readonlySnapshot(vol)
 			if err != nil {
 				return err
@@ -2153,9 +2154,11 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 
 	// Optimized backup.
 
+	parentVol, storageSnapshots := vol.backupParent(snapshots)
+
 	if len(snapshots) > 0 {
 		// Check requested snapshot match those in storage.
-		err := vol.SnapshotsMatch(snapshots, op)
+		err := parentVol.SnapshotsMatch(storageSnapshots, op)
 		if err != nil {
 			return err
 		}
@@ -2217,12 +2220,12 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 	finalParent := ""
 	if len(snapshots) > 0 {
 		for i, snapName := range snapshots {
-			snapshot, _ := vol.NewSnapshot(snapName)
+			snapshot, _ := parentVol.NewSnapshot(snapName)
 
 			// Figure out parent and current subvolumes.
 			parent := ""
 			if i > 0 {
-				oldSnapshot, _ := vol.NewSnapshot(snapshots[i-1])
+				oldSnapshot, _ := parentVol.NewSnapshot(snapshots[i-1])
 				parent = d.dataset(oldSnapshot, false)
 			}
 
@@ -2248,14 +2251,17 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 		}
 	}
 
-	// Create a temporary read-only snapshot.
-	srcSnapshot := fmt.Sprintf("%s@backup-%s", d.dataset(vol, false), uuid.New())
-	_, err := shared.RunCommand("zfs", "snapshot", srcSnapshot)
-	if err != nil {
-		return err
-	}
+	// Create a temporary read-only snapshot, unless exporting from a snapshot already.
+	srcSnapshot := d.dataset(vol, false)
+	if !vol.IsSnapshot() {
+		srcSnapshot = fmt.Sprintf("%s@backup-%s", d.dataset(vol, false), uuid.New())
+		_, err := shared.RunCommand("zfs", "snapshot", srcSnapshot)
+		if err != nil {
+			return err
+		}
 
-	defer func() { _, _ = shared.RunCommand("zfs", "destroy", srcSnapshot) }()
+		defer func() { _, _ = shared.RunCommand("zfs", "destroy", srcSnapshot) }()
+	}
 
 	// Dump the container to a file.
 	fileName := "container.bin"
@@ -2269,7 +2275,7 @@ func (d *zfs) BackupVolume(vol Volume, tarWriter *instancewriter.InstanceTarWrit
 		fileName = "volume.bin"
 	}
 
-	err = sendToFile(srcSnapshot, finalParent, fmt.Sprintf("backup/%s", fileName))
+	err := sendToFile(srcSnapshot, finalParent, fmt.Sprintf("backup/%s", fileName))
 	if err != nil {
 		return err
 	}
@@ -2545,6 +2551,70 @@ func (d *zfs) VolumeSnapshots(vol Volume, op *operations.Operation) ([]string, e
 	return snapshots, nil
 }
 
+// DiffVolume returns the paths of vol which differ from srcVol using "zfs diff", if vol is a clone of srcVol.
+func (d *zfs) DiffVolume(vol Volume, srcVol Volume, op *operations.Operation) (map[string]string, error) {
+	dataset := d.dataset(vol, false)
+
+	// Only clones of the source volume can be compared natively.
+	origin, err := d.getDatasetProperty(dataset, "origin")
+	if err != nil {
+		return nil, err
+	}
+
+	if origin != fmt.Sprintf("%s@readonly", d.dataset(srcVol, false)) && origin != fmt.Sprintf("%s@readonly", d.dataset(srcVol, true)) {
+		return nil, ErrNotSupported
+	}
+
+	// The volume must be mounted for the changed paths to be resolved.
+	var changes map[string]string
+	err = vol.MountTask(func(mountPath string, op *operations.Operation) error {
+		out, err := shared.RunCommand("zfs", "diff", "-H", "-F", origin, dataset)
+		if err != nil {
+			return err
+		}
+
+		var modifiedDirs []string
+		changes, modifiedDirs = zfsDiffParse(out, mountPath)
+		if len(modifiedDirs) == 0 {
+			return nil
+		}
+
+		// Directories are modified whenever their entries are, so compare their permissions and ownership
+		// with the origin snapshot to only report those which changed themselves.
+		originPath, err := ioutil.TempDir(GetVolumeMountPath(d.name, vol.volType, ""), "diff.")
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = os.Remove(originPath) }()
+
+		err = TryMount(origin, originPath, "zfs", unix.MS_RDONLY, "")
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = TryUnmount(originPath, 0) }()
+
+		for _, relPath := range modifiedDirs {
+			changed, err := zfsDiffMetadataChanged(filepath.Join(originPath, relPath), filepath.Join(mountPath, relPath))
+			if err != nil {
+				return err
+			}
+
+			if changed {
+				changes[relPath] = api.InstanceDiffChanged
+			}
+		}
+
+		return nil
+	}, op)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
 // RestoreVolume restores a volume from a snapshot.
 func (d *zfs) RestoreVolume(vol Volume, snapshotName string, op *operations.Operation) error {
 	snapVol := NewVolume(d, d.name, vol.volType, vol.contentType, fmt.Sprintf("%s/%s", vol.name, snapshotName), vol.config, vol.poolConfig)
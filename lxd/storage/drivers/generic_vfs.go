@@ -456,10 +456,13 @@ func genericVFSGetVolumeDiskPath(vol Volume) (string, error) {
 }
 
 // genericVFSBackupVolume is a generic BackupVolume implementation for VFS-only drivers.
+// If vol is a snapshot, it's exported as the main volume along with the given snapshots of its parent.
 func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.InstanceTarWriter, snapshots []string, op *operations.Operation) error {
+	parentVol, storageSnapshots := vol.backupParent(snapshots)
+
 	if len(snapshots) > 0 {
 		// Check requested snapshot match those in storage.
-		err := vol.SnapshotsMatch(snapshots, op)
+		err := parentVol.SnapshotsMatch(storageSnapshots, op)
 		if err != nil {
 			return err
 		}
@@ -611,7 +614,7 @@ func genericVFSBackupVolume(d Driver, vol Volume, tarWriter *instancewriter.Inst
 
 		for _, snapName := range snapshots {
 			prefix := filepath.Join(snapshotsPrefix, snapName)
-			snapVol, err := vol.NewSnapshot(snapName)
+			snapVol, err := parentVol.NewSnapshot(snapName)
 			if err != nil {
 				return err
 			}
@@ -80,6 +80,10 @@ type Driver interface {
 	VolumeSnapshots(vol Volume, op *operations.Operation) ([]string, error)
 	RestoreVolume(vol Volume, snapshotName string, op *operations.Operation) error
 
+	// DiffVolume returns the paths of vol (relative to its mount path) which differ from srcVol, using
+	// the driver's native clone relationship between them. Returns ErrNotSupported if not possible.
+	DiffVolume(vol Volume, srcVol Volume, op *operations.Operation) (map[string]string, error)
+
 	// Migration.
 	MigrationTypes(contentType ContentType, refresh bool) []migration.Type
 	MigrateVolume(vol Volume, conn io.ReadWriteCloser, volSrcArgs *migration.VolumeSourceArgs, op *operations.Operation) error
@@ -355,6 +355,20 @@ func (v Volume) SnapshotsMatch(snapNames []string, op *operations.Operation) err
 	return nil
 }
 
+// backupParent returns the volume whose snapshots are backed up along with the volume and the names of the snapshots
+// expected in its storage. When a volume is exported from a temporary snapshot of itself, that's the snapshot's
+// parent, which has the temporary snapshot in storage on top of the ones backed up.
+func (v Volume) backupParent(snapshots []string) (Volume, []string) {
+	if !v.IsSnapshot() {
+		return v, snapshots
+	}
+
+	parentName, snapName, _ := shared.InstanceGetParentAndSnapshotName(v.name)
+	parentVol := NewVolume(v.driver, v.pool, v.volType, v.contentType, parentName, v.config, v.poolConfig)
+
+	return parentVol, append([]string{snapName}, snapshots...)
+}
+
 // IsBlockBacked indicates whether storage device is block backed.
 func (v Volume) IsBlockBacked() bool {
 	return v.driver.Info().BlockBacking
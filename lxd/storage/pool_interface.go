@@ -59,6 +59,8 @@ type Pool interface {
 	CreateInstanceFromCopy(inst instance.Instance, src instance.Instance, snapshots bool, allowInconsistent bool, op *operations.Operation) error
 	CreateInstanceFromImage(inst instance.Instance, fingerprint string, op *operations.Operation) error
 	CreateInstanceFromMigration(inst instance.Instance, conn io.ReadWriteCloser, args migration.VolumeTargetArgs, op *operations.Operation) error
+	RebuildInstance(inst instance.Instance, fingerprint string, op *operations.Operation) (func() error, revert.Hook, error)
+	DiffInstance(inst instance.Instance, fingerprint string, op *operations.Operation) ([]api.InstanceDiffEntry, error)
 	RenameInstance(inst instance.Instance, newName string, op *operations.Operation) error
 	DeleteInstance(inst instance.Instance, op *operations.Operation) error
 	UpdateInstance(inst instance.Instance, newDesc string, newConfig map[string]string, op *operations.Operation) error
@@ -1,13 +1,17 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -28,6 +32,7 @@ import (
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/validate"
@@ -811,6 +816,7 @@ func VolumeUsedByExclusiveRemoteInstancesWithProfiles(s *state.State, poolName s
 func VolumeUsedByDaemon(s *state.State, poolName string, volumeName string) (bool, error) {
 	var storageBackups string
 	var storageImages string
+	var storageRecordings string
 	err := s.DB.Node.Transaction(func(tx *db.NodeTx) error {
 		nodeConfig, err := node.ConfigLoad(tx)
 		if err != nil {
@@ -819,6 +825,7 @@ func VolumeUsedByDaemon(s *state.State, poolName string, volumeName string) (boo
 
 		storageBackups = nodeConfig.StorageBackupsVolume()
 		storageImages = nodeConfig.StorageImagesVolume()
+		storageRecordings = nodeConfig.StorageRecordingsVolume()
 
 		return nil
 	})
@@ -827,7 +834,7 @@ func VolumeUsedByDaemon(s *state.State, poolName string, volumeName string) (boo
 	}
 
 	fullName := fmt.Sprintf("%s/%s", poolName, volumeName)
-	if storageBackups == fullName || storageImages == fullName {
+	if storageBackups == fullName || storageImages == fullName || storageRecordings == fullName {
 		return true, nil
 	}
 
@@ -919,3 +926,153 @@ func InstanceDiskBlockSize(pool Pool, inst instance.Instance, op *operations.Ope
 
 	return blockDiskSize, nil
 }
+
+// diffTrees compares the tree at dstPath with the one at srcPath and returns the paths (relative to the trees)
+// which were added, changed or removed in dstPath. Paths are changed if their type, permissions, ownership,
+// content (or symlink target, or device number) differ. If dstIdmap is set, it's used to unshift the ownership
+// of the paths in dstPath before comparing it.
+func diffTrees(srcPath string, dstPath string, dstIdmap *idmap.IdmapSet) (map[string]string, error) {
+	changes := map[string]string{}
+
+	err := filepath.Walk(dstPath, func(path string, dstInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dstPath, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		srcInfo, err := os.Lstat(filepath.Join(srcPath, relPath))
+		if err != nil {
+			if os.IsNotExist(err) {
+				changes[relPath] = api.InstanceDiffAdded
+				return nil
+			}
+
+			return err
+		}
+
+		changed, err := diffPath(filepath.Join(srcPath, relPath), srcInfo, path, dstInfo, dstIdmap)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			changes[relPath] = api.InstanceDiffChanged
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed comparing %q: %w", dstPath, err)
+	}
+
+	err = filepath.Walk(srcPath, func(path string, srcInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return err
+		}
+
+		_, err = os.Lstat(filepath.Join(dstPath, relPath))
+		if os.IsNotExist(err) {
+			changes[relPath] = api.InstanceDiffRemoved
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed comparing %q: %w", srcPath, err)
+	}
+
+	return changes, nil
+}
+
+// diffPath returns whether the path at dstPath differs from the one at srcPath.
+func diffPath(srcPath string, srcInfo os.FileInfo, dstPath string, dstInfo os.FileInfo, dstIdmap *idmap.IdmapSet) (bool, error) {
+	if srcInfo.Mode() != dstInfo.Mode() {
+		return true, nil
+	}
+
+	srcStat, ok := srcInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("Failed getting ownership of %q", srcPath)
+	}
+
+	dstStat, ok := dstInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("Failed getting ownership of %q", dstPath)
+	}
+
+	uid, gid := int64(dstStat.Uid), int64(dstStat.Gid)
+	if dstIdmap != nil {
+		uid, gid = dstIdmap.ShiftFromNs(uid, gid)
+	}
+
+	if int64(srcStat.Uid) != uid || int64(srcStat.Gid) != gid {
+		return true, nil
+	}
+
+	switch {
+	case srcInfo.Mode()&os.ModeSymlink != 0:
+		srcTarget, err := os.Readlink(srcPath)
+		if err != nil {
+			return false, err
+		}
+
+		dstTarget, err := os.Readlink(dstPath)
+		if err != nil {
+			return false, err
+		}
+
+		return srcTarget != dstTarget, nil
+	case srcInfo.Mode()&os.ModeDevice != 0:
+		return srcStat.Rdev != dstStat.Rdev, nil
+	case srcInfo.Mode().IsRegular():
+		if srcInfo.Size() != dstInfo.Size() {
+			return true, nil
+		}
+
+		srcHash, err := fileHash(srcPath)
+		if err != nil {
+			return false, err
+		}
+
+		dstHash, err := fileHash(dstPath)
+		if err != nil {
+			return false, err
+		}
+
+		return !bytes.Equal(srcHash, dstHash), nil
+	}
+
+	return false, nil
+}
+
+// fileHash returns the SHA256 hash of the content of the file at path.
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
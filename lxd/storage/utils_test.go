@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+// diffTreesSetup creates the same tree in two directories and returns their paths.
+func diffTreesSetup(t *testing.T) (string, string) {
+	srcPath := t.TempDir()
+	dstPath := t.TempDir()
+
+	for _, root := range []string{srcPath, dstPath} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "etc", "sub"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "hosts"), []byte("127.0.0.1 localhost\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "etc", "sub", "file"), []byte("file\n"), 0644))
+		require.NoError(t, os.Symlink("hosts", filepath.Join(root, "etc", "link")))
+	}
+
+	return srcPath, dstPath
+}
+
+func TestDiffTrees(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(t *testing.T, dstPath string)
+		changes map[string]string
+	}{
+		{
+			name:    "identical trees",
+			modify:  func(t *testing.T, dstPath string) {},
+			changes: map[string]string{},
+		},
+		{
+			name: "added paths",
+			modify: func(t *testing.T, dstPath string) {
+				require.NoError(t, os.Mkdir(filepath.Join(dstPath, "srv"), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(dstPath, "srv", "new"), []byte("new\n"), 0644))
+			},
+			changes: map[string]string{
+				"srv":     api.InstanceDiffAdded,
+				"srv/new": api.InstanceDiffAdded,
+			},
+		},
+		{
+			name: "removed paths",
+			modify: func(t *testing.T, dstPath string) {
+				require.NoError(t, os.RemoveAll(filepath.Join(dstPath, "etc", "sub")))
+			},
+			changes: map[string]string{
+				"etc/sub":      api.InstanceDiffRemoved,
+				"etc/sub/file": api.InstanceDiffRemoved,
+			},
+		},
+		{
+			name: "content changed with the same size",
+			modify: func(t *testing.T, dstPath string) {
+				require.NoError(t, os.WriteFile(filepath.Join(dstPath, "etc", "sub", "file"), []byte("edit\n"), 0644))
+			},
+			changes: map[string]string{
+				"etc/sub/file": api.InstanceDiffChanged,
+			},
+		},
+		{
+			name: "directory permissions changed",
+			modify: func(t *testing.T, dstPath string) {
+				require.NoError(t, os.Chmod(filepath.Join(dstPath, "etc", "sub"), 0700))
+			},
+			changes: map[string]string{
+				"etc/sub": api.InstanceDiffChanged,
+			},
+		},
+		{
+			name: "symlink target changed",
+			modify: func(t *testing.T, dstPath string) {
+				require.NoError(t, os.Remove(filepath.Join(dstPath, "etc", "link")))
+				require.NoError(t, os.Symlink("sub/file", filepath.Join(dstPath, "etc", "link")))
+			},
+			changes: map[string]string{
+				"etc/link": api.InstanceDiffChanged,
+			},
+		},
+		{
+			name: "type changed",
+			modify: func(t *testing.T, dstPath string) {
+				require.NoError(t, os.Remove(filepath.Join(dstPath, "etc", "link")))
+				require.NoError(t, os.WriteFile(filepath.Join(dstPath, "etc", "link"), []byte("hosts"), 0644))
+			},
+			changes: map[string]string{
+				"etc/link": api.InstanceDiffChanged,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcPath, dstPath := diffTreesSetup(t)
+			tt.modify(t, dstPath)
+
+			changes, err := diffTrees(srcPath, dstPath, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.changes, changes)
+		})
+	}
+}
+
+func TestDiffTreesIdmap(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Changing ownership requires root")
+	}
+
+	srcPath, dstPath := diffTreesSetup(t)
+
+	// Shift the ownership of the destination tree like a container volume shifted on disk.
+	diskIdmap := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
+		{Isuid: true, Hostid: 1000000, Nsid: 0, Maprange: 65536},
+		{Isgid: true, Hostid: 1000000, Nsid: 0, Maprange: 65536},
+	}}
+
+	err := filepath.Walk(dstPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		return os.Lchown(path, 1000000, 1000000)
+	})
+	require.NoError(t, err)
+
+	changes, err := diffTrees(srcPath, dstPath, diskIdmap)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{}, changes)
+
+	// Ownership changes inside the container are reported.
+	require.NoError(t, os.Lchown(filepath.Join(dstPath, "etc", "hosts"), 1001000, 1001000))
+
+	changes, err = diffTrees(srcPath, dstPath, diskIdmap)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"etc/hosts": api.InstanceDiffChanged}, changes)
+}
@@ -43,6 +43,7 @@ func (s *OS) initDirs() error {
 		{filepath.Join(s.VarDir, "images"), 0700},
 		{s.LogDir, 0700},
 		{filepath.Join(s.VarDir, "networks"), 0711},
+		{filepath.Join(s.VarDir, "recordings"), 0700},
 		{filepath.Join(s.VarDir, "security"), 0700},
 		{filepath.Join(s.VarDir, "security", "apparmor"), 0700},
 		{filepath.Join(s.VarDir, "security", "apparmor", "cache"), 0700},
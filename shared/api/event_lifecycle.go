@@ -41,6 +41,8 @@ const (
 	EventLifecycleInstanceFileDeleted               = "instance-file-deleted"
 	EventLifecycleInstanceFilePushed                = "instance-file-pushed"
 	EventLifecycleInstanceFileRetrieved             = "instance-file-retrieved"
+	EventLifecycleInstanceHealthy                   = "instance-healthy"
+	EventLifecycleInstanceIdleStopped               = "instance-idle-stopped"
 	EventLifecycleInstanceLogDeleted                = "instance-log-deleted"
 	EventLifecycleInstanceLogRetrieved              = "instance-log-retrieved"
 	EventLifecycleInstanceMetadataRetrieved         = "instance-metadata-retrieved"
@@ -49,6 +51,7 @@ const (
 	EventLifecycleInstanceMetadataTemplateRetrieved = "instance-metadata-template-retrieved"
 	EventLifecycleInstanceMetadataUpdated           = "instance-metadata-updated"
 	EventLifecycleInstancePaused                    = "instance-paused"
+	EventLifecycleInstanceRebuilt                   = "instance-rebuilt"
 	EventLifecycleInstanceRenamed                   = "instance-renamed"
 	EventLifecycleInstanceRestarted                 = "instance-restarted"
 	EventLifecycleInstanceRestored                  = "instance-restored"
@@ -60,6 +63,7 @@ const (
 	EventLifecycleInstanceSnapshotUpdated           = "instance-snapshot-updated"
 	EventLifecycleInstanceStarted                   = "instance-started"
 	EventLifecycleInstanceStopped                   = "instance-stopped"
+	EventLifecycleInstanceUnhealthy                 = "instance-unhealthy"
 	EventLifecycleInstanceUpdated                   = "instance-updated"
 	EventLifecycleNetworkACLCreated                 = "network-acl-created"
 	EventLifecycleNetworkACLDeleted                 = "network-acl-deleted"
@@ -67,6 +71,8 @@ const (
 	EventLifecycleNetworkACLUpdated                 = "network-acl-updated"
 	EventLifecycleNetworkCreated                    = "network-created"
 	EventLifecycleNetworkDeleted                    = "network-deleted"
+	EventLifecycleNetworkEgressAllowed              = "network-egress-allowed"
+	EventLifecycleNetworkEgressDenied               = "network-egress-denied"
 	EventLifecycleNetworkForwardCreated             = "network-forward-created"
 	EventLifecycleNetworkForwardDeleted             = "network-forward-deleted"
 	EventLifecycleNetworkForwardUpdated             = "network-forward-updated"
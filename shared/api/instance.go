@@ -117,6 +117,16 @@ type InstancePostTarget struct {
 	Websockets map[string]string `json:"secrets,omitempty" yaml:"secrets,omitempty"`
 }
 
+// InstanceRebuildPost represents the fields required to rebuild a LXD instance from an image.
+//
+// swagger:model
+//
+// API extension: instance_rebuild.
+type InstanceRebuildPost struct {
+	// Image to rebuild the instance from (only the image source fields are used)
+	Source InstanceSource `json:"source" yaml:"source"`
+}
+
 // InstancePut represents the modifiable fields of a LXD instance.
 //
 // swagger:model
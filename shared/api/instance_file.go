@@ -0,0 +1,65 @@
+package api
+
+import (
+	"time"
+)
+
+// InstanceFileWatchEventCreate is sent when a file or directory is created (or moved) into a watched directory.
+const InstanceFileWatchEventCreate = "create"
+
+// InstanceFileWatchEventModify is sent when a watched file (or a file in a watched directory) was written and closed.
+const InstanceFileWatchEventModify = "modify"
+
+// InstanceFileWatchEventDelete is sent when a watched file or directory (or an entry in it) is deleted or moved away.
+const InstanceFileWatchEventDelete = "delete"
+
+// InstanceFileWatchEventOverflow is sent when changes under the path may have been missed, either because the
+// kernel dropped events or because no more directories could be watched.
+const InstanceFileWatchEventOverflow = "overflow"
+
+// InstanceFileWatchEvent represents a change to a watched path inside an instance.
+//
+// swagger:model
+//
+// API extension: instance_file_watch.
+type InstanceFileWatchEvent struct {
+	// Path of the changed file inside the instance
+	// Example: /etc/nginx/nginx.conf
+	Path string `json:"path" yaml:"path"`
+
+	// Type of change (create, modify, delete or overflow)
+	// Example: modify
+	Action string `json:"action" yaml:"action"`
+
+	// Whether the changed path is a directory
+	// Example: false
+	Directory bool `json:"directory" yaml:"directory"`
+
+	// When the change was seen
+	// Example: 2021-03-23T20:00:00-04:00
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+}
+
+// InstanceDiffAdded is used for paths which don't exist in the image.
+const InstanceDiffAdded = "added"
+
+// InstanceDiffChanged is used for paths whose type, content, permissions or ownership differ from the image.
+const InstanceDiffChanged = "changed"
+
+// InstanceDiffRemoved is used for paths of the image which don't exist in the instance anymore.
+const InstanceDiffRemoved = "removed"
+
+// InstanceDiffEntry represents a difference between the root filesystem of an instance and its base image.
+//
+// swagger:model
+//
+// API extension: instance_diff.
+type InstanceDiffEntry struct {
+	// Path inside the instance
+	// Example: /etc/hosts
+	Path string `json:"path" yaml:"path"`
+
+	// Type of difference (added, changed or removed)
+	// Example: changed
+	Change string `json:"change" yaml:"change"`
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"time"
+)
+
+// InstanceRecordingTypeExec is the recording type of exec sessions.
+//
+// API extension: instance_session_recording.
+const InstanceRecordingTypeExec = "exec"
+
+// InstanceRecordingTypeConsole is the recording type of console sessions.
+//
+// API extension: instance_session_recording.
+const InstanceRecordingTypeConsole = "console"
+
+// InstanceRecording represents a recorded exec or console session of an instance.
+//
+// swagger:model
+//
+// API extension: instance_session_recording.
+type InstanceRecording struct {
+	// Recording name
+	// Example: 6d2e6b4c-2b39-4a4f-8f35-6b0c7c8ed0f1
+	Name string `json:"name" yaml:"name"`
+
+	// Type of session (exec or console)
+	// Example: exec
+	Type string `json:"type" yaml:"type"`
+
+	// Command which was run (exec sessions only)
+	// Example: ["bash"]
+	Command []string `json:"command" yaml:"command"`
+
+	// Whether the session had a terminal attached
+	// Example: true
+	Interactive bool `json:"interactive" yaml:"interactive"`
+
+	// User who started the session
+	// Example: 0b4e9a0b3a1c5ef8c3b4ebd4f8a8e8b48d3e6f0a
+	Username string `json:"username" yaml:"username"`
+
+	// Protocol used to authenticate the user
+	// Example: tls
+	Protocol string `json:"protocol" yaml:"protocol"`
+
+	// Address the session was started from
+	// Example: 10.0.0.1:45678
+	Address string `json:"address" yaml:"address"`
+
+	// When the session started
+	// Example: 2021-03-23T16:38:37.753398689-04:00
+	StartedAt time.Time `json:"started_at" yaml:"started_at"`
+
+	// When the session ended (empty while it's running)
+	// Example: 2021-03-23T16:40:12.412360131-04:00
+	EndedAt time.Time `json:"ended_at" yaml:"ended_at"`
+
+	// Exit code of the command (exec sessions only, -1 if unknown)
+	// Example: 0
+	ExitCode int `json:"exit_code" yaml:"exit_code"`
+
+	// Size of the recording in bytes
+	// Example: 16384
+	Size int64 `json:"size" yaml:"size"`
+}
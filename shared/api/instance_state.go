@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // InstanceStatePut represents the modifiable fields of a LXD instance's state.
 //
 // swagger:model
@@ -56,6 +60,16 @@ type InstanceState struct {
 
 	// CPU usage information
 	CPU InstanceStateCPU `json:"cpu" yaml:"cpu"`
+
+	// Health check state (only for running instances with a health check)
+	//
+	// API extension: instance_healthcheck
+	Health *InstanceStateHealth `json:"health,omitempty" yaml:"health,omitempty"`
+
+	// Guest OS details (only for running instances)
+	//
+	// API extension: instance_state_os
+	OS *InstanceStateOS `json:"os,omitempty" yaml:"os,omitempty"`
 }
 
 // InstanceStateDisk represents the disk information section of a LXD instance's state.
@@ -134,6 +148,11 @@ type InstanceStateNetwork struct {
 	// Type of interface (broadcast, loopback, point-to-point, ...)
 	// Example: broadcast
 	Type string `json:"type" yaml:"type"`
+
+	// Transfer accounting of the NIC device (only for NICs with a host-side interface)
+	//
+	// API extension: instance_nic_transfer
+	Transfer *InstanceStateNetworkTransfer `json:"transfer,omitempty" yaml:"transfer,omitempty"`
 }
 
 // InstanceStateNetworkAddress represents a network address as part of the network section of a LXD
@@ -199,3 +218,187 @@ type InstanceStateNetworkCounters struct {
 	// Example: 179
 	PacketsDroppedInbound int64 `json:"packets_dropped_inbound" yaml:"packets_dropped_inbound"`
 }
+
+// InstanceStateNetworkTransfer represents the transfer accounting of a NIC device as part of the network section
+// of a LXD instance's state. Received and sent are from the point of view of the instance.
+//
+// swagger:model
+//
+// API extension: instance_nic_transfer.
+type InstanceStateNetworkTransfer struct {
+	// Total number of bytes received since accounting started
+	// Example: 192021
+	BytesReceived int64 `json:"bytes_received" yaml:"bytes_received"`
+
+	// Total number of bytes sent since accounting started
+	// Example: 10888579
+	BytesSent int64 `json:"bytes_sent" yaml:"bytes_sent"`
+
+	// Start of the current billing period
+	// Example: 2022-07-01T00:00:00Z
+	PeriodStart time.Time `json:"period_start" yaml:"period_start"`
+
+	// Number of bytes received within the current billing period
+	// Example: 92021
+	PeriodBytesReceived int64 `json:"period_bytes_received" yaml:"period_bytes_received"`
+
+	// Number of bytes sent within the current billing period
+	// Example: 888579
+	PeriodBytesSent int64 `json:"period_bytes_sent" yaml:"period_bytes_sent"`
+
+	// Transfer limit in bytes for the billing period (0 if none)
+	// Example: 1000000000
+	Limit int64 `json:"limit" yaml:"limit"`
+
+	// Action applied to the NIC because the limit was reached (throttle or disconnect, empty if not reached)
+	// Example: throttle
+	Limited string `json:"limited" yaml:"limited"`
+}
+
+// InstanceStateHealth represents the health check section of a LXD instance's state.
+//
+// swagger:model
+//
+// API extension: instance_healthcheck.
+type InstanceStateHealth struct {
+	// Health status (starting, healthy or unhealthy)
+	// Example: healthy
+	Status string `json:"status" yaml:"status"`
+
+	// Number of consecutive failed checks
+	// Example: 0
+	Failures int64 `json:"failures" yaml:"failures"`
+
+	// Error of the last failed check
+	// Example: Connection refused
+	LastError string `json:"last_error" yaml:"last_error"`
+}
+
+// InstanceStateOS represents the guest OS section of a LXD instance's state.
+//
+// swagger:model
+//
+// API extension: instance_state_os.
+type InstanceStateOS struct {
+	// Name of the OS
+	// Example: Ubuntu
+	Name string `json:"name" yaml:"name"`
+
+	// Version of the OS
+	// Example: 22.04
+	Version string `json:"version" yaml:"version"`
+
+	// Version of the running kernel
+	// Example: 5.15.0-48-generic
+	Kernel string `json:"kernel" yaml:"kernel"`
+
+	// Hostname of the guest
+	// Example: c1
+	Hostname string `json:"hostname" yaml:"hostname"`
+
+	// Number of seconds since the guest OS started
+	// Example: 3600
+	Uptime int64 `json:"uptime" yaml:"uptime"`
+
+	// Logged-in users
+	Users []InstanceStateOSUser `json:"users" yaml:"users"`
+
+	// Number of installed packages by package manager
+	// Example: {"dpkg": 512}
+	Packages map[string]int64 `json:"packages" yaml:"packages"`
+
+	// Listening network ports
+	ListeningPorts []InstanceStateOSPort `json:"listening_ports" yaml:"listening_ports"`
+}
+
+// InstanceStateOSUser represents a logged-in user as part of the guest OS section of a LXD instance's state.
+//
+// swagger:model
+//
+// API extension: instance_state_os.
+type InstanceStateOSUser struct {
+	// User name
+	// Example: ubuntu
+	Name string `json:"name" yaml:"name"`
+
+	// Terminal the user is logged in on
+	// Example: pts/0
+	Terminal string `json:"terminal" yaml:"terminal"`
+
+	// Host the user is logged in from (empty for local logins)
+	// Example: 10.0.0.1
+	Host string `json:"host" yaml:"host"`
+
+	// When the user logged in
+	// Example: 2022-07-01T00:00:00Z
+	LoginTime time.Time `json:"login_time" yaml:"login_time"`
+}
+
+// InstanceStateOSPort represents a listening port as part of the guest OS section of a LXD instance's state.
+//
+// swagger:model
+//
+// API extension: instance_state_os.
+type InstanceStateOSPort struct {
+	// Protocol (tcp or udp)
+	// Example: tcp
+	Protocol string `json:"protocol" yaml:"protocol"`
+
+	// Address the port is bound to
+	// Example: 0.0.0.0
+	Address string `json:"address" yaml:"address"`
+
+	// Port number
+	// Example: 22
+	Port int64 `json:"port" yaml:"port"`
+}
+
+// InstanceStateHistory represents the recent resource usage of a LXD instance.
+//
+// swagger:model
+//
+// API extension: instance_state_history.
+type InstanceStateHistory struct {
+	// Number of seconds between samples
+	// Example: 60
+	Interval int64 `json:"interval" yaml:"interval"`
+
+	// Samples, oldest first
+	Samples []InstanceStateHistorySample `json:"samples" yaml:"samples"`
+}
+
+// InstanceStateHistorySample represents a resource usage sample of a LXD instance.
+// Apart from the memory usage, the values are counters since the instance started.
+//
+// swagger:model
+//
+// API extension: instance_state_history.
+type InstanceStateHistorySample struct {
+	// Time the sample was taken at
+	// Example: 2022-07-01T00:00:00Z
+	Time time.Time `json:"time" yaml:"time"`
+
+	// CPU time used in seconds
+	// Example: 3637
+	CPUSeconds float64 `json:"cpu_seconds" yaml:"cpu_seconds"`
+
+	// Memory usage in bytes (excluding caches)
+	// Example: 73248768
+	MemoryUsage int64 `json:"memory_usage" yaml:"memory_usage"`
+
+	// Number of bytes read from disks
+	// Example: 502239232
+	DiskReadBytes int64 `json:"disk_read_bytes" yaml:"disk_read_bytes"`
+
+	// Number of bytes written to disks
+	// Example: 102239232
+	DiskWrittenBytes int64 `json:"disk_written_bytes" yaml:"disk_written_bytes"`
+
+	// Number of bytes received on network interfaces
+	// Example: 192021
+	NetworkReceivedBytes int64 `json:"network_received_bytes" yaml:"network_received_bytes"`
+
+	// Number of bytes sent on network interfaces
+	// Example: 10888579
+	NetworkSentBytes int64 `json:"network_sent_bytes" yaml:"network_sent_bytes"`
+}
@@ -0,0 +1,27 @@
+package api
+
+// InstanceUEFIVars represents the UEFI variables stored in the NVRAM of a LXD virtual machine.
+//
+// swagger:model
+//
+// API extension: instance_uefi_vars.
+type InstanceUEFIVars struct {
+	// UEFI variables, indexed by <name>-<vendor GUID> (same as efivarfs)
+	// Example: {"BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c": {"data": "01000000", "attributes": 7}}
+	Variables map[string]InstanceUEFIVariable `json:"variables" yaml:"variables"`
+}
+
+// InstanceUEFIVariable represents a UEFI variable.
+//
+// swagger:model
+//
+// API extension: instance_uefi_vars.
+type InstanceUEFIVariable struct {
+	// Hex encoded value
+	// Example: 01000000
+	Data string `json:"data" yaml:"data"`
+
+	// Attributes (EFI_VARIABLE_* bit mask)
+	// Example: 7
+	Attributes uint32 `json:"attributes" yaml:"attributes"`
+}
@@ -0,0 +1,25 @@
+package api
+
+// NetworkFirewallRuleset represents the firewall rules LXD generated for a network or instance device
+// alongside the rules currently loaded in the kernel
+//
+// swagger:model
+//
+// API extension: network_firewall_ruleset.
+type NetworkFirewallRuleset struct {
+	// Name of the firewall driver in use
+	// Example: nftables
+	Driver string `json:"driver" yaml:"driver"`
+
+	// Config generated by LXD from the current configuration
+	// Example: table inet lxd {...}
+	Generated string `json:"generated" yaml:"generated"`
+
+	// Rules currently loaded in the kernel
+	// Example: table inet lxd {...}
+	Loaded string `json:"loaded" yaml:"loaded"`
+
+	// Differences between the generated rules and the loaded ones
+	// Example: -inet in.lxdbr0: iifname "lxdbr0" tcp dport 53 accept
+	Diff string `json:"diff" yaml:"diff"`
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kballard/go-shellquote"
+
 	"github.com/lxc/lxd/lxd/instance/instancetype"
 	"github.com/lxc/lxd/shared/units"
 	"github.com/lxc/lxd/shared/validate"
@@ -77,11 +79,33 @@ var HugePageSizeSuffix = [...]string{"64KB", "1MB", "2MB", "1GB"}
 
 // InstanceConfigKeysAny is a map of config key to validator. (keys applying to containers AND virtual machines).
 var InstanceConfigKeysAny = map[string]func(value string) error{
+	"boot.autorestart":           validate.Optional(validate.IsOneOf("never", "on-failure", "always")),
+	"boot.autorestart.delay":     validate.Optional(validate.IsUint32),
+	"boot.autorestart.retries":   validate.Optional(validate.IsUint32),
 	"boot.autostart":             validate.Optional(validate.IsBool),
 	"boot.autostart.delay":       validate.Optional(validate.IsInt64),
 	"boot.autostart.priority":    validate.Optional(validate.IsInt64),
+	"boot.depends_on":            validate.Optional(validate.IsListOf(validate.IsHostname)),
 	"boot.stop.priority":         validate.Optional(validate.IsInt64),
 	"boot.host_shutdown_timeout": validate.Optional(validate.IsInt64),
+	"boot.idle_stop":             validate.Optional(validate.IsUint32),
+	"boot.idle_stop.cpu":         validate.Optional(validate.IsUint32),
+	"boot.idle_stop.network":     validate.Optional(validate.IsSize),
+	"boot.idle_stop.stateful":    validate.Optional(validate.IsBool),
+	"boot.ready": func(value string) error {
+		if value == "" || value == "running" || value == "ready" {
+			return nil
+		}
+
+		if strings.HasPrefix(value, "tcp:") {
+			return validate.IsNetworkPort(strings.TrimPrefix(value, "tcp:"))
+		}
+
+		return fmt.Errorf(`Must be "running", "ready" or "tcp:<port>"`)
+	},
+	"boot.ready.timeout":  validate.Optional(validate.IsUint32),
+	"boot.schedule.start": validate.Optional(validate.IsCron([]string{"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@annually", "@yearly", "@never"})),
+	"boot.schedule.stop":  validate.Optional(validate.IsCron([]string{"@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@annually", "@yearly", "@never"})),
 
 	"cloud-init.network-config": validate.Optional(validate.IsAny),
 	"cloud-init.user-data":      validate.Optional(validate.IsAny),
@@ -89,6 +113,18 @@ var InstanceConfigKeysAny = map[string]func(value string) error{
 
 	"cluster.evacuate": validate.Optional(validate.IsOneOf("auto", "migrate", "live-migrate", "stop")),
 
+	"healthcheck.type": validate.Optional(validate.IsOneOf("exec", "tcp", "http")),
+	"healthcheck.command": func(value string) error {
+		_, err := shellquote.Split(value)
+		return err
+	},
+	"healthcheck.port":     validate.Optional(validate.IsNetworkPort),
+	"healthcheck.path":     validate.IsAny,
+	"healthcheck.interval": validate.Optional(validate.IsUint32),
+	"healthcheck.timeout":  validate.Optional(validate.IsUint32),
+	"healthcheck.retries":  validate.Optional(validate.IsUint32),
+	"healthcheck.restart":  validate.Optional(validate.IsBool),
+
 	"limits.cpu": func(value string) error {
 		if value == "" {
 			return nil
@@ -142,7 +178,18 @@ var InstanceConfigKeysAny = map[string]func(value string) error{
 
 		return nil
 	},
-	"limits.network.priority": validate.Optional(validate.IsPriority),
+	"limits.network.priority":           validate.Optional(validate.IsPriority),
+	"limits.network.transfer":           validate.Optional(validate.IsSize),
+	"limits.network.transfer.action":    validate.Optional(validate.IsOneOf("throttle", "disconnect")),
+	"limits.network.transfer.reset_day": validate.Optional(validate.IsInRange(1, 28)),
+	"limits.network.transfer.throttle": func(value string) error {
+		if value == "" {
+			return nil
+		}
+
+		_, err := units.ParseBitSizeString(value)
+		return err
+	},
 
 	// Caller is responsible for full validation of any raw.* value.
 	"raw.apparmor": validate.IsAny,
@@ -161,9 +208,13 @@ var InstanceConfigKeysAny = map[string]func(value string) error{
 
 	// Volatile keys.
 	"volatile.apply_template":         validate.IsAny,
+	"volatile.autorestart.count":      validate.Optional(validate.IsUint32),
 	"volatile.base_image":             validate.IsAny,
 	"volatile.cloud-init.instance-id": validate.Optional(validate.IsUUID),
 	"volatile.evacuate.origin":        validate.IsAny,
+	"volatile.health.error":           validate.IsAny,
+	"volatile.health.failures":        validate.Optional(validate.IsUint32),
+	"volatile.health.status":          validate.Optional(validate.IsOneOf("healthy", "unhealthy")),
 	"volatile.last_state.idmap":       validate.IsAny,
 	"volatile.last_state.power":       validate.IsAny,
 	"volatile.last_state.ready":       validate.IsBool,
@@ -235,6 +286,11 @@ var InstanceConfigKeysContainer = map[string]func(value string) error{
 	"nvidia.require.cuda":        validate.IsAny,
 	"nvidia.require.driver":      validate.IsAny,
 
+	"oci.cwd":        validate.IsAny,
+	"oci.entrypoint": validate.IsAny,
+	"oci.gid":        validate.Optional(validate.IsUint32),
+	"oci.uid":        validate.Optional(validate.IsUint32),
+
 	// Caller is responsible for full validation of any raw.* value.
 	"raw.lxc":     validate.IsAny,
 	"raw.seccomp": validate.IsAny,
@@ -271,6 +327,8 @@ var InstanceConfigKeysContainer = map[string]func(value string) error{
 
 // InstanceConfigKeysVM is a map of config key to validator. (keys applying to VM only).
 var InstanceConfigKeysVM = map[string]func(value string) error{
+	"limits.cpu.hotplug":      validate.Optional(validate.IsInRange(1, 288)),
+	"limits.memory.hotplug":   validate.Optional(validate.IsSize),
 	"limits.memory.hugepages": validate.Optional(validate.IsBool),
 
 	"migration.stateful": validate.Optional(validate.IsBool),
@@ -282,6 +340,9 @@ var InstanceConfigKeysVM = map[string]func(value string) error{
 	"security.agent.metrics": validate.Optional(validate.IsBool),
 	"security.secureboot":    validate.Optional(validate.IsBool),
 
+	"snapshots.fsfreeze":         validate.Optional(validate.IsBool),
+	"snapshots.fsfreeze.timeout": validate.Optional(validate.IsInRange(1, 3600)),
+
 	"agent.nic_config": validate.Optional(validate.IsBool),
 
 	"volatile.apply_nvram": validate.Optional(validate.IsBool),
@@ -369,6 +430,26 @@ func ConfigKeyChecker(key string, instanceType instancetype.Type) (func(value st
 		if strings.HasSuffix(key, ".last_state.ready") {
 			return validate.IsBool, nil
 		}
+
+		if strings.HasSuffix(key, ".transfer.rx") || strings.HasSuffix(key, ".transfer.tx") {
+			return validate.IsInt64, nil
+		}
+
+		if strings.HasSuffix(key, ".transfer.period.rx") || strings.HasSuffix(key, ".transfer.period.tx") {
+			return validate.IsInt64, nil
+		}
+
+		if strings.HasSuffix(key, ".transfer.period.start") {
+			return validate.IsInt64, nil
+		}
+
+		if strings.HasSuffix(key, ".transfer.last") {
+			return validate.IsAny, nil
+		}
+
+		if strings.HasSuffix(key, ".transfer.limited") {
+			return validate.IsOneOf("throttle", "disconnect"), nil
+		}
 	}
 
 	if strings.HasPrefix(key, "environment.") {
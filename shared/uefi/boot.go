@@ -0,0 +1,86 @@
+package uefi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// LoadOption represents a boot entry (EFI_LOAD_OPTION), as stored in the Boot#### variables.
+type LoadOption struct {
+	Attributes   uint32
+	Description  string
+	FilePath     []byte
+	OptionalData []byte
+}
+
+// LoadOptionActive is the attribute of boot entries the firmware attempts to boot from.
+const LoadOptionActive uint32 = 0x01
+
+// BootEntryName returns the name of the variable holding the given boot entry.
+func BootEntryName(number uint16) string {
+	return fmt.Sprintf("Boot%04X", number)
+}
+
+// ParseLoadOption parses the content of a Boot#### variable.
+func ParseLoadOption(data []byte) (*LoadOption, error) {
+	if len(data) < 6 {
+		return nil, fmt.Errorf("Truncated load option")
+	}
+
+	option := &LoadOption{Attributes: binary.LittleEndian.Uint32(data[0:])}
+	filePathSize := int(binary.LittleEndian.Uint16(data[4:]))
+
+	// The description is a NUL terminated UCS-2 string.
+	offset := 6
+	chars := []uint16{}
+	for {
+		if offset+2 > len(data) {
+			return nil, fmt.Errorf("Truncated load option description")
+		}
+
+		c := binary.LittleEndian.Uint16(data[offset:])
+		offset += 2
+
+		if c == 0 {
+			break
+		}
+
+		chars = append(chars, c)
+	}
+
+	option.Description = string(utf16.Decode(chars))
+
+	if offset+filePathSize > len(data) {
+		return nil, fmt.Errorf("Truncated load option file path")
+	}
+
+	option.FilePath = data[offset : offset+filePathSize]
+	option.OptionalData = data[offset+filePathSize:]
+
+	return option, nil
+}
+
+// ParseBootOrder parses the content of the BootOrder variable.
+func ParseBootOrder(data []byte) ([]uint16, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("Invalid boot order size %d", len(data))
+	}
+
+	order := make([]uint16, 0, len(data)/2)
+	for i := 0; i < len(data); i += 2 {
+		order = append(order, binary.LittleEndian.Uint16(data[i:]))
+	}
+
+	return order, nil
+}
+
+// BootOrder returns the content of the BootOrder variable for the given boot entries.
+func BootOrder(order []uint16) []byte {
+	data := make([]byte, len(order)*2)
+	for i, number := range order {
+		binary.LittleEndian.PutUint16(data[i*2:], number)
+	}
+
+	return data
+}
@@ -0,0 +1,57 @@
+package uefi
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// GUID represents a UEFI GUID in its binary encoding (first three fields in little endian).
+type GUID [16]byte
+
+// GlobalVariableGUID is the vendor GUID of the variables defined by the UEFI specification (EFI_GLOBAL_VARIABLE).
+var GlobalVariableGUID = MustParseGUID("8be4df61-93ca-11d2-aa0d-00e098032b8c")
+
+// ImageSecurityDatabaseGUID is the vendor GUID of the db and dbx variables (EFI_IMAGE_SECURITY_DATABASE_GUID).
+var ImageSecurityDatabaseGUID = MustParseGUID("d719b2cb-3d3a-4596-a3bc-dad00e67656f")
+
+// CertX509GUID is the signature type of X.509 certificates in signature lists (EFI_CERT_X509_GUID).
+var CertX509GUID = MustParseGUID("a5c059a1-94e4-4aa7-87b5-ab155c2bf072")
+
+// ParseGUID parses a GUID from its textual representation.
+func ParseGUID(s string) (GUID, error) {
+	var guid GUID
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return guid, fmt.Errorf("Invalid GUID %q", s)
+	}
+
+	raw, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return guid, fmt.Errorf("Invalid GUID %q: %w", s, err)
+	}
+
+	binary.LittleEndian.PutUint32(guid[0:4], binary.BigEndian.Uint32(raw[0:4]))
+	binary.LittleEndian.PutUint16(guid[4:6], binary.BigEndian.Uint16(raw[4:6]))
+	binary.LittleEndian.PutUint16(guid[6:8], binary.BigEndian.Uint16(raw[6:8]))
+	copy(guid[8:], raw[8:])
+
+	return guid, nil
+}
+
+// MustParseGUID parses a GUID from its textual representation and panics on failure.
+func MustParseGUID(s string) GUID {
+	guid, err := ParseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return guid
+}
+
+// String returns the textual representation of the GUID.
+func (g GUID) String() string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(g[0:4]), binary.LittleEndian.Uint16(g[4:6]), binary.LittleEndian.Uint16(g[6:8]), g[8:10], g[10:16])
+}
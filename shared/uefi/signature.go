@@ -0,0 +1,65 @@
+package uefi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// signatureListHeaderSize is the size of the EFI_SIGNATURE_LIST header.
+const signatureListHeaderSize = 28
+
+// SignatureList returns the EFI_SIGNATURE_LIST encoding of the DER encoded X.509 certificates, as stored in the PK,
+// KEK, db and dbx variables. Each certificate gets its own list as all signatures in a list have the same size.
+func SignatureList(owner GUID, certs ...[]byte) []byte {
+	data := []byte{}
+
+	for _, cert := range certs {
+		signatureSize := len(owner) + len(cert)
+
+		list := make([]byte, signatureListHeaderSize, signatureListHeaderSize+signatureSize)
+		copy(list[0:16], CertX509GUID[:])
+		binary.LittleEndian.PutUint32(list[16:], uint32(signatureListHeaderSize+signatureSize))
+		binary.LittleEndian.PutUint32(list[20:], 0)
+		binary.LittleEndian.PutUint32(list[24:], uint32(signatureSize))
+		list = append(list, owner[:]...)
+		list = append(list, cert...)
+
+		data = append(data, list...)
+	}
+
+	return data
+}
+
+// SignatureListCertificates returns the DER encoded X.509 certificates in the EFI_SIGNATURE_LIST encoded data.
+// Signatures of other types (such as hashes) are skipped.
+func SignatureListCertificates(data []byte) ([][]byte, error) {
+	certs := [][]byte{}
+
+	for len(data) > 0 {
+		if len(data) < signatureListHeaderSize {
+			return nil, fmt.Errorf("Truncated signature list")
+		}
+
+		var signatureType GUID
+		copy(signatureType[:], data[0:16])
+		listSize := int(binary.LittleEndian.Uint32(data[16:]))
+		headerSize := int(binary.LittleEndian.Uint32(data[20:]))
+		signatureSize := int(binary.LittleEndian.Uint32(data[24:]))
+
+		if listSize < signatureListHeaderSize+headerSize || listSize > len(data) || signatureSize <= len(signatureType) {
+			return nil, fmt.Errorf("Invalid signature list")
+		}
+
+		if signatureType == CertX509GUID {
+			signatures := data[signatureListHeaderSize+headerSize : listSize]
+			for len(signatures) >= signatureSize {
+				certs = append(certs, signatures[len(signatureType):signatureSize])
+				signatures = signatures[signatureSize:]
+			}
+		}
+
+		data = data[listSize:]
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,324 @@
+package uefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Variable attributes.
+const (
+	AttributeNonVolatile                       uint32 = 0x01
+	AttributeBootServiceAccess                 uint32 = 0x02
+	AttributeRuntimeAccess                     uint32 = 0x04
+	AttributeTimeBasedAuthenticatedWriteAccess uint32 = 0x20
+)
+
+// AttributesDefault are the attributes of regular non-volatile variables.
+const AttributesDefault = AttributeNonVolatile | AttributeBootServiceAccess | AttributeRuntimeAccess
+
+// AttributesAuthenticated are the attributes of the Secure Boot key variables (PK, KEK, db and dbx).
+const AttributesAuthenticated = AttributesDefault | AttributeTimeBasedAuthenticatedWriteAccess
+
+// Firmware volume and variable store layout, as defined by EDK2.
+const (
+	fvSignature          = "_FVH"
+	fvSignatureOffset    = 40
+	fvHeaderLengthOffset = 48
+
+	storeHeaderSize  = 28
+	storeFormatted   = 0x5a
+	storeHealthy     = 0xfe
+	variableStartID  = 0x55aa
+	variableAdded    = 0x3f
+	variableDeleting = 0x3e
+
+	variableHeaderSize     = 32
+	authVariableHeaderSize = 60
+	headerAlignment        = 4
+)
+
+// authVariableStoreGUID identifies variable stores holding authenticated variables (gEfiAuthenticatedVariableGuid).
+var authVariableStoreGUID = MustParseGUID("aaf32c78-947b-439a-a180-2e144ec37792")
+
+// variableStoreGUID identifies variable stores holding regular variables (gEfiVariableGuid).
+var variableStoreGUID = MustParseGUID("ddcf3616-3275-4164-98b6-fe85707ffe7d")
+
+// Variable represents a UEFI variable.
+type Variable struct {
+	Name       string
+	GUID       GUID
+	Attributes uint32
+	Data       []byte
+
+	// Only used by authenticated variable stores.
+	MonotonicCount uint64
+	Timestamp      [16]byte
+	PubKeyIndex    uint32
+}
+
+// Key returns the key identifying the variable, in the same <name>-<GUID> format as efivarfs.
+func (v Variable) Key() string {
+	return VariableKey(v.Name, v.GUID)
+}
+
+// VariableKey returns the key identifying a variable, in the same <name>-<GUID> format as efivarfs.
+func VariableKey(name string, guid GUID) string {
+	return fmt.Sprintf("%s-%s", name, guid)
+}
+
+// ParseVariableKey parses a key in the <name>-<GUID> format.
+func ParseVariableKey(key string) (string, GUID, error) {
+	// The GUID is 36 characters long, preceded by a dash.
+	if len(key) < 38 || key[len(key)-37] != '-' {
+		return "", GUID{}, fmt.Errorf("Invalid variable key %q, expected <name>-<GUID>", key)
+	}
+
+	guid, err := ParseGUID(key[len(key)-36:])
+	if err != nil {
+		return "", GUID{}, fmt.Errorf("Invalid variable key %q: %w", key, err)
+	}
+
+	return key[:len(key)-37], guid, nil
+}
+
+// Timestamp returns the EFI_TIME encoding of t, as used by time based authenticated variables.
+func Timestamp(t time.Time) [16]byte {
+	var ts [16]byte
+
+	t = t.UTC()
+	binary.LittleEndian.PutUint16(ts[0:2], uint16(t.Year()))
+	ts[2] = byte(t.Month())
+	ts[3] = byte(t.Day())
+	ts[4] = byte(t.Hour())
+	ts[5] = byte(t.Minute())
+	ts[6] = byte(t.Second())
+
+	return ts
+}
+
+// VarStore represents the variable store of an EDK2 (OVMF/AAVMF) NVRAM file.
+type VarStore struct {
+	Variables []Variable
+
+	raw           []byte
+	storeOffset   int
+	storeSize     int
+	authenticated bool
+}
+
+// ParseVarStore parses the content of an EDK2 NVRAM file.
+func ParseVarStore(raw []byte) (*VarStore, error) {
+	if len(raw) < fvHeaderLengthOffset+2 || string(raw[fvSignatureOffset:fvSignatureOffset+4]) != fvSignature {
+		return nil, fmt.Errorf("Invalid NVRAM file, missing firmware volume header")
+	}
+
+	s := &VarStore{
+		raw:         raw,
+		storeOffset: int(binary.LittleEndian.Uint16(raw[fvHeaderLengthOffset:])),
+	}
+
+	if len(raw) < s.storeOffset+storeHeaderSize {
+		return nil, fmt.Errorf("Invalid NVRAM file, missing variable store header")
+	}
+
+	var storeGUID GUID
+	copy(storeGUID[:], raw[s.storeOffset:])
+
+	switch storeGUID {
+	case authVariableStoreGUID:
+		s.authenticated = true
+	case variableStoreGUID:
+		s.authenticated = false
+	default:
+		return nil, fmt.Errorf("Unsupported variable store %q", storeGUID)
+	}
+
+	s.storeSize = int(binary.LittleEndian.Uint32(raw[s.storeOffset+16:]))
+	if s.storeSize < storeHeaderSize || len(raw) < s.storeOffset+s.storeSize {
+		return nil, fmt.Errorf("Invalid variable store size %d", s.storeSize)
+	}
+
+	if raw[s.storeOffset+20] != storeFormatted || raw[s.storeOffset+21] != storeHealthy {
+		return nil, fmt.Errorf("Variable store isn't formatted or isn't healthy")
+	}
+
+	headerSize := variableHeaderSize
+	if s.authenticated {
+		headerSize = authVariableHeaderSize
+	}
+
+	end := s.storeOffset + s.storeSize
+	deleting := map[string]Variable{}
+	seen := map[string]bool{}
+
+	offset := alignHeader(s.storeOffset + storeHeaderSize)
+	for offset+headerSize <= end && binary.LittleEndian.Uint16(raw[offset:]) == variableStartID {
+		header := raw[offset : offset+headerSize]
+		state := header[2]
+
+		v := Variable{Attributes: binary.LittleEndian.Uint32(header[4:])}
+
+		var nameSize, dataSize int
+		if s.authenticated {
+			v.MonotonicCount = binary.LittleEndian.Uint64(header[8:])
+			copy(v.Timestamp[:], header[16:32])
+			v.PubKeyIndex = binary.LittleEndian.Uint32(header[32:])
+			nameSize = int(binary.LittleEndian.Uint32(header[36:]))
+			dataSize = int(binary.LittleEndian.Uint32(header[40:]))
+			copy(v.GUID[:], header[44:60])
+		} else {
+			nameSize = int(binary.LittleEndian.Uint32(header[8:]))
+			dataSize = int(binary.LittleEndian.Uint32(header[12:]))
+			copy(v.GUID[:], header[16:32])
+		}
+
+		nameOffset := offset + headerSize
+		if nameSize < 0 || dataSize < 0 || nameOffset+nameSize+dataSize > end {
+			return nil, fmt.Errorf("Invalid variable at offset %d", offset)
+		}
+
+		v.Name = decodeName(raw[nameOffset : nameOffset+nameSize])
+		v.Data = append([]byte{}, raw[nameOffset+nameSize:nameOffset+nameSize+dataSize]...)
+
+		// Only keep the variables that are set, preferring them over those being replaced.
+		switch state {
+		case variableAdded:
+			s.Variables = append(s.Variables, v)
+			seen[v.Key()] = true
+		case variableDeleting:
+			deleting[v.Key()] = v
+		}
+
+		offset = alignHeader(nameOffset + nameSize + dataSize)
+	}
+
+	for key, v := range deleting {
+		if !seen[key] {
+			s.Variables = append(s.Variables, v)
+		}
+	}
+
+	return s, nil
+}
+
+// Get returns the variable with the given name and GUID, or nil if not set.
+func (s *VarStore) Get(name string, guid GUID) *Variable {
+	for i := range s.Variables {
+		if s.Variables[i].Name == name && s.Variables[i].GUID == guid {
+			return &s.Variables[i]
+		}
+	}
+
+	return nil
+}
+
+// Set adds the variable, replacing any variable with the same name and GUID.
+func (s *VarStore) Set(v Variable) {
+	existing := s.Get(v.Name, v.GUID)
+	if existing != nil {
+		*existing = v
+		return
+	}
+
+	s.Variables = append(s.Variables, v)
+}
+
+// Delete removes the variable with the given name and GUID, returning whether it was set.
+func (s *VarStore) Delete(name string, guid GUID) bool {
+	for i := range s.Variables {
+		if s.Variables[i].Name == name && s.Variables[i].GUID == guid {
+			s.Variables = append(s.Variables[:i], s.Variables[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bytes returns the content of the NVRAM file with the variable store rewritten to hold the current variables.
+// Anything outside of the variable store is kept as is.
+func (s *VarStore) Bytes() ([]byte, error) {
+	raw := append([]byte{}, s.raw...)
+
+	// Wipe the existing variables.
+	start := alignHeader(s.storeOffset + storeHeaderSize)
+	end := s.storeOffset + s.storeSize
+	copy(raw[start:end], bytes.Repeat([]byte{0xff}, end-start))
+
+	offset := start
+	for _, v := range s.Variables {
+		if v.Name == "" {
+			return nil, fmt.Errorf("Variable name is required")
+		}
+
+		name := encodeName(v.Name)
+
+		var header []byte
+		if s.authenticated {
+			header = make([]byte, authVariableHeaderSize)
+			binary.LittleEndian.PutUint64(header[8:], v.MonotonicCount)
+			copy(header[16:32], v.Timestamp[:])
+			binary.LittleEndian.PutUint32(header[32:], v.PubKeyIndex)
+			binary.LittleEndian.PutUint32(header[36:], uint32(len(name)))
+			binary.LittleEndian.PutUint32(header[40:], uint32(len(v.Data)))
+			copy(header[44:60], v.GUID[:])
+		} else {
+			header = make([]byte, variableHeaderSize)
+			binary.LittleEndian.PutUint32(header[8:], uint32(len(name)))
+			binary.LittleEndian.PutUint32(header[12:], uint32(len(v.Data)))
+			copy(header[16:32], v.GUID[:])
+		}
+
+		binary.LittleEndian.PutUint16(header[0:], variableStartID)
+		header[2] = variableAdded
+		binary.LittleEndian.PutUint32(header[4:], v.Attributes)
+
+		size := len(header) + len(name) + len(v.Data)
+		if offset+size > end {
+			return nil, fmt.Errorf("Not enough space in variable store for %q", v.Key())
+		}
+
+		offset += copy(raw[offset:], header)
+		offset += copy(raw[offset:], name)
+		offset += copy(raw[offset:], v.Data)
+		offset = alignHeader(offset)
+	}
+
+	return raw, nil
+}
+
+// alignHeader returns the offset of the next variable header at or after offset.
+func alignHeader(offset int) int {
+	return (offset + headerAlignment - 1) &^ (headerAlignment - 1)
+}
+
+// decodeName decodes a NUL terminated UCS-2 variable name.
+func decodeName(raw []byte) string {
+	chars := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		c := binary.LittleEndian.Uint16(raw[i:])
+		if c == 0 {
+			break
+		}
+
+		chars = append(chars, c)
+	}
+
+	return string(utf16.Decode(chars))
+}
+
+// encodeName encodes a variable name as NUL terminated UCS-2.
+func encodeName(name string) []byte {
+	chars := utf16.Encode([]rune(strings.TrimRight(name, "\x00")))
+
+	raw := make([]byte, (len(chars)+1)*2)
+	for i, c := range chars {
+		binary.LittleEndian.PutUint16(raw[i*2:], c)
+	}
+
+	return raw
+}
@@ -0,0 +1,122 @@
+package uefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestNVRAM returns an empty NVRAM file holding a variable store of the given size after its header.
+func newTestNVRAM(storeGUID GUID, storeSize int) []byte {
+	fvHeaderLength := 72
+	raw := bytes.Repeat([]byte{0xff}, fvHeaderLength+storeSize+64)
+
+	copy(raw[0:16], make([]byte, 16))
+	copy(raw[fvSignatureOffset:], fvSignature)
+	binary.LittleEndian.PutUint16(raw[fvHeaderLengthOffset:], uint16(fvHeaderLength))
+
+	copy(raw[fvHeaderLength:], storeGUID[:])
+	binary.LittleEndian.PutUint32(raw[fvHeaderLength+16:], uint32(storeSize))
+	raw[fvHeaderLength+20] = storeFormatted
+	raw[fvHeaderLength+21] = storeHealthy
+	copy(raw[fvHeaderLength+22:], make([]byte, 6))
+
+	return raw
+}
+
+func TestGUID(t *testing.T) {
+	guid, err := ParseGUID("8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	require.NoError(t, err)
+	assert.Equal(t, GUID{0x61, 0xdf, 0xe4, 0x8b, 0xca, 0x93, 0xd2, 0x11, 0xaa, 0x0d, 0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c}, guid)
+	assert.Equal(t, "8be4df61-93ca-11d2-aa0d-00e098032b8c", guid.String())
+
+	_, err = ParseGUID("8be4df61-93ca-11d2-aa0d")
+	assert.Error(t, err)
+
+	name, guid, err := ParseVariableKey("Boot0001-8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	require.NoError(t, err)
+	assert.Equal(t, "Boot0001", name)
+	assert.Equal(t, GlobalVariableGUID, guid)
+
+	_, _, err = ParseVariableKey("Boot0001")
+	assert.Error(t, err)
+}
+
+func TestVarStore(t *testing.T) {
+	for _, storeGUID := range []GUID{authVariableStoreGUID, variableStoreGUID} {
+		raw := newTestNVRAM(storeGUID, 512)
+
+		store, err := ParseVarStore(raw)
+		require.NoError(t, err)
+		assert.Empty(t, store.Variables)
+
+		store.Set(Variable{Name: "BootOrder", GUID: GlobalVariableGUID, Attributes: AttributesDefault, Data: BootOrder([]uint16{1, 0})})
+		store.Set(Variable{Name: "db", GUID: ImageSecurityDatabaseGUID, Attributes: AttributesAuthenticated, Data: []byte{1, 2, 3}})
+		store.Set(Variable{Name: "db", GUID: ImageSecurityDatabaseGUID, Attributes: AttributesAuthenticated, Data: []byte{4, 5}})
+
+		raw, err = store.Bytes()
+		require.NoError(t, err)
+
+		store, err = ParseVarStore(raw)
+		require.NoError(t, err)
+		require.Len(t, store.Variables, 2)
+
+		bootOrder := store.Get("BootOrder", GlobalVariableGUID)
+		require.NotNil(t, bootOrder)
+		assert.Equal(t, AttributesDefault, bootOrder.Attributes)
+
+		order, err := ParseBootOrder(bootOrder.Data)
+		require.NoError(t, err)
+		assert.Equal(t, []uint16{1, 0}, order)
+
+		db := store.Get("db", ImageSecurityDatabaseGUID)
+		require.NotNil(t, db)
+		assert.Equal(t, []byte{4, 5}, db.Data)
+
+		assert.True(t, store.Delete("db", ImageSecurityDatabaseGUID))
+		assert.False(t, store.Delete("db", ImageSecurityDatabaseGUID))
+
+		// Variables that don't fit are rejected.
+		store.Set(Variable{Name: "Big", GUID: GlobalVariableGUID, Attributes: AttributesDefault, Data: make([]byte, 512)})
+		_, err = store.Bytes()
+		assert.Error(t, err)
+	}
+
+	_, err := ParseVarStore(make([]byte, 128))
+	assert.Error(t, err)
+}
+
+func TestSignatureList(t *testing.T) {
+	owner := MustParseGUID("77fa9abd-0359-4d32-bd60-28f4e78f784b")
+	certs := [][]byte{{1, 2, 3}, {4, 5, 6, 7}}
+
+	data := SignatureList(owner, certs...)
+	assert.Len(t, data, 2*(signatureListHeaderSize+16)+7)
+
+	parsed, err := SignatureListCertificates(data)
+	require.NoError(t, err)
+	assert.Equal(t, certs, parsed)
+
+	_, err = SignatureListCertificates(data[:10])
+	assert.Error(t, err)
+}
+
+func TestParseLoadOption(t *testing.T) {
+	data := []byte{0x01, 0, 0, 0, 0x02, 0}
+	data = append(data, encodeName("UEFI Shell")...)
+	data = append(data, 0x7f, 0xff, 0xaa)
+
+	option, err := ParseLoadOption(data)
+	require.NoError(t, err)
+	assert.Equal(t, LoadOptionActive, option.Attributes)
+	assert.Equal(t, "UEFI Shell", option.Description)
+	assert.Equal(t, []byte{0x7f, 0xff}, option.FilePath)
+	assert.Equal(t, []byte{0xaa}, option.OptionalData)
+	assert.Equal(t, "Boot000A", BootEntryName(10))
+
+	_, err = ParseLoadOption(data[:10])
+	assert.Error(t, err)
+}
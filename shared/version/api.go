@@ -339,6 +339,30 @@ var APIExtensions = []string{
 	"network_load_balancer",
 	"vsock_api",
 	"instance_ready_state",
+	"network_firewall_ruleset",
+	"instance_nic_transfer",
+	"instance_nic_mirror",
+	"network_nat64",
+	"network_egress_proxy",
+	"instance_boot_autorestart",
+	"instance_boot_dependencies",
+	"instance_healthcheck",
+	"instance_boot_schedule",
+	"instance_state_history",
+	"instance_vm_hotplug",
+	"instance_vm_live_migration",
+	"instance_uefi_vars",
+	"instance_console_screenshot",
+	"instance_oci_images",
+	"instance_rebuild",
+	"instance_file_archive",
+	"instance_file_watch",
+	"instance_diff",
+	"instance_session_recording",
+	"instance_idle_stop",
+	"instance_state_os",
+	"instance_fsfreeze",
+	"proxy_vsock",
 }
 
 // APIExtensionsCount returns the number of available API extensions.
@@ -211,6 +211,7 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_projects_limits "projects limits"
     run_test test_projects_usage "projects usage"
     run_test test_projects_restrictions "projects restrictions"
+    run_test test_container_autorestart "container automatic restart"
     run_test test_container_devices_disk "container devices - disk"
     run_test test_container_devices_disk_restricted "container devices - disk - restricted"
     run_test test_container_devices_nic_p2p "container devices - nic - p2p"
@@ -223,6 +224,8 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_container_devices_nic_ipvlan "container devices - nic - ipvlan"
     run_test test_container_devices_nic_sriov "container devices - nic - sriov"
     run_test test_container_devices_nic_routed "container devices - nic - routed"
+    run_test test_container_devices_nic_mirror "container devices - nic - mirror"
+    run_test test_container_devices_nic_transfer "container devices - nic - transfer"
     run_test test_container_devices_infiniband_physical "container devices - infiniband - physical"
     run_test test_container_devices_infiniband_sriov "container devices - infiniband - sriov"
     run_test test_container_devices_proxy "container devices - proxy"
@@ -238,12 +241,16 @@ if [ "${1:-"all"}" != "cluster" ]; then
     run_test test_image_auto_update "image auto-update"
     run_test test_image_prefer_cached "image prefer cached"
     run_test test_image_import_dir "import image from directory"
+    run_test test_image_oci "OCI images"
     run_test test_image_refresh "image refresh"
     run_test test_cloud_init "cloud-init"
     run_test test_exec "exec"
     run_test test_concurrent_exec "concurrent exec"
     run_test test_concurrent "concurrent startup"
     run_test test_snapshots "container snapshots"
+    run_test test_container_rebuild "container rebuild"
+    run_test test_container_diff "container diff"
+    run_test test_container_os_state "container guest OS state"
     run_test test_snap_restore "snapshot restores"
     run_test test_snap_expiry "snapshot expiry"
     run_test test_snap_schedule "snapshot scheduling"
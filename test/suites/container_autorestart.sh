@@ -0,0 +1,30 @@
+test_container_autorestart() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  # Check that a clean poweroff from within the container isn't considered a failure.
+  lxc launch testimage c1 -c boot.autorestart=on-failure -c boot.autorestart.delay=1
+  lxc exec c1 -- poweroff || true
+  for _ in $(seq 20); do
+    [ "$(lxc list -c s --format csv c1)" = "STOPPED" ] && break
+    sleep 0.5
+  done
+
+  [ "$(lxc list -c s --format csv c1)" = "STOPPED" ]
+  sleep 5
+  [ "$(lxc list -c s --format csv c1)" = "STOPPED" ]
+  [ -z "$(lxc config get c1 volatile.autorestart.count)" ]
+
+  # Check that the container is restarted with the always policy.
+  lxc config set c1 boot.autorestart always
+  lxc start c1
+  lxc exec c1 -- poweroff || true
+  for _ in $(seq 30); do
+    [ "$(lxc config get c1 volatile.autorestart.count)" = "1" ] && [ "$(lxc list -c s --format csv c1)" = "RUNNING" ] && break
+    sleep 0.5
+  done
+
+  [ "$(lxc list -c s --format csv c1)" = "RUNNING" ]
+
+  lxc delete -f c1
+}
@@ -0,0 +1,58 @@
+test_container_devices_nic_mirror() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  vethHostName="veth$$"
+  vethTargetName="vethm$$"
+  mirrorName="mirror$$"
+  ctName="nt$$"
+  ctTargetName="ntm$$"
+
+  ip link add "${mirrorName}" type dummy
+  ip link set "${mirrorName}" up
+
+  # Check that traffic is mirrored to a host interface in both directions by default.
+  lxc init testimage "${ctName}"
+  lxc config device add "${ctName}" eth0 nic nictype=p2p host_name="${vethHostName}" mirror="${mirrorName}"
+  lxc start "${ctName}"
+  tc filter show dev "${vethHostName}" root | grep "mirred.*${mirrorName}"
+  tc filter show dev "${vethHostName}" ingress | grep "mirred.*${mirrorName}"
+
+  # Check that mirroring is combined with rate limits.
+  lxc config device set "${ctName}" eth0 limits.ingress 1Mbit limits.egress 2Mbit
+  tc class show dev "${vethHostName}" | grep "1Mbit"
+  tc filter show dev "${vethHostName}" root | grep "mirred.*${mirrorName}"
+  tc filter show dev "${vethHostName}" ingress | grep "2Mbit"
+  tc filter show dev "${vethHostName}" ingress | grep "mirred.*${mirrorName}"
+
+  # Check that only the requested direction is mirrored.
+  lxc config device set "${ctName}" eth0 mirror.direction egress
+  ! tc filter show dev "${vethHostName}" root | grep "mirred" || false
+  tc filter show dev "${vethHostName}" ingress | grep "mirred.*${mirrorName}"
+  lxc config device unset "${ctName}" eth0 mirror.direction
+  lxc config device unset "${ctName}" eth0 limits.ingress
+  lxc config device unset "${ctName}" eth0 limits.egress
+
+  # Check that a missing target doesn't prevent the NIC from starting.
+  lxc stop -f "${ctName}"
+  ip link delete "${mirrorName}"
+  lxc start "${ctName}"
+  ! tc filter show dev "${vethHostName}" root | grep "mirred" || false
+  lxc stop -f "${ctName}"
+
+  # Check that mirroring to another instance's NIC is applied once the target is started.
+  lxc init testimage "${ctTargetName}"
+  lxc config device add "${ctTargetName}" eth0 nic nictype=p2p host_name="${vethTargetName}"
+  lxc config device set "${ctName}" eth0 mirror "${ctTargetName}/eth0"
+  lxc start "${ctName}"
+  ! tc filter show dev "${vethHostName}" root | grep "mirred" || false
+  lxc start "${ctTargetName}"
+  tc filter show dev "${vethHostName}" root | grep "mirred.*${vethTargetName}"
+  tc filter show dev "${vethHostName}" ingress | grep "mirred.*${vethTargetName}"
+
+  # Check that mirroring is reapplied when the target is restarted.
+  lxc restart -f "${ctTargetName}"
+  tc filter show dev "${vethHostName}" root | grep "mirred.*${vethTargetName}"
+
+  lxc delete -f "${ctName}" "${ctTargetName}"
+}
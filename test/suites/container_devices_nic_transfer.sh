@@ -0,0 +1,30 @@
+test_container_devices_nic_transfer() {
+  ensure_import_testimage
+  ensure_has_localhost_remote "${LXD_ADDR}"
+
+  vethHostName="veth$$"
+  ctName="nt$$"
+
+  lxc init testimage "${ctName}"
+  lxc config device add "${ctName}" eth0 nic nictype=p2p host_name="${vethHostName}"
+
+  # Check that NICs are accounted for without a transfer limit.
+  lxc start "${ctName}"
+  ping6 -c 3 -W 1 "ff02::1%${vethHostName}" || true
+  lxc stop -f "${ctName}"
+  rx="$(lxc config get "${ctName}" volatile.eth0.transfer.rx)"
+  [ "${rx}" -gt 0 ]
+
+  # Check that the counters survive a restart.
+  lxc start "${ctName}"
+  ping6 -c 3 -W 1 "ff02::1%${vethHostName}" || true
+  lxc stop -f "${ctName}"
+  [ "$(lxc config get "${ctName}" volatile.eth0.transfer.rx)" -gt "${rx}" ]
+
+  # Check that the counters can't be changed by users.
+  ! lxc config set "${ctName}" volatile.eth0.transfer.rx 0 || false
+  ! lxc config set "${ctName}" volatile.eth0.transfer.period.rx 0 || false
+  [ "$(lxc config get "${ctName}" volatile.eth0.transfer.rx)" -gt "${rx}" ]
+
+  lxc delete -f "${ctName}"
+}
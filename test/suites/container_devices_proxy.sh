@@ -7,6 +7,7 @@ test_container_devices_proxy() {
   container_devices_proxy_unix
   container_devices_proxy_unix_udp
   container_devices_proxy_unix_tcp
+  container_devices_proxy_vm
 }
 
 container_devices_proxy_validation() {
@@ -688,3 +689,25 @@ container_devices_proxy_tcp_udp() {
   # Cleanup
   lxc delete -f proxyTester
 }
+
+container_devices_proxy_vm() {
+  if ! lxc query /1.0 | jq -r .environment.driver | grep -q qemu; then
+    echo "==> SKIP: proxy devices of virtual machines (missing VM support)"
+    return
+  fi
+
+  HOST_TCP_PORT=$(local_tcp_port)
+
+  # Check that a VM with a proxy device boots even though its agent isn't listening (nothing to boot here).
+  lxc init --vm --empty proxyTesterVM -c security.secureboot=false
+  lxc config device add proxyTesterVM proxyDev proxy "listen=tcp:127.0.0.1:${HOST_TCP_PORT}" connect=tcp:127.0.0.1:4321 bind=host
+  lxc start proxyTesterVM
+  [ "$(lxc list -c s --format csv proxyTesterVM)" = "RUNNING" ]
+
+  # Connections are accepted and dropped while the agent can't be reached, without stopping the proxy.
+  (echo test ; sleep 0.5) | socat - tcp:127.0.0.1:"${HOST_TCP_PORT}" || true
+  pgrep -f "forkvsockproxy -- tcp:127.0.0.1:${HOST_TCP_PORT}"
+  [ "$(lxc list -c s --format csv proxyTesterVM)" = "RUNNING" ]
+
+  lxc delete -f proxyTesterVM
+}
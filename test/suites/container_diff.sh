@@ -0,0 +1,19 @@
+test_container_diff() {
+  ensure_import_testimage
+
+  lxc init testimage c1
+
+  echo "foo" > "${TEST_DIR}/diff-file"
+  lxc file push -p "${TEST_DIR}/diff-file" c1/root/diff-added
+  lxc file push "${TEST_DIR}/diff-file" c1/etc/inittab
+  lxc file delete c1/bin/busybox
+  rm "${TEST_DIR}/diff-file"
+
+  lxc diff c1 --format=csv | grep -qx "ADDED,/root/diff-added"
+  lxc diff c1 --format=csv | grep -qx "CHANGED,/etc/inittab"
+  lxc diff c1 --format=csv | grep -qx "REMOVED,/bin/busybox"
+  ! lxc diff c1 --format=csv | grep -q ",/etc/hostname$" || false
+  lxc query /1.0/instances/c1/diff | jq -e '.[] | select(.path == "/root/diff-added" and .change == "added")'
+
+  lxc delete c1
+}
@@ -0,0 +1,22 @@
+test_container_os_state() {
+  ensure_import_testimage
+
+  lxc init testimage c1
+  printf 'NAME="LXD test"\nVERSION_ID="1.0"\n' > "${TEST_DIR}/os-release"
+  lxc file push "${TEST_DIR}/os-release" c1/etc/os-release
+  rm "${TEST_DIR}/os-release"
+  echo "guest-host" > "${TEST_DIR}/hostname"
+  lxc file push "${TEST_DIR}/hostname" c1/etc/hostname
+  rm "${TEST_DIR}/hostname"
+
+  # No guest OS details while stopped.
+  [ "$(lxc query /1.0/instances/c1/state | jq -r .os)" = "null" ]
+
+  lxc start c1
+  lxc query /1.0/instances/c1/state | jq -e '.os.name == "LXD test" and .os.version == "1.0"'
+  [ "$(lxc query /1.0/instances/c1/state | jq -r .os.kernel)" = "$(uname -r)" ]
+  [ "$(lxc query /1.0/instances/c1/state | jq -r .os.hostname)" = "guest-host" ]
+  lxc info c1 | grep -q "OS: LXD test 1.0"
+
+  lxc delete -f c1
+}
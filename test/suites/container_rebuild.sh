@@ -0,0 +1,33 @@
+test_container_rebuild() {
+  ensure_import_testimage
+
+  # Create a second image to rebuild from.
+  lxc init testimage c-src
+  lxc publish c-src --alias testimage-rebuild foo=bar
+  lxc delete c-src
+
+  lxc init testimage c1
+  lxc config set c1 user.foo bar
+  lxc snapshot c1 snap0
+  lxc start c1
+  lxc exec c1 -- touch /root/rebuild-marker
+  HWADDR="$(lxc config get c1 volatile.eth0.hwaddr)"
+
+  # Running instances can't be rebuilt.
+  ! lxc rebuild testimage-rebuild c1 || false
+  lxc stop c1 --force
+
+  lxc rebuild testimage-rebuild c1
+  [ "$(lxc config get c1 image.foo)" = "bar" ]
+  [ "$(lxc config get c1 user.foo)" = "bar" ]
+  [ "$(lxc config get c1 volatile.eth0.hwaddr)" = "${HWADDR}" ]
+  [ "$(lxc config get c1 volatile.base_image)" = "$(lxc image info testimage-rebuild | awk '/^Fingerprint/ {print $2}')" ]
+  lxc info c1 | grep -q snap0
+
+  # The root disk was replaced.
+  lxc start c1
+  ! lxc exec c1 -- test -e /root/rebuild-marker || false
+  lxc delete -f c1
+
+  lxc image delete testimage-rebuild
+}
@@ -37,6 +37,26 @@ test_exec() {
   stdOutURL=$(lxc query  /1.0/operations/"${opID}" | jq '.metadata.output["1"]')
   lxc query "${stdOutURL}" | grep -F "hello"
 
+  # Check session recording.
+  lxc config set instances.session_recording true
+  ret=0
+  echo "recorded" | lxc exec x1 -- sh -c "cat; exit 3" || ret=$?
+  [ "${ret}" = "3" ]
+  lxc config unset instances.session_recording
+  lxc exec x1 -- true
+
+  [ "$(lxc query /1.0/instances/x1/recordings | jq length)" = "1" ]
+  recording=$(lxc query "/1.0/instances/x1/recordings?recursion=1" | jq -r '.[0].name')
+  lxc query "/1.0/instances/x1/recordings/${recording}" | jq -e '.exit_code == 3 and .type == "exec" and .command == ["sh", "-c", "cat; exit 3"]'
+  my_curl -f "https://${LXD_ADDR}/1.0/instances/x1/recordings/${recording}/export" | grep -F '"o","recorded'
+
+  lxc project create recorded -c features.images=false -c features.profiles=false -c instances.session_recording=true
+  lxc launch testimage x2 --project recorded
+  lxc exec x2 --project recorded -- true
+  [ "$(lxc query "/1.0/instances/x2/recordings?project=recorded" | jq length)" = "1" ]
+  lxc delete -f x2 --project recorded
+  lxc project delete recorded
+
   lxc stop "${name}" --force
   lxc delete "${name}"
 }
@@ -101,6 +101,23 @@ test_filemanip() {
   [ "$(stat -c "%g" "${TEST_DIR}"/dest/source)" = "$(id -g)" ]
   [ "$(stat -c "%a" "${TEST_DIR}"/dest/source)" = "755" ]
 
+  # Test the archive API (ownership, permissions, symlinks and hard links).
+  lxc exec filemanip --project=test -- mkdir -p /tmp/archive/sub
+  lxc exec filemanip --project=test -- sh -c "echo foo > /tmp/archive/sub/foo"
+  lxc exec filemanip --project=test -- ln /tmp/archive/sub/foo /tmp/archive/hardlink
+  lxc exec filemanip --project=test -- ln -s sub/foo /tmp/archive/symlink
+  lxc exec filemanip --project=test -- chown -R 1000:1000 /tmp/archive/sub
+  lxc exec filemanip --project=test -- chmod 0700 /tmp/archive/sub
+  my_curl -f -o "${TEST_DIR}/archive.tar" "https://${LXD_ADDR}/1.0/instances/filemanip/files/archive?path=/tmp/archive&project=test"
+  my_curl -f -X POST --data-binary "@${TEST_DIR}/archive.tar" "https://${LXD_ADDR}/1.0/instances/filemanip/files/archive?path=/tmp/archive-copy&project=test"
+  [ "$(lxc exec filemanip --project=test -- cat /tmp/archive-copy/hardlink)" = "foo" ]
+  [ "$(lxc exec filemanip --project=test -- stat -c "%i" /tmp/archive-copy/hardlink)" = "$(lxc exec filemanip --project=test -- stat -c "%i" /tmp/archive-copy/sub/foo)" ]
+  [ "$(lxc exec filemanip --project=test -- readlink /tmp/archive-copy/symlink)" = "sub/foo" ]
+  [ "$(lxc exec filemanip --project=test -- stat -c "%u:%g %a" /tmp/archive-copy/sub)" = "1000:1000 700" ]
+  ! my_curl -f -o /dev/null "https://${LXD_ADDR}/1.0/instances/filemanip/files/archive?path=/tmp/missing&project=test" || false
+  lxc exec filemanip --project=test -- rm -rf /tmp/archive /tmp/archive-copy
+  rm "${TEST_DIR}/archive.tar"
+
   lxc file push -p "${TEST_DIR}"/source/foo local:filemanip/tmp/this/is/a/nonexistent/directory/
   lxc file pull local:filemanip/tmp/this/is/a/nonexistent/directory/foo "${TEST_DIR}"
   [ "$(cat "${TEST_DIR}"/foo)" = "foo" ]
@@ -117,6 +134,22 @@ test_filemanip() {
     lxc delete idmap --force
   fi
 
+  # Test watching for changes.
+  lxc exec filemanip --project=test -- mkdir -p /tmp/watch/sub
+  cmd=$(unset -f lxc; command -v lxc)
+  $cmd file watch filemanip/tmp/watch --recursive --format=json --project=test > "${TEST_DIR}/watch.log" &
+  watchPID=$!
+  sleep 1
+
+  lxc exec filemanip --project=test -- sh -c "echo foo > /tmp/watch/sub/foo"
+  lxc exec filemanip --project=test -- rm -rf /tmp/watch
+  wait "${watchPID}"
+
+  grep -q '"path":"/tmp/watch/sub/foo","action":"create"' "${TEST_DIR}/watch.log"
+  grep -q '"path":"/tmp/watch/sub/foo","action":"modify"' "${TEST_DIR}/watch.log"
+  grep -q '"path":"/tmp/watch","action":"delete"' "${TEST_DIR}/watch.log"
+  rm "${TEST_DIR}/watch.log"
+
   # Test SFTP functionality.
   cmd=$(unset -f lxc; command -v lxc)
   $cmd file mount filemanip --listen=127.0.0.1:2022 --no-auth &
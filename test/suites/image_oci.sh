@@ -0,0 +1,52 @@
+test_image_oci() {
+  if ! command -v skopeo >/dev/null 2>&1 || ! command -v umoci >/dev/null 2>&1; then
+    echo "==> SKIP: OCI image tests require skopeo and umoci"
+    return
+  fi
+
+  # Build a busybox OCI image layout, standing in for a registry.
+  # shellcheck disable=2039,3043
+  local OCI_DIR
+  OCI_DIR=$(mktemp -d -p "${TEST_DIR}" XXX)
+  umoci init --layout "${OCI_DIR}/layout"
+  umoci new --image "${OCI_DIR}/layout:busybox"
+  umoci unpack --image "${OCI_DIR}/layout:busybox" "${OCI_DIR}/bundle"
+  mkdir -p "${OCI_DIR}/bundle/rootfs/bin" "${OCI_DIR}/bundle/rootfs/srv"
+  cp "$(command -v busybox)" "${OCI_DIR}/bundle/rootfs/bin/busybox"
+  ln -s busybox "${OCI_DIR}/bundle/rootfs/bin/sh"
+  ln -s busybox "${OCI_DIR}/bundle/rootfs/bin/env"
+  ln -s busybox "${OCI_DIR}/bundle/rootfs/bin/sleep"
+  umoci repack --image "${OCI_DIR}/layout:busybox" "${OCI_DIR}/bundle"
+  umoci config --image "${OCI_DIR}/layout:busybox" --config.entrypoint /bin/sleep --config.cmd 600 --config.env FOO=bar --config.workingdir /srv
+  rm -rf "${OCI_DIR}/bundle"
+
+  # Import the OCI layout directory.
+  lxc image import "${OCI_DIR}/layout" --alias oci-busybox
+  lxc image show oci-busybox | grep -q "oci.entrypoint: /bin/sleep 600"
+  lxc image show oci-busybox | grep -q "oci.env.FOO: bar"
+  lxc image show oci-busybox | grep -q "oci.cwd: /srv"
+  lxc image show oci-busybox | grep -q "oci.digest: sha256:"
+
+  # The fingerprint is that of the generated image tarball.
+  lxc image export oci-busybox "${OCI_DIR}/export"
+  [ "$(sha256sum "${OCI_DIR}"/export.* | cut -d' ' -f1)" = "$(lxc image info oci-busybox | awk '/^Fingerprint/ {print $2}')" ]
+
+  # The image configuration is mapped to the instance configuration.
+  lxc launch oci-busybox c1 -c environment.FOO=baz
+  [ "$(lxc config get c1 oci.entrypoint)" = "/bin/sleep 600" ]
+  [ "$(lxc config get c1 oci.cwd)" = "/srv" ]
+  lxc exec c1 -- env | grep -q "^FOO=baz$"
+  lxc exec c1 -- sh -c "cat /proc/*/cmdline" | grep -q "sleep"
+  lxc delete -f c1
+
+  # Import the same image as a docker-archive tarball.
+  skopeo copy "oci:${OCI_DIR}/layout:busybox" "docker-archive:${OCI_DIR}/busybox.tar:busybox:latest"
+  lxc image import "${OCI_DIR}/busybox.tar" --alias docker-busybox
+  lxc image show docker-busybox | grep -q "oci.entrypoint: /bin/sleep 600"
+  lxc launch docker-busybox c2
+  lxc exec c2 -- env | grep -q "^FOO=bar$"
+  lxc delete -f c2
+
+  lxc image delete oci-busybox docker-busybox
+  rm -rf "${OCI_DIR}"
+}
@@ -53,7 +53,7 @@ test_migration() {
 
 migration() {
   # shellcheck disable=2039,3043
-  local lxd2_dir lxd_backend lxd2_backend
+  local lxd2_dir lxd_backend lxd2_backend transfer_rx
   lxd2_dir="$1"
   lxd_backend=$(storage_backend "$LXD_DIR")
   lxd2_backend=$(storage_backend "$lxd2_dir")
@@ -64,8 +64,16 @@ migration() {
   lxc_remote config set l1:nonlive user.tester foo
   lxc_remote snapshot l1:nonlive
   lxc_remote config unset l1:nonlive user.tester
+  # test network transfer counters survive moves
+  lxc_remote config device add l1:nonlive eth0 nic nictype=p2p
+  lxc_remote start l1:nonlive
+  ping6 -c 3 -W 1 "ff02::1%$(lxc_remote config get l1:nonlive volatile.eth0.host_name)" || true
+  lxc_remote stop l1:nonlive --force
+  transfer_rx="$(lxc_remote config get l1:nonlive volatile.eth0.transfer.rx)"
+  [ -n "${transfer_rx}" ]
   lxc_remote move l1:nonlive l2:
   lxc_remote config show l2:nonlive/snap0 | grep user.tester | grep foo
+  [ "$(lxc_remote config get l2:nonlive volatile.eth0.transfer.rx)" = "${transfer_rx}" ]
 
   # This line exists so that the container's storage volume is mounted when we
   # perform existence check for various files.
@@ -83,6 +91,8 @@ migration() {
   fi
 
   lxc_remote copy l2:nonlive l1:nonlive2 --mode=push
+  # network transfer counters are reset on copy
+  [ -z "$(lxc_remote config get l1:nonlive2 volatile.eth0.transfer.rx)" ]
   # This line exists so that the container's storage volume is mounted when we
   # perform existence check for various files.
   lxc_remote start l2:nonlive